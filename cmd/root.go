@@ -22,9 +22,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"kitops/pkg/cmd/dev"
 	"kitops/pkg/cmd/diff"
+	"kitops/pkg/cmd/export"
 	"kitops/pkg/cmd/info"
 	"kitops/pkg/cmd/inspect"
 	"kitops/pkg/cmd/kitcache"
@@ -37,12 +39,14 @@ import (
 	"kitops/pkg/cmd/pull"
 	"kitops/pkg/cmd/push"
 	"kitops/pkg/cmd/remove"
+	"kitops/pkg/cmd/serve"
 	"kitops/pkg/cmd/tag"
 	"kitops/pkg/cmd/unpack"
 	"kitops/pkg/cmd/version"
+	"kitops/pkg/lib/config"
 	"kitops/pkg/lib/constants"
-	"kitops/pkg/lib/filesystem/cache"
 	"kitops/pkg/lib/repo/local"
+	"kitops/pkg/lib/telemetry"
 	"kitops/pkg/lib/update"
 	"kitops/pkg/output"
 
@@ -61,6 +65,8 @@ type rootOptions struct {
 	verbosity    int
 	loglevel     string
 	progressBars string
+	outputFormat string
+	logFormat    string
 }
 
 func RunCommand() *cobra.Command {
@@ -73,9 +79,16 @@ func RunCommand() *cobra.Command {
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			output.SetOut(cmd.OutOrStdout())
 			output.SetErr(cmd.ErrOrStderr())
+			if err := output.SetOutputFormat(opts.outputFormat); err != nil {
+				return output.Fatalln(err)
+			}
 			if err := output.SetLogLevelFromString(opts.loglevel); err != nil {
 				return output.Fatalln(err)
 			}
+			if err := output.SetLogFormat(opts.logFormat); err != nil {
+				return output.Fatalln(err)
+			}
+			output.SetLogCommand(cmd.CommandPath())
 			output.SetProgressBars(opts.progressBars)
 
 			switch opts.verbosity {
@@ -101,7 +114,14 @@ func RunCommand() *cobra.Command {
 				return errors.New("exit")
 			}
 			ctx := context.WithValue(cmd.Context(), constants.ConfigKey{}, configHome)
-			cache.SetCacheHome(constants.CachePath(configHome))
+			// Operations run by commands log using the output configured above
+			ctx = output.WithLogger(ctx, output.DefaultLogger())
+			if cfg, err := config.LoadConfig(configHome); err != nil {
+				output.Debugf("Failed to load config; tracing will not be enabled from config file: %s", err)
+			} else if err := telemetry.Setup(ctx, cfg.Tracing); err != nil {
+				output.Errorf("Failed to set up tracing: %s", err)
+			}
+			ctx = telemetry.StartCommand(ctx, cmd.CommandPath())
 			cmd.SetContext(ctx)
 
 			update.CheckForUpdate(configHome)
@@ -127,7 +147,9 @@ func RunCommand() *cobra.Command {
 	}
 	addSubcommands(cmd)
 	cmd.PersistentFlags().StringVar(&opts.loglevel, "log-level", "info", "Log messages above specified level ('trace', 'debug', 'info', 'warn', 'error') (default 'info')")
+	cmd.PersistentFlags().StringVar(&opts.logFormat, "log-format", "text", "Format for log messages (options: text, json). With json, progress bars are replaced by periodic progress events")
 	cmd.PersistentFlags().StringVar(&opts.progressBars, "progress", "plain", "Configure progress bars for longer operations (options: none, plain, fancy)")
+	cmd.PersistentFlags().StringVar(&opts.outputFormat, "output", "text", "Output format for command results (options: text, json, yaml). Logs are written to stderr when using json or yaml")
 	cmd.PersistentFlags().StringVar(&opts.configHome, "config", "", "Alternate path to root storage directory for CLI")
 	cmd.PersistentFlags().CountVarP(&opts.verbosity, "verbose", "v", "Increase verbosity of output (use -vv for more)")
 	cmd.PersistentFlags().SortFlags = false
@@ -158,13 +180,22 @@ func addSubcommands(rootCmd *cobra.Command) {
 	rootCmd.AddCommand(kitinit.InitCommand())
 	rootCmd.AddCommand(diff.DiffCommand())
 	rootCmd.AddCommand(kitimport.ImportCommand())
+	rootCmd.AddCommand(export.ExportCommand())
 	rootCmd.AddCommand(kitcache.CacheCommand())
+	rootCmd.AddCommand(serve.ServeCommand())
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	err := RunCommand().Execute()
+	startTime := time.Now()
+	cmd, err := RunCommand().ExecuteC()
+	if traceErr := telemetry.Shutdown(err); traceErr != nil {
+		output.Debugf("%s", traceErr)
+	}
+	if printErr := output.PrintResult(cmd.CommandPath(), startTime, err); printErr != nil {
+		output.Errorln(printErr)
+	}
 	if err != nil {
 		os.Exit(1)
 	}
@@ -19,22 +19,17 @@ package diff
 import (
 	"context"
 	"fmt"
-	"strings"
-	"sync"
 
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/spf13/cobra"
-	"oras.land/oras-go/v2/registry"
 
 	"kitops/pkg/cmd/options"
+	"kitops/pkg/kit"
 	"kitops/pkg/lib/constants"
 	"kitops/pkg/output"
 )
 
 const (
-	// Constants for identifying reference types
-	remotePrefix = "remote://"
-	localPrefix  = "local://"
 	//Constants for formatting output tables.
 	layerTableHeadings = "Type    | Digest             | Size"
 	layerTableFormat   = "%-7s | %-18s | %s\n"
@@ -60,8 +55,6 @@ kit diff local://jozu.ml/foo:latest remote://jozu.ml/foo:latest
 type diffOptions struct {
 	options.NetworkOptions
 	configHome string
-	refA       *registry.Reference
-	refB       *registry.Reference
 }
 
 func DiffCommand() *cobra.Command {
@@ -74,7 +67,7 @@ func DiffCommand() *cobra.Command {
 		Example: examples,
 		RunE:    runCommand(opts),
 	}
-	opts.AddNetworkFlags(cmd)
+	options.AddNetworkFlags(cmd, &opts.NetworkOptions)
 	cmd.Flags().SortFlags = false
 	return cmd
 }
@@ -85,53 +78,38 @@ func runCommand(opts *diffOptions) func(cmd *cobra.Command, args []string) error
 			return output.Fatalf("Invalid arguments: %s", err)
 		}
 
-		var (
-			diffA, diffB *diffInfo
-			errA, errB   error
-		)
-		var wg sync.WaitGroup
-		wg.Add(2)
-
-		go func() {
-			defer wg.Done()
-			diffA, errA = getManifest(cmd.Context(), args[0], opts.refA, opts)
-		}()
-
-		go func() {
-			defer wg.Done()
-			diffB, errB = getManifest(cmd.Context(), args[1], opts.refB, opts)
-		}()
-
-		wg.Wait()
-
-		if errA != nil {
-			return output.Fatalf("Failed to get manifest for ModelKit1: %s", errA)
+		result, err := kit.Diff(cmd.Context(), kit.DiffOptions{
+			Options:   kit.Options{ConfigHome: opts.configHome},
+			Remote:    kit.NewRemoteOptions(&opts.NetworkOptions),
+			ModelKit1: args[0],
+			ModelKit2: args[1],
+		})
+		if err != nil {
+			return output.Fatalf("Failed to compare ModelKits: %s", err)
 		}
-		if errB != nil {
-			return output.Fatalf("Failed to get manifest for ModelKit2: %s", errB)
-		}
-
-		// Compare the two manifests
-		if diffA.Descriptor.Digest == diffB.Descriptor.Digest {
+		if result.Identical {
 			output.Infoln("ModelKits are identical")
+			output.SetResult(result)
+			return nil
+		}
+		if output.StructuredOutput() {
+			output.SetResult(result)
 			return nil
 		}
-
-		result := CompareManifests(diffA.Manifest, diffB.Manifest)
 		// Header
 		output.Infoln("Comparing:")
-		output.Infof("  ModelKit1: %s\n", opts.refA.String())
-		output.Infof("  ModelKit2: %s\n\n", opts.refB.String())
+		output.Infof("  ModelKit1: %s\n", result.ModelKit1)
+		output.Infof("  ModelKit2: %s\n\n", result.ModelKit2)
 
 		output.Infoln("Configurations:")
 		output.Infoln("---------------------------------------")
 		if result.SameConfig {
-			output.Infof("  Configs are identical (Digest: %s)\n\n", diffA.Manifest.Config.Digest[:17])
+			output.Infof("  Configs are identical (Digest: %s)\n\n", result.Manifest1.Config.Digest[:17])
 
 		} else {
 			output.Infof("Configs differ:\n")
-			output.Infof("  ModelKit1 Config Digest: %s\n", diffA.Manifest.Config.Digest[:17])
-			output.Infof("  ModelKit2 Config Digest: %s\n\n", diffB.Manifest.Config.Digest[:17])
+			output.Infof("  ModelKit1 Config Digest: %s\n", result.Manifest1.Config.Digest[:17])
+			output.Infof("  ModelKit2 Config Digest: %s\n\n", result.Manifest2.Config.Digest[:17])
 		}
 
 		output.Infoln("Annotations:")
@@ -143,46 +121,25 @@ func runCommand(opts *diffOptions) func(cmd *cobra.Command, args []string) error
 		}
 
 		displayLayers("Shared Layers", result.SharedLayers)
-		displayLayers(fmt.Sprintf("Unique Layers to ModelKit1 (%s)", opts.refA.String()), result.UniqueLayersA)
-		displayLayers(fmt.Sprintf("Unique Layers to ModelKit2 (%s)", opts.refB.String()), result.UniqueLayersB)
+		displayLayers(fmt.Sprintf("Unique Layers to ModelKit1 (%s)", result.ModelKit1), result.UniqueLayersA)
+		displayLayers(fmt.Sprintf("Unique Layers to ModelKit2 (%s)", result.ModelKit2), result.UniqueLayersB)
 		return nil
 	}
 }
 
 func (opts *diffOptions) complete(ctx context.Context, args []string) error {
-
 	configHome, ok := ctx.Value(constants.ConfigKey{}).(string)
 	if !ok {
 		return fmt.Errorf("default config path not set on command context")
 	}
 	opts.configHome = configHome
 
-	imageName := removePrefix(args[0])
-	refA, err := registry.ParseReference(imageName)
-	if err != nil {
-		return fmt.Errorf("failed to parse reference for ref1: %w", err)
-	}
-	opts.refA = &refA
-
-	imageName = removePrefix(args[1])
-	refB, err := registry.ParseReference(imageName)
-	if err != nil {
-		return fmt.Errorf("failed to parse reference for ref2: %w", err)
-	}
-	opts.refB = &refB
-
-	if err := opts.NetworkOptions.Complete(ctx, args); err != nil {
+	if err := options.CompleteNetworkOptions(ctx, &opts.NetworkOptions); err != nil {
 		return err
 	}
 	return nil
 }
 
-func removePrefix(arg string) string {
-	arg = strings.TrimPrefix(arg, remotePrefix)
-	arg = strings.TrimPrefix(arg, localPrefix)
-	return arg
-}
-
 func displayLayers(title string, layers []ocispec.Descriptor) {
 	output.Infoln(title)
 	output.Infoln("---------------------------------------")
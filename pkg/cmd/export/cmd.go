@@ -0,0 +1,201 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package export
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"kitops/pkg/cmd/options"
+	"kitops/pkg/lib/config"
+	"kitops/pkg/lib/constants"
+	"kitops/pkg/lib/hf"
+	"kitops/pkg/lib/ratelimit"
+	"kitops/pkg/output"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	shortDesc = `Export a modelkit for use with other tools`
+	longDesc  = `Export the contents of a modelkit in the format used by another tool.
+
+This command fetches the modelkit (from local storage, or from the remote
+registry if it is not stored locally) and writes its contents to the directory
+specified by --dir in the format specified by --format. Only the parts of the
+modelkit that are needed for the selected format are fetched.
+
+Supported formats:
+
+  ollama : Write an Ollama Modelfile and a blobs directory containing the
+           model's GGUF weights, which can be used to create a model using
+           'ollama create'. The modelkit's model must be a GGUF file (or a
+           directory containing a single GGUF file). Model parts with type
+           'template', 'system', 'params', 'messages', and 'adapter' are
+           included in the Modelfile, along with license files in the
+           modelkit's docs. ModelKits created using 'kit import --tool=ollama'
+           contain these parts.
+
+  hf     : Write the modelkit's model (including its parts), docs, and Kitfile
+           in the layout used by Hugging Face model repositories, along with
+           a README.md model card. The card's metadata (license, tags,
+           datasets, and base model) is generated from the Kitfile. If the
+           modelkit contains a README.md, it is kept and the generated
+           metadata is added to it, without replacing existing values.
+
+When exporting to the hf format, the --push-hf flag can be used to upload the
+exported files to an existing Hugging Face model repository in a single commit
+on its main branch. Files are uploaded using Git LFS where required by the Hub.
+If --token is not specified, the HF_TOKEN environment variable or the token
+saved by the Hugging Face CLI is used. The Hub endpoint can be changed using
+the HF_ENDPOINT environment variable or the huggingface.endpoint config option.`
+
+	example = `# Export a modelkit to an Ollama Modelfile and create an Ollama model from it
+kit export myrepo/my-model:latest --format ollama -d ./my-model
+ollama create my-model -f ./my-model/Modelfile
+
+# Export a modelkit in the Hugging Face repository layout
+kit export myrepo/my-model:latest --format hf -d ./my-model
+
+# Export a modelkit and upload it to a Hugging Face repository
+kit export myrepo/my-model:latest --format hf -d ./my-model --push-hf my-org/my-model`
+)
+
+var validFormats = []string{"ollama", "hf"}
+
+type exportOptions struct {
+	options.NetworkOptions
+	configHome string
+	modelRef   string
+	format     string
+	exportDir  string
+	overwrite  bool
+	pushRepo   string
+	// Options used when pushing to HuggingFace
+	hfToken     string
+	hfEndpoint  string
+	rateLimiter *ratelimit.Limiter
+}
+
+// exportResult describes the files written by an export
+type exportResult struct {
+	Reference string   `json:"reference"`
+	Format    string   `json:"format"`
+	Directory string   `json:"directory"`
+	Files     []string `json:"files"`
+}
+
+func ExportCommand() *cobra.Command {
+	opts := &exportOptions{}
+
+	cmd := &cobra.Command{
+		Use:     "export [flags] [registry/]repository[:tag|@digest]",
+		Short:   shortDesc,
+		Long:    longDesc,
+		Example: example,
+		RunE:    runCommand(opts),
+		Args:    cobra.ExactArgs(1),
+	}
+
+	cmd.Flags().StringVar(&opts.format, "format", "", fmt.Sprintf("Format to export the modelkit in (options: %s)", strings.Join(validFormats, ", ")))
+	cmd.Flags().StringVarP(&opts.exportDir, "dir", "d", "", "The directory to export into. This directory will be created if it does not exist")
+	cmd.Flags().BoolVarP(&opts.overwrite, "overwrite", "o", false, "Overwrite existing files in the export directory")
+	cmd.Flags().StringVar(&opts.pushRepo, "push-hf", "", "Upload the exported files to a Hugging Face model repository (e.g. my-org/my-model). Only supported for --format hf")
+	cmd.Flags().StringVar(&opts.hfToken, "token", "", "Token to use for authenticating with Hugging Face when using --push-hf (default: $HF_TOKEN or saved token)")
+	options.AddNetworkFlags(cmd, &opts.NetworkOptions)
+	options.AddLimitRateFlag(cmd, &opts.NetworkOptions)
+	cmd.Flags().SortFlags = false
+	return cmd
+}
+
+func runCommand(opts *exportOptions) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		if err := opts.complete(cmd.Context(), args); err != nil {
+			return output.Fatalf("Invalid arguments: %s", err)
+		}
+
+		output.Infof("Exporting %s to %s", opts.modelRef, opts.exportDir)
+		var result *exportResult
+		var err error
+		switch opts.format {
+		case "ollama":
+			result, err = exportOllama(cmd.Context(), opts)
+		case "hf":
+			result, err = exportHF(cmd.Context(), opts)
+		}
+		if err != nil {
+			return output.Fatalln(err)
+		}
+		output.SetResult(result)
+		return nil
+	}
+}
+
+func (opts *exportOptions) complete(ctx context.Context, args []string) error {
+	configHome, ok := ctx.Value(constants.ConfigKey{}).(string)
+	if !ok {
+		return fmt.Errorf("default config path not set on command context")
+	}
+	opts.configHome = configHome
+	opts.modelRef = args[0]
+
+	if opts.format == "" {
+		return fmt.Errorf("the --format flag is required (options: %s)", strings.Join(validFormats, ", "))
+	}
+	if !slices.Contains(validFormats, opts.format) {
+		return fmt.Errorf("invalid format %s (options: %s)", opts.format, strings.Join(validFormats, ", "))
+	}
+
+	absDir, err := filepath.Abs(opts.exportDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve absolute path %s: %w", opts.exportDir, err)
+	}
+	opts.exportDir = absDir
+
+	if opts.pushRepo != "" {
+		if opts.format != "hf" {
+			return fmt.Errorf("--push-hf is only supported when exporting with --format hf")
+		}
+		if owner, name, ok := strings.Cut(opts.pushRepo, "/"); !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+			return fmt.Errorf("invalid repository %s for --push-hf: must be in the format owner/name", opts.pushRepo)
+		}
+		cfg, err := config.LoadConfig(configHome)
+		if err != nil {
+			return err
+		}
+		opts.hfEndpoint = hf.ResolveEndpoint(cfg.HuggingFace.Endpoint)
+		if opts.hfToken == "" {
+			opts.hfToken = hf.LookupToken(ctx)
+		}
+		if opts.hfToken == "" {
+			return fmt.Errorf("a Hugging Face token is required for --push-hf (use --token or set %s)", hf.TokenEnvVar)
+		}
+		limitRate := opts.LimitRate
+		if limitRate == "" {
+			limitRate = cfg.LimitRate
+		}
+		rateLimiter, err := ratelimit.NewFromString(limitRate)
+		if err != nil {
+			return fmt.Errorf("invalid argument for limit-rate: %w", err)
+		}
+		opts.rateLimiter = rateLimiter
+	}
+	return nil
+}
@@ -0,0 +1,387 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package export
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"kitops/pkg/artifact"
+	"kitops/pkg/kit"
+	"kitops/pkg/lib/constants"
+	"kitops/pkg/lib/hf"
+	"kitops/pkg/output"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	readmeFileName   = "README.md"
+	frontMatterDelim = "---"
+)
+
+// exportHF writes the model, docs, and Kitfile from the modelkit to the export directory in the layout
+// used by HuggingFace model repositories, along with a README.md model card generated from the Kitfile.
+// If --push-hf is set, the exported files are then uploaded to the HuggingFace repository.
+func exportHF(ctx context.Context, opts *exportOptions) (*exportResult, error) {
+	if err := os.MkdirAll(opts.exportDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+	readmePath := filepath.Join(opts.exportDir, readmeFileName)
+	if _, err := os.Stat(readmePath); err == nil {
+		if !opts.overwrite {
+			return nil, fmt.Errorf("file %s already exists (use --overwrite to replace it)", readmePath)
+		}
+		// Remove the existing README so that it is not mistaken for one included in the modelkit
+		if err := os.Remove(readmePath); err != nil {
+			return nil, fmt.Errorf("failed to remove existing %s: %w", readmeFileName, err)
+		}
+	}
+
+	unpackResult, err := kit.Unpack(ctx, kit.UnpackOptions{
+		Options:   kit.Options{ConfigHome: opts.configHome},
+		Remote:    kit.NewRemoteOptions(&opts.NetworkOptions),
+		Reference: opts.modelRef,
+		Dir:       opts.exportDir,
+		Filters:   []string{"kitfile,model,docs"},
+		Overwrite: opts.overwrite,
+	})
+	if err != nil {
+		return nil, err
+	}
+	kitfile, err := readKitfile(filepath.Join(opts.exportDir, constants.DefaultKitfileName))
+	if err != nil {
+		return nil, err
+	}
+
+	// If the modelkit includes a README.md, its contents are kept and the generated metadata is merged into it
+	existingReadme, err := os.ReadFile(readmePath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read %s: %w", readmeFileName, err)
+	}
+	card, err := generateModelCard(kitfile, unpackResult.Reference, existingReadme)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(readmePath, card, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", readmeFileName, err)
+	}
+
+	files, err := exportedFiles(opts.exportDir, unpackResult)
+	if err != nil {
+		return nil, err
+	}
+	result := &exportResult{
+		Reference: unpackResult.Reference,
+		Format:    "hf",
+		Directory: opts.exportDir,
+		Files:     files,
+	}
+	output.Infof("Exported %d files to %s", len(files), opts.exportDir)
+
+	if opts.pushRepo != "" {
+		if err := pushToHF(ctx, opts, unpackResult.Reference, files); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// pushToHF uploads files from the export directory to the HuggingFace repository specified by --push-hf
+// in a single commit.
+func pushToHF(ctx context.Context, opts *exportOptions, modelRef string, files []string) error {
+	var uploads []hf.UploadFile
+	for _, f := range files {
+		uploads = append(uploads, hf.UploadFile{
+			Path:      f,
+			LocalPath: filepath.Join(opts.exportDir, filepath.FromSlash(f)),
+		})
+	}
+	client := hf.NewClient(opts.hfEndpoint, opts.hfToken)
+	output.Infof("Uploading to %s", client.RepoURL(opts.pushRepo, hf.RepoTypeModel))
+	summary := fmt.Sprintf("Upload ModelKit %s", modelRef)
+	commit, err := client.UploadFiles(ctx, opts.pushRepo, hf.RepoTypeModel, hf.DefaultRevision, summary, uploads, opts.rateLimiter)
+	if err != nil {
+		return fmt.Errorf("failed to push to %s: %w", opts.pushRepo, err)
+	}
+	if commit.URL != "" {
+		output.Infof("Created commit %s", commit.URL)
+	} else {
+		output.Infof("Created commit %s", commit.OID)
+	}
+	return nil
+}
+
+// exportedFiles lists the files written by the export, relative to dir and using forward slashes. Only
+// files from unpacked layers and the generated model card are included, even if dir contains other files.
+func exportedFiles(dir string, unpackResult *kit.UnpackResult) ([]string, error) {
+	fileSet := map[string]bool{readmeFileName: true}
+	for _, layer := range unpackResult.Unpacked {
+		layerPath := filepath.Join(dir, filepath.FromSlash(layer.Path))
+		err := filepath.WalkDir(layerPath, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			relPath, err := filepath.Rel(dir, file)
+			if err != nil {
+				return err
+			}
+			fileSet[filepath.ToSlash(relPath)] = true
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list exported files: %w", err)
+		}
+	}
+	var files []string
+	for f := range fileSet {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// generateModelCard returns a README.md model card for kitfile. The card's metadata (YAML front matter) declares
+// the license, tags, datasets, and base model derived from the Kitfile. If existing is not empty, it is treated
+// as a README included in the modelkit: its contents are preserved and generated metadata is only added for
+// keys that are not already present in its front matter.
+func generateModelCard(kitfile *artifact.KitFile, modelRef string, existing []byte) ([]byte, error) {
+	metadata := cardMetadata(kitfile)
+
+	frontMatter := &yaml.Node{Kind: yaml.MappingNode}
+	body := string(existing)
+	if len(existing) == 0 {
+		body = cardBody(kitfile, modelRef)
+	} else if existingFrontMatter, rest, ok := splitFrontMatter(string(existing)); ok {
+		doc := &yaml.Node{}
+		if err := yaml.Unmarshal([]byte(existingFrontMatter), doc); err != nil {
+			return nil, fmt.Errorf("failed to parse metadata in %s: %w", readmeFileName, err)
+		}
+		if len(doc.Content) > 0 {
+			if doc.Content[0].Kind != yaml.MappingNode {
+				return nil, fmt.Errorf("failed to parse metadata in %s: metadata is not a map", readmeFileName)
+			}
+			frontMatter = doc.Content[0]
+		}
+		body = rest
+	}
+
+	for _, entry := range metadata {
+		if hasKey(frontMatter, entry.key) {
+			continue
+		}
+		valueNode := &yaml.Node{}
+		if err := valueNode.Encode(entry.value); err != nil {
+			return nil, fmt.Errorf("failed to generate model card metadata: %w", err)
+		}
+		frontMatter.Content = append(frontMatter.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: entry.key}, valueNode)
+	}
+
+	buf := &bytes.Buffer{}
+	if len(frontMatter.Content) > 0 {
+		buf.WriteString(frontMatterDelim + "\n")
+		enc := yaml.NewEncoder(buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(frontMatter); err != nil {
+			return nil, fmt.Errorf("failed to generate model card metadata: %w", err)
+		}
+		if err := enc.Close(); err != nil {
+			return nil, fmt.Errorf("failed to generate model card metadata: %w", err)
+		}
+		buf.WriteString(frontMatterDelim + "\n")
+	}
+	buf.WriteString(body)
+	return buf.Bytes(), nil
+}
+
+type cardEntry struct {
+	key   string
+	value any
+}
+
+// cardMetadata returns the model card metadata for kitfile, in the order it should appear in the card.
+func cardMetadata(kitfile *artifact.KitFile) []cardEntry {
+	var entries []cardEntry
+
+	license := kitfile.Package.License
+	if kitfile.Model != nil && kitfile.Model.License != "" {
+		license = kitfile.Model.License
+	}
+	var hubLicenses, otherLicenses []string
+	for _, l := range strings.Split(license, ",") {
+		l = strings.TrimSpace(l)
+		if l == "" {
+			continue
+		}
+		if id, ok := hf.HubLicense(l); ok {
+			hubLicenses = append(hubLicenses, id)
+		} else {
+			otherLicenses = append(otherLicenses, l)
+		}
+	}
+	switch {
+	case len(otherLicenses) > 0:
+		// HuggingFace only allows one license name for licenses it does not recognize
+		entries = append(entries, cardEntry{"license", "other"}, cardEntry{"license_name", licenseName(otherLicenses[0])})
+	case len(hubLicenses) == 1:
+		entries = append(entries, cardEntry{"license", hubLicenses[0]})
+	case len(hubLicenses) > 1:
+		entries = append(entries, cardEntry{"license", hubLicenses})
+	}
+
+	tags := []string{"kitops"}
+	if kitfile.Model != nil {
+		for _, tag := range []string{kitfile.Model.Framework, kitfile.Model.Format} {
+			tag = strings.ToLower(strings.TrimSpace(tag))
+			if tag != "" && !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	entries = append(entries, cardEntry{"tags", tags})
+
+	var datasets []string
+	for _, dataset := range kitfile.DataSets {
+		if dataset.Name != "" && !slices.Contains(datasets, dataset.Name) {
+			datasets = append(datasets, dataset.Name)
+		}
+	}
+	if len(datasets) > 0 {
+		entries = append(entries, cardEntry{"datasets", datasets})
+	}
+
+	if kitfile.Model != nil {
+		if params, ok := kitfile.Model.Parameters.(map[string]any); ok {
+			if baseModels := hf.CardStrings(params, "base_model"); len(baseModels) == 1 {
+				entries = append(entries, cardEntry{"base_model", baseModels[0]})
+			} else if len(baseModels) > 1 {
+				entries = append(entries, cardEntry{"base_model", baseModels})
+			}
+		}
+	}
+	return entries
+}
+
+// cardBody returns the Markdown content of a generated model card, describing the modelkit and its contents.
+func cardBody(kitfile *artifact.KitFile, modelRef string) string {
+	sb := &strings.Builder{}
+	title := kitfile.Package.Name
+	if title == "" && kitfile.Model != nil {
+		title = kitfile.Model.Name
+	}
+	if title == "" {
+		title = modelRef
+	}
+	fmt.Fprintf(sb, "# %s\n\n", title)
+	description := kitfile.Package.Description
+	if description == "" && kitfile.Model != nil {
+		description = kitfile.Model.Description
+	}
+	if description != "" {
+		fmt.Fprintf(sb, "%s\n\n", description)
+	}
+	fmt.Fprintf(sb, "This repository was exported from the [KitOps](https://kitops.org) ModelKit `%s`.\n", modelRef)
+
+	var contents []string
+	if kitfile.Model != nil && kitfile.Model.Path != "" {
+		contents = append(contents, contentsEntry(kitfile.Model.Path, "Model", kitfile.Model.Description))
+		for _, part := range kitfile.Model.Parts {
+			kind := "Model part"
+			if part.Type != "" {
+				kind = fmt.Sprintf("Model part (%s)", part.Type)
+			}
+			contents = append(contents, contentsEntry(part.Path, kind, part.Name))
+		}
+	}
+	for _, docs := range kitfile.Docs {
+		if path.Clean(docs.Path) == readmeFileName {
+			continue
+		}
+		contents = append(contents, contentsEntry(docs.Path, "Documentation", docs.Description))
+	}
+	if len(contents) > 0 {
+		sb.WriteString("\n## Contents\n\n")
+		for _, entry := range contents {
+			fmt.Fprintf(sb, "- %s\n", entry)
+		}
+	}
+	return sb.String()
+}
+
+func contentsEntry(entryPath, kind, description string) string {
+	if description == "" {
+		return fmt.Sprintf("`%s`: %s", entryPath, kind)
+	}
+	return fmt.Sprintf("`%s`: %s: %s", entryPath, kind, description)
+}
+
+// splitFrontMatter splits a Markdown document into its YAML front matter and the rest of the document. If the
+// document does not start with front matter, ok is false.
+func splitFrontMatter(doc string) (frontMatter, rest string, ok bool) {
+	normalized := strings.ReplaceAll(doc, "\r\n", "\n")
+	if !strings.HasPrefix(normalized, frontMatterDelim+"\n") {
+		return "", doc, false
+	}
+	remaining := strings.TrimPrefix(normalized, frontMatterDelim+"\n")
+	if strings.HasPrefix(remaining, frontMatterDelim+"\n") {
+		return "", strings.TrimPrefix(remaining, frontMatterDelim+"\n"), true
+	}
+	end := strings.Index(remaining, "\n"+frontMatterDelim+"\n")
+	if end < 0 {
+		if strings.HasSuffix(remaining, "\n"+frontMatterDelim) {
+			return strings.TrimSuffix(remaining, "\n"+frontMatterDelim), "", true
+		}
+		return "", doc, false
+	}
+	return remaining[:end], remaining[end+len(frontMatterDelim)+2:], true
+}
+
+func hasKey(mapping *yaml.Node, key string) bool {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return true
+		}
+	}
+	return false
+}
+
+// licenseName converts a license name into the form HuggingFace accepts for license_name: lowercase letters,
+// digits, hyphens, and periods.
+func licenseName(license string) string {
+	name := strings.ToLower(license)
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		default:
+			return '-'
+		}
+	}, name)
+	return strings.Trim(name, "-")
+}
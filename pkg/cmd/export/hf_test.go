@@ -0,0 +1,177 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package export
+
+import (
+	"testing"
+
+	"kitops/pkg/artifact"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateModelCard(t *testing.T) {
+	tests := []struct {
+		name     string
+		kitfile  *artifact.KitFile
+		existing string
+		expected string
+	}{
+		{
+			name: "generates metadata and body",
+			kitfile: &artifact.KitFile{
+				Package: artifact.Package{Name: "my-model", Description: "A test model", License: "Apache-2.0"},
+				Model: &artifact.Model{
+					Path:       "model.safetensors",
+					Framework:  "Transformers",
+					Format:     "safetensors",
+					Parameters: map[string]any{"base_model": "org/base-model"},
+					Parts:      []artifact.ModelPart{{Path: "tokenizer.json", Type: "tokenizer"}},
+				},
+				DataSets: []artifact.DataSet{{Name: "org/dataset", Path: "data"}, {Path: "other"}},
+				Docs:     []artifact.Docs{{Path: "LICENSE", Description: "License"}},
+			},
+			expected: `---
+license: apache-2.0
+tags:
+  - kitops
+  - transformers
+  - safetensors
+datasets:
+  - org/dataset
+base_model: org/base-model
+---
+# my-model
+
+A test model
+
+This repository was exported from the [KitOps](https://kitops.org) ModelKit ` + "`test:latest`" + `.
+
+## Contents
+
+- ` + "`model.safetensors`" + `: Model
+- ` + "`tokenizer.json`" + `: Model part (tokenizer)
+- ` + "`LICENSE`" + `: Documentation: License
+`,
+		},
+		{
+			name: "uses model license over package license",
+			kitfile: &artifact.KitFile{
+				Package: artifact.Package{Name: "my-model", License: "MIT"},
+				Model:   &artifact.Model{Path: "model", License: "ODbL-1.0, cc-by-4.0"},
+			},
+			expected: `---
+license:
+  - odbl
+  - cc-by-4.0
+tags:
+  - kitops
+---
+# my-model
+
+This repository was exported from the [KitOps](https://kitops.org) ModelKit ` + "`test:latest`" + `.
+
+## Contents
+
+- ` + "`model`" + `: Model
+`,
+		},
+		{
+			name: "declares unrecognized licenses as other",
+			kitfile: &artifact.KitFile{
+				Package: artifact.Package{License: "Llama 3.1 Community License"},
+			},
+			expected: `---
+license: other
+license_name: llama-3.1-community-license
+tags:
+  - kitops
+---
+# test:latest
+
+This repository was exported from the [KitOps](https://kitops.org) ModelKit ` + "`test:latest`" + `.
+`,
+		},
+		{
+			name: "merges metadata into existing README",
+			kitfile: &artifact.KitFile{
+				Package: artifact.Package{License: "MIT"},
+				Model:   &artifact.Model{Path: "model", Framework: "PyTorch"},
+			},
+			existing: "---\n# keep comments\nlicense: apache-2.0\npipeline_tag: text-generation\n---\n# Existing\n\nExisting content\n",
+			expected: `---
+# keep comments
+license: apache-2.0
+pipeline_tag: text-generation
+tags:
+  - kitops
+  - pytorch
+---
+# Existing
+
+Existing content
+`,
+		},
+		{
+			name: "adds metadata to existing README without front matter",
+			kitfile: &artifact.KitFile{
+				Package: artifact.Package{License: "MIT"},
+			},
+			existing: "# Existing\n",
+			expected: `---
+license: mit
+tags:
+  - kitops
+---
+# Existing
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card, err := generateModelCard(tt.kitfile, "test:latest", []byte(tt.existing))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.expected, string(card))
+		})
+	}
+}
+
+func TestSplitFrontMatter(t *testing.T) {
+	tests := []struct {
+		input               string
+		expectedFrontMatter string
+		expectedRest        string
+		expectedOk          bool
+	}{
+		{input: "---\na: b\n---\nbody\n", expectedFrontMatter: "a: b", expectedRest: "body\n", expectedOk: true},
+		{input: "---\r\na: b\r\n---\r\nbody\r\n", expectedFrontMatter: "a: b", expectedRest: "body\n", expectedOk: true},
+		{input: "---\n---\nbody", expectedFrontMatter: "", expectedRest: "body", expectedOk: true},
+		{input: "---\na: b\n---", expectedFrontMatter: "a: b", expectedRest: "", expectedOk: true},
+		{input: "# Title\n---\n", expectedFrontMatter: "", expectedRest: "# Title\n---\n", expectedOk: false},
+		{input: "---\nunterminated\n", expectedFrontMatter: "", expectedRest: "---\nunterminated\n", expectedOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			frontMatter, rest, ok := splitFrontMatter(tt.input)
+			assert.Equal(t, tt.expectedOk, ok)
+			assert.Equal(t, tt.expectedFrontMatter, frontMatter)
+			assert.Equal(t, tt.expectedRest, rest)
+		})
+	}
+}
@@ -0,0 +1,303 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package export
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"kitops/pkg/artifact"
+	"kitops/pkg/kit"
+	"kitops/pkg/lib/constants"
+	"kitops/pkg/lib/ollama"
+	"kitops/pkg/output"
+)
+
+const (
+	modelfileName = "Modelfile"
+	blobsDirName  = "blobs"
+)
+
+var (
+	licenseFileRegexp = regexp.MustCompile(`(?i)(^|/)(licen[cs]e|copying)[^/]*$`)
+	// licenseDocsFilter selects docs layers for license files when unpacking
+	licenseDocsFilter = fmt.Sprintf("docs:path=/%s/", licenseFileRegexp.String())
+)
+
+// exportOllama writes a Modelfile and blobs directory for the modelkit to the export directory.
+func exportOllama(ctx context.Context, opts *exportOptions) (*exportResult, error) {
+	if err := os.MkdirAll(opts.exportDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+	modelfilePath := filepath.Join(opts.exportDir, modelfileName)
+	if _, err := os.Stat(modelfilePath); err == nil && !opts.overwrite {
+		return nil, fmt.Errorf("file %s already exists (use --overwrite to replace it)", modelfilePath)
+	}
+
+	// Unpack into a staging directory within the export directory so that weights can be moved into
+	// the blobs directory without copying them.
+	stagingDir, err := os.MkdirTemp(opts.exportDir, ".kit-export-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(stagingDir); err != nil {
+			output.Logf(output.LogLevelWarn, "Failed to remove temporary directory %s: %s", stagingDir, err)
+		}
+	}()
+	unpackResult, err := kit.Unpack(ctx, kit.UnpackOptions{
+		Options:   kit.Options{ConfigHome: opts.configHome},
+		Remote:    kit.NewRemoteOptions(&opts.NetworkOptions),
+		Reference: opts.modelRef,
+		Dir:       stagingDir,
+		Filters:   []string{"kitfile,model", licenseDocsFilter},
+	})
+	if err != nil {
+		return nil, err
+	}
+	kitfile, err := readKitfile(filepath.Join(stagingDir, constants.DefaultKitfileName))
+	if err != nil {
+		return nil, err
+	}
+
+	modelfile, blobs, err := buildOllamaModelfile(stagingDir, kitfile)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &exportResult{
+		Reference: unpackResult.Reference,
+		Format:    "ollama",
+		Directory: opts.exportDir,
+	}
+	blobsDir := filepath.Join(opts.exportDir, blobsDirName)
+	if err := os.MkdirAll(blobsDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create blobs directory: %w", err)
+	}
+	for srcPath, blobName := range blobs {
+		destPath := filepath.Join(blobsDir, blobName)
+		if err := os.Rename(srcPath, destPath); err != nil {
+			return nil, fmt.Errorf("failed to move %s to blobs directory: %w", srcPath, err)
+		}
+		result.Files = append(result.Files, path.Join(blobsDirName, blobName))
+	}
+
+	modelfileContents, err := modelfile.String()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate Modelfile: %w", err)
+	}
+	if err := os.WriteFile(modelfilePath, []byte(modelfileContents), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write Modelfile: %w", err)
+	}
+	result.Files = append(result.Files, modelfileName)
+
+	output.Infof("Exported Modelfile to %s. Create an Ollama model from it using", modelfilePath)
+	output.Infof("    ollama create %s -f %s", ollamaModelName(kitfile), modelfilePath)
+	return result, nil
+}
+
+// buildOllamaModelfile returns a Modelfile for the modelkit unpacked in dir. Weights (the model and any
+// adapters) are returned as a map of the unpacked path to the name of the blob to store it as; the Modelfile
+// refers to blobs by their path relative to the Modelfile.
+func buildOllamaModelfile(dir string, kitfile *artifact.KitFile) (*ollama.Modelfile, map[string]string, error) {
+	if kitfile.Model == nil {
+		return nil, nil, fmt.Errorf("modelkit does not contain a model")
+	}
+	blobs := map[string]string{}
+	addBlob := func(blobPath string) (string, error) {
+		blobName, err := blobNameForFile(blobPath)
+		if err != nil {
+			return "", err
+		}
+		blobs[blobPath] = blobName
+		return "./" + path.Join(blobsDirName, blobName), nil
+	}
+
+	weightsPath, err := findGGUFFile(dir, kitfile.Model.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	modelfile := &ollama.Modelfile{}
+	if modelfile.From, err = addBlob(weightsPath); err != nil {
+		return nil, nil, err
+	}
+
+	for _, part := range kitfile.Model.Parts {
+		partPath := filepath.Join(dir, filepath.FromSlash(part.Path))
+		switch part.Type {
+		case "template", "system":
+			contents, err := os.ReadFile(partPath)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to read %s: %w", part.Type, err)
+			}
+			if part.Type == "template" {
+				modelfile.Template = string(contents)
+			} else {
+				modelfile.System = string(contents)
+			}
+		case "params":
+			if err := readJSONFile(partPath, &modelfile.Parameters); err != nil {
+				return nil, nil, fmt.Errorf("failed to read params: %w", err)
+			}
+		case "messages":
+			if err := readJSONFile(partPath, &modelfile.Messages); err != nil {
+				return nil, nil, fmt.Errorf("failed to read messages: %w", err)
+			}
+		case "adapter":
+			adapterPath, err := findGGUFFile(dir, part.Path)
+			if err != nil {
+				return nil, nil, err
+			}
+			adapter, err := addBlob(adapterPath)
+			if err != nil {
+				return nil, nil, err
+			}
+			modelfile.Adapters = append(modelfile.Adapters, adapter)
+		case "projector":
+			output.Logf(output.LogLevelWarn, "Skipping projector %s: projectors are not supported in Modelfiles", part.Path)
+		default:
+			output.Debugf("Skipping model part %s with type '%s'", part.Path, part.Type)
+		}
+	}
+
+	for _, docs := range kitfile.Docs {
+		if !licenseFileRegexp.MatchString(path.Clean(docs.Path)) {
+			continue
+		}
+		contents, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(docs.Path)))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, nil, fmt.Errorf("failed to read license: %w", err)
+		}
+		modelfile.Licenses = append(modelfile.Licenses, string(contents))
+	}
+
+	return modelfile, blobs, nil
+}
+
+// findGGUFFile returns the path of the GGUF file for a model or model part at relPath in dir. If relPath
+// is a directory, it must contain exactly one GGUF file.
+func findGGUFFile(dir, relPath string) (string, error) {
+	fullPath := filepath.Join(dir, filepath.FromSlash(relPath))
+	stat, err := os.Stat(fullPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read model: %w", err)
+	}
+	if !stat.IsDir() {
+		if !isGGUFFile(fullPath) {
+			return "", fmt.Errorf("file %s is not a GGUF file; only GGUF models can be exported to Ollama", relPath)
+		}
+		return fullPath, nil
+	}
+	entries, err := os.ReadDir(fullPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read model directory: %w", err)
+	}
+	var ggufFiles []string
+	for _, entry := range entries {
+		entryPath := filepath.Join(fullPath, entry.Name())
+		if entry.Type().IsRegular() && isGGUFFile(entryPath) {
+			ggufFiles = append(ggufFiles, entryPath)
+		}
+	}
+	switch len(ggufFiles) {
+	case 0:
+		return "", fmt.Errorf("no GGUF file found in %s; only GGUF models can be exported to Ollama", relPath)
+	case 1:
+		return ggufFiles[0], nil
+	default:
+		return "", fmt.Errorf("found multiple GGUF files in %s; cannot determine which to export", relPath)
+	}
+}
+
+// isGGUFFile checks whether the file at path starts with the GGUF magic number
+func isGGUFFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return false
+	}
+	return string(magic) == "GGUF"
+}
+
+// blobNameForFile returns the name Ollama uses for a blob with the same contents as the file at path.
+func blobNameForFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return ollama.BlobFileName("sha256:" + hex.EncodeToString(hash.Sum(nil))), nil
+}
+
+func readKitfile(kitfilePath string) (*artifact.KitFile, error) {
+	f, err := os.Open(kitfilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Kitfile: %w", err)
+	}
+	defer f.Close()
+	kitfile := &artifact.KitFile{}
+	if err := kitfile.LoadModel(f); err != nil {
+		return nil, fmt.Errorf("failed to parse Kitfile: %w", err)
+	}
+	return kitfile, nil
+}
+
+func readJSONFile(path string, v any) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(contents, v)
+}
+
+// ollamaModelName returns a suggested name for the Ollama model created from kitfile
+func ollamaModelName(kitfile *artifact.KitFile) string {
+	name := kitfile.Package.Name
+	if name == "" {
+		name = kitfile.Model.Name
+	}
+	if name == "" {
+		return "<model-name>"
+	}
+	name = strings.ToLower(name)
+	if kitfile.Package.Version != "" {
+		name = name + ":" + kitfile.Package.Version
+	}
+	return name
+}
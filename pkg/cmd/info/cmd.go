@@ -80,7 +80,7 @@ func InfoCommand() *cobra.Command {
 		Args:    cobra.ExactArgs(1),
 	}
 
-	opts.AddNetworkFlags(cmd)
+	options.AddNetworkFlags(cmd, &opts.NetworkOptions)
 	cmd.Flags().BoolVarP(&opts.checkRemote, "remote", "r", false, "Check remote registry instead of local storage")
 	cmd.Flags().StringVarP(&opts.filter, "filter", "f", "", "filter with node selectors")
 	cmd.Flags().SortFlags = false
@@ -106,11 +106,21 @@ func runCommand(opts *infoOptions) func(*cobra.Command, []string) error {
 			if err != nil {
 				return output.Fatalln(err)
 			}
+			if output.StructuredOutput() {
+				var filtered any
+				if err := yaml.Unmarshal(filteredOutput, &filtered); err != nil {
+					return output.Fatalf("Error formatting manifest: %s", err)
+				}
+				output.SetResult(filtered)
+				return nil
+			}
 			fmt.Print(string(filteredOutput))
+		} else if output.StructuredOutput() {
+			output.SetResult(config)
 		} else {
 			yamlBytes, err := config.MarshalToYAML()
 			if err != nil {
-				return output.Fatalf("Error formatting manifest: %w", err)
+				return output.Fatalf("Error formatting manifest: %s", err)
 			}
 			fmt.Print(string(yamlBytes))
 		}
@@ -139,7 +149,7 @@ func (opts *infoOptions) complete(ctx context.Context, args []string) error {
 		return fmt.Errorf("can not check remote: %s does not contain registry", util.FormatRepositoryForDisplay(opts.modelRef.String()))
 	}
 
-	if err := opts.NetworkOptions.Complete(ctx, args); err != nil {
+	if err := options.CompleteNetworkOptions(ctx, &opts.NetworkOptions); err != nil {
 		return err
 	}
 
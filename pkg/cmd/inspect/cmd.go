@@ -21,16 +21,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"strings"
 
 	"kitops/pkg/cmd/options"
+	"kitops/pkg/kit"
 	"kitops/pkg/lib/constants"
-	"kitops/pkg/lib/repo/util"
 	"kitops/pkg/output"
 
 	"github.com/spf13/cobra"
 	"oras.land/oras-go/v2/errdef"
-	"oras.land/oras-go/v2/registry"
 )
 
 const (
@@ -51,9 +49,7 @@ kit inspect --remote registry.example.com/my-model:1.0.0`
 
 type inspectOptions struct {
 	options.NetworkOptions
-	configHome  string
 	checkRemote bool
-	modelRef    *registry.Reference
 }
 
 func InspectCommand() *cobra.Command {
@@ -68,7 +64,7 @@ func InspectCommand() *cobra.Command {
 		Args:    cobra.ExactArgs(1),
 	}
 
-	opts.AddNetworkFlags(cmd)
+	options.AddNetworkFlags(cmd, &opts.NetworkOptions)
 	cmd.Flags().BoolVarP(&opts.checkRemote, "remote", "r", false, "Check remote registry instead of local storage")
 	cmd.Flags().SortFlags = false
 
@@ -77,16 +73,21 @@ func InspectCommand() *cobra.Command {
 
 func runCommand(opts *inspectOptions) func(*cobra.Command, []string) error {
 	return func(cmd *cobra.Command, args []string) error {
-		if err := opts.complete(cmd.Context(), args); err != nil {
+		inspectOpts, err := opts.complete(cmd.Context(), args)
+		if err != nil {
 			return output.Fatalf("Invalid arguments: %s", err)
 		}
-		inspectInfo, err := inspectReference(cmd.Context(), opts)
+		inspectInfo, err := kit.Inspect(cmd.Context(), *inspectOpts)
 		if err != nil {
 			if errors.Is(err, errdef.ErrNotFound) {
-				return output.Fatalf("Could not find modelkit %s", util.FormatRepositoryForDisplay(opts.modelRef.String()))
+				return output.Fatalf("Could not find modelkit %s", args[0])
 			}
 			return output.Fatalf("Error resolving modelkit: %s", err)
 		}
+		if output.StructuredOutput() {
+			output.SetResult(inspectInfo)
+			return nil
+		}
 		jsonBytes, err := json.MarshalIndent(inspectInfo, "", "  ")
 		if err != nil {
 			return fmt.Errorf("Error formatting manifest: %w", err)
@@ -96,29 +97,15 @@ func runCommand(opts *inspectOptions) func(*cobra.Command, []string) error {
 	}
 }
 
-func (opts *inspectOptions) complete(ctx context.Context, args []string) error {
+func (opts *inspectOptions) complete(ctx context.Context, args []string) (*kit.InspectOptions, error) {
 	configHome, ok := ctx.Value(constants.ConfigKey{}).(string)
 	if !ok {
-		return fmt.Errorf("default config path not set on command context")
-	}
-	opts.configHome = configHome
-
-	ref, extraTags, err := util.ParseReference(args[0])
-	if err != nil {
-		return err
-	}
-	if len(extraTags) > 0 {
-		return fmt.Errorf("invalid reference format: extra tags are not supported: %s", strings.Join(extraTags, ", "))
+		return nil, fmt.Errorf("default config path not set on command context")
 	}
-	opts.modelRef = ref
-
-	if opts.modelRef.Registry == util.DefaultRegistry && opts.checkRemote {
-		return fmt.Errorf("can not check remote: %s does not contain registry", util.FormatRepositoryForDisplay(opts.modelRef.String()))
-	}
-
-	if err := opts.NetworkOptions.Complete(ctx, args); err != nil {
-		return err
-	}
-
-	return nil
+	return &kit.InspectOptions{
+		Options:     kit.Options{ConfigHome: configHome},
+		Remote:      kit.NewRemoteOptions(&opts.NetworkOptions),
+		Reference:   args[0],
+		CheckRemote: opts.checkRemote,
+	}, nil
 }
@@ -54,16 +54,25 @@ kit cache clear
 	return cmd
 }
 
+type cacheInfo struct {
+	TotalSize int64            `json:"totalSize"`
+	Contents  map[string]int64 `json:"contents"`
+}
+
 func cacheInfoCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "info",
 		Short: `Get information about cache disk usage`,
 		Long:  `Print the total size of temporary files in the cache directory.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			totalSize, stats, err := fscache.StatCache()
+			totalSize, stats, err := fscache.StatCache(cmd.Context())
 			if err != nil {
 				return output.Fatalln(err)
 			}
+			if output.StructuredOutput() {
+				output.SetResult(cacheInfo{TotalSize: totalSize, Contents: stats})
+				return nil
+			}
 			if totalSize == 0 {
 				output.Infof("Cache is currently empty")
 				return nil
@@ -81,7 +90,7 @@ func cacheClearCommand() *cobra.Command {
 		Short: `Clear temporary cache storage`,
 		Long:  `Clear temporary files from cache storage.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if err := fscache.ClearCache(); err != nil {
+			if err := fscache.ClearCache(cmd.Context()); err != nil {
 				return output.Fatalln(err)
 			}
 			return nil
@@ -23,7 +23,11 @@ import (
 	"slices"
 	"strings"
 
+	"kitops/pkg/lib/config"
 	"kitops/pkg/lib/constants"
+	"kitops/pkg/lib/hf"
+	"kitops/pkg/lib/mlflow"
+	"kitops/pkg/lib/ratelimit"
 	repoutils "kitops/pkg/lib/repo/util"
 	"kitops/pkg/output"
 
@@ -39,6 +43,13 @@ The repository can be specified either via a repository (e.g. myorg/myrepo) or
 with a full URL (https://huggingface.co/myorg/myrepo). The repository will be
 downloaded to a temporary directory and be packaged using a generated Kitfile.
 
+HuggingFace dataset repositories can be imported by prefixing the repository
+with 'datasets/' (e.g. datasets/myorg/mydataset) or by using the dataset URL
+(https://huggingface.co/datasets/myorg/mydataset). For datasets, the generated
+Kitfile contains a dataset entry for each directory of data files (e.g. a split
+or config), and metadata and license information from the dataset card are
+included in the dataset entries.
+
 In interactive settings, this command will read the EDITOR environment variable
 to determine which editor should be used for editing the Kitfile.
 
@@ -49,11 +60,54 @@ options below:
   --tool=hf  : Download files using the Huggingface API. Requires REPOSITORY to
 	             be a Huggingface repository. This is the default for Huggingface
 							 repositories
-  --tool=git : Download files using Git and Git LFS. Works for any Git
-	             repository but requires that Git and Git LFS are installed.
+  --tool=git : Download files using the Git and Git LFS protocols. Works for
+	             any Git repository accessible over HTTP(S) and does not require
+	             Git or Git LFS to be installed.
+  --tool=mlflow : Import a model saved by MLflow from a local path or file://
+	                URI. REPOSITORY may be a model directory (containing an
+	                MLmodel file) or a run's artifact directory containing a
+	                single model. This is the default for file:// URIs.
+  --tool=ollama : Import a model pulled or created with Ollama from the local
+	                Ollama models directory ($OLLAMA_MODELS, or ~/.ollama/models
+	                by default). REPOSITORY is the name of the model in Ollama,
+	                e.g. llama3.2:1b.
 
 By default, Kit will automatically select the tool based on the provided
-REPOSITORY.`
+REPOSITORY.
+
+When using the Huggingface API, files are downloaded from https://huggingface.co
+unless a different endpoint (e.g. a mirror) is configured via the HF_ENDPOINT
+environment variable or the 'huggingface.endpoint' setting in Kit's config. If
+--token is not specified, the HF_TOKEN environment variable or the token saved
+by 'huggingface-cli login' is used to authenticate, if available.
+
+A specific revision of the repository can be imported using the --revision
+flag. The revision may be a branch, tag, commit SHA, or ref (e.g. refs/pr/12)
+and defaults to 'main' for the Huggingface API, or the repository's default
+branch for Git. The revision is resolved to a commit before downloading, and
+the repository URL and commit SHA are recorded in the ModelKit's manifest using
+the org.opencontainers.image.source and org.opencontainers.image.revision
+annotations.
+
+Files downloaded using the Huggingface API are verified against the checksums
+provided by the API, and failed downloads are retried. If an import is
+interrupted, running the same command again resumes downloading where it left
+off.
+
+When importing from MLflow, the generated Kitfile uses the model directory as
+the model path and sets the framework based on the model's flavor. The model's
+flavors, signature, and input example are stored in the model's parameters, and
+requirements.txt and conda.yaml are included as code.
+
+When importing from Ollama, the GGUF weights are used as the model, and the
+prompt template, parameters, system prompt, and other layers of the Ollama
+model are included as model parts with types such as 'template', 'params', and
+'system'. Licenses are included as docs. Use 'kit export --format ollama' to
+convert the ModelKit back into a Modelfile for 'ollama create'.
+
+When using Git, only the latest commit of the requested revision is cloned, and
+only the Git LFS files that are included in the Kitfile are downloaded. Git LFS
+files are verified against the checksums in their LFS pointers.`
 
 	example = `# Download repository myorg/myrepo and package it, using the default tag (myorg/myrepo:latest)
 kit import myorg/myrepo
@@ -62,7 +116,19 @@ kit import myorg/myrepo
 kit import myorg/myrepo --tag myrepository:mytag
 
 # Download repository and pack it using an existing Kitfile
-kit import myorg/myrepo --file ./path/to/Kitfile`
+kit import myorg/myrepo --file ./path/to/Kitfile
+
+# Download dataset repository myorg/mydataset and package it
+kit import datasets/myorg/mydataset --tag mydataset:latest
+
+# Download a specific revision of a repository (branch, tag, commit, or PR ref)
+kit import myorg/myrepo --revision refs/pr/12 --tag myrepository:pr-12
+
+# Import a model logged to MLflow from a local artifact store
+kit import --tool=mlflow ./mlruns/0/<run-id>/artifacts/model --tag mymodel:latest
+
+# Import a model from the local Ollama models directory
+kit import --tool=ollama llama3.2:1b`
 )
 
 type importOptions struct {
@@ -70,10 +136,14 @@ type importOptions struct {
 	repo         string
 	tag          string
 	token        string
+	revision     string
 	kitfilePath  string
 	downloadTool string
 	concurrency  int
+	limitRate    string
+	rateLimiter  *ratelimit.Limiter
 	modelKitRef  *registry.Reference
+	hfEndpoint   string
 }
 
 func ImportCommand() *cobra.Command {
@@ -88,11 +158,13 @@ func ImportCommand() *cobra.Command {
 		Args:    cobra.ExactArgs(1),
 	}
 
-	cmd.Flags().StringVar(&opts.token, "token", "", "Token to use for authenticating with repository")
+	cmd.Flags().StringVar(&opts.token, "token", "", "Token to use for authenticating with repository (default for huggingface: $HF_TOKEN or saved token)")
 	cmd.Flags().StringVarP(&opts.tag, "tag", "t", "", "Tag for the ModelKit (default is '[repository]:latest')")
+	cmd.Flags().StringVar(&opts.revision, "revision", "", "Revision (branch, tag, commit, or ref) to import (default for huggingface is 'main')")
 	cmd.Flags().StringVarP(&opts.kitfilePath, "file", "f", "", "Path to Kitfile to use for packing (use '-' to read from standard input)")
-	cmd.Flags().StringVar(&opts.downloadTool, "tool", "", "Tool to use for downloading files: options are 'git' and 'hf' (default: detect based on repository)")
-	cmd.Flags().IntVar(&opts.concurrency, "concurrency", 5, "Maximum number of simultaneous downloads (for huggingface)")
+	cmd.Flags().StringVar(&opts.downloadTool, "tool", "", "Tool to use for downloading files: options are 'git', 'hf', 'mlflow', and 'ollama' (default: detect based on repository)")
+	cmd.Flags().IntVar(&opts.concurrency, "concurrency", 5, "Maximum number of simultaneous downloads")
+	cmd.Flags().StringVar(&opts.limitRate, "limit-rate", "", "Maximum combined download rate, e.g. 50MB/s")
 	cmd.Flags().SortFlags = false
 	return cmd
 }
@@ -123,14 +195,18 @@ func (opts *importOptions) complete(ctx context.Context, args []string) error {
 	opts.configHome = configHome
 	opts.repo = args[0]
 
+	validTools := []string{"git", "hf", "mlflow", "ollama"}
+	if opts.downloadTool != "" && !slices.Contains(validTools, opts.downloadTool) {
+		return fmt.Errorf("invalid value for --tool flag. Valid options are: %s", strings.Join(validTools, ", "))
+	}
+
 	if opts.tag == "" {
-		tag, err := extractRepoFromURL(opts.repo)
+		tag, err := opts.defaultTag()
 		if err != nil {
 			output.Errorf("Could not generate tag from URL: %s", err)
 			return fmt.Errorf("use flag --tag to set a tag for ModelKit")
 		}
-		tag = strings.ToLower(tag)
-		opts.tag = fmt.Sprintf("%s:latest", tag)
+		opts.tag = strings.ToLower(tag)
 		output.Infof("Using tag %s. Use flag --tag to override", opts.tag)
 	}
 
@@ -140,14 +216,23 @@ func (opts *importOptions) complete(ctx context.Context, args []string) error {
 	}
 	opts.modelKitRef = ref
 
-	validTools := []string{"git", "hf"}
-	if opts.downloadTool != "" && !slices.Contains(validTools, opts.downloadTool) {
-		return fmt.Errorf("invalid value for --tool flag. Valid options are: %s", strings.Join(validTools, ", "))
-	}
-
 	if opts.concurrency < 1 {
 		return fmt.Errorf("invalid argument for concurrency (%d): must be at least 1", opts.concurrency)
 	}
+
+	cfg, err := config.LoadConfig(configHome)
+	if err != nil {
+		return err
+	}
+	if opts.limitRate == "" {
+		opts.limitRate = cfg.LimitRate
+	}
+	opts.hfEndpoint = hf.ResolveEndpoint(cfg.HuggingFace.Endpoint)
+	rateLimiter, err := ratelimit.NewFromString(opts.limitRate)
+	if err != nil {
+		return fmt.Errorf("invalid argument for limit-rate: %w", err)
+	}
+	opts.rateLimiter = rateLimiter
 	return nil
 }
 
@@ -157,7 +242,14 @@ func getImporter(opts *importOptions) (func(context.Context, *importOptions) err
 		return importUsingHF, nil
 	case "git":
 		return importUsingGit, nil
+	case "mlflow":
+		return importUsingMLflow, nil
+	case "ollama":
+		return importUsingOllama, nil
 	default:
+		if opts.isMLflowImport() {
+			return importUsingMLflow, nil
+		}
 		repoUrl, err := url.Parse(opts.repo)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse %s: %w", opts.repo, err)
@@ -166,7 +258,39 @@ func getImporter(opts *importOptions) (func(context.Context, *importOptions) err
 		if repoUrl.Host == "" || strings.Contains(repoUrl.Host, "huggingface") {
 			return importUsingHF, nil
 		}
+		if endpointUrl, err := url.Parse(opts.hfEndpoint); err == nil && repoUrl.Host == endpointUrl.Host {
+			return importUsingHF, nil
+		}
 
 		return importUsingGit, nil
 	}
 }
+
+// isMLflowImport returns true if the repository should be imported from an MLflow model directory, either
+// because --tool=mlflow was specified or because the repository is a file:// URI.
+func (opts *importOptions) isMLflowImport() bool {
+	if opts.downloadTool == "" {
+		return mlflow.IsFileURI(opts.repo)
+	}
+	return opts.downloadTool == "mlflow"
+}
+
+// defaultTag returns the tag to use for the imported ModelKit if --tag is not specified
+func (opts *importOptions) defaultTag() (string, error) {
+	switch {
+	case opts.downloadTool == "ollama":
+		return defaultOllamaTag(opts.repo)
+	case opts.isMLflowImport():
+		name, err := defaultMLflowTag(opts.repo)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s:latest", name), nil
+	default:
+		repo, _, err := extractHFRepoFromURL(opts.repo)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s:latest", repo), nil
+	}
+}
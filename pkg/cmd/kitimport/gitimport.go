@@ -33,10 +33,12 @@ import (
 	kfgen "kitops/pkg/lib/kitfile/generate"
 	"kitops/pkg/lib/util"
 	"kitops/pkg/output"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
 func importUsingGit(ctx context.Context, opts *importOptions) error {
-	tmpDir, cleanupTmp, err := cache.MkCacheDir(cache.CacheImportSubdir, "")
+	tmpDir, cleanupTmp, err := cache.MkCacheDir(ctx, cache.CacheImportSubdir, "")
 	if err != nil {
 		return err
 	}
@@ -47,9 +49,26 @@ func importUsingGit(ctx context.Context, opts *importOptions) error {
 		}
 	}()
 
-	if err := cloneRepository(opts.repo, tmpDir, opts.token); err != nil {
+	repoURL := opts.repo
+	if !strings.HasPrefix(repoURL, "http") {
+		repoURL = fmt.Sprintf("%s/%s", opts.hfEndpoint, opts.repo)
+	}
+	commitSHA, err := cloneRepository(ctx, repoURL, tmpDir, opts.token, opts.revision)
+	if err != nil {
+		return err
+	}
+
+	// Files stored in Git LFS are checked out as pointer files; list them using the size of the actual
+	// file so that the Kitfile is generated in the same way as if the files had been downloaded.
+	lfsObjects, err := git.FindLFSObjects(tmpDir)
+	if err != nil {
 		return err
 	}
+	dirContents, err := kfgen.DirectoryListingFromFS(tmpDir)
+	if err != nil {
+		return fmt.Errorf("error processing directory: %w", err)
+	}
+	applyLFSObjectsToListing(dirContents, lfsObjects)
 
 	var kitfile *artifact.KitFile
 	if opts.kitfilePath == "-" {
@@ -73,10 +92,6 @@ func importUsingGit(ctx context.Context, opts *importOptions) error {
 		}
 		kitfile = kf
 	} else {
-		dirContents, err := kfgen.DirectoryListingFromFS(tmpDir)
-		if err != nil {
-			return fmt.Errorf("error processing directory: %w", err)
-		}
 		kf, err := generateKitfile(dirContents, opts.repo, tmpDir)
 		if err != nil {
 			return err
@@ -94,7 +109,7 @@ func importUsingGit(ctx context.Context, opts *importOptions) error {
 					output.Logf(output.LogLevelWarn, "Please manually edit Kitfile at path")
 					output.Logf(output.LogLevelWarn, "    %s", filepath.Join(tmpDir, constants.DefaultKitfileName))
 					output.Logf(output.LogLevelWarn, "and run command")
-					output.Logf(output.LogLevelWarn, "    kit pack -t %s %s", opts.tag, tmpDir)
+					output.Logf(output.LogLevelWarn, "    kit import %s -t %s -f %s --revision %s", opts.repo, opts.tag, filepath.Join(tmpDir, constants.DefaultKitfileName), commitSHA)
 					output.Logf(output.LogLevelWarn, "to complete process")
 					return err
 				}
@@ -104,30 +119,77 @@ func importUsingGit(ctx context.Context, opts *importOptions) error {
 		}
 	}
 
+	// Only download LFS files that will be packed into the ModelKit
+	selectedFiles, err := filterListingForKitfile(dirContents, kitfile)
+	if err != nil {
+		return err
+	}
+	toDownload := selectLFSObjects(lfsObjects, selectedFiles)
+	if len(toDownload) > 0 {
+		output.Infof("Downloading %d of %d Git LFS files", len(toDownload), len(lfsObjects))
+	}
+	if err := git.DownloadLFSObjects(ctx, repoURL, opts.token, tmpDir, toDownload, opts.concurrency, opts.rateLimiter); err != nil {
+		return fmt.Errorf("error downloading Git LFS files: %w", err)
+	}
+
 	output.Infof("Packing model to %s", opts.tag)
-	if err := packDirectory(ctx, opts.configHome, tmpDir, kitfile, opts.modelKitRef); err != nil {
+	annotations := map[string]string{
+		ocispec.AnnotationSource:   repoURL,
+		ocispec.AnnotationRevision: commitSHA,
+	}
+	if err := packDirectory(ctx, opts.configHome, tmpDir, kitfile, opts.modelKitRef, annotations); err != nil {
 		return fmt.Errorf("failed to pack ModelKit: %w", err)
 	}
 	output.Infof("Model is packed as %s", opts.tag)
 
-	if err := cache.CleanCacheDir(cache.CacheImportSubdir); err != nil {
-		output.Logf(output.LogLevelWarn, "Failed to clean cache directory: %s", err)
-	}
-
 	return nil
 }
 
-func cloneRepository(repo, destDir, token string) error {
-	fullRepo := repo
-	if !strings.HasPrefix(fullRepo, "http") {
-		fullRepo = fmt.Sprintf("https://huggingface.co/%s", repo)
-	}
-	if err := git.CloneRepository(fullRepo, destDir, token); err != nil {
-		return err
+func cloneRepository(ctx context.Context, repoURL, destDir, token, revision string) (string, error) {
+	commitSHA, err := git.CloneRepository(ctx, repoURL, destDir, token, revision)
+	if err != nil {
+		return "", err
 	}
 	// Clean up git-related files, since we probably don't want those
 	if err := git.CleanGitMetadata(destDir); err != nil {
-		return err
+		return "", err
 	}
-	return nil
+	return commitSHA, nil
+}
+
+// applyLFSObjectsToListing updates the size and digest of files in listing that are Git LFS pointers to match
+// the LFS object they point to.
+func applyLFSObjectsToListing(listing *kfgen.DirectoryListing, objects []git.LFSObject) {
+	byPath := map[string]git.LFSObject{}
+	for _, obj := range objects {
+		byPath[obj.Path] = obj
+	}
+	var processDir func(dir *kfgen.DirectoryListing)
+	processDir = func(dir *kfgen.DirectoryListing) {
+		for idx, file := range dir.Files {
+			if obj, ok := byPath[file.Path]; ok {
+				dir.Files[idx].Size = obj.Size
+				dir.Files[idx].SHA256 = obj.OID
+			}
+		}
+		for idx := range dir.Subdirs {
+			processDir(&dir.Subdirs[idx])
+		}
+	}
+	processDir(listing)
+}
+
+// selectLFSObjects returns the LFS objects whose paths are included in files
+func selectLFSObjects(objects []git.LFSObject, files []kfgen.FileListing) []git.LFSObject {
+	selected := map[string]bool{}
+	for _, file := range files {
+		selected[file.Path] = true
+	}
+	var result []git.LFSObject
+	for _, obj := range objects {
+		if selected[obj.Path] {
+			result = append(result, obj)
+		}
+	}
+	return result
 }
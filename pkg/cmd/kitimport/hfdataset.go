@@ -0,0 +1,109 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kitimport
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"kitops/pkg/artifact"
+	"kitops/pkg/lib/hf"
+	kfgen "kitops/pkg/lib/kitfile/generate"
+)
+
+// Dataset card fields that are copied to the parameters of each dataset in a generated Kitfile
+var datasetCardParameters = []string{
+	"pretty_name", "task_categories", "task_ids", "language", "tags", "size_categories", "source_datasets",
+}
+
+func generateDatasetKitfile(dirContents *kfgen.DirectoryListing, repo string, cardData map[string]any, outDir string) (*artifact.KitFile, error) {
+	kitfile, err := kfgen.GenerateDatasetKitfile(dirContents, packageForRepo(repo))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate Kitfile: %w", err)
+	}
+	applyDatasetCard(kitfile, cardData)
+	if err := writeGeneratedKitfile(kitfile, outDir); err != nil {
+		return nil, err
+	}
+	return kitfile, nil
+}
+
+// applyDatasetCard adds metadata from a HuggingFace dataset card to the datasets in a Kitfile. The
+// license from the card is applied to each dataset (or the package, if there are none), selected card
+// fields are added to each dataset's parameters, and datasets are matched to the configs declared in
+// the card by path.
+func applyDatasetCard(kitfile *artifact.KitFile, cardData map[string]any) {
+	if len(cardData) == 0 {
+		return
+	}
+	license := hf.CardLicense(cardData)
+	if license != "" && len(kitfile.DataSets) == 0 {
+		kitfile.Package.License = license
+	}
+	configs := hf.DatasetConfigs(cardData)
+
+	for idx := range kitfile.DataSets {
+		dataset := &kitfile.DataSets[idx]
+		if license != "" && dataset.License == "" {
+			dataset.License = license
+		}
+		params, ok := dataset.Parameters.(map[string]any)
+		if !ok {
+			if dataset.Parameters != nil {
+				continue
+			}
+			params = map[string]any{}
+		}
+		for _, key := range datasetCardParameters {
+			values := hf.CardStrings(cardData, key)
+			switch {
+			case len(values) == 0:
+				continue
+			case key == "pretty_name":
+				params[key] = values[0]
+			default:
+				params[key] = values
+			}
+		}
+		if config := matchDatasetConfig(dataset.Path, configs); config != "" {
+			params["config"] = config
+		}
+		if len(params) > 0 {
+			dataset.Parameters = params
+		}
+	}
+}
+
+// matchDatasetConfig returns the name of the first config whose data files are in datasetPath. Configs
+// are matched if one of their data file patterns matches datasetPath directly (for single files) or if
+// the directory part of a pattern is within datasetPath.
+func matchDatasetConfig(datasetPath string, configs []hf.DatasetConfig) string {
+	for _, config := range configs {
+		for _, pattern := range config.DataFiles {
+			pattern = strings.TrimPrefix(pattern, "./")
+			if matched, err := path.Match(pattern, datasetPath); err == nil && matched {
+				return config.Name
+			}
+			patternDir := path.Dir(pattern)
+			if patternDir == datasetPath || strings.HasPrefix(patternDir, datasetPath+"/") {
+				return config.Name
+			}
+		}
+	}
+	return ""
+}
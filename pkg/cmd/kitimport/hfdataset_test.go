@@ -0,0 +1,154 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kitimport
+
+import (
+	"testing"
+
+	"kitops/pkg/artifact"
+	"kitops/pkg/lib/hf"
+	kfgen "kitops/pkg/lib/kitfile/generate"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestGenerateDatasetKitfile(t *testing.T) {
+	listing := &kfgen.DirectoryListing{
+		Name: ".",
+		Path: ".",
+		Files: []kfgen.FileListing{
+			{Name: "README.md", Path: "README.md"},
+			{Name: "extra.csv", Path: "extra.csv"},
+		},
+		Subdirs: []kfgen.DirectoryListing{
+			{
+				Name: "data",
+				Path: "data",
+				Files: []kfgen.FileListing{
+					{Name: "train-00000-of-00002.parquet", Path: "data/train-00000-of-00002.parquet"},
+					{Name: "train-00001-of-00002.parquet", Path: "data/train-00001-of-00002.parquet"},
+					{Name: "test-00000-of-00001.parquet", Path: "data/test-00000-of-00001.parquet"},
+				},
+			},
+			{
+				Name: "en",
+				Path: "en",
+				Subdirs: []kfgen.DirectoryListing{
+					{
+						Name: "validation",
+						Path: "en/validation",
+						Files: []kfgen.FileListing{
+							{Name: "data-00000-of-00001.arrow", Path: "en/validation/data-00000-of-00001.arrow"},
+							{Name: "state.json", Path: "en/validation/state.json"},
+						},
+					},
+				},
+			},
+		},
+	}
+	cardData := map[string]any{
+		"license":         []any{"apache-2.0"},
+		"pretty_name":     "Test dataset",
+		"task_categories": []any{"text-classification"},
+		"configs": []any{
+			map[string]any{
+				"config_name": "default",
+				"data_files": []any{
+					map[string]any{"split": "train", "path": "data/train-*"},
+					map[string]any{"split": "test", "path": "data/test-*"},
+				},
+			},
+			map[string]any{
+				"config_name": "en",
+				"data_files":  "en/validation/*.arrow",
+			},
+		},
+	}
+
+	kitfile, err := kfgen.GenerateDatasetKitfile(listing, packageForRepo("org/dataset"))
+	require.NoError(t, err)
+	applyDatasetCard(kitfile, cardData)
+
+	assert.Equal(t, []artifact.Docs{{Path: "README.md", Description: "Readme file"}}, kitfile.Docs)
+	assert.Empty(t, kitfile.Code)
+	require.Len(t, kitfile.DataSets, 3)
+	assert.Equal(t, artifact.DataSet{
+		Name:    "extra",
+		Path:    "extra.csv",
+		License: "Apache-2.0",
+		Parameters: map[string]any{
+			"format":          "csv",
+			"shards":          1,
+			"splits":          []string{"extra"},
+			"pretty_name":     "Test dataset",
+			"task_categories": []string{"text-classification"},
+		},
+	}, kitfile.DataSets[0])
+	assert.Equal(t, artifact.DataSet{
+		Name:    "data",
+		Path:    "data",
+		License: "Apache-2.0",
+		Parameters: map[string]any{
+			"format":          "parquet",
+			"shards":          3,
+			"splits":          []string{"test", "train"},
+			"config":          "default",
+			"pretty_name":     "Test dataset",
+			"task_categories": []string{"text-classification"},
+		},
+	}, kitfile.DataSets[1])
+	assert.Equal(t, "en/validation", kitfile.DataSets[2].Path)
+	assert.Equal(t, "arrow", kitfile.DataSets[2].Parameters.(map[string]any)["format"])
+	assert.Equal(t, []string{"validation"}, kitfile.DataSets[2].Parameters.(map[string]any)["splits"])
+	assert.Equal(t, "en", kitfile.DataSets[2].Parameters.(map[string]any)["config"])
+
+	// Generated Kitfile should be valid and serializable
+	kitfileBytes, err := kitfile.MarshalToYAML()
+	require.NoError(t, err)
+	roundTrip := map[string]any{}
+	require.NoError(t, yaml.Unmarshal(kitfileBytes, &roundTrip))
+}
+
+func TestExtractHFRepoFromURL(t *testing.T) {
+	testcases := []struct {
+		input        string
+		expected     string
+		expectedType hf.RepoType
+		expectErr    bool
+	}{
+		{input: "org/repo", expected: "org/repo", expectedType: hf.RepoTypeModel},
+		{input: "https://huggingface.co/org/repo", expected: "org/repo", expectedType: hf.RepoTypeModel},
+		{input: "datasets/org/repo", expected: "org/repo", expectedType: hf.RepoTypeDataset},
+		{input: "https://huggingface.co/datasets/org/repo/", expected: "org/repo", expectedType: hf.RepoTypeDataset},
+		{input: "datasets/repo", expectErr: true},
+		{input: "https://huggingface.co/datasets/org/repo/tree/main", expectErr: true},
+	}
+	for _, tt := range testcases {
+		t.Run(tt.input, func(t *testing.T) {
+			repo, repoType, err := extractHFRepoFromURL(tt.input)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, repo)
+			assert.Equal(t, tt.expectedType, repoType)
+		})
+	}
+}
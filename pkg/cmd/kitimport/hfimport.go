@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"kitops/pkg/artifact"
 	"kitops/pkg/lib/constants"
@@ -33,27 +34,49 @@ import (
 	repoutil "kitops/pkg/lib/repo/util"
 	"kitops/pkg/lib/util"
 	"kitops/pkg/output"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
 func importUsingHF(ctx context.Context, opts *importOptions) error {
 	// Handle full HF URLs by extracting repository name from URL
-	repo, err := extractRepoFromURL(opts.repo)
+	repo, repoType, err := extractHFRepoFromURL(opts.repo)
 	if err != nil {
 		return fmt.Errorf("could not process URL %s: %w", opts.repo, err)
 	}
 
-	tmpDir, cleanupTmp, err := cache.MkCacheDir("import", "")
+	revision := opts.revision
+	if revision == "" {
+		revision = hf.DefaultRevision
+	}
+	// Resolve the revision to a commit up front so that all files are listed and downloaded from the
+	// same snapshot, even if e.g. a branch is updated while the import is running.
+	token := opts.token
+	if token == "" {
+		token = hf.LookupToken(ctx)
+	}
+	hfClient := hf.NewClient(opts.hfEndpoint, token)
+	if hfClient.Endpoint() != hf.DefaultEndpoint {
+		output.Infof("Using HuggingFace endpoint %s", hfClient.Endpoint())
+	}
+
+	revInfo, err := hfClient.ResolveRevision(ctx, repo, repoType, revision)
+	if err != nil {
+		return fmt.Errorf("failed to resolve revision %s: %w", revision, err)
+	}
+	commitSHA := revInfo.SHA
+	output.Infof("Importing %s %s at revision %s (commit %s)", repoType, repo, revision, commitSHA)
+
+	// Use a directory specific to this commit so that downloads can be resumed if the import is interrupted.
+	// The directory is only removed once the import completes successfully.
+	cacheKey := fmt.Sprintf("hf_%s_%s_%s", repoType, strings.ReplaceAll(repo, "/", "_"), commitSHA)
+	tmpDir, cleanupTmp, err := cache.MkCacheDir(ctx, cache.CacheImportSubdir, cacheKey)
 	if err != nil {
 		return fmt.Errorf("failed to create temporary directory: %w", err)
 	}
-	doCleanup := true
-	defer func() {
-		if doCleanup {
-			cleanupTmp()
-		}
-	}()
+	output.Debugf("Downloading files to %s", tmpDir)
 
-	dirListing, err := hf.ListFiles(ctx, repo, opts.token)
+	dirListing, err := hfClient.ListFiles(ctx, repo, repoType, commitSHA)
 	if err != nil {
 		return fmt.Errorf("failed to list files from HuggingFace API: %w", err)
 	}
@@ -74,7 +97,12 @@ func importUsingHF(ctx context.Context, opts *importOptions) error {
 		}
 		kitfile = kf
 	} else {
-		kf, err := generateKitfile(dirListing, repo, tmpDir)
+		var kf *artifact.KitFile
+		if repoType == hf.RepoTypeDataset {
+			kf, err = generateDatasetKitfile(dirListing, repo, revInfo.CardData, tmpDir)
+		} else {
+			kf, err = generateKitfile(dirListing, repo, tmpDir)
+		}
 		if err != nil {
 			return err
 		}
@@ -86,13 +114,12 @@ func importUsingHF(ctx context.Context, opts *importOptions) error {
 			newKitfile, err := promptToEditKitfile(tmpDir, kf)
 			if err != nil {
 				if errors.Is(err, ErrNoEditorFound) {
-					doCleanup = false
 					kfPath := filepath.Join(tmpDir, constants.DefaultKitfileName)
 					output.Logf(output.LogLevelWarn, "Could not determine default editor from $EDITOR environment variable")
 					output.Logf(output.LogLevelWarn, "Please manually edit Kitfile at path")
 					output.Logf(output.LogLevelWarn, "    %s", kfPath)
 					output.Logf(output.LogLevelWarn, "and run command")
-					output.Logf(output.LogLevelWarn, "    kit import %s -t %s -f %s", opts.repo, opts.tag, kfPath)
+					output.Logf(output.LogLevelWarn, "    kit import %s -t %s -f %s --revision %s", opts.repo, opts.tag, kfPath, commitSHA)
 					output.Logf(output.LogLevelWarn, "to complete process")
 					return err
 				}
@@ -106,24 +133,26 @@ func importUsingHF(ctx context.Context, opts *importOptions) error {
 	if err != nil {
 		return err
 	}
-	if err := hf.DownloadFiles(ctx, repo, tmpDir, toDownload, opts.token, opts.concurrency); err != nil {
+	if err := hfClient.DownloadFiles(ctx, repo, repoType, commitSHA, tmpDir, toDownload, opts.concurrency, opts.rateLimiter); err != nil {
 		return fmt.Errorf("error downloading repository: %w", err)
 	}
 
 	output.Infof("Packing model to %s", opts.tag)
-	if err := packDirectory(ctx, opts.configHome, tmpDir, kitfile, opts.modelKitRef); err != nil {
+	annotations := map[string]string{
+		ocispec.AnnotationSource:   hfClient.RepoURL(repo, repoType),
+		ocispec.AnnotationRevision: commitSHA,
+	}
+	if err := packDirectory(ctx, opts.configHome, tmpDir, kitfile, opts.modelKitRef, annotations); err != nil {
 		return fmt.Errorf("failed to pack ModelKit: %w", err)
 	}
 	output.Infof("Model is packed as %s", opts.tag)
 
-	if err := cache.CleanCacheDir(cache.CacheImportSubdir); err != nil {
-		output.Logf(output.LogLevelWarn, "Failed to clean cache directory: %s", err)
-	}
+	cleanupTmp()
 
 	return nil
 }
 
-func filterListingForKitfile(contents *kfgen.DirectoryListing, kitfile *artifact.KitFile) ([]string, error) {
+func filterListingForKitfile(contents *kfgen.DirectoryListing, kitfile *artifact.KitFile) ([]kfgen.FileListing, error) {
 	// Repurpose the ignore implementation to find which files we need to download and which ones we can skip.
 	// This works because ignore is designed to _also_ ignore paths that are packed as part of another layer
 	// instead of the current one.
@@ -133,12 +162,12 @@ func filterListingForKitfile(contents *kfgen.DirectoryListing, kitfile *artifact
 	}
 
 	hasCatchall := kitfileHasCatchallLayer(kitfile)
-	var pathsToDownload []string
+	var filesToDownload []kfgen.FileListing
 	var processDir func(dir *kfgen.DirectoryListing) error
 	processDir = func(dir *kfgen.DirectoryListing) error {
 		for _, file := range dir.Files {
 			if hasCatchall {
-				pathsToDownload = append(pathsToDownload, file.Path)
+				filesToDownload = append(filesToDownload, file)
 				continue
 			}
 			matches, err := ignore.Matches(file.Path, "")
@@ -146,7 +175,7 @@ func filterListingForKitfile(contents *kfgen.DirectoryListing, kitfile *artifact
 				return fmt.Errorf("failed to process path %s: %w", file.Path, err)
 			}
 			if matches {
-				pathsToDownload = append(pathsToDownload, file.Path)
+				filesToDownload = append(filesToDownload, file)
 			}
 		}
 		for _, subDir := range dir.Subdirs {
@@ -160,7 +189,7 @@ func filterListingForKitfile(contents *kfgen.DirectoryListing, kitfile *artifact
 		return nil, err
 	}
 
-	return pathsToDownload, nil
+	return filesToDownload, nil
 }
 
 func kitfileHasCatchallLayer(kitfile *artifact.KitFile) bool {
@@ -0,0 +1,246 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kitimport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"kitops/pkg/artifact"
+	"kitops/pkg/lib/constants"
+	"kitops/pkg/lib/filesystem"
+	"kitops/pkg/lib/filesystem/cache"
+	kfutils "kitops/pkg/lib/kitfile"
+	"kitops/pkg/lib/mlflow"
+	"kitops/pkg/lib/util"
+	"kitops/pkg/output"
+)
+
+var invalidTagCharsRegexp = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+func importUsingMLflow(ctx context.Context, opts *importOptions) error {
+	if opts.revision != "" {
+		return fmt.Errorf("the --revision flag is not supported when importing from MLflow")
+	}
+	srcDir, err := mlflow.LocalPath(opts.repo)
+	if err != nil {
+		return err
+	}
+	if stat, err := os.Stat(srcDir); err != nil {
+		return fmt.Errorf("failed to read MLflow model at %s: %w", srcDir, err)
+	} else if !stat.IsDir() {
+		return fmt.Errorf("MLflow model path %s is not a directory", srcDir)
+	}
+	modelDir, err := findMLflowModelDir(srcDir)
+	if err != nil {
+		return err
+	}
+	mlmodel, err := mlflow.ReadMLModel(filepath.Join(srcDir, filepath.FromSlash(modelDir)))
+	if err != nil {
+		return err
+	}
+	output.Infof("Importing MLflow %s model from %s", mlmodel.Flavor(), filepath.Join(srcDir, filepath.FromSlash(modelDir)))
+
+	var kitfile *artifact.KitFile
+	if opts.kitfilePath == "-" {
+		kitfile = &artifact.KitFile{}
+		if err := kitfile.LoadModel(os.Stdin); err != nil {
+			return fmt.Errorf("failed to read Kitfile from input: %w", err)
+		}
+		if err := kfutils.ValidateKitfile(kitfile); err != nil {
+			return err
+		}
+	} else if opts.kitfilePath != "" {
+		kf, err := readExistingKitfile(opts.kitfilePath)
+		if err != nil {
+			return err
+		}
+		kitfile = kf
+	} else if kfpath, err := filesystem.FindKitfileInPath(srcDir); err == nil {
+		kf, err := readExistingKitfile(kfpath)
+		if err != nil {
+			return err
+		}
+		kitfile = kf
+	} else {
+		// The generated Kitfile is written to a temporary directory rather than the MLflow directory, so
+		// that importing does not modify the artifact store.
+		tmpDir, cleanupTmp, err := cache.MkCacheDir(ctx, cache.CacheImportSubdir, "")
+		if err != nil {
+			return err
+		}
+		doCleanup := true
+		defer func() {
+			if doCleanup {
+				cleanupTmp()
+			}
+		}()
+
+		kf, err := generateMLflowKitfile(srcDir, modelDir, mlmodel)
+		if err != nil {
+			return err
+		}
+		if err := writeGeneratedKitfile(kf, tmpDir); err != nil {
+			return err
+		}
+		kitfile = kf
+
+		if util.IsInteractiveSession() {
+			newKitfile, err := promptToEditKitfile(tmpDir, kf)
+			if err != nil {
+				if errors.Is(err, ErrNoEditorFound) {
+					doCleanup = false
+					kfPath := filepath.Join(tmpDir, constants.DefaultKitfileName)
+					output.Logf(output.LogLevelWarn, "Could not determine default editor from $EDITOR environment variable")
+					output.Logf(output.LogLevelWarn, "Please manually edit Kitfile at path")
+					output.Logf(output.LogLevelWarn, "    %s", kfPath)
+					output.Logf(output.LogLevelWarn, "and run command")
+					output.Logf(output.LogLevelWarn, "    kit import %s -t %s -f %s --tool mlflow", opts.repo, opts.tag, kfPath)
+					output.Logf(output.LogLevelWarn, "to complete process")
+					return err
+				}
+				return err
+			}
+			kitfile = newKitfile
+		}
+	}
+
+	output.Infof("Packing model to %s", opts.tag)
+	if err := packDirectory(ctx, opts.configHome, srcDir, kitfile, opts.modelKitRef, nil); err != nil {
+		return fmt.Errorf("failed to pack ModelKit: %w", err)
+	}
+	output.Infof("Model is packed as %s", opts.tag)
+
+	return nil
+}
+
+// findMLflowModelDir returns the path of the MLflow model directory within srcDir, which may be either a model
+// directory or an artifact directory containing a single model.
+func findMLflowModelDir(srcDir string) (string, error) {
+	modelDirs, err := mlflow.FindModelDirs(srcDir)
+	if err != nil {
+		return "", err
+	}
+	switch len(modelDirs) {
+	case 0:
+		return "", fmt.Errorf("no MLflow model (%s file) found in %s", mlflow.MLmodelFileName, srcDir)
+	case 1:
+		return modelDirs[0], nil
+	default:
+		return "", fmt.Errorf("found multiple MLflow models in %s (%s): specify the path to a single model directory", srcDir, strings.Join(modelDirs, ", "))
+	}
+}
+
+// generateMLflowKitfile generates a Kitfile for the MLflow model in modelDir, relative to contextDir. The
+// model's flavors, signature, and input example are stored in the model's parameters, and its requirements
+// and conda environment are included as code.
+func generateMLflowKitfile(contextDir, modelDir string, mlmodel *mlflow.MLModel) (*artifact.KitFile, error) {
+	name := mlmodel.ArtifactPath
+	if name == "" {
+		name = filepath.Base(filepath.Join(contextDir, filepath.FromSlash(modelDir)))
+	}
+
+	parameters := map[string]any{
+		"flavor":  mlmodel.Flavor(),
+		"flavors": mlmodel.Flavors,
+	}
+	if mlmodel.Signature != nil {
+		signature, err := mlmodel.Signature.Parse()
+		if err != nil {
+			return nil, err
+		}
+		parameters["signature"] = signature
+	}
+	if mlmodel.SavedInputExampleInfo != nil {
+		parameters["inputExample"] = mlmodel.SavedInputExampleInfo
+	}
+	if mlmodel.Metadata != nil {
+		parameters["metadata"] = mlmodel.Metadata
+	}
+	if mlmodel.MLflowVersion != "" {
+		parameters["mlflowVersion"] = mlmodel.MLflowVersion
+	}
+	if mlmodel.RunID != "" {
+		parameters["runId"] = mlmodel.RunID
+	}
+	if mlmodel.ModelUUID != "" {
+		parameters["modelUuid"] = mlmodel.ModelUUID
+	}
+
+	kitfile := &artifact.KitFile{
+		ManifestVersion: "1.0.0",
+		Package: artifact.Package{
+			Name: name,
+		},
+		Model: &artifact.Model{
+			Name:       name,
+			Path:       modelDir,
+			Framework:  mlmodel.Framework(),
+			Parameters: parameters,
+		},
+	}
+	if mlmodel.RunID != "" {
+		kitfile.Package.Description = fmt.Sprintf("MLflow model logged in run %s", mlmodel.RunID)
+	}
+
+	envFiles := []struct {
+		path        string
+		description string
+	}{
+		{mlflow.RequirementsFileName, "Python requirements for the model"},
+		{mlmodel.CondaEnvFile(), "Conda environment for the model"},
+	}
+	for _, envFile := range envFiles {
+		relPath := path.Join(modelDir, envFile.path)
+		if _, err := os.Stat(filepath.Join(contextDir, filepath.FromSlash(relPath))); err != nil {
+			continue
+		}
+		kitfile.Code = append(kitfile.Code, artifact.Code{
+			Path:        relPath,
+			Description: envFile.description,
+		})
+	}
+
+	if err := kfutils.ValidateKitfile(kitfile); err != nil {
+		return nil, fmt.Errorf("failed to generate Kitfile: %w", err)
+	}
+	return kitfile, nil
+}
+
+// defaultMLflowTag returns a tag for a ModelKit imported from location, based on the name of the directory.
+func defaultMLflowTag(location string) (string, error) {
+	srcDir, err := mlflow.LocalPath(location)
+	if err != nil {
+		return "", err
+	}
+	absPath, err := filepath.Abs(srcDir)
+	if err != nil {
+		return "", err
+	}
+	name := invalidTagCharsRegexp.ReplaceAllString(strings.ToLower(filepath.Base(absPath)), "-")
+	name = strings.Trim(name, "._-")
+	if name == "" {
+		return "", fmt.Errorf("could not generate name from path %s", srcDir)
+	}
+	return name, nil
+}
@@ -0,0 +1,237 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kitimport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"kitops/pkg/artifact"
+	"kitops/pkg/lib/filesystem/cache"
+	kfutils "kitops/pkg/lib/kitfile"
+	"kitops/pkg/lib/ollama"
+	"kitops/pkg/output"
+)
+
+// ollamaLayerFile describes how a layer in an Ollama model is stored in the ModelKit
+type ollamaLayerFile struct {
+	name string
+	ext  string
+	// partType is the type of the model part used for the layer. If empty, the layer is stored as docs.
+	partType string
+}
+
+var ollamaLayerFiles = map[string]ollamaLayerFile{
+	ollama.MediaTypeModel:     {name: "model", ext: ".gguf"},
+	ollama.MediaTypeAdapter:   {name: "adapter", ext: ".gguf", partType: "adapter"},
+	ollama.MediaTypeProjector: {name: "projector", ext: ".gguf", partType: "projector"},
+	ollama.MediaTypeTemplate:  {name: "template", ext: ".tmpl", partType: "template"},
+	ollama.MediaTypeSystem:    {name: "system", ext: ".txt", partType: "system"},
+	ollama.MediaTypeParams:    {name: "params", ext: ".json", partType: "params"},
+	ollama.MediaTypeMessages:  {name: "messages", ext: ".json", partType: "messages"},
+	ollama.MediaTypeLicense:   {name: "LICENSE"},
+}
+
+func importUsingOllama(ctx context.Context, opts *importOptions) error {
+	if opts.revision != "" {
+		return fmt.Errorf("the --revision flag is not supported when importing from Ollama")
+	}
+	name, err := ollama.ParseModelName(opts.repo)
+	if err != nil {
+		return err
+	}
+	modelsDir, err := ollama.ModelsDir()
+	if err != nil {
+		return err
+	}
+	manifest, err := ollama.ReadManifest(modelsDir, name)
+	if err != nil {
+		return err
+	}
+	output.Infof("Importing Ollama model %s from %s", name, modelsDir)
+
+	tmpDir, cleanupTmp, err := cache.MkCacheDir(ctx, cache.CacheImportSubdir, "")
+	if err != nil {
+		return err
+	}
+	defer cleanupTmp()
+
+	kitfile, err := generateOllamaKitfile(modelsDir, name, manifest, tmpDir)
+	if err != nil {
+		return err
+	}
+	if opts.kitfilePath != "" {
+		// Files are named consistently, so a custom Kitfile can refer to them (e.g. to add a license)
+		if opts.kitfilePath == "-" {
+			kitfile = &artifact.KitFile{}
+			if err := kitfile.LoadModel(os.Stdin); err != nil {
+				return fmt.Errorf("failed to read Kitfile from input: %w", err)
+			}
+			if err := kfutils.ValidateKitfile(kitfile); err != nil {
+				return err
+			}
+		} else {
+			kf, err := readExistingKitfile(opts.kitfilePath)
+			if err != nil {
+				return err
+			}
+			kitfile = kf
+		}
+	} else if err := writeGeneratedKitfile(kitfile, tmpDir); err != nil {
+		return err
+	}
+
+	output.Infof("Packing model to %s", opts.tag)
+	if err := packDirectory(ctx, opts.configHome, tmpDir, kitfile, opts.modelKitRef, nil); err != nil {
+		return fmt.Errorf("failed to pack ModelKit: %w", err)
+	}
+	output.Infof("Model is packed as %s", opts.tag)
+
+	return nil
+}
+
+// generateOllamaKitfile links or copies the blobs for the Ollama model described by manifest into outDir and
+// returns a Kitfile for packing them. The GGUF weights are used as the model, while other layers (e.g. the
+// prompt template and parameters) are added as model parts with a type matching the layer. Licenses are
+// added as docs.
+func generateOllamaKitfile(modelsDir string, name ollama.ModelName, manifest *ollama.Manifest, outDir string) (*artifact.KitFile, error) {
+	kitfile := &artifact.KitFile{
+		ManifestVersion: "1.0.0",
+		Package: artifact.Package{
+			Name:    name.Model,
+			Version: name.Tag,
+		},
+	}
+	if name.Namespace != ollama.DefaultNamespace {
+		kitfile.Package.Authors = []string{name.Namespace}
+	}
+
+	// Parts are added to the model once all layers are processed, as the model layer may not be first
+	var parts []artifact.ModelPart
+	fileCounts := map[string]int{}
+	for _, layer := range manifest.Layers {
+		layerFile, ok := ollamaLayerFiles[layer.MediaType]
+		if !ok {
+			output.Logf(output.LogLevelWarn, "Skipping layer %s with unsupported media type %s", layer.Digest, layer.MediaType)
+			continue
+		}
+		fileCounts[layer.MediaType]++
+		fileName := layerFile.name + layerFile.ext
+		if count := fileCounts[layer.MediaType]; count > 1 {
+			fileName = fmt.Sprintf("%s-%d%s", layerFile.name, count, layerFile.ext)
+		}
+		blobPath, err := ollama.BlobPath(modelsDir, layer.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid layer in manifest for %s: %w", name, err)
+		}
+		if err := linkOrCopyBlob(blobPath, filepath.Join(outDir, fileName), layer.Size); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case layer.MediaType == ollama.MediaTypeModel:
+			if kitfile.Model != nil {
+				return nil, fmt.Errorf("models with multiple weights layers are not supported")
+			}
+			kitfile.Model = &artifact.Model{
+				Name:   name.Model,
+				Path:   fileName,
+				Format: "gguf",
+			}
+		case layerFile.partType != "":
+			parts = append(parts, artifact.ModelPart{
+				Name: strings.TrimSuffix(fileName, layerFile.ext),
+				Path: fileName,
+				Type: layerFile.partType,
+			})
+		default:
+			kitfile.Docs = append(kitfile.Docs, artifact.Docs{Path: fileName, Description: "License"})
+		}
+	}
+	if kitfile.Model == nil {
+		return nil, fmt.Errorf("model %s does not contain model weights", name)
+	}
+	kitfile.Model.Parts = parts
+
+	if config, err := ollama.ReadModelConfig(modelsDir, manifest); err != nil {
+		output.Debugf("Could not read model config: %s", err)
+	} else {
+		parameters := map[string]any{}
+		if config.ModelFamily != "" {
+			parameters["family"] = config.ModelFamily
+		}
+		if config.ModelType != "" {
+			parameters["parameterSize"] = config.ModelType
+		}
+		if config.FileType != "" {
+			parameters["quantization"] = config.FileType
+		}
+		if len(parameters) > 0 {
+			kitfile.Model.Parameters = parameters
+		}
+	}
+
+	if err := kfutils.ValidateKitfile(kitfile); err != nil {
+		return nil, fmt.Errorf("failed to generate Kitfile: %w", err)
+	}
+	return kitfile, nil
+}
+
+// linkOrCopyBlob hard links src to dest, falling back to copying it if linking is not possible (e.g. if
+// they are on different filesystems).
+func linkOrCopyBlob(src, dest string, expectedSize int64) error {
+	stat, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("failed to read blob: %w", err)
+	}
+	if stat.Size() != expectedSize {
+		return fmt.Errorf("blob %s has unexpected size: expected %d but got %d", src, expectedSize, stat.Size())
+	}
+	if err := os.Link(src, dest); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to read blob: %w", err)
+	}
+	defer in.Close()
+	out, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("failed to copy blob: %w", err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy blob: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to copy blob: %w", err)
+	}
+	return nil
+}
+
+// defaultOllamaTag returns a tag for a ModelKit imported from the Ollama model name
+func defaultOllamaTag(modelName string) (string, error) {
+	name, err := ollama.ParseModelName(modelName)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%s", name.Model, name.Tag), nil
+}
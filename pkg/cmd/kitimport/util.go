@@ -30,9 +30,11 @@ import (
 	"kitops/pkg/artifact"
 	"kitops/pkg/lib/constants"
 	"kitops/pkg/lib/filesystem"
+	"kitops/pkg/lib/hf"
 	kfutils "kitops/pkg/lib/kitfile"
 	kfgen "kitops/pkg/lib/kitfile/generate"
 	"kitops/pkg/lib/repo/local"
+	repoutil "kitops/pkg/lib/repo/util"
 	"kitops/pkg/lib/util"
 	"kitops/pkg/output"
 
@@ -42,29 +44,39 @@ import (
 var ErrNoEditorFound = errors.New("no editor found")
 
 func generateKitfile(dirContents *kfgen.DirectoryListing, repo string, outDir string) (*artifact.KitFile, error) {
-	// Fill fields in package so that they're not empty in `kit list` later.
-	sections := strings.Split(repo, "/")
-	var modelPackage *artifact.Package
-	if len(sections) >= 2 {
-		modelPackage = &artifact.Package{
-			Name:    sections[len(sections)-1],
-			Authors: []string{sections[len(sections)-2]},
-		}
-	}
-	kitfile, err := kfgen.GenerateKitfile(dirContents, modelPackage)
+	kitfile, err := kfgen.GenerateKitfile(dirContents, packageForRepo(repo))
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate Kitfile: %w", err)
 	}
+	if err := writeGeneratedKitfile(kitfile, outDir); err != nil {
+		return nil, err
+	}
+	return kitfile, nil
+}
+
+// packageForRepo fills fields in package so that they're not empty in `kit list` later.
+func packageForRepo(repo string) *artifact.Package {
+	sections := strings.Split(repo, "/")
+	if len(sections) < 2 {
+		return nil
+	}
+	return &artifact.Package{
+		Name:    sections[len(sections)-1],
+		Authors: []string{sections[len(sections)-2]},
+	}
+}
+
+func writeGeneratedKitfile(kitfile *artifact.KitFile, outDir string) error {
 	kitfileBytes, err := kitfile.MarshalToYAML()
 	if err != nil {
-		return nil, fmt.Errorf("failed to write Kitfile: %w", err)
+		return fmt.Errorf("failed to write Kitfile: %w", err)
 	}
 	kitfilePath := filepath.Join(outDir, constants.DefaultKitfileName)
 	if err := os.WriteFile(kitfilePath, kitfileBytes, 0644); err != nil {
-		return nil, fmt.Errorf("failed to write Kitfile: %s", err)
+		return fmt.Errorf("failed to write Kitfile: %s", err)
 	}
 	output.Infof("Generated Kitfile:\n\n%s\n", string(kitfileBytes))
-	return kitfile, nil
+	return nil
 }
 
 func readExistingKitfile(kfPath string) (*artifact.KitFile, error) {
@@ -83,22 +95,7 @@ func readExistingKitfile(kfPath string) (*artifact.KitFile, error) {
 	return kitfile, nil
 }
 
-func packDirectory(ctx context.Context, configHome, contextDir string, kitfile *artifact.KitFile, ref *registry.Reference) error {
-	// Packing requires the working dir to be the context dir so that relative paths are correct in the tarball
-	// On Windows, we need to switch back to the current directory or removing the temporary directory will fail
-	curDir, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("failed to get current directory: %w", err)
-	}
-	if err := os.Chdir(contextDir); err != nil {
-		return fmt.Errorf("failed to use context path %s: %w", contextDir, err)
-	}
-	defer func() {
-		if err := os.Chdir(curDir); err != nil {
-			output.Logf(output.LogLevelWarn, "Failed to change directory to %s: %s", curDir, err)
-		}
-	}()
-
+func packDirectory(ctx context.Context, configHome, contextDir string, kitfile *artifact.KitFile, ref *registry.Reference, annotations map[string]string) error {
 	localRepo, err := local.NewLocalRepo(constants.StoragePath(configHome), ref)
 	if err != nil {
 		return err
@@ -107,13 +104,14 @@ func packDirectory(ctx context.Context, configHome, contextDir string, kitfile *
 	if err != nil {
 		return err
 	}
-	manifestDesc, err := kfutils.SaveModel(ctx, localRepo, kitfile, ignore, constants.NoneCompression)
+	manifestDesc, err := kfutils.SaveModel(ctx, localRepo, kitfile, contextDir, ignore, constants.NoneCompression, annotations)
 	if err != nil {
 		return err
 	}
 	if err := localRepo.Tag(ctx, *manifestDesc, ref.Reference); err != nil {
 		return fmt.Errorf("failed to tag manifest: %w", err)
 	}
+	repoutil.SetModelKitResult(ctx, localRepo, ref, *manifestDesc)
 	return nil
 }
 
@@ -162,6 +160,22 @@ func getEditorName() (string, error) {
 	return "", ErrNoEditorFound
 }
 
+// extractHFRepoFromURL extracts a HuggingFace repository and its type from a URL or repository name. Dataset
+// repositories are prefixed with 'datasets/', e.g. 'datasets/org/repo' or 'https://huggingface.co/datasets/org/repo'.
+func extractHFRepoFromURL(rawUrl string) (string, hf.RepoType, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", hf.RepoTypeModel, fmt.Errorf("failed to parse url: %w", err)
+	}
+	if datasetPath, ok := strings.CutPrefix(strings.Trim(u.Path, "/"), "datasets/"); ok {
+		u.Path = datasetPath
+		repo, err := extractRepoFromURL(u.String())
+		return repo, hf.RepoTypeDataset, err
+	}
+	repo, err := extractRepoFromURL(rawUrl)
+	return repo, hf.RepoTypeModel, err
+}
+
 // extractRepoFromURL attempts to normalize a string or URL into a repository name as is used on GitHub and Huggingface.
 // Returns an error we cannot automatically handle the input URL/string.
 //   - https://example.com/segment1/segment2 --> segment1/segment2
@@ -23,6 +23,8 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
+	"text/tabwriter"
 
 	"kitops/pkg/artifact"
 	"kitops/pkg/lib/constants"
@@ -39,6 +41,12 @@ const (
 based on common file formats. Any files whose type (i.e. model, dataset, etc.)
 cannot be determined will be included in a code layer.
 
+Directories are classified by their contents, including the contents of any
+subdirectories. A directory that contains only one type of file, or where one
+type makes up most of the directory's size, is added as a single layer. A
+directory with mixed contents is split into several layers by type. Use
+--explain to show why each path was added as its layer type.
+
 By default the command will prompt for input for a name and description for the Kitfile`
 	example = `# Generate a Kitfile for the current directory:
 kit init .
@@ -47,7 +55,10 @@ kit init .
 kit init ./my-model --name "mymodel" --desc "This is my model's description"
 
 # Generate a Kitfile, overwriting any existing Kitfile:
-kit init ./my-model --force`
+kit init ./my-model --force
+
+# Generate a Kitfile and show why each path was added as its layer type:
+kit init ./my-model --explain`
 )
 
 type initOptions struct {
@@ -57,6 +68,7 @@ type initOptions struct {
 	modelkitDescription string
 	modelkitAuthor      string
 	overwrite           bool
+	explain             bool
 }
 
 func InitCommand() *cobra.Command {
@@ -75,6 +87,7 @@ func InitCommand() *cobra.Command {
 	cmd.Flags().StringVar(&opts.modelkitDescription, "desc", "", "Description for the ModelKit")
 	cmd.Flags().StringVar(&opts.modelkitAuthor, "author", "", "Author for the ModelKit")
 	cmd.Flags().BoolVarP(&opts.overwrite, "force", "f", false, "Overwrite existing Kitfile if present")
+	cmd.Flags().BoolVar(&opts.explain, "explain", false, "Show why each path was added to the Kitfile as its layer type")
 	cmd.Flags().SortFlags = false
 	return cmd
 }
@@ -110,7 +123,7 @@ func runCommand(opts *initOptions) func(*cobra.Command, []string) error {
 		if err != nil {
 			return output.Fatalf("Error processing directory: %s", err)
 		}
-		kitfile, err := kfgen.GenerateKitfile(dirContents, modelPackage)
+		kitfile, explanations, err := kfgen.GenerateKitfileWithExplanations(dirContents, modelPackage)
 		if err != nil {
 			return output.Fatalf("Error generating Kitfile: %s", err)
 		}
@@ -123,10 +136,36 @@ func runCommand(opts *initOptions) func(*cobra.Command, []string) error {
 		}
 		output.Infof("Generated Kitfile:\n\n%s", string(bytes))
 		output.Infof("Saved to path '%s'", kitfilePath)
+		result := initResult{KitfilePath: kitfilePath, Kitfile: kitfile}
+		if opts.explain {
+			result.Explanations = explanations
+			if !output.StructuredOutput() {
+				output.Infof("Explanations:\n\n%s", formatExplanations(explanations))
+			}
+		}
+		output.SetResult(result)
 		return nil
 	}
 }
 
+// initResult is the structured result of the init command
+type initResult struct {
+	KitfilePath  string              `json:"kitfilePath"`
+	Kitfile      *artifact.KitFile   `json:"kitfile"`
+	Explanations []kfgen.Explanation `json:"explanations,omitempty"`
+}
+
+func formatExplanations(explanations []kfgen.Explanation) string {
+	buf := &strings.Builder{}
+	tw := tabwriter.NewWriter(buf, 0, 2, 3, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tLAYER\tREASON")
+	for _, e := range explanations {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", e.Path, e.Layer, e.Reason)
+	}
+	tw.Flush()
+	return buf.String()
+}
+
 func (opts *initOptions) complete(ctx context.Context, args []string) error {
 	configHome, ok := ctx.Value(constants.ConfigKey{}).(string)
 	if !ok {
@@ -20,15 +20,15 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"sort"
 	"text/tabwriter"
 
 	"kitops/pkg/cmd/options"
+	"kitops/pkg/kit"
 	"kitops/pkg/lib/constants"
-	"kitops/pkg/lib/repo/util"
 	"kitops/pkg/output"
 
 	"github.com/spf13/cobra"
-	"oras.land/oras-go/v2/registry"
 )
 
 const (
@@ -59,33 +59,22 @@ kit list registry.example.com/my-namespace/my-model`
 
 type listOptions struct {
 	options.NetworkOptions
-	configHome string
-	remoteRef  *registry.Reference
 }
 
-func (opts *listOptions) complete(ctx context.Context, args []string) error {
+func (opts *listOptions) complete(ctx context.Context, args []string) (*kit.ListOptions, error) {
 	configHome, ok := ctx.Value(constants.ConfigKey{}).(string)
 	if !ok {
-		return fmt.Errorf("default config path not set on command context")
+		return nil, fmt.Errorf("default config path not set on command context")
 	}
-	opts.configHome = configHome
-	if len(args) > 0 {
-		remoteRef, extraTags, err := util.ParseReference(args[0])
-		if err != nil {
-			return fmt.Errorf("invalid reference: %w", err)
-		}
-		if len(extraTags) > 0 {
-			return fmt.Errorf("repository cannot reference multiple tags")
-		}
-		opts.remoteRef = remoteRef
+	listOpts := &kit.ListOptions{
+		Options: kit.Options{ConfigHome: configHome},
+		Remote:  kit.NewRemoteOptions(&opts.NetworkOptions),
 	}
-
-	if err := opts.NetworkOptions.Complete(ctx, args); err != nil {
-		return err
+	if len(args) > 0 {
+		listOpts.Repository = args[0]
+		output.Debugf("Listing remote model kits in %s", args[0])
 	}
-
-	printConfig(opts)
-	return nil
+	return listOpts, nil
 }
 
 // ListCommand represents the models command
@@ -101,7 +90,7 @@ func ListCommand() *cobra.Command {
 	}
 
 	cmd.Args = cobra.MaximumNArgs(1)
-	opts.AddNetworkFlags(cmd)
+	options.AddNetworkFlags(cmd, &opts.NetworkOptions)
 	cmd.Flags().SortFlags = false
 
 	return cmd
@@ -109,30 +98,30 @@ func ListCommand() *cobra.Command {
 
 func runCommand(opts *listOptions) func(*cobra.Command, []string) error {
 	return func(cmd *cobra.Command, args []string) error {
-		if err := opts.complete(cmd.Context(), args); err != nil {
+		listOpts, err := opts.complete(cmd.Context(), args)
+		if err != nil {
 			return output.Fatalf("Invalid arguments: %s", err)
 		}
 
-		var allInfoLines []string
-		if opts.remoteRef == nil {
-			lines, err := listLocalKits(cmd.Context(), opts)
-			if err != nil {
-				return output.Fatalln(err)
-			}
-			allInfoLines = lines
-		} else {
-			lines, err := listRemoteKits(cmd.Context(), opts)
-			if err != nil {
-				return output.Fatalln(err)
-			}
-			allInfoLines = lines
+		allInfos, err := kit.List(cmd.Context(), *listOpts)
+		if err != nil {
+			return output.Fatalln(err)
 		}
-		printSummary(cmd.OutOrStdout(), allInfoLines)
+		if output.StructuredOutput() {
+			output.SetResult(allInfos)
+			return nil
+		}
+		printSummary(cmd.OutOrStdout(), allInfos)
 		return nil
 	}
 }
 
-func printSummary(w io.Writer, lines []string) {
+func printSummary(w io.Writer, infos []kit.ListedModelKit) {
+	var lines []string
+	for _, info := range infos {
+		lines = append(lines, formatModelKit(info)...)
+	}
+	sort.Strings(lines)
 	tw := tabwriter.NewWriter(w, 0, 2, 3, ' ', 0)
 	fmt.Fprintln(tw, listTableHeader)
 	for _, line := range lines {
@@ -140,9 +129,3 @@ func printSummary(w io.Writer, lines []string) {
 	}
 	tw.Flush()
 }
-
-func printConfig(opts *listOptions) {
-	if opts.remoteRef != nil {
-		output.Debugf("Listing remote model kits in %s", opts.remoteRef.String())
-	}
-}
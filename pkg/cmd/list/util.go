@@ -18,11 +18,10 @@ package list
 
 import (
 	"fmt"
+	"strings"
 
-	"kitops/pkg/artifact"
+	"kitops/pkg/kit"
 	"kitops/pkg/output"
-
-	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
 const (
@@ -30,54 +29,32 @@ const (
 	listTableFmt    = "%s\t%s\t%s\t%s\t%s\t%s"
 )
 
-type modelInfo struct {
-	repo      string
-	digest    string
-	tags      []string
-	modelName string
-	size      string
-	author    string
-}
-
-func (m *modelInfo) format() []string {
-	if len(m.tags) == 0 {
-		line := fmt.Sprintf(listTableFmt, m.repo, "<none>", m.author, m.modelName, m.size, m.digest)
+// formatModelKit returns the table lines for m, one per tag.
+func formatModelKit(m kit.ListedModelKit) []string {
+	author, modelName, size := orNone(m.Author), orNone(m.ModelName), output.FormatBytes(m.Size)
+	if len(m.Variants) > 0 {
+		modelName = fmt.Sprintf("%s (variants: %s)", modelName, strings.Join(m.Variants, ", "))
+	} else if m.Variant != "" {
+		modelName = fmt.Sprintf("%s (variant: %s)", modelName, m.Variant)
+	}
+	if m.Partial {
+		size = fmt.Sprintf("%s (partial)", size)
+	}
+	if len(m.Tags) == 0 {
+		line := fmt.Sprintf(listTableFmt, m.Repository, "<none>", author, modelName, size, m.Digest)
 		return []string{line}
 	}
 	var lines []string
-	for _, tag := range m.tags {
-		line := fmt.Sprintf(listTableFmt, m.repo, tag, m.author, m.modelName, m.size, m.digest)
+	for _, tag := range m.Tags {
+		line := fmt.Sprintf(listTableFmt, m.Repository, tag, author, modelName, size, m.Digest)
 		lines = append(lines, line)
 	}
 	return lines
 }
 
-func (m *modelInfo) fill(manifest *ocispec.Manifest, kitfile *artifact.KitFile) {
-	m.size = getModelSize(manifest)
-	m.author = getModelAuthor(kitfile)
-	m.modelName = getModelName(kitfile)
-}
-
-func getModelSize(manifest *ocispec.Manifest) string {
-	var size int64
-	for _, layer := range manifest.Layers {
-		size += layer.Size
-	}
-	return output.FormatBytes(size)
-}
-
-func getModelAuthor(kitfile *artifact.KitFile) string {
-	if len(kitfile.Package.Authors) > 0 {
-		return kitfile.Package.Authors[0]
-	} else {
+func orNone(s string) string {
+	if s == "" {
 		return "<none>"
 	}
-}
-
-func getModelName(kitfile *artifact.KitFile) string {
-	name := kitfile.Package.Name
-	if name == "" {
-		name = "<none>"
-	}
-	return name
+	return s
 }
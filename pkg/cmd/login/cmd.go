@@ -69,7 +69,7 @@ func LoginCommand() *cobra.Command {
 	cmd.Flags().StringVarP(&opts.username, "username", "u", "", "registry username")
 	cmd.Flags().StringVarP(&opts.password, "password", "p", "", "registry password or token")
 	cmd.Flags().BoolVar(&opts.passwordFromStdIn, "password-stdin", false, "read password from stdin")
-	opts.AddNetworkFlags(cmd)
+	options.AddNetworkFlags(cmd, &opts.NetworkOptions)
 	cmd.Flags().SortFlags = false
 
 	return cmd
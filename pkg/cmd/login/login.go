@@ -34,7 +34,7 @@ func login(ctx context.Context, opts *loginOptions) error {
 	if err != nil {
 		return err
 	}
-	registry, err := remote.NewRegistry(opts.registry, &opts.NetworkOptions)
+	registry, err := remote.NewRegistry(ctx, opts.registry, &opts.NetworkOptions)
 	if err != nil {
 		return fmt.Errorf("could not resolve registry %s: %w", opts.registry, err)
 	}
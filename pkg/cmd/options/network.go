@@ -19,26 +19,19 @@ package options
 import (
 	"context"
 	"fmt"
-	"os"
 
 	"kitops/pkg/lib/constants"
+	"kitops/pkg/lib/network"
 
 	"github.com/spf13/cobra"
 )
 
 // NetworkOptions represent common networking-related flags that are used by multiple commands.
 // The flags should be added to the command via AddNetworkFlags before running.
-type NetworkOptions struct {
-	PlainHTTP         bool
-	TLSVerify         bool
-	CredentialsPath   string
-	ClientCertPath    string
-	ClientCertKeyPath string
-	Concurrency       int
-	Proxy             string
-}
+type NetworkOptions = network.Options
 
-func (o *NetworkOptions) AddNetworkFlags(cmd *cobra.Command) {
+// AddNetworkFlags adds flags for connecting to remote registries to cmd, storing their values in o.
+func AddNetworkFlags(cmd *cobra.Command, o *NetworkOptions) {
 	cmd.Flags().BoolVar(&o.PlainHTTP, "plain-http", false, "Use plain HTTP when connecting to remote registries")
 	cmd.Flags().BoolVar(&o.TLSVerify, "tls-verify", true, "Require TLS and verify certificates when connecting to remote registries")
 	cmd.Flags().StringVar(&o.ClientCertPath, "cert", "",
@@ -49,30 +42,17 @@ func (o *NetworkOptions) AddNetworkFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVar(&o.Proxy, "proxy", "", "Proxy to use for connections (overrides proxy set by environment)")
 }
 
-func (o *NetworkOptions) Complete(ctx context.Context, args []string) error {
+// AddLimitRateFlag adds the --limit-rate flag to cmd. This should be used for commands that transfer
+// ModelKit contents.
+func AddLimitRateFlag(cmd *cobra.Command, o *NetworkOptions) {
+	cmd.Flags().StringVar(&o.LimitRate, "limit-rate", "", "Maximum combined transfer rate for all uploads/downloads (e.g. 50MB/s, 512KiB/s)")
+}
+
+// CompleteNetworkOptions completes o using the config path set on the command context.
+func CompleteNetworkOptions(ctx context.Context, o *NetworkOptions) error {
 	configHome, ok := ctx.Value(constants.ConfigKey{}).(string)
 	if !ok {
 		return fmt.Errorf("default config path not set on command context")
 	}
-	o.CredentialsPath = constants.CredentialsPath(configHome)
-
-	if certPath := os.Getenv(constants.ClientCertEnvVar); certPath != "" {
-		o.ClientCertPath = certPath
-	}
-	if certKeyPath := os.Getenv(constants.ClientCertKeyEnvVar); certKeyPath != "" {
-		o.ClientCertKeyPath = certKeyPath
-	}
-	if o.Concurrency < 1 {
-		return fmt.Errorf("invalid argument for concurrency (%d): must be at least 1", o.Concurrency)
-	}
-
-	return nil
-}
-
-func DefaultNetworkOptions(configHome string) *NetworkOptions {
-	return &NetworkOptions{
-		PlainHTTP:       false,
-		TLSVerify:       true,
-		CredentialsPath: constants.CredentialsPath(configHome),
-	}
+	return o.CompleteWithConfigHome(configHome)
 }
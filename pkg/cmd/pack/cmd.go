@@ -20,17 +20,12 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"path/filepath"
-	"strings"
-
-	"kitops/pkg/lib/repo/util"
 
+	"kitops/pkg/kit"
 	"kitops/pkg/lib/constants"
-	"kitops/pkg/lib/filesystem"
 	"kitops/pkg/output"
 
 	"github.com/spf13/cobra"
-	"oras.land/oras-go/v2/registry"
 )
 
 const (
@@ -45,24 +40,30 @@ as pushing to a remote registry for collaboration.
 Unless a different location is specified, this command looks for the kitfile
 at the root of the provided context directory. Any relative paths defined
 within the kitfile are interpreted as being relative to this context
-directory.`
+directory.
+
+Multiple modelkits can be grouped as variants of the same model (for example,
+different quantizations or formats) by packing each with --variant and the
+same tag. The tag then refers to an OCI image index containing all variants,
+and commands such as pull and unpack can select a variant using --variant.`
 
 	examples = `# Pack a modelkit using the kitfile in the current directory
 kit pack .
 
 # Pack a modelkit with a specific kitfile and tag
-kit pack . -f /path/to/your/Kitfile -t registry/repository:modelv1`
+kit pack . -f /path/to/your/Kitfile -t registry/repository:modelv1
+
+# Pack q4 and q8 GGUF variants of a model under the same tag
+kit pack ./q4 -t registry/repository:modelv1 --variant name=q4,quantization=q4_0
+kit pack ./q8 -t registry/repository:modelv1 --variant name=q8,quantization=q8_0`
 )
 
 type packOptions struct {
 	modelFile   string
 	contextDir  string
-	configHome  string
-	storageHome string
 	fullTagRef  string
 	compression string
-	modelRef    *registry.Reference
-	extraRefs   []string
+	variant     string
 }
 
 func PackCommand() *cobra.Command {
@@ -78,6 +79,7 @@ func PackCommand() *cobra.Command {
 	cmd.Flags().StringVarP(&opts.modelFile, "file", "f", "", "Specifies the path to the Kitfile explicitly (use \"-\" to read from standard input)")
 	cmd.Flags().StringVarP(&opts.fullTagRef, "tag", "t", "", "Assigns one or more tags to the built modelkit. Example: -t registry/repository:tag1,tag2")
 	cmd.Flags().StringVar(&opts.compression, "compression", "none", "Compression format to use for layers. Valid options: 'none' (default), 'gzip', 'gzip-fastest'")
+	cmd.Flags().StringVar(&opts.variant, "variant", "", "Add the modelkit as a variant of the tagged modelkit, with comma-separated annotations. Example: --variant name=q4,quantization=q4_0,format=gguf")
 	cmd.Flags().SortFlags = false
 	cmd.Args = cobra.ExactArgs(1)
 	return cmd
@@ -85,76 +87,58 @@ func PackCommand() *cobra.Command {
 
 func runCommand(opts *packOptions) func(cmd *cobra.Command, args []string) error {
 	return func(cmd *cobra.Command, args []string) error {
-		err := opts.complete(cmd.Context(), args)
+		packOpts, err := opts.complete(cmd.Context(), args)
 		if err != nil {
 			return output.Fatalf("Invalid arguments: %s", err)
 		}
 
-		// Change working directory to context path to make sure relative paths within
-		// tarballs are correct. This is the equivalent of using the -C parameter for tar
-		if err := os.Chdir(opts.contextDir); err != nil {
-			return output.Fatalf("Failed to use context path %s: %s", opts.contextDir, err)
-		}
-
-		err = runPack(cmd.Context(), opts)
+		result, err := kit.Pack(cmd.Context(), *packOpts)
 		if err != nil {
 			return output.Fatalf("Failed to pack model kit: %s", err)
 		}
+		output.SetResult(result)
 		return nil
 	}
 }
 
-func (opts *packOptions) complete(ctx context.Context, args []string) error {
-	contextDir, err := filepath.Abs(args[0])
-	if err != nil {
-		return fmt.Errorf("failed to get context dir %s: %w", args[0], err)
-	}
-	opts.contextDir = contextDir
-
-	if opts.modelFile == "" {
-		foundModel, err := filesystem.FindKitfileInPath(opts.contextDir)
-		if err != nil {
-			return err
-		}
-		opts.modelFile = foundModel
-	}
-
+func (opts *packOptions) complete(ctx context.Context, args []string) (*kit.PackOptions, error) {
 	configHome, ok := ctx.Value(constants.ConfigKey{}).(string)
 	if !ok {
-		return fmt.Errorf("default config path not set on command context")
-	}
-	opts.configHome = configHome
-	opts.storageHome = constants.StoragePath(opts.configHome)
-
-	if opts.fullTagRef != "" {
-		modelRef, extraRefs, err := util.ParseReference(opts.fullTagRef)
-		if err != nil {
-			return fmt.Errorf("failed to parse reference: %w", err)
-		}
-		opts.modelRef = modelRef
-		opts.extraRefs = extraRefs
-	} else {
-		opts.modelRef = util.DefaultReference()
+		return nil, fmt.Errorf("default config path not set on command context")
 	}
-
 	if err := constants.IsValidCompression(opts.compression); err != nil {
-		return err
+		return nil, err
+	}
+	opts.contextDir = args[0]
+
+	packOpts := &kit.PackOptions{
+		Options:     kit.Options{ConfigHome: configHome},
+		ContextDir:  opts.contextDir,
+		Kitfile:     opts.modelFile,
+		Reference:   opts.fullTagRef,
+		Compression: opts.compression,
+		Variant:     opts.variant,
+	}
+	if opts.modelFile == "-" {
+		stat, _ := os.Stdin.Stat()
+		if (stat.Mode() & os.ModeCharDevice) != 0 {
+			return nil, fmt.Errorf("No input file specified and no data piped")
+		}
+		packOpts.KitfileReader = os.Stdin
 	}
 
 	printConfig(opts)
-	return nil
+	return packOpts, nil
 }
 
 func printConfig(opts *packOptions) {
-	output.Debugf("Using storage path: %s", opts.storageHome)
 	output.Debugf("Context dir: %s", opts.contextDir)
-	output.Debugf("Model file: %s", opts.modelFile)
-	if opts.modelRef != nil {
-		output.Debugf("Packing %s", opts.modelRef.String())
+	if opts.modelFile != "" {
+		output.Debugf("Model file: %s", opts.modelFile)
+	}
+	if opts.fullTagRef != "" {
+		output.Debugf("Packing %s", opts.fullTagRef)
 	} else {
 		output.Debugln("No tag or reference specified")
 	}
-	if len(opts.extraRefs) > 0 {
-		output.Debugf("Additional tags: %s", strings.Join(opts.extraRefs, ", "))
-	}
 }
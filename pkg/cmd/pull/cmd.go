@@ -21,53 +21,71 @@ import (
 	"fmt"
 
 	"kitops/pkg/cmd/options"
+	"kitops/pkg/kit"
 	"kitops/pkg/lib/constants"
-	"kitops/pkg/lib/repo/util"
 	"kitops/pkg/output"
 
 	"github.com/spf13/cobra"
-	"oras.land/oras-go/v2/registry"
 )
 
 const (
 	shortDesc = `Retrieve modelkits from a remote registry to your local environment.`
 	longDesc  = `Downloads modelkits from a specified registry. The downloaded modelkits
-are stored in the local registry.`
+are stored in the local registry.
+
+If the reference refers to an index of modelkit variants (for example,
+different quantizations of the same model), only one variant is pulled and
+tagged locally. The variant can be selected with --variant, either by name or
+using a comma-separated list of annotations to match (e.g. format=gguf) and
+an optional preference of 'smallest' or 'largest'. By default, the first
+variant in the index is pulled.
+
+The layers that are pulled can be limited via the --filter (-f) flag, using
+the same format as 'kit unpack'. Only matching layers are stored locally, and
+the modelkit is listed as partial. Missing layers are fetched from the
+registry when they are needed, e.g. when unpacking or pushing the modelkit.
+Running pull again without filters completes a partially-pulled modelkit.`
 
 	example = `# Pull the latest version of a modelkit from a remote registry
-kit pull registry.example.com/my-model:latest`
+kit pull registry.example.com/my-model:latest
+
+# Pull a modelkit, downloading each large layer over 8 connections
+kit pull --parallel-chunks 8 registry.example.com/my-model:latest
+
+# Pull the variant named q4 of a modelkit
+kit pull --variant q4 registry.example.com/my-model:latest
+
+# Pull the smallest GGUF variant of a modelkit
+kit pull --variant format=gguf,smallest registry.example.com/my-model:latest
+
+# Pull only the model from a modelkit
+kit pull --filter=model registry.example.com/my-model:latest
+
+# Pull only the dataset named validation from a modelkit
+kit pull --filter=datasets:validation registry.example.com/my-model:latest`
 )
 
 type pullOptions struct {
 	options.NetworkOptions
-	configHome string
-	modelRef   *registry.Reference
+	variant string
+	filters []string
 }
 
-func (opts *pullOptions) complete(ctx context.Context, args []string) error {
+func (opts *pullOptions) complete(ctx context.Context, args []string) (*kit.PullOptions, error) {
 	configHome, ok := ctx.Value(constants.ConfigKey{}).(string)
 	if !ok {
-		return fmt.Errorf("default config path not set on command context")
+		return nil, fmt.Errorf("default config path not set on command context")
 	}
-	opts.configHome = configHome
-
-	modelRef, extraTags, err := util.ParseReference(args[0])
-	if err != nil {
-		return fmt.Errorf("failed to parse reference: %w", err)
-	}
-	if modelRef.Registry == "localhost" {
-		return fmt.Errorf("registry is required when pulling")
+	if opts.ParallelChunks < 1 {
+		return nil, fmt.Errorf("invalid argument for parallel-chunks (%d): must be at least 1", opts.ParallelChunks)
 	}
-	if len(extraTags) > 0 {
-		return fmt.Errorf("reference cannot include multiple tags")
-	}
-	opts.modelRef = modelRef
-
-	if err := opts.NetworkOptions.Complete(ctx, args); err != nil {
-		return err
-	}
-
-	return nil
+	return &kit.PullOptions{
+		Options:   kit.Options{ConfigHome: configHome},
+		Remote:    kit.NewRemoteOptions(&opts.NetworkOptions),
+		Reference: args[0],
+		Variant:   opts.variant,
+		Filters:   opts.filters,
+	}, nil
 }
 
 func PullCommand() *cobra.Command {
@@ -81,7 +99,11 @@ func PullCommand() *cobra.Command {
 	}
 
 	cmd.Args = cobra.ExactArgs(1)
-	opts.AddNetworkFlags(cmd)
+	options.AddNetworkFlags(cmd, &opts.NetworkOptions)
+	options.AddLimitRateFlag(cmd, &opts.NetworkOptions)
+	cmd.Flags().StringVar(&opts.variant, "variant", "", "Select the variant to pull if the modelkit has variants, by name or by annotations (e.g. format=gguf,smallest)")
+	cmd.Flags().StringArrayVarP(&opts.filters, "filter", "f", []string{}, "Filter which layers are pulled from the modelkit based on type and name. Can be specified multiple times")
+	cmd.Flags().IntVar(&opts.ParallelChunks, "parallel-chunks", 1, "Number of byte ranges to download in parallel for each large layer, if supported by the registry")
 	cmd.Flags().SortFlags = false
 
 	return cmd
@@ -89,16 +111,16 @@ func PullCommand() *cobra.Command {
 
 func runCommand(opts *pullOptions) func(*cobra.Command, []string) error {
 	return func(cmd *cobra.Command, args []string) error {
-		if err := opts.complete(cmd.Context(), args); err != nil {
+		pullOpts, err := opts.complete(cmd.Context(), args)
+		if err != nil {
 			return output.Fatalf("Invalid arguments: %s", err)
 		}
 
-		output.Infof("Pulling %s", opts.modelRef.String())
-		desc, err := runPull(cmd.Context(), opts)
+		result, err := kit.Pull(cmd.Context(), *pullOpts)
 		if err != nil {
 			return output.Fatalln(err)
 		}
-		output.Infof("Pulled %s", desc.Digest)
+		output.SetResult(result)
 		return nil
 	}
 }
@@ -23,14 +23,11 @@ import (
 	"net/http"
 
 	"kitops/pkg/cmd/options"
+	"kitops/pkg/kit"
 	"kitops/pkg/lib/constants"
-	"kitops/pkg/lib/repo/local"
-	"kitops/pkg/lib/repo/remote"
-	"kitops/pkg/lib/repo/util"
 	"kitops/pkg/output"
 
 	"github.com/spf13/cobra"
-	"oras.land/oras-go/v2/registry"
 	"oras.land/oras-go/v2/registry/remote/errcode"
 )
 
@@ -39,7 +36,12 @@ const (
 	longDesc  = `This command pushes modelkits from local storage to a remote registry.
 
 If specified without a destination, the ModelKit must be tagged locally before
-pushing.`
+pushing.
+
+Layers that already exist in another repository on the same registry are
+mounted rather than uploaded when possible. Kit tries repositories specified
+with --mount-from as well as repositories it recently pushed the same layers
+to, and falls back to a regular upload if the registry cannot mount a layer.`
 
 	example = `# Push the ModelKit tagged 'latest' to a remote registry
 kit push registry.example.com/my-org/my-model:latest
@@ -48,53 +50,32 @@ kit push registry.example.com/my-org/my-model:latest
 kit push registry.example.com/my-org/my-model@sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a
 
 # Push local modelkit 'mymodel:1.0.0' to a remote registry
-kit push mymodel:1.0.0 registry.example.com/my-org/my-model:latest`
+kit push mymodel:1.0.0 registry.example.com/my-org/my-model:latest
+
+# Push a new version, reusing layers already pushed to another repository
+kit push registry.example.com/my-org/my-model-v2:latest --mount-from my-org/my-model-v1`
 )
 
 type pushOptions struct {
 	options.NetworkOptions
-	configHome   string
-	srcModelRef  *registry.Reference
-	destModelRef *registry.Reference
+	mountFrom []string
 }
 
-func (opts *pushOptions) complete(ctx context.Context, args []string) error {
+func (opts *pushOptions) complete(ctx context.Context, args []string) (*kit.PushOptions, error) {
 	configHome, ok := ctx.Value(constants.ConfigKey{}).(string)
 	if !ok {
-		return fmt.Errorf("default config path not set on command context")
-	}
-	opts.configHome = configHome
-
-	srcRef, extraTags, err := util.ParseReference(args[0])
-	if err != nil {
-		return fmt.Errorf("failed to parse reference %s: %w", args[0], err)
-	}
-	if len(extraTags) > 0 {
-		return fmt.Errorf("reference cannot include multiple tags")
+		return nil, fmt.Errorf("default config path not set on command context")
 	}
-	opts.srcModelRef = srcRef
-	if len(args) == 1 {
-		opts.destModelRef = srcRef
-	} else {
-		destRef, extraTags, err := util.ParseReference(args[1])
-		if err != nil {
-			return fmt.Errorf("failed to parse target reference %s: %w", args[1], err)
-		}
-		if len(extraTags) > 0 {
-			return fmt.Errorf("target reference cannot include multiple tags")
-		}
-		opts.destModelRef = destRef
-	}
-
-	if opts.destModelRef.Registry == "localhost" {
-		return fmt.Errorf("registry is required when pushing")
+	pushOpts := &kit.PushOptions{
+		Options:   kit.Options{ConfigHome: configHome},
+		Remote:    kit.NewRemoteOptions(&opts.NetworkOptions),
+		Source:    args[0],
+		MountFrom: opts.mountFrom,
 	}
-
-	if err := opts.NetworkOptions.Complete(ctx, args); err != nil {
-		return err
+	if len(args) > 1 {
+		pushOpts.Destination = args[1]
 	}
-
-	return nil
+	return pushOpts, nil
 }
 
 func PushCommand() *cobra.Command {
@@ -108,7 +89,9 @@ func PushCommand() *cobra.Command {
 	}
 
 	cmd.Args = cobra.RangeArgs(1, 2)
-	opts.AddNetworkFlags(cmd)
+	options.AddNetworkFlags(cmd, &opts.NetworkOptions)
+	options.AddLimitRateFlag(cmd, &opts.NetworkOptions)
+	cmd.Flags().StringArrayVar(&opts.mountFrom, "mount-from", nil, "Repository on the destination registry to mount existing layers from instead of uploading them (can be specified multiple times)")
 	cmd.Flags().SortFlags = false
 
 	return cmd
@@ -116,31 +99,12 @@ func PushCommand() *cobra.Command {
 
 func runCommand(opts *pushOptions) func(*cobra.Command, []string) error {
 	return func(cmd *cobra.Command, args []string) error {
-		if err := opts.complete(cmd.Context(), args); err != nil {
-			return output.Fatalf("Invalid arguments: %s", err)
-		}
-
-		remoteRepo, err := remote.NewRepository(
-			cmd.Context(),
-			opts.destModelRef.Registry,
-			opts.destModelRef.Repository,
-			&opts.NetworkOptions,
-		)
+		pushOpts, err := opts.complete(cmd.Context(), args)
 		if err != nil {
-			return output.Fatalln(err)
-		}
-
-		localRepo, err := local.NewLocalRepo(constants.StoragePath(opts.configHome), opts.srcModelRef)
-		if err != nil {
-			return output.Fatalln(err)
+			return output.Fatalf("Invalid arguments: %s", err)
 		}
 
-		if opts.srcModelRef.String() != opts.destModelRef.String() {
-			output.Infof("Pushing %s to %s", opts.srcModelRef.String(), opts.destModelRef.String())
-		} else {
-			output.Infof("Pushing %s", opts.srcModelRef.String())
-		}
-		desc, err := PushModel(cmd.Context(), localRepo, remoteRepo, opts)
+		result, err := kit.Push(cmd.Context(), *pushOpts)
 		respErr := &errcode.ErrorResponse{}
 		if ok := errors.As(err, &respErr); ok {
 			output.Debugf("Got error pushing: %s", err)
@@ -149,11 +113,11 @@ func runCommand(opts *pushOptions) func(*cobra.Command, []string) error {
 			case http.StatusUnauthorized:
 				errMsg = fmt.Sprintf("%s. Ensure the repository exists and you have push access to it.", errMsg)
 			}
-			return output.Fatalf(errMsg)
+			return output.Fatalf("%s", errMsg)
 		} else if err != nil {
 			return output.Fatalf("Failed to push: %s.", err)
 		}
-		output.Infof("Pushed %s", desc.Digest)
+		output.SetResult(result)
 		return nil
 	}
 }
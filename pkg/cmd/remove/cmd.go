@@ -22,6 +22,7 @@ import (
 	"strings"
 
 	"kitops/pkg/cmd/options"
+	"kitops/pkg/kit"
 	"kitops/pkg/lib/constants"
 	"kitops/pkg/lib/repo/util"
 	"kitops/pkg/output"
@@ -62,6 +63,7 @@ type removeOptions struct {
 	forceDelete bool
 	removeAll   bool
 	remote      bool
+	reference   string
 	modelRef    *registry.Reference
 	extraTags   []string
 }
@@ -78,6 +80,7 @@ func (opts *removeOptions) complete(ctx context.Context, args []string) error {
 		if err != nil {
 			return fmt.Errorf("failed to parse reference: %w", err)
 		}
+		opts.reference = args[0]
 		opts.modelRef = modelRef
 		opts.extraTags = extraTags
 	}
@@ -86,7 +89,7 @@ func (opts *removeOptions) complete(ctx context.Context, args []string) error {
 		return fmt.Errorf("cannot use --all or --force with --remote")
 	}
 
-	if err := opts.NetworkOptions.Complete(ctx, args); err != nil {
+	if err := options.CompleteNetworkOptions(ctx, &opts.NetworkOptions); err != nil {
 		return err
 	}
 
@@ -107,7 +110,7 @@ func RemoveCommand() *cobra.Command {
 	cmd.Flags().BoolVarP(&opts.forceDelete, "force", "f", false, "remove modelkit and all other tags that refer to it")
 	cmd.Flags().BoolVarP(&opts.removeAll, "all", "a", false, "remove all untagged modelkits")
 	cmd.Flags().BoolVarP(&opts.remote, "remote", "r", false, "remove modelkit from remote registry")
-	opts.AddNetworkFlags(cmd)
+	options.AddNetworkFlags(cmd, &opts.NetworkOptions)
 	cmd.Flags().SortFlags = false
 
 	cmd.Args = func(cmd *cobra.Command, args []string) error {
@@ -136,21 +139,17 @@ func runCommand(opts *removeOptions) func(*cobra.Command, []string) error {
 			return output.Fatalf("Invalid arguments: %s", err)
 		}
 
-		var err error
-		switch {
-		case opts.modelRef != nil:
-			if opts.remote {
-				err = removeRemoteModel(cmd.Context(), opts)
-			} else {
-				err = removeModel(cmd.Context(), opts)
-			}
-		case opts.removeAll && !opts.forceDelete:
-			err = removeUntaggedModels(cmd.Context(), opts)
-		case opts.removeAll && opts.forceDelete:
-			err = removeAllModels(cmd.Context(), opts)
-		}
+		result, err := kit.Remove(cmd.Context(), kit.RemoveOptions{
+			Options:    kit.Options{ConfigHome: opts.configHome},
+			Remote:     kit.NewRemoteOptions(&opts.NetworkOptions),
+			Reference:  opts.reference,
+			All:        opts.removeAll,
+			Force:      opts.forceDelete,
+			FromRemote: opts.remote,
+		})
+		output.SetResult(result)
 		if err != nil {
-			return output.Fatalf(err.Error())
+			return output.Fatalf("%s", err)
 		}
 		return nil
 	}
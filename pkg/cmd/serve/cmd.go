@@ -0,0 +1,189 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package serve
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"kitops/pkg/cmd/options"
+	"kitops/pkg/kit"
+	"kitops/pkg/lib/constants"
+	"kitops/pkg/output"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	shortDesc = `Serve a JSON API for working with modelkits`
+	longDesc  = `Start an HTTP server that exposes a JSON API for working with modelkits in
+local storage: listing, inspecting, pulling, unpacking, tagging, and removing.
+
+All endpoints except the OpenAPI document at /api/v1/openapi.yaml require a
+bearer token. The token is read from the KITOPS_SERVE_TOKEN environment
+variable; if it is not set, a random token is generated and printed at startup.
+
+Operations that modify local storage are run one at a time, in the order they
+are received. Unpack requests may only write to directories within the
+directory specified by --unpack-root.`
+
+	examples = `# Serve the API on the default address
+kit serve
+
+# Serve the API on all interfaces, using a pre-configured token
+KITOPS_SERVE_TOKEN=my-secret-token kit serve --addr 0.0.0.0:8080
+
+# Allow clients to unpack modelkits under /srv/models
+kit serve --unpack-root /srv/models`
+)
+
+type serveOptions struct {
+	options.NetworkOptions
+	configHome string
+	addr       string
+	unpackRoot string
+	token      string
+}
+
+func (opts *serveOptions) complete(ctx context.Context) error {
+	configHome, ok := ctx.Value(constants.ConfigKey{}).(string)
+	if !ok {
+		return fmt.Errorf("default config path not set on command context")
+	}
+	opts.configHome = configHome
+
+	if opts.unpackRoot == "" {
+		opts.unpackRoot = "."
+	}
+	unpackRoot, err := filepath.Abs(opts.unpackRoot)
+	if err != nil {
+		return fmt.Errorf("failed to resolve unpack root: %w", err)
+	}
+	opts.unpackRoot = unpackRoot
+
+	opts.token = os.Getenv(constants.ServeTokenEnvVar)
+	if opts.token == "" {
+		token, err := generateToken()
+		if err != nil {
+			return err
+		}
+		opts.token = token
+		output.Infof("Generated API token: %s", token)
+		output.Infof("Set %s to use a fixed token", constants.ServeTokenEnvVar)
+	}
+	return nil
+}
+
+func ServeCommand() *cobra.Command {
+	opts := &serveOptions{}
+	cmd := &cobra.Command{
+		Use:     "serve [flags]",
+		Short:   shortDesc,
+		Long:    longDesc,
+		Example: examples,
+		RunE:    runCommand(opts),
+	}
+
+	cmd.Args = cobra.NoArgs
+	cmd.Flags().StringVar(&opts.addr, "addr", "127.0.0.1:8080", "Address to listen on")
+	cmd.Flags().StringVar(&opts.unpackRoot, "unpack-root", "", "Directory that unpack requests are confined to (default: current directory)")
+	options.AddNetworkFlags(cmd, &opts.NetworkOptions)
+	cmd.Flags().SortFlags = false
+
+	return cmd
+}
+
+func runCommand(opts *serveOptions) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		if err := opts.complete(cmd.Context()); err != nil {
+			return output.Fatalf("Invalid arguments: %s", err)
+		}
+
+		unpackRoot, err := os.OpenRoot(opts.unpackRoot)
+		if err != nil {
+			return output.Fatalf("Invalid arguments: failed to open unpack root: %s", err)
+		}
+		defer unpackRoot.Close()
+
+		srv := &server{
+			configHome: opts.configHome,
+			token:      opts.token,
+			unpackRoot: unpackRoot,
+			remote:     kit.NewRemoteOptions(&opts.NetworkOptions),
+			writes:     newWriteQueue(),
+		}
+		if err := serve(cmd.Context(), opts.addr, srv); err != nil {
+			return output.Fatalln(err)
+		}
+		return nil
+	}
+}
+
+func serve(ctx context.Context, addr string, srv *server) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	httpServer := &http.Server{
+		Handler:           srv.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		// Operations create their own loggers rather than using the CLI's output
+		BaseContext: func(net.Listener) context.Context { return context.Background() },
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- httpServer.Serve(listener)
+	}()
+	output.Infof("Serving API at http://%s%s", listener.Addr(), apiPrefix)
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	output.Infof("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down server: %w", err)
+	}
+	if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func generateToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
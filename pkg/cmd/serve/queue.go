@@ -0,0 +1,64 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package serve
+
+import "context"
+
+// writeQueue runs operations that modify local storage one at a time, in the order they
+// were submitted. Read-only operations do not need to go through the queue.
+type writeQueue struct {
+	jobs chan *writeJob
+}
+
+type writeJob struct {
+	ctx  context.Context
+	fn   func(context.Context) error
+	err  error
+	done chan struct{}
+}
+
+func newWriteQueue() *writeQueue {
+	q := &writeQueue{jobs: make(chan *writeJob)}
+	go q.run()
+	return q
+}
+
+func (q *writeQueue) run() {
+	for job := range q.jobs {
+		// Skip jobs whose request was cancelled while waiting in the queue
+		if err := job.ctx.Err(); err != nil {
+			job.err = err
+		} else {
+			job.err = job.fn(job.ctx)
+		}
+		close(job.done)
+	}
+}
+
+// do submits fn to the queue and waits for it to complete, returning its error. If ctx is
+// cancelled before fn starts, fn is not run and ctx's error is returned.
+func (q *writeQueue) do(ctx context.Context, fn func(context.Context) error) error {
+	job := &writeJob{ctx: ctx, fn: fn, done: make(chan struct{})}
+	// Senders blocked on the (unbuffered) channel are served in order
+	select {
+	case q.jobs <- job:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	<-job.done
+	return job.err
+}
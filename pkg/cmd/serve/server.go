@@ -0,0 +1,452 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package serve
+
+import (
+	"context"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"kitops/pkg/kit"
+	"kitops/pkg/lib/repo/util"
+	"kitops/pkg/output"
+
+	"oras.land/oras-go/v2/errdef"
+)
+
+const apiPrefix = "/api/v1"
+
+//go:embed openapi.yaml
+var openAPIDocument []byte
+
+// server implements the kit serve API on top of package kit. Operations that modify local
+// storage are run through a writeQueue; all others run concurrently.
+type server struct {
+	configHome string
+	token      string
+	// unpackRoot is the directory that unpack requests are confined to
+	unpackRoot *os.Root
+	remote     kit.RemoteOptions
+	writes     *writeQueue
+}
+
+// errorResponse is the body of all non-2xx responses
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// requestError is returned for invalid requests, and results in a 400 Bad Request response
+type requestError struct {
+	msg string
+}
+
+func (e *requestError) Error() string {
+	return e.msg
+}
+
+func badRequest(s string, args ...any) error {
+	return &requestError{msg: fmt.Sprintf(s, args...)}
+}
+
+type pullRequest struct {
+	Reference string   `json:"reference"`
+	Variant   string   `json:"variant,omitempty"`
+	Filters   []string `json:"filters,omitempty"`
+}
+
+type unpackRequest struct {
+	Reference string   `json:"reference"`
+	Dir       string   `json:"dir"`
+	Filters   []string `json:"filters,omitempty"`
+	Exclude   []string `json:"exclude,omitempty"`
+	Overwrite bool     `json:"overwrite,omitempty"`
+	Variant   string   `json:"variant,omitempty"`
+}
+
+type tagRequest struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+apiPrefix+"/openapi.yaml", s.handleOpenAPI)
+	mux.Handle("GET "+apiPrefix+"/modelkits", s.auth(s.handleList))
+	mux.Handle("DELETE "+apiPrefix+"/modelkits", s.auth(s.handleRemove))
+	mux.Handle("GET "+apiPrefix+"/modelkits/inspect", s.auth(s.handleInspect))
+	mux.Handle("GET "+apiPrefix+"/modelkits/info", s.auth(s.handleInfo))
+	mux.Handle("POST "+apiPrefix+"/modelkits/pull", s.auth(s.handlePull))
+	mux.Handle("POST "+apiPrefix+"/modelkits/unpack", s.auth(s.handleUnpack))
+	mux.Handle("POST "+apiPrefix+"/modelkits/tag", s.auth(s.handleTag))
+	return logRequests(mux)
+}
+
+// options returns kit options for a request. Logs from operations are discarded unless they
+// are streamed to the client.
+func (s *server) options() kit.Options {
+	return kit.Options{ConfigHome: s.configHome}
+}
+
+// auth requires requests to include the server's token as a bearer token.
+func (s *server) auth(h func(http.ResponseWriter, *http.Request) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="kit"`)
+			writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "missing or invalid token"})
+			return
+		}
+		if err := h(w, r); err != nil {
+			writeError(w, err)
+		}
+	})
+}
+
+func (s *server) handleOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(openAPIDocument)
+}
+
+func (s *server) handleList(w http.ResponseWriter, r *http.Request) error {
+	kits, err := kit.List(r.Context(), kit.ListOptions{Options: s.options()})
+	if err != nil {
+		return err
+	}
+	if kits == nil {
+		kits = []kit.ListedModelKit{}
+	}
+	writeJSON(w, http.StatusOK, kits)
+	return nil
+}
+
+func (s *server) inspect(r *http.Request) (*kit.InspectResult, error) {
+	ref, err := queryReference(r)
+	if err != nil {
+		return nil, err
+	}
+	checkRemote, err := queryBool(r, "remote")
+	if err != nil {
+		return nil, err
+	}
+	return kit.Inspect(r.Context(), kit.InspectOptions{
+		Options:     s.options(),
+		Remote:      s.remote,
+		Reference:   ref,
+		CheckRemote: checkRemote,
+	})
+}
+
+func (s *server) handleInspect(w http.ResponseWriter, r *http.Request) error {
+	result, err := s.inspect(r)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, result)
+	return nil
+}
+
+func (s *server) handleInfo(w http.ResponseWriter, r *http.Request) error {
+	result, err := s.inspect(r)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, result.Kitfile)
+	return nil
+}
+
+// handlePull pulls a modelkit. If the client accepts text/event-stream, logs and progress
+// are streamed as server-sent events, followed by a final result or error event.
+func (s *server) handlePull(w http.ResponseWriter, r *http.Request) error {
+	req := &pullRequest{}
+	if err := readJSON(r, req); err != nil {
+		return err
+	}
+	if err := validateReference(req.Reference); err != nil {
+		return err
+	}
+	opts := kit.PullOptions{
+		Options:   s.options(),
+		Remote:    s.remote,
+		Reference: req.Reference,
+		Variant:   req.Variant,
+		Filters:   req.Filters,
+	}
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		var result *kit.ModelKit
+		err := s.writes.do(r.Context(), func(ctx context.Context) (err error) {
+			result, err = kit.Pull(ctx, opts)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		writeJSON(w, http.StatusOK, result)
+		return nil
+	}
+
+	events, err := newEventStream(w)
+	if err != nil {
+		return err
+	}
+	opts.Log = events.logWriter()
+	opts.LogLevel = kit.LogLevelInfo
+	opts.Progress = func(ev kit.ProgressEvent) {
+		events.send("progress", ev)
+	}
+	var result *kit.ModelKit
+	err = s.writes.do(r.Context(), func(ctx context.Context) (err error) {
+		result, err = kit.Pull(ctx, opts)
+		return err
+	})
+	if err != nil {
+		events.send("error", errorResponse{Error: err.Error()})
+	} else {
+		events.send("result", result)
+	}
+	return nil
+}
+
+func (s *server) handleUnpack(w http.ResponseWriter, r *http.Request) error {
+	req := &unpackRequest{}
+	if err := readJSON(r, req); err != nil {
+		return err
+	}
+	if err := validateReference(req.Reference); err != nil {
+		return err
+	}
+	// Clients may only unpack to directories within the configured root
+	if !filepath.IsLocal(req.Dir) {
+		return badRequest("dir must be a relative path within the server's unpack root")
+	}
+	var result *kit.UnpackResult
+	err := s.writes.do(r.Context(), func(ctx context.Context) (err error) {
+		result, err = kit.Unpack(ctx, kit.UnpackOptions{
+			Options:   s.options(),
+			Remote:    s.remote,
+			Reference: req.Reference,
+			Dir:       req.Dir,
+			Root:      s.unpackRoot,
+			Filters:   req.Filters,
+			Exclude:   req.Exclude,
+			Overwrite: req.Overwrite,
+			Variant:   req.Variant,
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, result)
+	return nil
+}
+
+func (s *server) handleTag(w http.ResponseWriter, r *http.Request) error {
+	req := &tagRequest{}
+	if err := readJSON(r, req); err != nil {
+		return err
+	}
+	if err := validateReference(req.Source); err != nil {
+		return err
+	}
+	if err := validateReference(req.Target); err != nil {
+		return err
+	}
+	var result *kit.TagResult
+	err := s.writes.do(r.Context(), func(ctx context.Context) (err error) {
+		result, err = kit.Tag(ctx, kit.TagOptions{
+			Options: s.options(),
+			Source:  req.Source,
+			Target:  req.Target,
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, result)
+	return nil
+}
+
+func (s *server) handleRemove(w http.ResponseWriter, r *http.Request) error {
+	ref, err := queryReference(r)
+	if err != nil {
+		return err
+	}
+	force, err := queryBool(r, "force")
+	if err != nil {
+		return err
+	}
+	var result *kit.RemoveResult
+	err = s.writes.do(r.Context(), func(ctx context.Context) (err error) {
+		result, err = kit.Remove(ctx, kit.RemoveOptions{
+			Options:   s.options(),
+			Reference: ref,
+			Force:     force,
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, result)
+	return nil
+}
+
+func queryReference(r *http.Request) (string, error) {
+	ref := r.URL.Query().Get("ref")
+	if err := validateReference(ref); err != nil {
+		return "", err
+	}
+	return ref, nil
+}
+
+func queryBool(r *http.Request, name string) (bool, error) {
+	val := r.URL.Query().Get(name)
+	if val == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, badRequest("invalid value for %s: %s", name, val)
+	}
+	return b, nil
+}
+
+func validateReference(ref string) error {
+	if ref == "" {
+		return badRequest("a modelkit reference is required")
+	}
+	if _, _, err := util.ParseReference(ref); err != nil {
+		return badRequest("invalid reference %s: %s", ref, err)
+	}
+	return nil
+}
+
+func readJSON(r *http.Request, v any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return badRequest("invalid request body: %s", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		output.Debugf("Failed to write response: %s", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var reqErr *requestError
+	switch {
+	case errors.As(err, &reqErr):
+		status = http.StatusBadRequest
+	case errors.Is(err, errdef.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, context.Canceled):
+		// Client went away; there's no one to respond to
+		return
+	}
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+// eventStream writes server-sent events to a response. Events may be sent concurrently.
+type eventStream struct {
+	mu sync.Mutex
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func newEventStream(w http.ResponseWriter) (*eventStream, error) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil {
+		return nil, fmt.Errorf("streaming is not supported: %w", err)
+	}
+	return &eventStream{w: w, rc: rc}, nil
+}
+
+func (s *eventStream) send(event string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		output.Debugf("Failed to marshal %s event: %s", event, err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, data)
+	_ = s.rc.Flush()
+}
+
+// logWriter returns a writer that sends each line written to it as a log event.
+func (s *eventStream) logWriter() *eventLogWriter {
+	return &eventLogWriter{events: s}
+}
+
+type eventLogWriter struct {
+	events *eventStream
+}
+
+type logEvent struct {
+	Message string `json:"message"`
+}
+
+func (w *eventLogWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		w.events.send("log", logEvent{Message: line})
+	}
+	return len(p), nil
+}
+
+// statusRecorder records the status code of a response for logging
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap allows http.ResponseController to access the underlying ResponseWriter
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func logRequests(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r)
+		output.Infof("%s %s %d", r.Method, r.URL.Path, rec.status)
+	})
+}
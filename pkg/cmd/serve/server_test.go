@@ -0,0 +1,240 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package serve
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kitops/pkg/kit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testToken   = "test-token"
+	testKitfile = `manifestVersion: 1.0.0
+package:
+  name: test-model
+model:
+  path: model.bin
+`
+)
+
+func newTestServer(t *testing.T) (*httptest.Server, *server) {
+	t.Helper()
+	unpackRoot, err := os.OpenRoot(t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { unpackRoot.Close() })
+	srv := &server{
+		configHome: t.TempDir(),
+		token:      testToken,
+		unpackRoot: unpackRoot,
+		writes:     newWriteQueue(),
+	}
+
+	contextDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(contextDir, "Kitfile"), []byte(testKitfile), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(contextDir, "model.bin"), []byte("model"), 0644))
+	_, err = kit.Pack(context.Background(), kit.PackOptions{
+		Options:    kit.Options{ConfigHome: srv.configHome},
+		ContextDir: contextDir,
+		Reference:  "example.com/test/model:v1",
+	})
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(srv.handler())
+	t.Cleanup(ts.Close)
+	return ts, srv
+}
+
+func doRequest(t *testing.T, ts *httptest.Server, method, path, body string, out any) int {
+	t.Helper()
+	var reqBody io.Reader
+	if body != "" {
+		reqBody = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, ts.URL+apiPrefix+path, reqBody)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := ts.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	if out != nil {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp.StatusCode
+}
+
+func TestServerRequiresToken(t *testing.T) {
+	ts, _ := newTestServer(t)
+
+	for _, auth := range []string{"", "Bearer wrong-token", testToken} {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+apiPrefix+"/modelkits", nil)
+		require.NoError(t, err)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "Authorization: %q", auth)
+		assert.NotEmpty(t, resp.Header.Get("WWW-Authenticate"))
+	}
+
+	// The OpenAPI document is available without a token
+	resp, err := ts.Client().Get(ts.URL + apiPrefix + "/openapi.yaml")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	doc, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(doc), "openapi: 3.0.3")
+}
+
+func TestServerOperations(t *testing.T) {
+	ts, srv := newTestServer(t)
+	ref := url.QueryEscape("example.com/test/model:v1")
+
+	var kits []kit.ListedModelKit
+	require.Equal(t, http.StatusOK, doRequest(t, ts, http.MethodGet, "/modelkits", "", &kits))
+	require.Len(t, kits, 1)
+	assert.Equal(t, []string{"v1"}, kits[0].Tags)
+
+	var inspected kit.InspectResult
+	require.Equal(t, http.StatusOK, doRequest(t, ts, http.MethodGet, "/modelkits/inspect?ref="+ref, "", &inspected))
+	assert.Equal(t, kits[0].Digest, inspected.Digest.String())
+	require.NotNil(t, inspected.Kitfile)
+	assert.Equal(t, "test-model", inspected.Kitfile.Package.Name)
+
+	var info map[string]any
+	require.Equal(t, http.StatusOK, doRequest(t, ts, http.MethodGet, "/modelkits/info?ref="+ref, "", &info))
+	assert.Contains(t, info, "model")
+
+	var tagged kit.TagResult
+	status := doRequest(t, ts, http.MethodPost, "/modelkits/tag",
+		`{"source": "example.com/test/model:v1", "target": "example.com/test/model:v2"}`, &tagged)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, kits[0].Digest, tagged.Digest)
+
+	var unpacked kit.UnpackResult
+	status = doRequest(t, ts, http.MethodPost, "/modelkits/unpack",
+		`{"reference": "example.com/test/model:v2", "dir": "out"}`, &unpacked)
+	require.Equal(t, http.StatusOK, status)
+	content, err := os.ReadFile(filepath.Join(srv.unpackRoot.Name(), "out", "model.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, "model", string(content))
+
+	var removed kit.RemoveResult
+	require.Equal(t, http.StatusOK, doRequest(t, ts, http.MethodDelete, "/modelkits?ref="+ref, "", &removed))
+	require.Equal(t, http.StatusOK, doRequest(t, ts, http.MethodGet, "/modelkits", "", &kits))
+	require.Len(t, kits, 1)
+	assert.Equal(t, []string{"v2"}, kits[0].Tags)
+}
+
+func TestServerUnpackSymlinkOutsideRoot(t *testing.T) {
+	ts, srv := newTestServer(t)
+	outsideDir := t.TempDir()
+	require.NoError(t, os.Symlink(outsideDir, filepath.Join(srv.unpackRoot.Name(), "link")))
+
+	for _, dir := range []string{"link", "link/out"} {
+		var errResp errorResponse
+		status := doRequest(t, ts, http.MethodPost, "/modelkits/unpack",
+			fmt.Sprintf(`{"reference": "example.com/test/model:v1", "dir": %q}`, dir), &errResp)
+		assert.NotEqual(t, http.StatusOK, status, "unpack to %s should fail", dir)
+		assert.NotEmpty(t, errResp.Error)
+	}
+	entries, err := os.ReadDir(outsideDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "nothing should be written outside the unpack root")
+}
+
+func TestServerErrors(t *testing.T) {
+	ts, _ := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"missing reference", http.MethodGet, "/modelkits/inspect", "", http.StatusBadRequest},
+		{"invalid reference", http.MethodGet, "/modelkits/inspect?ref=" + url.QueryEscape("Not A Ref"), "", http.StatusBadRequest},
+		{"invalid bool", http.MethodDelete, "/modelkits?ref=example.com/test/model:v1&force=maybe", "", http.StatusBadRequest},
+		{"not found", http.MethodGet, "/modelkits/info?ref=" + url.QueryEscape("example.com/test/model:missing"), "", http.StatusNotFound},
+		{"malformed body", http.MethodPost, "/modelkits/tag", `{"source":`, http.StatusBadRequest},
+		{"unknown field", http.MethodPost, "/modelkits/pull", `{"reference": "example.com/test/model:v1", "extra": true}`, http.StatusBadRequest},
+		{"unpack outside root", http.MethodPost, "/modelkits/unpack", `{"reference": "example.com/test/model:v1", "dir": "../escape"}`, http.StatusBadRequest},
+		{"unpack absolute dir", http.MethodPost, "/modelkits/unpack", `{"reference": "example.com/test/model:v1", "dir": "/tmp/escape"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errResp errorResponse
+			status := doRequest(t, ts, tt.method, tt.path, tt.body, &errResp)
+			assert.Equal(t, tt.status, status)
+			assert.NotEmpty(t, errResp.Error)
+		})
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	rec := httptest.NewRecorder()
+	events, err := newEventStream(rec)
+	require.NoError(t, err)
+
+	_, err = events.logWriter().Write([]byte("first line\nsecond line\n"))
+	require.NoError(t, err)
+	events.send("progress", kit.ProgressEvent{Operation: "pull", Bytes: 10, TotalBytes: 20})
+
+	assert.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+	expected := "event: log\ndata: {\"message\":\"first line\"}\n\n" +
+		"event: log\ndata: {\"message\":\"second line\"}\n\n" +
+		"event: progress\ndata: {\"operation\":\"pull\",\"bytes\":10,\"totalBytes\":20,\"done\":false}\n\n"
+	assert.Equal(t, expected, rec.Body.String())
+}
+
+func TestWriteQueueRunsInOrder(t *testing.T) {
+	q := newWriteQueue()
+	var order []int
+	for i := 0; i < 5; i++ {
+		err := q.do(context.Background(), func(context.Context) error {
+			order = append(order, i)
+			return nil
+		})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, order)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := q.do(ctx, func(context.Context) error {
+		t.Fatal("cancelled job should not run")
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -17,15 +17,11 @@
 package tag
 
 import (
-	"context"
-	"fmt"
-
+	"kitops/pkg/kit"
 	"kitops/pkg/lib/constants"
-	"kitops/pkg/lib/repo/util"
 	"kitops/pkg/output"
 
 	"github.com/spf13/cobra"
-	"oras.land/oras-go/v2/registry"
 )
 
 const (
@@ -68,33 +64,6 @@ organization to maintain clarity and avoid confusion.`
 	example = `kit tag myregistry.com/myrepo/mykit:latest myregistry.com/myrepo/mykit:v1.0.0`
 )
 
-type tagOptions struct {
-	configHome string
-	sourceRef  *registry.Reference
-	targetRef  *registry.Reference
-}
-
-func (opts *tagOptions) complete(ctx context.Context, args []string) error {
-
-	configHome, ok := ctx.Value(constants.ConfigKey{}).(string)
-	if !ok {
-		return fmt.Errorf("default config path not set on command context")
-	}
-	opts.configHome = configHome
-	modelRef, _, err := util.ParseReference(args[0])
-	if err != nil {
-		return fmt.Errorf("failed to parse reference: %w", err)
-	}
-	opts.sourceRef = modelRef
-
-	modelRef, _, err = util.ParseReference(args[1])
-	if err != nil {
-		return fmt.Errorf("failed to parse reference: %w", err)
-	}
-	opts.targetRef = modelRef
-	return nil
-}
-
 func TagCommand() *cobra.Command {
 
 	cmd := &cobra.Command{
@@ -102,24 +71,27 @@ func TagCommand() *cobra.Command {
 		Short:   shortDesc,
 		Long:    longDesc,
 		Example: example,
-		RunE:    runCommand(&tagOptions{}),
+		RunE:    runCommand,
 	}
 
 	cmd.Args = cobra.ExactArgs(2)
 	return cmd
 }
 
-func runCommand(opts *tagOptions) func(cmd *cobra.Command, args []string) error {
-	return func(cmd *cobra.Command, args []string) error {
-		if err := opts.complete(cmd.Context(), args); err != nil {
-			return output.Fatalf("Invalid arguments: %s", err)
-		}
-
-		err := RunTag(cmd.Context(), opts)
-		if err != nil {
-			return output.Fatalf("Failed to tag modelkit: %s", err)
-		}
-		output.Infof("Modelkit %s tagged as %s", opts.sourceRef, opts.targetRef)
-		return nil
+func runCommand(cmd *cobra.Command, args []string) error {
+	configHome, ok := cmd.Context().Value(constants.ConfigKey{}).(string)
+	if !ok {
+		return output.Fatalf("Invalid arguments: default config path not set on command context")
 	}
+
+	result, err := kit.Tag(cmd.Context(), kit.TagOptions{
+		Options: kit.Options{ConfigHome: configHome},
+		Source:  args[0],
+		Target:  args[1],
+	})
+	if err != nil {
+		return output.Fatalf("Failed to tag modelkit: %s", err)
+	}
+	output.SetResult(result)
+	return nil
 }
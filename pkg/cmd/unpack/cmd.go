@@ -19,16 +19,17 @@ package unpack
 import (
 	"context"
 	"fmt"
-	"os"
+	"io"
 	"path/filepath"
+	"strings"
+	"text/tabwriter"
 
 	"kitops/pkg/cmd/options"
+	"kitops/pkg/kit"
 	"kitops/pkg/lib/constants"
-	"kitops/pkg/lib/repo/util"
 	"kitops/pkg/output"
 
 	"github.com/spf13/cobra"
-	"oras.land/oras-go/v2/registry"
 )
 
 const (
@@ -58,8 +59,22 @@ to apply, which are matched against the Kitfile to further restrict what is extr
 Additional filters match elements of the Kitfile on either the name (if present) or
 the path used.
 
+Additional filters can also match a specific attribute using [attribute]=[value],
+where [attribute] is one of name, path, type (model parts), license, format and
+framework (model), or param (keys in the parameters of a model or dataset). Values
+are matched exactly, as a glob if they contain '*', '?' or '[', or as a regular
+expression if they are enclosed in slashes (e.g. /^train-[0-9]+$/).
+
 The filter field can be specified multiple times. A layer will be unpacked if it matches
-any of the specified filters`
+any of the specified filters, unless it matches a filter passed to --exclude, which
+uses the same format. Use --explain to show whether each layer would be unpacked
+and why, without unpacking anything.
+
+If the modelkit has variants (for example, different quantizations of the same
+model), the variant to unpack can be selected with --variant, either by name or
+using a comma-separated list of annotations to match (e.g. format=gguf) and an
+optional preference of 'smallest' or 'largest'. By default, the first variant is
+unpacked.`
 
 	example = `# Unpack all components of a modelkit to the current directory
 kit unpack myrepo/my-model:latest -d /path/to/unpacked
@@ -76,23 +91,35 @@ kit unpack myrepo/my-model:latest --filter=docs:./README.md
 # Unpack the model and the dataset named "validation"
 kit unpack myrepo/my-model:latest --filter=model --filter=datasets:validation
 
+# Unpack datasets whose names start with "train-", except for raw datasets
+kit unpack myrepo/my-model:latest --filter=datasets:train-* --exclude=datasets:raw-*
+
+# Unpack only the model parts of type "lora"
+kit unpack myrepo/my-model:latest --filter=model:type=lora
+
+# Show which layers would be unpacked by a filter, and why
+kit unpack myrepo/my-model:latest --filter=datasets:/^train-[0-9]+$/ --explain
+
 # Unpack a modelkit from a remote registry with overwrite enabled
-kit unpack registry.example.com/myrepo/my-model:latest -o -d /path/to/unpacked`
+kit unpack registry.example.com/myrepo/my-model:latest -o -d /path/to/unpacked
+
+# Unpack the smallest GGUF variant of a modelkit
+kit unpack registry.example.com/myrepo/my-model:latest --variant format=gguf,smallest`
 )
 
 type unpackOptions struct {
 	options.NetworkOptions
-	configHome  string
-	unpackDir   string
-	filters     []string
-	filterConfs []filterConf
-	unpackConf  unpackConf
-	modelRef    *registry.Reference
-	overwrite   bool
+	unpackDir  string
+	filters    []string
+	exclude    []string
+	explain    bool
+	unpackConf unpackConf
+	overwrite  bool
+	variant    string
 }
 
 // unpackConf configures which elements of the modelkit should be unpacked.
-// Deprecated: use filterConf instead, which supports advanced filtering
+// Deprecated: use filters instead, which support advanced filtering
 type unpackConf struct {
 	unpackKitfile  bool
 	unpackModels   bool
@@ -101,49 +128,56 @@ type unpackConf struct {
 	unpackDocs     bool
 }
 
-func (opts *unpackOptions) complete(ctx context.Context, args []string) error {
-	configHome, ok := ctx.Value(constants.ConfigKey{}).(string)
-	if !ok {
-		return fmt.Errorf("default config path not set on command context")
+// filter converts a (deprecated) unpackConf to a filter to enable supporting the old flags
+func (conf unpackConf) filter() string {
+	var types []string
+	if conf.unpackKitfile {
+		types = append(types, "kitfile")
 	}
-	opts.configHome = configHome
-	modelRef, extraTags, err := util.ParseReference(args[0])
-	if err != nil {
-		return fmt.Errorf("failed to parse reference: %w", err)
+	if conf.unpackModels {
+		types = append(types, "model")
 	}
-	if len(extraTags) > 0 {
-		return fmt.Errorf("can not unpack multiple tags")
+	if conf.unpackDocs {
+		types = append(types, "docs")
 	}
-	opts.modelRef = modelRef
-
-	if len(opts.filters) > 0 {
-		for _, filter := range opts.filters {
-			filterConf, err := parseFilter(filter)
-			if err != nil {
-				return err
-			}
-			opts.filterConfs = append(opts.filterConfs, *filterConf)
-		}
-	} else {
+	if conf.unpackDatasets {
+		types = append(types, "datasets")
+	}
+	if conf.unpackCode {
+		types = append(types, "code")
+	}
+	return strings.Join(types, ",")
+}
+
+func (opts *unpackOptions) complete(ctx context.Context, args []string) (*kit.UnpackOptions, error) {
+	configHome, ok := ctx.Value(constants.ConfigKey{}).(string)
+	if !ok {
+		return nil, fmt.Errorf("default config path not set on command context")
+	}
+	filters := opts.filters
+	if len(filters) == 0 {
 		// Deprecated, but handle original filtering flags as well for now
-		conf := opts.unpackConf
-		if conf.unpackKitfile || conf.unpackModels || conf.unpackCode || conf.unpackDatasets || conf.unpackDocs {
-			opts.filterConfs = filtersFromUnpackConf(conf)
+		if filter := opts.unpackConf.filter(); filter != "" {
+			filters = []string{filter}
 		}
 	}
-
 	absDir, err := filepath.Abs(opts.unpackDir)
 	if err != nil {
-		return fmt.Errorf("failed to resolve absolute path %s: %w", opts.unpackDir, err)
+		return nil, fmt.Errorf("failed to resolve absolute path %s: %w", opts.unpackDir, err)
 	}
 	opts.unpackDir = absDir
 
-	if err := opts.NetworkOptions.Complete(ctx, args); err != nil {
-		return err
-	}
-
-	printConfig(opts)
-	return nil
+	return &kit.UnpackOptions{
+		Options:   kit.Options{ConfigHome: configHome},
+		Remote:    kit.NewRemoteOptions(&opts.NetworkOptions),
+		Reference: args[0],
+		Dir:       opts.unpackDir,
+		Filters:   filters,
+		Exclude:   opts.exclude,
+		Explain:   opts.explain,
+		Overwrite: opts.overwrite,
+		Variant:   opts.variant,
+	}, nil
 }
 
 func UnpackCommand() *cobra.Command {
@@ -161,12 +195,19 @@ func UnpackCommand() *cobra.Command {
 	cmd.Flags().StringVarP(&opts.unpackDir, "dir", "d", "", "The target directory to unpack components into. This directory will be created if it does not exist")
 	cmd.Flags().BoolVarP(&opts.overwrite, "overwrite", "o", false, "Overwrites existing files and directories in the target unpack directory without prompting")
 	cmd.Flags().StringArrayVarP(&opts.filters, "filter", "f", []string{}, "Filter what is unpacked from the modelkit based on type and name. Can be specified multiple times")
+	cmd.Flags().StringArrayVar(&opts.exclude, "exclude", []string{}, "Exclude layers matching a filter from being unpacked. Can be specified multiple times")
+	cmd.Flags().BoolVar(&opts.explain, "explain", false, "Show whether each layer would be unpacked and why, without unpacking")
+	cmd.Flags().StringVar(&opts.variant, "variant", "", "Select the variant to unpack if the modelkit has variants, by name or by annotations (e.g. format=gguf,smallest)")
 	cmd.Flags().BoolVar(&opts.unpackConf.unpackKitfile, "kitfile", false, "Unpack only Kitfile (deprecated: use --filter=kitfile)")
 	cmd.Flags().BoolVar(&opts.unpackConf.unpackModels, "model", false, "Unpack only model (deprecated: use --filter=model)")
 	cmd.Flags().BoolVar(&opts.unpackConf.unpackCode, "code", false, "Unpack only code (deprecated: use --filter=code)")
 	cmd.Flags().BoolVar(&opts.unpackConf.unpackDatasets, "datasets", false, "Unpack only datasets (deprecated: use --filter=datasets)")
 	cmd.Flags().BoolVar(&opts.unpackConf.unpackDocs, "docs", false, "Unpack only docs (deprecated: use --filter=docs)")
-	opts.AddNetworkFlags(cmd)
+	options.AddNetworkFlags(cmd, &opts.NetworkOptions)
+	options.AddLimitRateFlag(cmd, &opts.NetworkOptions)
+	// There is no --parallel-chunks flag: when unpacking from a remote registry, each layer is
+	// extracted as it is streamed, which requires reading it sequentially. Ranged downloads only
+	// apply when layers are downloaded into local storage (i.e. kit pull).
 	cmd.Flags().SortFlags = false
 
 	return cmd
@@ -174,38 +215,40 @@ func UnpackCommand() *cobra.Command {
 
 func runCommand(opts *unpackOptions) func(*cobra.Command, []string) error {
 	return func(cmd *cobra.Command, args []string) error {
-		if err := opts.complete(cmd.Context(), args); err != nil {
+		unpackOpts, err := opts.complete(cmd.Context(), args)
+		if err != nil {
 			return output.Fatalf("Invalid arguments: %s", err)
 		}
+		output.Debugf("Overwrite: %t", opts.overwrite)
 
-		if opts.modelRef.Reference == "" {
-			return output.Fatalf("Invalid reference: unpacking requires a tag or digest")
-		}
-
-		unpackTo := opts.unpackDir
-		if unpackTo == "" {
-			unpackTo = "current directory"
+		if !opts.explain {
+			output.Infof("Unpacking to %s", opts.unpackDir)
 		}
-		// Make sure target directory exists, in case user is using the -d flag
-		if err := os.MkdirAll(opts.unpackDir, 0755); err != nil {
-			return output.Fatalf("failed to create directory %s: %w", opts.unpackDir, err)
-		}
-		// Change working directory to context path to make sure relative paths within
-		// tarballs are correct. This is the equivalent of using the -C parameter for tar
-		if err := os.Chdir(opts.unpackDir); err != nil {
-			return output.Fatalf("Failed to use unpack path %s: %w", opts.unpackDir, err)
-		}
-
-		output.Infof("Unpacking to %s", unpackTo)
-		err := runUnpack(cmd.Context(), opts)
+		result, err := kit.Unpack(cmd.Context(), *unpackOpts)
 		if err != nil {
 			return output.Fatalln(err)
 		}
+		output.SetResult(result)
+		if opts.explain && !output.StructuredOutput() {
+			printExplanations(cmd.OutOrStdout(), result.Explanations)
+		}
 		return nil
 	}
 }
 
-func printConfig(opts *unpackOptions) {
-	output.Debugf("Overwrite: %t", opts.overwrite)
-	output.Debugf("Unpacking %s", opts.modelRef.String())
+func printExplanations(w io.Writer, explanations []kit.LayerExplanation) {
+	tw := tabwriter.NewWriter(w, 0, 2, 3, ' ', 0)
+	fmt.Fprintln(tw, "REFERENCE\tTYPE\tNAME\tPATH\tUNPACK\tREASON")
+	for _, e := range explanations {
+		unpack := "no"
+		if e.Selected {
+			unpack = "yes"
+		}
+		name := e.Name
+		if name == "" {
+			name = "<none>"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", e.Reference, e.Type, name, e.Path, unpack, e.Reason)
+	}
+	tw.Flush()
 }
@@ -39,6 +39,13 @@ type versionOpts struct {
 	shouldShowNotifications bool
 }
 
+type versionInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Built     string `json:"built"`
+	GoVersion string `json:"goVersion"`
+}
+
 func VersionCommand() *cobra.Command {
 	opts := &versionOpts{}
 
@@ -55,6 +62,13 @@ func VersionCommand() *cobra.Command {
 				if err := update.SetShowNotifications(configHome, opts.shouldShowNotifications); err != nil {
 					output.Fatalln(err)
 				}
+			} else if output.StructuredOutput() {
+				output.SetResult(versionInfo{
+					Version:   constants.Version,
+					Commit:    constants.GitCommit,
+					Built:     constants.BuildTime,
+					GoVersion: constants.GoVersion,
+				})
 			} else {
 				output.Infof("Version: %s\nCommit: %s\nBuilt: %s\nGo version: %s\n", constants.Version, constants.GitCommit, constants.BuildTime, constants.GoVersion)
 			}
@@ -14,23 +14,42 @@
 //
 // SPDX-License-Identifier: Apache-2.0
 
-package diff
+package kit
 
 import (
 	"context"
 	"fmt"
 	"sort"
 	"strings"
+	"sync"
 
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"oras.land/oras-go/v2/registry"
 
 	"kitops/pkg/lib/constants"
+	"kitops/pkg/lib/network"
 	"kitops/pkg/lib/repo/local"
 	"kitops/pkg/lib/repo/remote"
 	"kitops/pkg/lib/repo/util"
 )
 
+const (
+	// Prefixes for selecting where a ModelKit in a diff is read from
+	remotePrefix = "remote://"
+	localPrefix  = "local://"
+)
+
+// DiffOptions configures Diff.
+type DiffOptions struct {
+	Options
+	Remote RemoteOptions
+	// ModelKit1 and ModelKit2 are the references to compare. A reference may be prefixed
+	// with 'local://' or 'remote://' to select where it is read from; otherwise, local
+	// storage is checked first, followed by the remote registry.
+	ModelKit1 string
+	ModelKit2 string
+}
+
 // Helper struct diffInfo holds the manifest and its descriptor for a ModelKit.
 type diffInfo struct {
 	Manifest   *ocispec.Manifest
@@ -39,11 +58,86 @@ type diffInfo struct {
 
 // Helper struct DiffResult contains the comparison results between two ModelKits.
 type DiffResult struct {
-	SameConfig       bool
-	AnnotationsMatch bool
-	SharedLayers     []ocispec.Descriptor
-	UniqueLayersA    []ocispec.Descriptor
-	UniqueLayersB    []ocispec.Descriptor
+	SameConfig       bool                 `json:"sameConfig"`
+	AnnotationsMatch bool                 `json:"annotationsMatch"`
+	SharedLayers     []ocispec.Descriptor `json:"sharedLayers"`
+	UniqueLayersA    []ocispec.Descriptor `json:"uniqueLayersA"`
+	UniqueLayersB    []ocispec.Descriptor `json:"uniqueLayersB"`
+}
+
+// DiffOutput is the result of comparing two ModelKits. If the ModelKits are identical,
+// DiffResult is nil.
+type DiffOutput struct {
+	ModelKit1 string `json:"modelkit1"`
+	ModelKit2 string `json:"modelkit2"`
+	Identical bool   `json:"identical"`
+	*DiffResult
+	// Manifests for ModelKit1 and ModelKit2, respectively
+	Manifest1 *ocispec.Manifest `json:"-"`
+	Manifest2 *ocispec.Manifest `json:"-"`
+}
+
+// Diff compares the manifests of two ModelKits, which may be stored locally or in a
+// remote registry.
+func Diff(ctx context.Context, opts DiffOptions) (*DiffOutput, error) {
+	ctx, configHome, err := opts.setup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	refA, err := registry.ParseReference(removePrefix(opts.ModelKit1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse reference for ref1: %w", err)
+	}
+	refB, err := registry.ParseReference(removePrefix(opts.ModelKit2))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse reference for ref2: %w", err)
+	}
+	network, err := opts.Remote.networkOptions(configHome)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		diffA, diffB *diffInfo
+		errA, errB   error
+	)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		diffA, errA = getManifest(ctx, opts.ModelKit1, &refA, configHome, network)
+	}()
+	go func() {
+		defer wg.Done()
+		diffB, errB = getManifest(ctx, opts.ModelKit2, &refB, configHome, network)
+	}()
+	wg.Wait()
+
+	if errA != nil {
+		return nil, fmt.Errorf("failed to get manifest for ModelKit1: %w", errA)
+	}
+	if errB != nil {
+		return nil, fmt.Errorf("failed to get manifest for ModelKit2: %w", errB)
+	}
+
+	result := &DiffOutput{
+		ModelKit1: refA.String(),
+		ModelKit2: refB.String(),
+		Manifest1: diffA.Manifest,
+		Manifest2: diffB.Manifest,
+	}
+	if diffA.Descriptor.Digest == diffB.Descriptor.Digest {
+		result.Identical = true
+		return result, nil
+	}
+	result.DiffResult = CompareManifests(diffA.Manifest, diffB.Manifest)
+	return result, nil
+}
+
+func removePrefix(arg string) string {
+	arg = strings.TrimPrefix(arg, remotePrefix)
+	arg = strings.TrimPrefix(arg, localPrefix)
+	return arg
 }
 
 // compareManifests compares two OCI manifests and returns the shared and unique layers.
@@ -104,22 +198,22 @@ func CompareManifests(manifestA *ocispec.Manifest, manifestB *ocispec.Manifest)
 	return result
 }
 
-func getManifest(ctx context.Context, arg string, ref *registry.Reference, opts *diffOptions) (*diffInfo, error) {
+func getManifest(ctx context.Context, arg string, ref *registry.Reference, configHome string, network *network.Options) (*diffInfo, error) {
 	if strings.HasPrefix(arg, remotePrefix) {
-		return getManifestFromRemote(ctx, ref, opts)
+		return getManifestFromRemote(ctx, ref, network)
 	} else if strings.HasPrefix(arg, localPrefix) {
-		return getManifestFromLocal(ctx, ref, opts)
+		return getManifestFromLocal(ctx, ref, configHome)
 	} else {
-		manifest, err := getManifestFromLocal(ctx, ref, opts)
+		manifest, err := getManifestFromLocal(ctx, ref, configHome)
 		if err != nil {
-			return getManifestFromRemote(ctx, ref, opts)
+			return getManifestFromRemote(ctx, ref, network)
 		}
 		return manifest, nil
 	}
 }
 
-func getManifestFromRemote(ctx context.Context, ref *registry.Reference, opts *diffOptions) (*diffInfo, error) {
-	repository, err := remote.NewRepository(ctx, ref.Registry, ref.Repository, &opts.NetworkOptions)
+func getManifestFromRemote(ctx context.Context, ref *registry.Reference, network *network.Options) (*diffInfo, error) {
+	repository, err := remote.NewRepository(ctx, ref.Registry, ref.Repository, network)
 	if err != nil {
 		return nil, err
 	}
@@ -133,8 +227,8 @@ func getManifestFromRemote(ctx context.Context, ref *registry.Reference, opts *d
 	}, nil
 }
 
-func getManifestFromLocal(ctx context.Context, ref *registry.Reference, opts *diffOptions) (*diffInfo, error) {
-	storageRoot := constants.StoragePath(opts.configHome)
+func getManifestFromLocal(ctx context.Context, ref *registry.Reference, configHome string) (*diffInfo, error) {
+	storageRoot := constants.StoragePath(configHome)
 	localRepo, err := local.NewLocalRepo(storageRoot, ref)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read local storage: %w", err)
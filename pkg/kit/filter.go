@@ -0,0 +1,383 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kit
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"kitops/pkg/artifact"
+	"kitops/pkg/lib/constants"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// filterAttributes lists the attributes of Kitfile entries that can be matched using
+// <attribute>=<value>. Filters that do not specify an attribute match the name or path.
+var filterAttributes = []string{"name", "path", "type", "license", "format", "framework", "param"}
+
+type filterConf struct {
+	// filter is the filter as it was specified, used to explain matches
+	filter    string
+	baseTypes []string
+	fields    []fieldFilter
+}
+
+// fieldFilter matches a single attribute of a Kitfile entry. Values are matched exactly, as a
+// glob if they contain any of '*', '?' or '[', or as a regular expression if they are enclosed
+// in slashes (e.g. /^train-[0-9]+$/).
+type fieldFilter struct {
+	// attribute is the attribute to match; if empty, either the name or path may match
+	attribute string
+	value     string
+	glob      bool
+	regex     *regexp.Regexp
+}
+
+// layerFilter selects layers using include and exclude filters. A layer is selected if it
+// matches any include filter (or there are no include filters) and does not match any exclude
+// filter.
+type layerFilter struct {
+	include []filterConf
+	exclude []filterConf
+}
+
+// selects returns true if layer, an entry in a Kitfile, is selected by the filter.
+func (lf layerFilter) selects(layer any) bool {
+	selected, _ := lf.explain(layer)
+	return selected
+}
+
+// explain returns whether layer is selected by the filter, along with the reason why.
+func (lf layerFilter) explain(layer any) (bool, string) {
+	reason := "no filters specified"
+	if len(lf.include) > 0 {
+		conf, match, ok := matchFilters(layer, lf.include)
+		if !ok {
+			return false, "does not match any filter"
+		}
+		reason = describeMatch("matches filter", conf, match)
+	}
+	if conf, match, ok := matchFilters(layer, lf.exclude); ok {
+		return false, describeMatch("excluded by filter", conf, match)
+	}
+	return true, reason
+}
+
+func describeMatch(prefix string, conf *filterConf, match string) string {
+	if match == "" {
+		return fmt.Sprintf("%s '%s'", prefix, conf.filter)
+	}
+	return fmt.Sprintf("%s '%s' (%s)", prefix, conf.filter, match)
+}
+
+// matchFilters returns the first filter in filters that matches layer, along with a
+// description of the attribute that matched, which is empty if the filter matches on type only.
+func matchFilters(layer any, filters []filterConf) (*filterConf, string, bool) {
+	baseType, attrs := layerAttributes(layer)
+	if baseType == "" {
+		return nil, "", false
+	}
+	for idx := range filters {
+		if match, ok := filters[idx].match(baseType, attrs); ok {
+			return &filters[idx], match, true
+		}
+	}
+	return nil, "", false
+}
+
+func (fc *filterConf) match(baseType string, attrs map[string][]string) (string, bool) {
+	if !slices.Contains(fc.baseTypes, baseType) {
+		return "", false
+	}
+	if len(fc.fields) == 0 || baseType == constants.ConfigType {
+		// By default everything matches
+		return "", true
+	}
+	for _, field := range fc.fields {
+		if match, ok := field.match(attrs); ok {
+			return match, true
+		}
+	}
+	return "", false
+}
+
+func (ff *fieldFilter) match(attrs map[string][]string) (string, bool) {
+	attributes := []string{ff.attribute}
+	if ff.attribute == "" {
+		attributes = []string{"name", "path"}
+	}
+	for _, attribute := range attributes {
+		for _, value := range attrs[attribute] {
+			if ff.matchesValue(value) {
+				return fmt.Sprintf("%s '%s'", attribute, value), true
+			}
+		}
+	}
+	return "", false
+}
+
+func (ff *fieldFilter) matchesValue(value string) bool {
+	switch {
+	case ff.regex != nil:
+		return ff.regex.MatchString(value)
+	case ff.glob:
+		matched, _ := path.Match(ff.value, value)
+		return matched
+	default:
+		return ff.value == value
+	}
+}
+
+// layerAttributes returns the media base type of a Kitfile entry and the values of its
+// attributes that filters can match. Model parts use the model base type, as they are
+// selected by 'model' filters. Returns an empty base type for unknown entries.
+func layerAttributes(layer any) (string, map[string][]string) {
+	// The type switch below checks for concrete (non-pointer) types. We need to use
+	// reflect to dereference the pointer and get a new interface{} (any) type.
+	if val := reflect.ValueOf(layer); val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return "", nil
+		}
+		layer = val.Elem().Interface()
+	}
+
+	attrs := map[string][]string{}
+	add := func(attribute, value string) {
+		if value != "" {
+			attrs[attribute] = append(attrs[attribute], value)
+		}
+	}
+	addParams := func(parameters any) {
+		if params, ok := parameters.(map[string]any); ok {
+			for _, key := range slices.Sorted(maps.Keys(params)) {
+				add("param", key)
+			}
+		}
+	}
+	switch l := layer.(type) {
+	case artifact.KitFile:
+		return constants.ConfigType, attrs
+	case artifact.Model:
+		add("name", l.Name)
+		add("path", l.Path)
+		add("license", l.License)
+		add("format", l.Format)
+		add("framework", l.Framework)
+		addParams(l.Parameters)
+		return constants.ModelType, attrs
+	case artifact.ModelPart:
+		add("name", l.Name)
+		add("path", l.Path)
+		add("license", l.License)
+		add("type", l.Type)
+		return constants.ModelType, attrs
+	case artifact.DataSet:
+		add("name", l.Name)
+		add("path", l.Path)
+		add("license", l.License)
+		addParams(l.Parameters)
+		return constants.DatasetType, attrs
+	case artifact.Code:
+		// Code does not have a ID/name field so we can only match on path
+		add("path", l.Path)
+		add("license", l.License)
+		return constants.CodeType, attrs
+	case artifact.Docs:
+		// Docs does not have an ID/name field so we can only match on path
+		add("path", l.Path)
+		return constants.DocsType, attrs
+	default:
+		return "", nil
+	}
+}
+
+// parseFilters parses filters in the format accepted by parseFilter.
+func parseFilters(filters []string) ([]filterConf, error) {
+	var filterConfs []filterConf
+	for _, filter := range filters {
+		filterConf, err := parseFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+		filterConfs = append(filterConfs, *filterConf)
+	}
+	return filterConfs, nil
+}
+
+// parseFilter parses a filter in the format <type1>,<type2>[:<filter1>,<filter2>], where each
+// additional filter is a value optionally prefixed with an attribute (<attribute>=<value>).
+func parseFilter(filter string) (*filterConf, error) {
+	types, fields, hasFields := strings.Cut(filter, ":")
+
+	conf := &filterConf{filter: filter}
+
+	for _, filterType := range strings.Split(types, ",") {
+		baseType, err := filterToMediaBaseType(filterType)
+		if err != nil {
+			return nil, err
+		}
+		conf.baseTypes = append(conf.baseTypes, baseType)
+	}
+
+	// Check for additional filtering based on name/path
+	if !hasFields {
+		return conf, nil
+	}
+
+	for _, term := range splitFilterTerms(fields) {
+		field, err := parseFieldFilter(term)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter %s: %w", filter, err)
+		}
+		conf.fields = append(conf.fields, *field)
+	}
+	return conf, nil
+}
+
+func parseFieldFilter(term string) (*fieldFilter, error) {
+	field := &fieldFilter{value: term}
+	if attribute, value, ok := strings.Cut(term, "="); ok && !strings.HasPrefix(term, "/") {
+		if !slices.Contains(filterAttributes, attribute) {
+			return nil, fmt.Errorf("unknown attribute %s (must be one of %s)", attribute, strings.Join(filterAttributes, ", "))
+		}
+		field.attribute = attribute
+		field.value = value
+	}
+	switch {
+	case isRegexFilter(field.value):
+		regex, err := regexp.Compile(field.value[1 : len(field.value)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %s: %w", field.value, err)
+		}
+		field.regex = regex
+	case strings.ContainsAny(field.value, "*?["):
+		if _, err := path.Match(field.value, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", field.value, err)
+		}
+		field.glob = true
+	}
+	return field, nil
+}
+
+// splitFilterTerms splits a comma-separated list of filter terms. Commas in regular expressions
+// (e.g. /a{1,2}/) do not separate terms.
+func splitFilterTerms(fields string) []string {
+	var terms []string
+	for _, part := range strings.Split(fields, ",") {
+		if len(terms) > 0 {
+			last := terms[len(terms)-1]
+			if value := termValue(last); strings.HasPrefix(value, "/") && !isRegexFilter(value) {
+				terms[len(terms)-1] = last + "," + part
+				continue
+			}
+		}
+		terms = append(terms, part)
+	}
+	return terms
+}
+
+// termValue returns the value of a filter term, without the attribute if one is specified.
+func termValue(term string) string {
+	if attribute, value, ok := strings.Cut(term, "="); ok && slices.Contains(filterAttributes, attribute) {
+		return value
+	}
+	return term
+}
+
+func isRegexFilter(value string) bool {
+	return len(value) >= 2 && strings.HasPrefix(value, "/") && strings.HasSuffix(value, "/")
+}
+
+// filterLayers returns the layers in manifest whose entries in config are selected by filter.
+// If filter has no include or exclude filters, all layers are selected.
+func filterLayers(manifest *ocispec.Manifest, config *artifact.KitFile, filter layerFilter) ([]ocispec.Descriptor, error) {
+	entries, err := layerEntries(manifest, config)
+	if err != nil {
+		return nil, err
+	}
+	layers := []ocispec.Descriptor{}
+	for idx, layerDesc := range manifest.Layers {
+		if filter.selects(entries[idx]) {
+			layers = append(layers, layerDesc)
+		}
+	}
+	return layers, nil
+}
+
+// layerEntries returns the Kitfile entry (e.g. an artifact.DataSet) that describes each layer in
+// manifest, in the same order as manifest.Layers. Layers are matched to entries by their order
+// within each type, as older ModelKits do not record layer digests in the config. Layers of
+// unknown types have a nil entry.
+func layerEntries(manifest *ocispec.Manifest, config *artifact.KitFile) ([]any, error) {
+	var modelPartIdx, codeIdx, datasetIdx, docsIdx int
+	entries := make([]any, len(manifest.Layers))
+	for idx, layerDesc := range manifest.Layers {
+		baseType := constants.ParseMediaType(layerDesc.MediaType).BaseType
+		switch baseType {
+		case constants.ModelType:
+			if config.Model != nil {
+				entries[idx] = config.Model
+			}
+		case constants.ModelPartType:
+			if config.Model != nil && modelPartIdx < len(config.Model.Parts) {
+				entries[idx] = config.Model.Parts[modelPartIdx]
+			}
+			modelPartIdx += 1
+		case constants.CodeType:
+			if codeIdx < len(config.Code) {
+				entries[idx] = config.Code[codeIdx]
+			}
+			codeIdx += 1
+		case constants.DatasetType:
+			if datasetIdx < len(config.DataSets) {
+				entries[idx] = config.DataSets[datasetIdx]
+			}
+			datasetIdx += 1
+		case constants.DocsType:
+			if docsIdx < len(config.Docs) {
+				entries[idx] = config.Docs[docsIdx]
+			}
+			docsIdx += 1
+		default:
+			continue
+		}
+		if entries[idx] == nil {
+			return nil, fmt.Errorf("config does not contain an entry for %s layer %s", baseType, layerDesc.Digest)
+		}
+	}
+	return entries, nil
+}
+
+func filterToMediaBaseType(filterType string) (string, error) {
+	switch filterType {
+	case "kitfile":
+		return constants.ConfigType, nil
+	case "datasets":
+		// annoyingly, the mediatype is dataset, but for the filter we want the plural
+		return constants.DatasetType, nil
+	case constants.ModelType, constants.CodeType, constants.DocsType:
+		return filterType, nil
+	default:
+		return "", fmt.Errorf("invalid filter type %s (must be one of 'kitfile', 'model', 'datasets', 'code', or 'docs')", filterType)
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kit
+
+import (
+	"testing"
+
+	"kitops/pkg/artifact"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterLayers(t *testing.T) {
+	layer := func(mediaType, name string) ocispec.Descriptor {
+		return ocispec.Descriptor{MediaType: mediaType, Digest: digest.FromString(name)}
+	}
+	model := layer("application/vnd.kitops.modelkit.model.v1.tar+gzip", "model")
+	part := layer("application/vnd.kitops.modelkit.modelpart.v1.tar+gzip", "part")
+	train := layer("application/vnd.kitops.modelkit.dataset.v1.tar+gzip", "train")
+	validation := layer("application/vnd.kitops.modelkit.dataset.v1.tar+gzip", "validation")
+	docs := layer("application/vnd.kitops.modelkit.docs.v1.tar+gzip", "docs")
+	manifest := &ocispec.Manifest{Layers: []ocispec.Descriptor{model, part, train, validation, docs}}
+	config := &artifact.KitFile{
+		Model: &artifact.Model{Name: "my-model", Path: "model.gguf", Parts: []artifact.ModelPart{{Path: "tokenizer"}}},
+		DataSets: []artifact.DataSet{
+			{Name: "train", Path: "data/train.csv"},
+			{Name: "validation", Path: "data/validation.csv"},
+		},
+		Docs: []artifact.Docs{{Path: "README.md"}},
+	}
+
+	tests := []struct {
+		filters  []string
+		expected []ocispec.Descriptor
+	}{
+		{filters: nil, expected: manifest.Layers},
+		{filters: []string{"model"}, expected: []ocispec.Descriptor{model, part}},
+		{filters: []string{"datasets:validation"}, expected: []ocispec.Descriptor{validation}},
+		{filters: []string{"datasets:data/train.csv", "docs"}, expected: []ocispec.Descriptor{train, docs}},
+		{filters: []string{"kitfile"}, expected: []ocispec.Descriptor{}},
+	}
+	for _, tt := range tests {
+		filterConfs, err := parseFilters(tt.filters)
+		require.NoError(t, err)
+		layers, err := filterLayers(manifest, config, layerFilter{include: filterConfs})
+		require.NoError(t, err, "filters %v", tt.filters)
+		assert.Equal(t, tt.expected, layers, "filters %v", tt.filters)
+	}
+
+	// Layers that do not correspond to an entry in the config are an error
+	config.DataSets = config.DataSets[:1]
+	_, err := filterLayers(manifest, config, layerFilter{})
+	assert.Error(t, err)
+}
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		filter    string
+		expectErr bool
+		matches   []any
+		excludes  []any
+	}{
+		{
+			filter:   "datasets:train",
+			matches:  []any{artifact.DataSet{Name: "train"}, artifact.DataSet{Path: "train"}},
+			excludes: []any{artifact.DataSet{Name: "train-2"}, artifact.Code{Path: "train"}},
+		},
+		{
+			filter:   "datasets:train-*,data/*.csv",
+			matches:  []any{artifact.DataSet{Name: "train-1"}, artifact.DataSet{Path: "data/val.csv"}},
+			excludes: []any{artifact.DataSet{Name: "val-1", Path: "data/val/x.csv"}},
+		},
+		{
+			filter:   "datasets:/^train-[0-9]{1,2}$/",
+			matches:  []any{artifact.DataSet{Name: "train-10"}},
+			excludes: []any{artifact.DataSet{Name: "train-100"}, artifact.DataSet{Name: "train-a"}},
+		},
+		{
+			filter:   "model:type=lora",
+			matches:  []any{artifact.ModelPart{Path: "adapter", Type: "lora"}},
+			excludes: []any{artifact.ModelPart{Name: "lora"}, &artifact.Model{Name: "lora"}},
+		},
+		{
+			filter:   "datasets,code:license=Apache-*",
+			matches:  []any{artifact.Code{License: "Apache-2.0"}, artifact.DataSet{License: "Apache-2.0"}},
+			excludes: []any{artifact.Code{License: "MIT"}, artifact.DataSet{}},
+		},
+		{
+			filter:   "datasets:param=split",
+			matches:  []any{artifact.DataSet{Parameters: map[string]any{"split": "train", "rows": 10}}},
+			excludes: []any{artifact.DataSet{Parameters: map[string]any{"rows": 10}}, artifact.DataSet{Name: "split"}},
+		},
+		{
+			filter:  "kitfile",
+			matches: []any{&artifact.KitFile{}},
+		},
+		{filter: "datasets:unknown=value", expectErr: true},
+		{filter: "datasets:/[/", expectErr: true},
+		{filter: "datasets:[", expectErr: true},
+		{filter: "models", expectErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			conf, err := parseFilter(tt.filter)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			filter := layerFilter{include: []filterConf{*conf}}
+			for _, layer := range tt.matches {
+				assert.True(t, filter.selects(layer), "should match %+v", layer)
+			}
+			for _, layer := range tt.excludes {
+				assert.False(t, filter.selects(layer), "should not match %+v", layer)
+			}
+		})
+	}
+}
+
+func TestLayerFilterExplain(t *testing.T) {
+	include, err := parseFilters([]string{"model", "datasets:train-*"})
+	require.NoError(t, err)
+	exclude, err := parseFilters([]string{"datasets:path=raw/*"})
+	require.NoError(t, err)
+	filter := layerFilter{include: include, exclude: exclude}
+
+	tests := []struct {
+		layer    any
+		selected bool
+		reason   string
+	}{
+		{&artifact.Model{Name: "m"}, true, "matches filter 'model'"},
+		{artifact.DataSet{Name: "train-1", Path: "data/train"}, true, "matches filter 'datasets:train-*' (name 'train-1')"},
+		{artifact.DataSet{Name: "train-2", Path: "raw/train"}, false, "excluded by filter 'datasets:path=raw/*' (path 'raw/train')"},
+		{artifact.DataSet{Name: "validation"}, false, "does not match any filter"},
+		{artifact.Docs{Path: "README.md"}, false, "does not match any filter"},
+	}
+	for _, tt := range tests {
+		selected, reason := filter.explain(tt.layer)
+		assert.Equal(t, tt.selected, selected, "layer %+v", tt.layer)
+		assert.Equal(t, tt.reason, reason, "layer %+v", tt.layer)
+	}
+
+	selected, reason := layerFilter{}.explain(artifact.Docs{Path: "README.md"})
+	assert.True(t, selected)
+	assert.Equal(t, "no filters specified", reason)
+}
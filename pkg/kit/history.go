@@ -0,0 +1,169 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"kitops/pkg/lib/filesystem/cache"
+	"kitops/pkg/lib/repo/local"
+	"kitops/pkg/output"
+
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	pushHistoryFilename = "history.json"
+	// maxPushHistory is the number of recent pushes remembered for suggesting mount sources
+	maxPushHistory = 50
+	// maxHistoryMountSources limits how many repositories from push history are tried when
+	// mounting a single blob
+	maxHistoryMountSources = 3
+)
+
+// pushHistory records which blobs were recently pushed to which repositories, so that
+// later pushes to the same registry can mount those blobs instead of uploading them.
+// It is stored in the cache directory: losing it only means blobs may be uploaded again.
+type pushHistory struct {
+	Pushes []pushRecord `json:"pushes"`
+	path   string
+}
+
+type pushRecord struct {
+	Registry   string          `json:"registry"`
+	Repository string          `json:"repository"`
+	PushedAt   time.Time       `json:"pushedAt"`
+	Blobs      []digest.Digest `json:"blobs"`
+}
+
+// loadPushHistory reads push history from the cache directory. Since history is only used
+// as a hint, errors are logged and an empty history is returned.
+func loadPushHistory(ctx context.Context) *pushHistory {
+	historyPath, err := cache.CacheFilePath(ctx, cache.CachePushSubdir, pushHistoryFilename)
+	if err != nil {
+		output.FromContext(ctx).Debugf("Failed to get push history path: %s", err)
+		return &pushHistory{}
+	}
+	history := &pushHistory{path: historyPath}
+	history.read(ctx)
+	return history
+}
+
+// read replaces the contents of the history with what is currently saved on disk.
+func (h *pushHistory) read(ctx context.Context) {
+	h.Pushes = nil
+	historyBytes, err := os.ReadFile(h.path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			output.FromContext(ctx).Debugf("Failed to read push history: %s", err)
+		}
+		return
+	}
+	if err := json.Unmarshal(historyBytes, h); err != nil {
+		output.FromContext(ctx).Debugf("Ignoring invalid push history: %s", err)
+		h.Pushes = nil
+	}
+}
+
+// mountSources returns repositories on registry, other than destRepo, that were recently
+// pushed with blob dgst, most recent first.
+func (h *pushHistory) mountSources(registry, destRepo string, dgst digest.Digest) []string {
+	var sources []string
+	for _, record := range h.Pushes {
+		if len(sources) >= maxHistoryMountSources {
+			break
+		}
+		if record.Registry != registry || record.Repository == destRepo {
+			continue
+		}
+		if slices.Contains(record.Blobs, dgst) {
+			sources = append(sources, record.Repository)
+		}
+	}
+	return sources
+}
+
+// record adds a push to the history, replacing any previous record for the same repository.
+func (h *pushHistory) record(registry, repository string, blobs []digest.Digest) {
+	h.Pushes = slices.DeleteFunc(h.Pushes, func(record pushRecord) bool {
+		return record.Registry == registry && record.Repository == repository
+	})
+	newRecord := pushRecord{
+		Registry:   registry,
+		Repository: repository,
+		PushedAt:   time.Now(),
+		Blobs:      blobs,
+	}
+	h.Pushes = append([]pushRecord{newRecord}, h.Pushes...)
+	if len(h.Pushes) > maxPushHistory {
+		h.Pushes = h.Pushes[:maxPushHistory]
+	}
+}
+
+// update records a push and saves the history. Other kit processes may have saved pushes since
+// the history was loaded, so it is re-read and saved while holding the lock on local storage at
+// storagePath.
+func (h *pushHistory) update(ctx context.Context, storagePath, registry, repository string, blobs []digest.Digest) error {
+	if h.path == "" {
+		h.record(registry, repository, blobs)
+		return nil
+	}
+	unlock, err := local.LockStorage(storagePath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	h.read(ctx)
+	h.record(registry, repository, blobs)
+	return h.save()
+}
+
+// save writes history to disk. The file is replaced atomically so that concurrent pushes
+// cannot leave it partially written.
+func (h *pushHistory) save() error {
+	if h.path == "" {
+		return nil
+	}
+	historyBytes, err := json.Marshal(h)
+	if err != nil {
+		return fmt.Errorf("failed to serialize push history: %w", err)
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(h.path), "history-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to save push history: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.Write(historyBytes); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to save push history: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to save push history: %w", err)
+	}
+	if err := os.Rename(tmpFile.Name(), h.path); err != nil {
+		return fmt.Errorf("failed to save push history: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"kitops/pkg/lib/constants"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushHistory(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constants.ConfigKey{}, t.TempDir())
+	shared := digest.FromString("shared")
+	unique := digest.FromString("unique")
+
+	history := loadPushHistory(ctx)
+	history.record("registry.example.com", "team/model-v1", []digest.Digest{shared, unique})
+	history.record("other.example.com", "team/model-v1", []digest.Digest{shared})
+	require.NoError(t, history.save())
+
+	history = loadPushHistory(ctx)
+	assert.Equal(t, []string{"team/model-v1"}, history.mountSources("registry.example.com", "team/model-v2", shared))
+	assert.Empty(t, history.mountSources("registry.example.com", "team/model-v1", shared), "destination repository should not be a mount source")
+	assert.Empty(t, history.mountSources("registry.example.com", "team/model-v2", digest.FromString("missing")))
+
+	// Pushing the same repository again replaces its record
+	history.record("registry.example.com", "team/model-v1", []digest.Digest{shared})
+	assert.Empty(t, history.mountSources("registry.example.com", "team/model-v2", unique))
+
+	for i := 0; i < maxPushHistory+10; i++ {
+		history.record("registry.example.com", fmt.Sprintf("team/model-%d", i), []digest.Digest{shared})
+	}
+	assert.Len(t, history.Pushes, maxPushHistory)
+	assert.Len(t, history.mountSources("registry.example.com", "team/new-model", shared), maxHistoryMountSources)
+}
+
+func TestPushHistoryConcurrentUpdates(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constants.ConfigKey{}, t.TempDir())
+	storagePath := t.TempDir()
+	blob := digest.FromString("blob")
+
+	// Each push loads history before any of the others have saved theirs
+	const numPushes = 10
+	histories := make([]*pushHistory, numPushes)
+	for i := range histories {
+		histories[i] = loadPushHistory(ctx)
+	}
+	var wg sync.WaitGroup
+	for i, history := range histories {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, history.update(ctx, storagePath, "registry.example.com", fmt.Sprintf("team/model-%d", i), []digest.Digest{blob}))
+		}()
+	}
+	wg.Wait()
+
+	history := loadPushHistory(ctx)
+	assert.Len(t, history.Pushes, numPushes, "no push should be lost")
+}
@@ -0,0 +1,119 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"kitops/pkg/artifact"
+	"kitops/pkg/lib/constants"
+	"kitops/pkg/lib/repo/local"
+	"kitops/pkg/lib/repo/remote"
+	"kitops/pkg/lib/repo/util"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+)
+
+// InspectOptions configures Inspect.
+type InspectOptions struct {
+	Options
+	Remote RemoteOptions
+	// Reference is the ModelKit to inspect.
+	Reference string
+	// CheckRemote reads the ModelKit from its remote registry instead of local storage.
+	CheckRemote bool
+}
+
+// InspectResult contains the manifest and Kitfile of a ModelKit.
+type InspectResult struct {
+	Digest     digest.Digest     `json:"digest,omitempty" yaml:"digest,omitempty"`
+	CLIVersion string            `json:"cliVersion,omitempty" yaml:"cliVersion,omitempty"`
+	Kitfile    *artifact.KitFile `json:"kitfile,omitempty" yaml:"kitfile,omitempty"`
+	Manifest   *ocispec.Manifest `json:"manifest,omitempty" yaml:"manifest,omitempty"`
+	// MissingLayers lists the digests of layers that are not present in local storage, for
+	// ModelKits that were pulled with filters.
+	MissingLayers []digest.Digest `json:"missingLayers,omitempty" yaml:"missingLayers,omitempty"`
+}
+
+// Inspect reads the manifest and Kitfile of a ModelKit. If the ModelKit cannot be found,
+// the returned error wraps errdef.ErrNotFound.
+func Inspect(ctx context.Context, opts InspectOptions) (*InspectResult, error) {
+	ctx, configHome, err := opts.setup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	modelRef, extraTags, err := util.ParseReference(opts.Reference)
+	if err != nil {
+		return nil, err
+	}
+	if len(extraTags) > 0 {
+		return nil, fmt.Errorf("invalid reference format: extra tags are not supported: %s", strings.Join(extraTags, ", "))
+	}
+
+	if !opts.CheckRemote {
+		localRepo, err := local.NewLocalRepo(constants.StoragePath(configHome), modelRef)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read local storage: %w", err)
+		}
+		result, err := getInspectInfo(ctx, localRepo, modelRef.Reference)
+		if err != nil {
+			return nil, err
+		}
+		missing, err := local.MissingBlobs(ctx, localRepo, result.Manifest.Layers)
+		if err != nil {
+			return nil, err
+		}
+		for _, layer := range missing {
+			result.MissingLayers = append(result.MissingLayers, layer.Digest)
+		}
+		return result, nil
+	}
+
+	if modelRef.Registry == util.DefaultRegistry {
+		return nil, fmt.Errorf("can not check remote: %s does not contain registry", util.FormatRepositoryForDisplay(modelRef.String()))
+	}
+	network, err := opts.Remote.networkOptions(configHome)
+	if err != nil {
+		return nil, err
+	}
+	repository, err := remote.NewRepository(ctx, modelRef.Registry, modelRef.Repository, network)
+	if err != nil {
+		return nil, err
+	}
+	return getInspectInfo(ctx, repository, modelRef.Reference)
+}
+
+func getInspectInfo(ctx context.Context, repository oras.Target, ref string) (*InspectResult, error) {
+	desc, manifest, kitfile, err := util.ResolveManifestAndConfig(ctx, repository, ref)
+	if err != nil {
+		return nil, err
+	}
+	version := "unknown"
+	if manifest.Annotations != nil && manifest.Annotations[constants.CliVersionAnnotation] != "" {
+		version = manifest.Annotations[constants.CliVersionAnnotation]
+	}
+	return &InspectResult{
+		Digest:     desc.Digest,
+		CLIVersion: version,
+		Kitfile:    kitfile,
+		Manifest:   manifest,
+	}, nil
+}
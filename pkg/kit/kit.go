@@ -0,0 +1,142 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package kit provides functions for working with ModelKits from Go programs, equivalent to
+// the pack, unpack, push, pull, inspect, list, tag, remove, and diff commands of the Kit CLI.
+//
+// Operations are configured entirely through their options: they do not change the working
+// directory or depend on the log level and configuration directory set up by the CLI, so
+// independent operations may be run concurrently.
+package kit
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"kitops/pkg/lib/constants"
+	"kitops/pkg/lib/network"
+	"kitops/pkg/output"
+)
+
+const defaultConcurrency = 5
+
+type LogLevel = output.LogLevel
+
+const (
+	LogLevelTrace = output.LogLevelTrace
+	LogLevelDebug = output.LogLevelDebug
+	LogLevelInfo  = output.LogLevelInfo
+	LogLevelWarn  = output.LogLevelWarn
+	LogLevelError = output.LogLevelError
+)
+
+// ProgressEvent describes the progress of a single transfer.
+type ProgressEvent = output.ProgressEvent
+
+// ProgressFunc is called with progress updates for transfers. It may be called
+// concurrently for different transfers.
+type ProgressFunc = output.ProgressFunc
+
+// ModelKit describes a ModelKit that was packed, pushed, pulled, or tagged.
+type ModelKit = output.ModelKitResult
+
+// Options contains settings common to all operations.
+type Options struct {
+	// ConfigHome is the Kit configuration directory, which contains local storage,
+	// credentials, and the config file. If empty, the default directory for the
+	// platform is used.
+	ConfigHome string
+	// Log receives log messages. If nil, messages are discarded.
+	Log io.Writer
+	// LogLevel is the minimum level of messages written to Log. The zero value
+	// is LogLevelInfo.
+	LogLevel LogLevel
+	// Progress, if set, is called with progress updates for transfers.
+	Progress ProgressFunc
+}
+
+// RemoteOptions configures connections to remote registries.
+type RemoteOptions struct {
+	PlainHTTP             bool
+	InsecureSkipTLSVerify bool
+	ClientCertPath        string
+	ClientKeyPath         string
+	// Concurrency is the maximum number of simultaneous uploads/downloads. Defaults to 5.
+	Concurrency int
+	// ParallelChunks is the number of byte ranges a single large blob is split into when
+	// pulling. Values less than 2 disable ranged downloads.
+	ParallelChunks int
+	Proxy          string
+	// LimitRate is the maximum combined transfer rate (e.g. "50MB/s"). If empty, the rate
+	// from the config file is used.
+	LimitRate string
+}
+
+// NewRemoteOptions returns RemoteOptions equivalent to network flags parsed by the CLI.
+func NewRemoteOptions(netOpts *network.Options) RemoteOptions {
+	return RemoteOptions{
+		PlainHTTP:             netOpts.PlainHTTP,
+		InsecureSkipTLSVerify: !netOpts.TLSVerify,
+		ClientCertPath:        netOpts.ClientCertPath,
+		ClientKeyPath:         netOpts.ClientCertKeyPath,
+		Concurrency:           netOpts.Concurrency,
+		ParallelChunks:        netOpts.ParallelChunks,
+		Proxy:                 netOpts.Proxy,
+		LimitRate:             netOpts.LimitRate,
+	}
+}
+
+// setup returns the context and configuration directory to use for an operation. If ctx does
+// not already carry a logger (e.g. when called from the CLI), one is created from opts.
+func (opts *Options) setup(ctx context.Context) (context.Context, string, error) {
+	configHome := opts.ConfigHome
+	if configHome == "" {
+		defaultHome, err := constants.DefaultConfigPath()
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to get default config directory: %w", err)
+		}
+		configHome = defaultHome
+	}
+	if !output.HasLogger(ctx) {
+		ctx = output.WithLogger(ctx, output.NewLogger(opts.Log, opts.LogLevel, opts.Progress))
+	}
+	// Temporary and resumable files are stored in the cache directory within configHome
+	ctx = context.WithValue(ctx, constants.ConfigKey{}, configHome)
+	return ctx, configHome, nil
+}
+
+// networkOptions converts opts to the options used when creating remote repositories,
+// reading credentials and registry settings from configHome.
+func (opts *RemoteOptions) networkOptions(configHome string) (*network.Options, error) {
+	netOpts := &network.Options{
+		PlainHTTP:         opts.PlainHTTP,
+		TLSVerify:         !opts.InsecureSkipTLSVerify,
+		ClientCertPath:    opts.ClientCertPath,
+		ClientCertKeyPath: opts.ClientKeyPath,
+		Concurrency:       opts.Concurrency,
+		ParallelChunks:    opts.ParallelChunks,
+		Proxy:             opts.Proxy,
+		LimitRate:         opts.LimitRate,
+	}
+	if netOpts.Concurrency == 0 {
+		netOpts.Concurrency = defaultConcurrency
+	}
+	if err := netOpts.CompleteWithConfigHome(configHome); err != nil {
+		return nil, err
+	}
+	return netOpts, nil
+}
@@ -0,0 +1,237 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kit
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kitops/pkg/output"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKitfile = `manifestVersion: 1.0.0
+package:
+  name: test-model
+model:
+  path: model.bin
+datasets:
+  - path: data
+`
+
+func TestPackListTagUnpack(t *testing.T) {
+	ctx := context.Background()
+	contextDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(contextDir, "Kitfile"), []byte(testKitfile), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(contextDir, "model.bin"), []byte("model"), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(contextDir, "data"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(contextDir, "data", "train.csv"), []byte("a,b"), 0644))
+
+	log := &bytes.Buffer{}
+	opts := Options{ConfigHome: t.TempDir(), Log: log, LogLevel: LogLevelInfo}
+
+	packed, err := Pack(ctx, PackOptions{
+		Options:    opts,
+		ContextDir: contextDir,
+		Reference:  "example.com/test/model:v1",
+	})
+	require.NoError(t, err)
+	assert.Contains(t, log.String(), "Model saved")
+
+	tagged, err := Tag(ctx, TagOptions{
+		Options: opts,
+		Source:  "example.com/test/model:v1",
+		Target:  "example.com/test/model:v2",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, packed.Digest, tagged.Digest)
+
+	kits, err := List(ctx, ListOptions{Options: opts})
+	require.NoError(t, err)
+	require.Len(t, kits, 1)
+	assert.Equal(t, packed.Digest, kits[0].Digest)
+	assert.ElementsMatch(t, []string{"v1", "v2"}, kits[0].Tags)
+	assert.Equal(t, "test-model", kits[0].ModelName)
+
+	unpackDir := filepath.Join(t.TempDir(), "unpacked")
+	unpacked, err := Unpack(ctx, UnpackOptions{
+		Options:   opts,
+		Reference: "example.com/test/model:v2",
+		Dir:       unpackDir,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, packed.Digest, unpacked.Digest)
+	for _, path := range []string{"Kitfile", "model.bin", filepath.Join("data", "train.csv")} {
+		assert.FileExists(t, filepath.Join(unpackDir, path))
+	}
+
+	explainDir := filepath.Join(t.TempDir(), "explained")
+	explained, err := Unpack(ctx, UnpackOptions{
+		Options:   opts,
+		Reference: "example.com/test/model:v2",
+		Dir:       explainDir,
+		Exclude:   []string{"datasets:data*"},
+		Explain:   true,
+	})
+	require.NoError(t, err)
+	assert.NoDirExists(t, explainDir)
+	assert.Empty(t, explained.Unpacked)
+	selected := map[string]bool{}
+	for _, explanation := range explained.Explanations {
+		selected[explanation.Path] = explanation.Selected
+	}
+	assert.Equal(t, map[string]bool{"Kitfile": true, "model.bin": true, "data": false}, selected)
+
+	// Removing one of two tags only untags the ModelKit
+	log.Reset()
+	removed, err := Remove(ctx, RemoveOptions{Options: opts, Reference: "example.com/test/model:v1"})
+	require.NoError(t, err)
+	assert.Empty(t, removed.Removed)
+	assert.Equal(t, []string{"example.com/test/model:v1"}, removed.Untagged)
+	assert.Contains(t, log.String(), "Untagged example.com/test/model:v1")
+	assert.NotContains(t, log.String(), "Removed")
+	kits, err = List(ctx, ListOptions{Options: opts})
+	require.NoError(t, err)
+	require.Len(t, kits, 1)
+	assert.Equal(t, []string{"v2"}, kits[0].Tags)
+
+	// Removing all remaining tags untags it for all but the last, which removes it
+	_, err = Tag(ctx, TagOptions{Options: opts, Source: "example.com/test/model:v2", Target: "example.com/test/model:v3"})
+	require.NoError(t, err)
+	removed, err = Remove(ctx, RemoveOptions{Options: opts, Reference: "example.com/test/model:v2,v3"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"example.com/test/model:v2"}, removed.Untagged)
+	require.Len(t, removed.Removed, 1)
+	assert.Equal(t, "example.com/test/model:v3", removed.Removed[0].Reference)
+	assert.Equal(t, packed.Digest, removed.Removed[0].Digest)
+	kits, err = List(ctx, ListOptions{Options: opts})
+	require.NoError(t, err)
+	assert.Empty(t, kits)
+}
+
+func TestPackVariants(t *testing.T) {
+	ctx := context.Background()
+	opts := Options{ConfigHome: t.TempDir()}
+	const ref = "example.com/test/model:latest"
+
+	packVariant := func(variant string, model string) *ModelKit {
+		contextDir := t.TempDir()
+		kitfile := "manifestVersion: 1.0.0\npackage:\n  name: test-model\nmodel:\n  path: model.gguf\n"
+		require.NoError(t, os.WriteFile(filepath.Join(contextDir, "Kitfile"), []byte(kitfile), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(contextDir, "model.gguf"), []byte(model), 0644))
+		packed, err := Pack(ctx, PackOptions{
+			Options:    opts,
+			ContextDir: contextDir,
+			Reference:  ref,
+			Variant:    variant,
+		})
+		require.NoError(t, err)
+		return packed
+	}
+	q8 := packVariant("name=q8,quantization=q8_0", strings.Repeat("q8 model", 1024))
+	q4 := packVariant("q4,quantization=q4_0", "q4 model")
+
+	kits, err := List(ctx, ListOptions{Options: opts})
+	require.NoError(t, err)
+	require.Len(t, kits, 3)
+	byVariant := map[string]ListedModelKit{}
+	for _, kit := range kits {
+		if len(kit.Variants) > 0 {
+			assert.Equal(t, []string{"latest"}, kit.Tags)
+			assert.Equal(t, []string{"q8", "q4"}, kit.Variants)
+			assert.Equal(t, "test-model", kit.ModelName)
+		} else {
+			byVariant[kit.Variant] = kit
+		}
+	}
+	assert.Equal(t, q8.Digest, byVariant["q8"].Digest)
+	assert.Equal(t, q4.Digest, byVariant["q4"].Digest)
+
+	unpackVariant := func(variant string) *UnpackResult {
+		unpacked, err := Unpack(ctx, UnpackOptions{
+			Options:   opts,
+			Reference: ref,
+			Dir:       t.TempDir(),
+			Variant:   variant,
+		})
+		require.NoError(t, err)
+		return unpacked
+	}
+	assert.Equal(t, q8.Digest, unpackVariant("").Digest)
+	assert.Equal(t, q4.Digest, unpackVariant("q4").Digest)
+	assert.Equal(t, q4.Digest, unpackVariant("format=gguf,smallest").Digest)
+	assert.Equal(t, q8.Digest, unpackVariant("quantization=q8_0").Digest)
+	_, err = Unpack(ctx, UnpackOptions{Options: opts, Reference: ref, Dir: t.TempDir(), Variant: "q2"})
+	assert.ErrorContains(t, err, "available variants: q8, q4")
+
+	// Packing a variant with an existing name replaces it, without leaving the old index or the
+	// replaced variant behind
+	newQ4 := packVariant("q4", "updated q4 model")
+	assert.Equal(t, newQ4.Digest, unpackVariant("q4").Digest)
+	kits, err = List(ctx, ListOptions{Options: opts})
+	require.NoError(t, err)
+	require.Len(t, kits, 3)
+	for _, kit := range kits {
+		if len(kit.Variants) > 0 {
+			assert.Equal(t, []string{"q8", "q4"}, kit.Variants)
+		} else {
+			assert.Contains(t, []string{q8.Digest, newQ4.Digest}, kit.Digest)
+		}
+	}
+
+	// Removing untagged modelkits keeps variants in use
+	removed, err := Remove(ctx, RemoveOptions{Options: opts, All: true})
+	require.NoError(t, err)
+	assert.Empty(t, removed.Removed)
+	assert.Equal(t, newQ4.Digest, unpackVariant("q4").Digest)
+	assert.Equal(t, q8.Digest, unpackVariant("q8").Digest)
+
+	// Variants cannot be added to a tag that refers to a modelkit that is not a variant
+	contextDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(contextDir, "Kitfile"), []byte(testKitfile), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(contextDir, "model.bin"), []byte("model"), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(contextDir, "data"), 0755))
+	plain, err := Pack(ctx, PackOptions{Options: opts, ContextDir: contextDir, Reference: "example.com/test/model:plain"})
+	require.NoError(t, err)
+	_, err = Pack(ctx, PackOptions{Options: opts, ContextDir: contextDir, Reference: "example.com/test/model:plain", Variant: "q4"})
+	assert.ErrorContains(t, err, "not a variant")
+	kits, err = List(ctx, ListOptions{Options: opts})
+	require.NoError(t, err)
+	for _, kit := range kits {
+		if kit.Digest == plain.Digest {
+			assert.Equal(t, []string{"plain"}, kit.Tags)
+		}
+	}
+}
+
+func TestDefaultLogLevel(t *testing.T) {
+	log := &bytes.Buffer{}
+	opts := Options{ConfigHome: t.TempDir(), Log: log}
+	ctx, _, err := opts.setup(context.Background())
+	require.NoError(t, err)
+
+	output.FromContext(ctx).Debugf("Debug message")
+	output.FromContext(ctx).Infof("Info message")
+	assert.NotContains(t, log.String(), "Debug message")
+	assert.Contains(t, log.String(), "Info message")
+}
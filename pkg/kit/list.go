@@ -0,0 +1,301 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"kitops/pkg/artifact"
+	"kitops/pkg/lib/constants"
+	"kitops/pkg/lib/repo/local"
+	"kitops/pkg/lib/repo/remote"
+	"kitops/pkg/lib/repo/util"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/registry"
+)
+
+// ListOptions configures List.
+type ListOptions struct {
+	Options
+	Remote RemoteOptions
+	// Repository is the remote repository to list, optionally including a tag. If
+	// empty, ModelKits in local storage are listed.
+	Repository string
+}
+
+// ListedModelKit describes a single ModelKit in a list.
+type ListedModelKit struct {
+	Repository string   `json:"repository"`
+	Digest     string   `json:"digest"`
+	Tags       []string `json:"tags"`
+	ModelName  string   `json:"name,omitempty"`
+	Size       int64    `json:"size"`
+	Author     string   `json:"maintainer,omitempty"`
+	// Variants lists the names of the variants in an index of variants
+	Variants []string `json:"variants,omitempty"`
+	// Variant is the name of the variant, for ModelKits that are a variant of another
+	Variant string `json:"variant,omitempty"`
+	// Partial is true if the ModelKit was pulled with filters and some of its layers are
+	// not present in local storage
+	Partial bool `json:"partial,omitempty"`
+}
+
+// List lists ModelKits in local storage or in a remote repository, sorted by repository
+// and digest. ModelKits stored under multiple repositories appear once per repository.
+func List(ctx context.Context, opts ListOptions) ([]ListedModelKit, error) {
+	ctx, configHome, err := opts.setup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var infos []ListedModelKit
+	if opts.Repository == "" {
+		infos, err = listLocalKits(ctx, configHome)
+	} else {
+		infos, err = listRemoteKits(ctx, configHome, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Repository != infos[j].Repository {
+			return infos[i].Repository < infos[j].Repository
+		}
+		return infos[i].Digest < infos[j].Digest
+	})
+	return infos, nil
+}
+
+func listLocalKits(ctx context.Context, configHome string) ([]ListedModelKit, error) {
+	storageRoot := constants.StoragePath(configHome)
+
+	localRepos, err := local.GetAllLocalRepos(storageRoot)
+	if err != nil {
+		return nil, err
+	}
+	var allInfos []ListedModelKit
+	for _, repo := range localRepos {
+		infos, err := readInfoFromRepo(ctx, repo)
+		if err != nil {
+			return nil, err
+		}
+		allInfos = append(allInfos, infos...)
+	}
+
+	return allInfos, nil
+}
+
+func readInfoFromRepo(ctx context.Context, repo local.LocalRepo) ([]ListedModelKit, error) {
+	var infos []ListedModelKit
+	manifestDescs := repo.GetAllModels()
+	// Variants that are part of an index are stored untagged; find their names from the index
+	variantNames := map[digest.Digest]string{}
+	for _, desc := range manifestDescs {
+		if desc.MediaType != ocispec.MediaTypeImageIndex {
+			continue
+		}
+		index, err := util.GetIndex(ctx, repo, desc)
+		if err != nil {
+			return nil, err
+		}
+		for _, variantDesc := range index.Manifests {
+			variantNames[variantDesc.Digest] = util.VariantName(variantDesc)
+		}
+	}
+	for _, manifestDesc := range manifestDescs {
+		tags := repo.GetTags(manifestDesc)
+		// Strip localhost from repo if present, since we added it
+		repository := util.FormatRepositoryForDisplay(repo.GetRepoName())
+		if repository == "" {
+			repository = "<none>"
+		}
+		info := ListedModelKit{
+			Repository: repository,
+			Digest:     string(manifestDesc.Digest),
+			Tags:       tags,
+		}
+		if manifestDesc.MediaType == ocispec.MediaTypeImageIndex {
+			if err := info.fillIndex(ctx, repo, manifestDesc); err != nil {
+				return nil, err
+			}
+			infos = append(infos, info)
+			continue
+		}
+
+		manifest, config, err := util.GetManifestAndConfig(ctx, repo, manifestDesc)
+		if err != nil && !errors.Is(err, util.ErrNotAModelKit) {
+			return nil, err
+		}
+		info.fill(manifest, config)
+		missing, err := local.MissingBlobs(ctx, repo, manifest.Layers)
+		if err != nil {
+			return nil, err
+		}
+		info.Partial = len(missing) > 0
+		info.Variant = variantNames[manifestDesc.Digest]
+		if info.Variant == "" && len(tags) > 0 {
+			// Variants pulled from a remote index are tagged with the variant's descriptor
+			if tagDesc, err := repo.Resolve(ctx, tags[0]); err == nil {
+				info.Variant = util.VariantName(tagDesc)
+			}
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+func listRemoteKits(ctx context.Context, configHome string, opts ListOptions) ([]ListedModelKit, error) {
+	remoteRef, extraTags, err := util.ParseReference(opts.Repository)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference: %w", err)
+	}
+	if len(extraTags) > 0 {
+		return nil, fmt.Errorf("repository cannot reference multiple tags")
+	}
+	network, err := opts.Remote.networkOptions(configHome)
+	if err != nil {
+		return nil, err
+	}
+	remoteRegistry, err := remote.NewRegistry(ctx, remoteRef.Registry, network)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve registry %s: %w", remoteRef.Registry, err)
+	}
+
+	repo, err := remoteRegistry.Repository(ctx, remoteRef.Repository)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read repository: %w", err)
+	}
+	if remoteRef.Reference != "" {
+		return listImageTag(ctx, repo, remoteRef)
+	}
+	return listTags(ctx, repo, remoteRef)
+}
+
+func listTags(ctx context.Context, repo registry.Repository, ref *registry.Reference) ([]ListedModelKit, error) {
+	var tags []string
+	err := repo.Tags(ctx, "", func(tagsPage []string) error {
+		tags = append(tags, tagsPage...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags on repostory: %w", err)
+	}
+
+	var allInfos []ListedModelKit
+	for _, tag := range tags {
+		tagRef := &registry.Reference{
+			Registry:   ref.Registry,
+			Repository: ref.Repository,
+			Reference:  tag,
+		}
+		infos, err := listImageTag(ctx, repo, tagRef)
+		if err != nil && !errors.Is(err, util.ErrNotAModelKit) {
+			return nil, err
+		}
+		allInfos = append(allInfos, infos...)
+	}
+
+	return allInfos, nil
+}
+
+func listImageTag(ctx context.Context, repo registry.Repository, ref *registry.Reference) ([]ListedModelKit, error) {
+	manifestDesc, err := repo.Resolve(ctx, ref.Reference)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve reference %s: %w", ref.Reference, err)
+	}
+	if manifestDesc.MediaType == ocispec.MediaTypeImageIndex {
+		info := ListedModelKit{
+			Repository: ref.Repository,
+			Digest:     string(manifestDesc.Digest),
+			Tags:       []string{ref.Reference},
+		}
+		if err := info.fillIndex(ctx, repo, manifestDesc); err != nil {
+			return nil, fmt.Errorf("failed to read modelkit: %w", err)
+		}
+		return []ListedModelKit{info}, nil
+	}
+	manifest, config, err := util.GetManifestAndConfig(ctx, repo, manifestDesc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read modelkit: %w", err)
+	}
+	if manifest.Config.MediaType != constants.ModelConfigMediaType.String() {
+		return nil, nil
+	}
+	info := ListedModelKit{
+		Repository: ref.Repository,
+		Digest:     string(manifestDesc.Digest),
+		Tags:       []string{ref.Reference},
+	}
+	info.fill(manifest, config)
+
+	return []ListedModelKit{info}, nil
+}
+
+func (m *ListedModelKit) fill(manifest *ocispec.Manifest, kitfile *artifact.KitFile) {
+	m.Size = getModelSize(manifest)
+	m.Author = getModelAuthor(kitfile)
+	m.ModelName = kitfile.Package.Name
+}
+
+// fillIndex fills m with information about the index of variants described by desc. The
+// size is the total size of all variants, and the model name and maintainer are read from
+// the first variant.
+func (m *ListedModelKit) fillIndex(ctx context.Context, store oras.ReadOnlyTarget, desc ocispec.Descriptor) error {
+	index, err := util.GetIndex(ctx, store, desc)
+	if err != nil {
+		return err
+	}
+	for _, variantDesc := range index.Manifests {
+		m.Variants = append(m.Variants, util.VariantName(variantDesc))
+		if size, ok := util.VariantSize(variantDesc); ok {
+			m.Size += size
+		}
+	}
+	defaultVariant, err := util.SelectVariant(index, nil)
+	if err != nil {
+		return err
+	}
+	_, config, err := util.GetManifestAndConfig(ctx, store, defaultVariant)
+	if err != nil {
+		return err
+	}
+	m.Author = getModelAuthor(config)
+	m.ModelName = config.Package.Name
+	return nil
+}
+
+func getModelSize(manifest *ocispec.Manifest) int64 {
+	var size int64
+	for _, layer := range manifest.Layers {
+		size += layer.Size
+	}
+	return size
+}
+
+func getModelAuthor(kitfile *artifact.KitFile) string {
+	if len(kitfile.Package.Authors) > 0 {
+		return kitfile.Package.Authors[0]
+	}
+	return ""
+}
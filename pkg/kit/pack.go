@@ -0,0 +1,228 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"kitops/pkg/artifact"
+	"kitops/pkg/lib/constants"
+	"kitops/pkg/lib/filesystem"
+	kfutils "kitops/pkg/lib/kitfile"
+	"kitops/pkg/lib/repo/local"
+	"kitops/pkg/lib/repo/util"
+	"kitops/pkg/output"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/registry"
+)
+
+// PackOptions configures Pack.
+type PackOptions struct {
+	Options
+	// ContextDir is the directory containing the ModelKit's contents. Paths in the Kitfile
+	// are relative to this directory.
+	ContextDir string
+	// Kitfile is the path to the Kitfile. Relative paths are relative to ContextDir. If empty,
+	// the Kitfile is found in ContextDir.
+	Kitfile string
+	// KitfileReader, if set, is read instead of Kitfile.
+	KitfileReader io.Reader
+	// Reference is the reference to tag the packed ModelKit with, optionally including
+	// multiple tags, e.g. registry.example.com/org/model:tag1,tag2. If empty, the ModelKit
+	// is stored untagged.
+	Reference string
+	// Compression is the compression used for layers: 'none' (default), 'gzip', or
+	// 'gzip-fastest'.
+	Compression string
+	// Variant, if set, adds the ModelKit as a variant to the image index tagged by Reference
+	// instead of tagging the ModelKit directly. It is a comma-separated list of key=value
+	// annotations, e.g. name=q4,quantization=q4_0,format=gguf; a term without '=' is used as
+	// the variant's name. The format defaults to the extension of the model's path.
+	Variant string
+}
+
+// Pack compresses and stores a ModelKit in local storage based on a Kitfile. Returns an error
+// if packing fails for any reason, or if any path in the Kitfile is not a subdirectory of the
+// context directory.
+//
+// As OCI-spec indexes only support one registry/repository reference at a time, individual
+// blobs may be duplicated on disk if stored under different references.
+func Pack(ctx context.Context, opts PackOptions) (*ModelKit, error) {
+	ctx, configHome, err := opts.setup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	logger := output.FromContext(ctx)
+
+	contextDir, err := filepath.Abs(opts.ContextDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get context dir %s: %w", opts.ContextDir, err)
+	}
+	compression := opts.Compression
+	if compression == "" {
+		compression = constants.NoneCompression
+	}
+	if err := constants.IsValidCompression(compression); err != nil {
+		return nil, err
+	}
+	var variantAnnotations map[string]string
+	if opts.Variant != "" {
+		variantAnnotations, err = util.ParseVariantAnnotations(opts.Variant)
+		if err != nil {
+			return nil, err
+		}
+	}
+	modelRef := util.DefaultReference()
+	var extraTags []string
+	if opts.Reference != "" {
+		modelRef, extraTags, err = util.ParseReference(opts.Reference)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse reference: %w", err)
+		}
+	}
+	if variantAnnotations != nil && (modelRef.Reference == "" || util.ReferenceIsDigest(modelRef.Reference)) {
+		return nil, fmt.Errorf("a tag is required when packing a variant")
+	}
+
+	kitfile, err := readKitfile(contextDir, opts.Kitfile, opts.KitfileReader)
+	if err != nil {
+		return nil, err
+	}
+
+	localRepo, err := local.NewLocalRepo(constants.StoragePath(configHome), modelRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open local storage: %w", err)
+	}
+
+	var extraLayerPaths []string
+	if kitfile.Model != nil && util.IsModelKitReference(kitfile.Model.Path) {
+		baseRef := util.FormatRepositoryForDisplay(modelRef.String())
+		parentKitfile, err := kfutils.ResolveKitfile(ctx, configHome, kitfile.Model.Path, baseRef)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to resolve referenced modelkit %s: %w", kitfile.Model.Path, err)
+		}
+		extraLayerPaths = util.LayerPathsFromKitfile(parentKitfile)
+	}
+
+	ignore, err := filesystem.NewIgnoreFromContext(contextDir, kitfile, extraLayerPaths...)
+	if err != nil {
+		return nil, err
+	}
+	manifestDesc, err := kfutils.SaveModel(ctx, localRepo, kitfile, contextDir, ignore, compression, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := util.GetManifest(ctx, localRepo, *manifestDesc)
+	if err != nil {
+		return nil, err
+	}
+	if variantAnnotations != nil {
+		variantDesc := newVariantDescriptor(*manifestDesc, manifest, kitfile, variantAnnotations)
+		tags := append([]string{modelRef.Reference}, extraTags...)
+		for _, tag := range tags {
+			indexDesc, err := localRepo.TagVariant(ctx, variantDesc, tag)
+			if err != nil {
+				return nil, fmt.Errorf("failed to add variant to index: %w", err)
+			}
+			logger.Debugf("Added variant %s to index %s tagged %s", util.VariantName(variantDesc), indexDesc.Digest, tag)
+		}
+		logger.WithFields(output.Fields{"digest": manifestDesc.Digest, "variant": util.VariantName(variantDesc)}).Infof("Model saved as variant %s: %s", util.VariantName(variantDesc), manifestDesc.Digest)
+		return output.NewModelKitResult(displayReference(modelRef), *manifestDesc, manifest, tags), nil
+	}
+
+	var tags []string
+	if modelRef.Reference != "" {
+		if err := localRepo.Tag(ctx, *manifestDesc, modelRef.Reference); err != nil {
+			return nil, fmt.Errorf("failed to tag manifest: %w", err)
+		}
+		logger.Debugf("Added tag to manifest: %s", modelRef.Reference)
+		tags = append(tags, modelRef.Reference)
+	}
+	for _, tag := range extraTags {
+		if err := localRepo.Tag(ctx, *manifestDesc, tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	logger.WithFields(output.Fields{"digest": manifestDesc.Digest}).Infof("Model saved: %s", manifestDesc.Digest)
+
+	return output.NewModelKitResult(displayReference(modelRef), *manifestDesc, manifest, tags), nil
+}
+
+// newVariantDescriptor returns a descriptor for the manifest in desc, annotated with
+// annotations and the total size of its layers. If annotations do not include a format, the
+// extension of the model's path is used.
+func newVariantDescriptor(desc ocispec.Descriptor, manifest *ocispec.Manifest, kitfile *artifact.KitFile, annotations map[string]string) ocispec.Descriptor {
+	variantDesc := desc
+	variantDesc.Annotations = maps.Clone(annotations)
+	variantDesc.Annotations[constants.VariantSizeAnnotation] = strconv.FormatInt(getModelSize(manifest), 10)
+	if _, ok := variantDesc.Annotations[constants.VariantFormatAnnotation]; !ok && kitfile.Model != nil {
+		if ext := strings.TrimPrefix(filepath.Ext(kitfile.Model.Path), "."); ext != "" && !util.IsModelKitReference(kitfile.Model.Path) {
+			variantDesc.Annotations[constants.VariantFormatAnnotation] = strings.ToLower(ext)
+		}
+	}
+	return variantDesc
+}
+
+// readKitfile reads and validates the Kitfile from r if it is not nil, or from the file
+// kitfilePath. If kitfilePath is empty, the Kitfile is found in contextDir.
+func readKitfile(contextDir, kitfilePath string, r io.Reader) (*artifact.KitFile, error) {
+	if r == nil {
+		if kitfilePath == "" {
+			foundPath, err := filesystem.FindKitfileInPath(contextDir)
+			if err != nil {
+				return nil, err
+			}
+			kitfilePath = foundPath
+		} else if !filepath.IsAbs(kitfilePath) {
+			kitfilePath = filepath.Join(contextDir, kitfilePath)
+		}
+		f, err := os.Open(kitfilePath)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	kitfile := &artifact.KitFile{}
+	if err := kitfile.LoadModel(io.NopCloser(r)); err != nil {
+		return nil, err
+	}
+	if err := kfutils.ValidateKitfile(kitfile); err != nil {
+		return nil, err
+	}
+	return kitfile, nil
+}
+
+// displayReference formats ref for results, omitting default values. Returns an empty
+// string if ref does not refer to a repository.
+func displayReference(ref *registry.Reference) string {
+	if ref == nil {
+		return ""
+	}
+	return util.FormatRepositoryForDisplay(ref.String())
+}
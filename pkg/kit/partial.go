@@ -0,0 +1,101 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kit
+
+import (
+	"context"
+	"fmt"
+
+	"kitops/pkg/lib/network"
+	"kitops/pkg/lib/repo/local"
+	"kitops/pkg/lib/repo/remote"
+	"kitops/pkg/lib/repo/util"
+	"kitops/pkg/output"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/registry"
+)
+
+// fetchMissingLayers pulls any of layers that are not present in localRepo from the registry
+// that modelRef refers to. Layers are missing if the ModelKit described by manifestDesc was
+// pulled with filters.
+func fetchMissingLayers(ctx context.Context, localRepo local.LocalRepo, modelRef *registry.Reference, manifestDesc ocispec.Descriptor, layers []ocispec.Descriptor, network *network.Options) error {
+	missing, err := local.MissingBlobs(ctx, localRepo, layers)
+	if err != nil {
+		return err
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	displayRef := util.FormatRepositoryForDisplay(modelRef.String())
+	if modelRef.Registry == util.DefaultRegistry {
+		return fmt.Errorf("%d layers of %s are not present in local storage and it does not contain a registry to fetch them from", len(missing), displayRef)
+	}
+	output.FromContext(ctx).Infof("Fetching %d layers of %s that are not present in local storage", len(missing), displayRef)
+	repo, err := remote.NewRepository(ctx, modelRef.Registry, modelRef.Repository, network)
+	if err != nil {
+		return err
+	}
+	digestRef := *modelRef
+	digestRef.Reference = manifestDesc.Digest.String()
+	if _, err := localRepo.PullModel(ctx, repo, digestRef, missing, network); err != nil {
+		return fmt.Errorf("failed to fetch missing layers: %w", err)
+	}
+	return nil
+}
+
+// fetchLayersForPush fetches the layers of the ModelKit that srcRef refers to in localRepo that
+// are present in neither local storage nor repo, so that the ModelKit can be pushed to repo.
+// Layers that repo already contains are not needed, as they are not uploaded again.
+func fetchLayersForPush(ctx context.Context, localRepo local.LocalRepo, repo registry.Repository, srcRef *registry.Reference, network *network.Options) error {
+	desc, err := localRepo.Resolve(ctx, srcRef.Reference)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", util.FormatRepositoryForDisplay(srcRef.String()), err)
+	}
+	manifestDescs := []ocispec.Descriptor{desc}
+	if desc.MediaType == ocispec.MediaTypeImageIndex {
+		index, err := util.GetIndex(ctx, localRepo, desc)
+		if err != nil {
+			return err
+		}
+		manifestDescs = index.Manifests
+	}
+	for _, manifestDesc := range manifestDescs {
+		manifest, err := util.GetManifest(ctx, localRepo, manifestDesc)
+		if err != nil {
+			return err
+		}
+		missing, err := local.MissingBlobs(ctx, localRepo, manifest.Layers)
+		if err != nil {
+			return err
+		}
+		var needed []ocispec.Descriptor
+		for _, layer := range missing {
+			exists, err := repo.Exists(ctx, layer)
+			if err != nil {
+				return fmt.Errorf("failed to check registry for layer %s: %w", layer.Digest, err)
+			}
+			if !exists {
+				needed = append(needed, layer)
+			}
+		}
+		if err := fetchMissingLayers(ctx, localRepo, srcRef, manifestDesc, needed, network); err != nil {
+			return err
+		}
+	}
+	return nil
+}
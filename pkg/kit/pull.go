@@ -0,0 +1,226 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"kitops/pkg/lib/constants"
+	"kitops/pkg/lib/network"
+	"kitops/pkg/lib/repo/local"
+	"kitops/pkg/lib/repo/remote"
+	"kitops/pkg/lib/repo/util"
+	"kitops/pkg/output"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/registry"
+)
+
+// PullOptions configures Pull.
+type PullOptions struct {
+	Options
+	Remote RemoteOptions
+	// Reference is the ModelKit to pull, including the registry.
+	Reference string
+	// Variant selects which ModelKit to pull if Reference refers to an index of variants;
+	// see util.ParseVariantSelector for the format. If empty, the first variant is pulled.
+	Variant string
+	// Filters limits which layers are pulled, using the same format as UnpackOptions.Filters.
+	// The ModelKit is stored partially and any missing layers are fetched when they are needed.
+	// If empty, all layers are pulled.
+	Filters []string
+}
+
+// Pull downloads a ModelKit, along with any ModelKits it references, from a remote registry
+// to local storage. Referenced ModelKits are pulled using the same filters.
+func Pull(ctx context.Context, opts PullOptions) (*ModelKit, error) {
+	ctx, configHome, err := opts.setup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	logger := output.FromContext(ctx)
+
+	modelRef, extraTags, err := util.ParseReference(opts.Reference)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse reference: %w", err)
+	}
+	if modelRef.Registry == util.DefaultRegistry {
+		return nil, fmt.Errorf("registry is required when pulling")
+	}
+	if len(extraTags) > 0 {
+		return nil, fmt.Errorf("reference cannot include multiple tags")
+	}
+	variant, err := util.ParseVariantSelector(opts.Variant)
+	if err != nil {
+		return nil, err
+	}
+	filterConfs, err := parseFilters(opts.Filters)
+	if err != nil {
+		return nil, err
+	}
+	network, err := opts.Remote.networkOptions(configHome)
+	if err != nil {
+		return nil, err
+	}
+
+	localRepo, err := local.NewLocalRepo(constants.StoragePath(configHome), modelRef)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("Pulling %s", modelRef.String())
+	desc, err := pullRecursive(ctx, localRepo, modelRef, variant, filterConfs, network, []string{})
+	if err != nil {
+		return nil, err
+	}
+	logger.WithFields(output.Fields{"digest": desc.Digest, "registry": modelRef.Registry}).Infof("Pulled %s", desc.Digest)
+	return util.ModelKitResult(ctx, localRepo, modelRef, desc), nil
+}
+
+func pullRecursive(ctx context.Context, localRepo local.LocalRepo, modelRef *registry.Reference, variant *util.VariantSelector, filterConfs []filterConf, network *network.Options, pulledRefs []string) (ocispec.Descriptor, error) {
+	refStr := util.FormatRepositoryForDisplay(modelRef.String())
+	if idx := getIndex(pulledRefs, refStr); idx != -1 {
+		cycleStr := fmt.Sprintf("[%s=>%s]", strings.Join(pulledRefs[idx:], "=>"), refStr)
+		return ocispec.DescriptorEmptyJSON, fmt.Errorf("found cycle in modelkit references: %s", cycleStr)
+	}
+	pulledRefs = append(pulledRefs, refStr)
+	if len(pulledRefs) > constants.MaxModelRefChain {
+		return ocispec.DescriptorEmptyJSON, fmt.Errorf("reached maximum number of model references: [%s]", strings.Join(pulledRefs, "=>"))
+	}
+
+	desc, err := pullModel(ctx, localRepo, modelRef, variant, filterConfs, network)
+	if err != nil {
+		return ocispec.DescriptorEmptyJSON, err
+	}
+
+	if err := pullParents(ctx, localRepo, desc, filterConfs, network, pulledRefs); err != nil {
+		return ocispec.DescriptorEmptyJSON, fmt.Errorf("failed to pull referenced modelkits: %w", err)
+	}
+
+	return desc, nil
+}
+
+func pullParents(ctx context.Context, localRepo local.LocalRepo, desc ocispec.Descriptor, filterConfs []filterConf, network *network.Options, pulledRefs []string) error {
+	_, config, err := util.GetManifestAndConfig(ctx, localRepo, desc)
+	if err != nil {
+		return err
+	}
+	if config.Model == nil || !util.IsModelKitReference(config.Model.Path) {
+		return nil
+	}
+	output.FromContext(ctx).Infof("Pulling referenced image %s", config.Model.Path)
+	parentRef, _, err := util.ParseReference(config.Model.Path)
+	if err != nil {
+		return err
+	}
+	_, err = pullRecursive(ctx, localRepo, parentRef, nil, filterConfs, network, pulledRefs)
+	return err
+}
+
+func pullModel(ctx context.Context, localRepo local.LocalRepo, modelRef *registry.Reference, variant *util.VariantSelector, filterConfs []filterConf, network *network.Options) (ocispec.Descriptor, error) {
+	repo, err := remote.NewRepository(ctx, modelRef.Registry, modelRef.Repository, network)
+	if err != nil {
+		return ocispec.DescriptorEmptyJSON, err
+	}
+	desc, manifest, err := resolveModel(ctx, modelRef, variant, repo)
+	if err != nil {
+		return ocispec.DescriptorEmptyJSON, err
+	}
+	// A nil list of layers pulls all layers
+	var layers []ocispec.Descriptor
+	if len(filterConfs) > 0 {
+		config, err := util.GetConfig(ctx, repo, manifest.Config)
+		if err != nil {
+			return ocispec.DescriptorEmptyJSON, err
+		}
+		layers, err = filterLayers(manifest, config, layerFilter{include: filterConfs})
+		if err != nil {
+			return ocispec.DescriptorEmptyJSON, fmt.Errorf("failed to filter layers: %w", err)
+		}
+		output.FromContext(ctx).Infof("Pulling %d of %d layers matching filters", len(layers), len(manifest.Layers))
+	}
+	if util.VariantName(desc) == "" {
+		localDesc, err := localRepo.PullModel(ctx, repo, *modelRef, layers, network)
+		if err != nil {
+			return ocispec.DescriptorEmptyJSON, fmt.Errorf("failed to pull: %w", err)
+		}
+		return localDesc, nil
+	}
+
+	// Only the selected variant is pulled, and the tag is applied to it locally. The variant's
+	// annotations are kept on the tagged descriptor so it can be matched against selectors.
+	output.FromContext(ctx).Infof("Selected variant %s (%s)", util.VariantName(desc), desc.Digest)
+	variantRef := *modelRef
+	variantRef.Reference = desc.Digest.String()
+	if _, err := localRepo.PullModel(ctx, repo, variantRef, layers, network); err != nil {
+		return ocispec.DescriptorEmptyJSON, fmt.Errorf("failed to pull: %w", err)
+	}
+	if !util.ReferenceIsDigest(modelRef.Reference) {
+		if err := localRepo.Tag(ctx, desc, modelRef.Reference); err != nil {
+			return ocispec.DescriptorEmptyJSON, fmt.Errorf("failed to save tag: %w", err)
+		}
+	}
+	return desc, nil
+}
+
+// resolveModel returns the descriptor and contents of the modelkit manifest that ref refers to in
+// repo. If ref refers to an index of variants, the variant is chosen using variant.
+func resolveModel(ctx context.Context, ref *registry.Reference, variant *util.VariantSelector, repo registry.Repository) (ocispec.Descriptor, *ocispec.Manifest, error) {
+	desc, rc, err := repo.FetchReference(ctx, ref.Reference)
+	if err != nil {
+		return ocispec.DescriptorEmptyJSON, nil, fmt.Errorf("failed to fetch %s: %w", ref.String(), err)
+	}
+	defer rc.Close()
+	contents, err := io.ReadAll(rc)
+	if err != nil {
+		return ocispec.DescriptorEmptyJSON, nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	manifest := &ocispec.Manifest{}
+	switch desc.MediaType {
+	case ocispec.MediaTypeImageManifest:
+		if variant != nil {
+			return ocispec.DescriptorEmptyJSON, nil, fmt.Errorf("reference %s does not have variants", ref.String())
+		}
+		if err := json.Unmarshal(contents, manifest); err != nil {
+			return ocispec.DescriptorEmptyJSON, nil, fmt.Errorf("failed to parse manifest: %w", err)
+		}
+	case ocispec.MediaTypeImageIndex:
+		index := &ocispec.Index{}
+		if err := json.Unmarshal(contents, index); err != nil {
+			return ocispec.DescriptorEmptyJSON, nil, fmt.Errorf("failed to parse index: %w", err)
+		}
+		desc, err = util.SelectVariant(index, variant)
+		if err != nil {
+			return ocispec.DescriptorEmptyJSON, nil, fmt.Errorf("failed to select variant of %s: %w", ref.String(), err)
+		}
+		manifest, err = util.GetManifest(ctx, repo, desc)
+		if err != nil && !errors.Is(err, util.ErrNotAModelKit) {
+			return ocispec.DescriptorEmptyJSON, nil, err
+		}
+	default:
+		return ocispec.DescriptorEmptyJSON, nil, fmt.Errorf("reference %s is not an image manifest", ref.String())
+	}
+	if manifest == nil || manifest.Config.MediaType != constants.ModelConfigMediaType.String() {
+		return ocispec.DescriptorEmptyJSON, nil, fmt.Errorf("reference %s does not refer to a model", ref.String())
+	}
+	return desc, manifest, nil
+}
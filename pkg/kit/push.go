@@ -0,0 +1,220 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kit
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"kitops/pkg/lib/constants"
+	"kitops/pkg/lib/repo/local"
+	"kitops/pkg/lib/repo/remote"
+	"kitops/pkg/lib/repo/util"
+	"kitops/pkg/output"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/registry"
+)
+
+// PushOptions configures Push.
+type PushOptions struct {
+	Options
+	Remote RemoteOptions
+	// Source is the ModelKit in local storage to push.
+	Source string
+	// Destination is the reference to push to. If empty, Source is pushed to the registry
+	// it refers to.
+	Destination string
+	// MountFrom lists repositories on the destination registry to mount existing layers
+	// from instead of uploading them.
+	MountFrom []string
+}
+
+// Push uploads a ModelKit from local storage to a remote registry. Layers that already exist
+// in another repository on the same registry are mounted rather than uploaded when possible.
+// Layers missing from a partially-pulled ModelKit are fetched first if the destination does
+// not already contain them.
+// Errors returned by the registry can be inspected using errors.As with
+// *errcode.ErrorResponse.
+func Push(ctx context.Context, opts PushOptions) (*ModelKit, error) {
+	ctx, configHome, err := opts.setup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	logger := output.FromContext(ctx)
+
+	srcRef, extraTags, err := util.ParseReference(opts.Source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse reference %s: %w", opts.Source, err)
+	}
+	if len(extraTags) > 0 {
+		return nil, fmt.Errorf("reference cannot include multiple tags")
+	}
+	destRef := srcRef
+	if opts.Destination != "" {
+		destRef, extraTags, err = util.ParseReference(opts.Destination)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse target reference %s: %w", opts.Destination, err)
+		}
+		if len(extraTags) > 0 {
+			return nil, fmt.Errorf("target reference cannot include multiple tags")
+		}
+	}
+	if destRef.Registry == util.DefaultRegistry {
+		return nil, fmt.Errorf("registry is required when pushing")
+	}
+	var mountFrom []string
+	for _, mountRepo := range opts.MountFrom {
+		mountRepo = strings.TrimPrefix(mountRepo, destRef.Registry+"/")
+		mountRef := registry.Reference{Registry: destRef.Registry, Repository: mountRepo}
+		if err := mountRef.ValidateRepository(); err != nil {
+			return nil, fmt.Errorf("invalid mount source %s: must be a repository on %s", mountRepo, destRef.Registry)
+		}
+		mountFrom = append(mountFrom, mountRepo)
+	}
+	network, err := opts.Remote.networkOptions(configHome)
+	if err != nil {
+		return nil, err
+	}
+
+	remoteRepo, err := remote.NewRepository(ctx, destRef.Registry, destRef.Repository, network)
+	if err != nil {
+		return nil, err
+	}
+	localRepo, err := local.NewLocalRepo(constants.StoragePath(configHome), srcRef)
+	if err != nil {
+		return nil, err
+	}
+
+	if srcRef.String() != destRef.String() {
+		logger.Infof("Pushing %s to %s", srcRef.String(), destRef.String())
+	} else {
+		logger.Infof("Pushing %s", srcRef.String())
+	}
+	if err := fetchLayersForPush(ctx, localRepo, remoteRepo, srcRef, network); err != nil {
+		return nil, err
+	}
+	desc, err := pushModel(ctx, localRepo, remoteRepo, constants.StoragePath(configHome), srcRef, destRef, mountFrom, network.Concurrency)
+	if err != nil {
+		return nil, err
+	}
+	logger.WithFields(output.Fields{"digest": desc.Digest, "registry": destRef.Registry}).Infof("Pushed %s", desc.Digest)
+
+	return util.ModelKitResult(ctx, localRepo, destRef, desc), nil
+}
+
+func pushModel(ctx context.Context, localRepo local.LocalRepo, repo registry.Repository, storagePath string, srcRef, destRef *registry.Reference, mountFrom []string, concurrency int) (ocispec.Descriptor, error) {
+	logger := output.FromContext(ctx)
+	history := loadPushHistory(ctx)
+	blobs := modelBlobs(ctx, localRepo, srcRef.Reference)
+	if mounter, ok := repo.(remote.BlobMounter); ok && len(blobs) > 0 {
+		mountedCount, mountedBytes := mountBlobs(ctx, repo, mounter, blobs, history, destRef, mountFrom)
+		if mountedCount > 0 {
+			logger.WithFields(output.Fields{"registry": destRef.Registry, "bytes": mountedBytes}).Infof("Mounted %d blobs from other repositories, saved uploading %s", mountedCount, output.FormatBytes(mountedBytes))
+		}
+	}
+
+	trackedRepo, plog := logger.WrapTarget(repo)
+	copyOpts := oras.CopyOptions{}
+	copyOpts.Concurrency = concurrency
+	desc, err := oras.Copy(ctx, localRepo, srcRef.Reference, trackedRepo, destRef.Reference, copyOpts)
+	if err != nil {
+		return ocispec.DescriptorEmptyJSON, fmt.Errorf("failed to copy to remote: %w", err)
+	}
+	plog.Wait()
+
+	if len(blobs) > 0 {
+		var digests []digest.Digest
+		for _, blob := range blobs {
+			digests = append(digests, blob.Digest)
+		}
+		if err := history.update(ctx, storagePath, destRef.Registry, destRef.Repository, digests); err != nil {
+			logger.Debugf("Failed to save push history: %s", err)
+		}
+	}
+
+	return desc, err
+}
+
+// modelBlobs returns the config and layers of the modelkit that reference refers to in
+// localRepo, including all variants if it refers to an index of variants. Returns nil if the
+// modelkit cannot be read.
+func modelBlobs(ctx context.Context, localRepo local.LocalRepo, reference string) []ocispec.Descriptor {
+	desc, err := localRepo.Resolve(ctx, reference)
+	if err != nil {
+		return nil
+	}
+	manifestDescs := []ocispec.Descriptor{desc}
+	if desc.MediaType == ocispec.MediaTypeImageIndex {
+		index, err := util.GetIndex(ctx, localRepo, desc)
+		if err != nil {
+			return nil
+		}
+		manifestDescs = index.Manifests
+	}
+	var blobs []ocispec.Descriptor
+	for _, manifestDesc := range manifestDescs {
+		manifest, err := util.GetManifest(ctx, localRepo, manifestDesc)
+		if err != nil {
+			continue
+		}
+		blobs = append(blobs, manifest.Config)
+		blobs = append(blobs, manifest.Layers...)
+	}
+	return blobs
+}
+
+// mountBlobs attempts to mount blobs that are not yet present in repo from other
+// repositories on the same registry: first from repositories in mountFrom, then from
+// repositories the blob was recently pushed to. Blobs that cannot be mounted are left
+// to be uploaded normally. Returns the number and total size of mounted blobs.
+func mountBlobs(ctx context.Context, repo registry.Repository, mounter remote.BlobMounter, blobs []ocispec.Descriptor, history *pushHistory, destRef *registry.Reference, mountFrom []string) (count int, size int64) {
+	logger := output.FromContext(ctx)
+	for _, blob := range blobs {
+		sources := slices.Concat(mountFrom, history.mountSources(destRef.Registry, destRef.Repository, blob.Digest))
+		if len(sources) == 0 {
+			continue
+		}
+		if exists, err := repo.Exists(ctx, blob); err != nil || exists {
+			continue
+		}
+		tried := map[string]bool{destRef.Repository: true}
+		for _, source := range sources {
+			if tried[source] {
+				continue
+			}
+			tried[source] = true
+			mounted, err := mounter.MountBlob(ctx, blob, source)
+			if err != nil {
+				logger.Debugf("Failed to mount blob %s from %s: %s", blob.Digest, source, err)
+				continue
+			}
+			if mounted {
+				logger.Debugf("Mounted blob %s from %s", blob.Digest, source)
+				count++
+				size += blob.Size
+				break
+			}
+			logger.Debugf("Blob %s could not be mounted from %s", blob.Digest, source)
+		}
+	}
+	return count, size
+}
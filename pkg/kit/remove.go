@@ -0,0 +1,317 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"kitops/pkg/lib/constants"
+	"kitops/pkg/lib/network"
+	"kitops/pkg/lib/repo/local"
+	"kitops/pkg/lib/repo/remote"
+	"kitops/pkg/lib/repo/util"
+	"kitops/pkg/output"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+// RemoveOptions configures Remove.
+type RemoveOptions struct {
+	Options
+	Remote RemoteOptions
+	// Reference is the ModelKit to remove, optionally including multiple tags, e.g.
+	// registry.example.com/org/model:tag1,tag2. If specified by tag, the ModelKit is only
+	// untagged if other tags refer to it, unless Force is set.
+	Reference string
+	// All removes all untagged ModelKits from local storage (or all ModelKits, if Force is
+	// set) instead of Reference.
+	All bool
+	// Force removes ModelKits along with any other tags that refer to them.
+	Force bool
+	// FromRemote removes Reference from its remote registry instead of local storage.
+	FromRemote bool
+}
+
+// RemoveResult records the ModelKits affected by Remove.
+type RemoveResult struct {
+	Removed  []RemovedModelKit `json:"removed,omitempty"`
+	Untagged []string          `json:"untagged,omitempty"`
+	Errors   []string          `json:"errors,omitempty"`
+}
+
+type RemovedModelKit struct {
+	Reference string `json:"reference"`
+	Digest    string `json:"digest"`
+}
+
+// Remove removes ModelKits from local storage or from a remote registry. Failures to remove
+// individual ModelKits or tags are recorded in the result rather than returned as an error.
+// The result is returned even if an error occurs.
+func Remove(ctx context.Context, opts RemoveOptions) (*RemoveResult, error) {
+	result := &RemoveResult{}
+	ctx, configHome, err := opts.setup(ctx)
+	if err != nil {
+		return result, err
+	}
+	var modelRef *registry.Reference
+	var extraTags []string
+	if opts.Reference != "" {
+		modelRef, extraTags, err = util.ParseReference(opts.Reference)
+		if err != nil {
+			return result, fmt.Errorf("failed to parse reference: %w", err)
+		}
+	}
+	if opts.FromRemote && (opts.Force || opts.All) {
+		return result, fmt.Errorf("cannot remove all or force remove from a remote registry")
+	}
+	network, err := opts.Remote.networkOptions(configHome)
+	if err != nil {
+		return result, err
+	}
+
+	storageRoot := constants.StoragePath(configHome)
+	switch {
+	case modelRef != nil && opts.FromRemote:
+		err = removeRemoteModel(ctx, network, modelRef, result)
+	case modelRef != nil:
+		err = removeModel(ctx, storageRoot, modelRef, extraTags, opts.Force, result)
+	case opts.All && !opts.Force:
+		err = removeUntaggedModels(ctx, storageRoot, result)
+	case opts.All && opts.Force:
+		err = removeAllModels(ctx, storageRoot, result)
+	default:
+		err = fmt.Errorf("a modelkit is required unless removing all modelkits")
+	}
+	return result, err
+}
+
+func (r *RemoveResult) removed(reference string, dgst digest.Digest) {
+	r.Removed = append(r.Removed, RemovedModelKit{Reference: reference, Digest: dgst.String()})
+}
+
+func (r *RemoveResult) untagged(reference string) {
+	r.Untagged = append(r.Untagged, reference)
+}
+
+func (r *RemoveResult) failed(ctx context.Context, s string, args ...any) {
+	msg := fmt.Sprintf(s, args...)
+	r.Errors = append(r.Errors, msg)
+	output.FromContext(ctx).Errorln(msg)
+}
+
+// removeAllModels removes all modelkits from local storage, including tagged ones
+func removeAllModels(ctx context.Context, storageRoot string, result *RemoveResult) error {
+	logger := output.FromContext(ctx)
+	localRepos, err := local.GetAllLocalRepos(storageRoot)
+	if err != nil {
+		return fmt.Errorf("failed to read local storage: %w", err)
+	}
+	for _, localRepo := range localRepos {
+		repository := util.FormatRepositoryForDisplay(localRepo.GetRepoName())
+
+		models := localRepo.GetAllModels()
+
+		// Store a list of removed manifests for this LocalStore. This is necessary
+		// as index.Manifests may have multiple manifest descriptors with the same
+		// digest (and different tags). If we delete a manifest we don't want to try
+		// to delete it (by digest) again.
+		skipManifests := map[digest.Digest]bool{}
+		for _, manifestDesc := range models {
+			if skipManifests[manifestDesc.Digest] {
+				continue
+			}
+			tags := localRepo.GetTags(manifestDesc)
+			// First untag all manifests for this digest
+			for _, tag := range tags {
+				if err := localRepo.Untag(ctx, tag); err != nil {
+					result.failed(ctx, "Failed to untag %s:%s: %s", repository, tag, err)
+					continue
+				}
+				logger.Infof("Untagged %s:%s", repository, tag)
+				result.untagged(fmt.Sprintf("%s:%s", repository, tag))
+			}
+
+			if err := localRepo.Delete(ctx, manifestDesc); err != nil {
+				result.failed(ctx, "Failed to remove %s@%s: %s", repository, manifestDesc.Digest, err)
+				continue
+			}
+			// Skip future manifest descriptors with this digest, since we just removed it.
+			skipManifests[manifestDesc.Digest] = true
+			logger.Infof("Removed %s@%s", repository, manifestDesc.Digest)
+			result.removed(repository, manifestDesc.Digest)
+		}
+	}
+	return nil
+}
+
+// removeUntaggedModels removes all untagged modelkits from local storage
+func removeUntaggedModels(ctx context.Context, storageRoot string, result *RemoveResult) error {
+	logger := output.FromContext(ctx)
+	localRepos, err := local.GetAllLocalRepos(storageRoot)
+	if err != nil {
+		return fmt.Errorf("failed to read local storage: %w", err)
+	}
+repos:
+	for _, localRepo := range localRepos {
+		manifests := localRepo.GetAllModels()
+		repo := util.FormatRepositoryForDisplay(localRepo.GetRepoName())
+		// Variants are stored untagged, but should be kept as long as an index refers to them
+		variants := map[digest.Digest]bool{}
+		for _, manifestDesc := range manifests {
+			if manifestDesc.MediaType != ocispec.MediaTypeImageIndex || len(localRepo.GetTags(manifestDesc)) == 0 {
+				continue
+			}
+			index, err := util.GetIndex(ctx, localRepo, manifestDesc)
+			if err != nil {
+				// Without knowing which variants are in use, none can be removed safely
+				result.failed(ctx, "Failed to read index %s@%s: %s", repo, manifestDesc.Digest, err)
+				continue repos
+			}
+			for _, variantDesc := range index.Manifests {
+				variants[variantDesc.Digest] = true
+			}
+		}
+		for _, manifestDesc := range manifests {
+			tags := localRepo.GetTags(manifestDesc)
+			if len(tags) > 0 {
+				logger.Debugf("Skipping %s (tags: %s)", manifestDesc.Digest, strings.Join(tags, ", "))
+				continue
+			}
+			if variants[manifestDesc.Digest] {
+				logger.Debugf("Skipping %s (variant in tagged index)", manifestDesc.Digest)
+				continue
+			}
+			if err := localRepo.Delete(ctx, manifestDesc); err != nil {
+				result.failed(ctx, "Failed to remove %s@%s: %s", repo, manifestDesc.Digest, err)
+				continue
+			}
+			logger.Infof("Removed %s@%s", repo, manifestDesc.Digest)
+			result.removed(repo, manifestDesc.Digest)
+		}
+	}
+	return nil
+}
+
+func removeModel(ctx context.Context, storageRoot string, modelRef *registry.Reference, extraTags []string, forceDelete bool, result *RemoveResult) error {
+	localRepo, err := local.NewLocalRepo(storageRoot, modelRef)
+	if err != nil {
+		return fmt.Errorf("failed to read local storage at path %s: %w", storageRoot, err)
+	}
+	desc, deleted, err := removeModelRef(ctx, localRepo, modelRef, forceDelete)
+	if err != nil {
+		return fmt.Errorf("failed to remove: %s", err)
+	}
+	recordRemovedRef(ctx, util.FormatRepositoryForDisplay(modelRef.String()), desc, deleted, result)
+
+	for _, tag := range extraTags {
+		ref := *modelRef
+		ref.Reference = tag
+		desc, deleted, err := removeModelRef(ctx, localRepo, &ref, forceDelete)
+		if err != nil {
+			result.failed(ctx, "Failed to remove tag %s: %s", tag, err)
+		} else {
+			recordRemovedRef(ctx, util.FormatRepositoryForDisplay(ref.String()), desc, deleted, result)
+		}
+	}
+	return nil
+}
+
+// recordRemovedRef logs and records a reference handled by removeModelRef, depending on whether
+// the manifest it refers to was deleted or only untagged.
+func recordRemovedRef(ctx context.Context, displayRef string, desc ocispec.Descriptor, deleted bool, result *RemoveResult) {
+	logger := output.FromContext(ctx)
+	if deleted {
+		logger.Infof("Removed %s (digest %s)", displayRef, desc.Digest)
+		result.removed(displayRef, desc.Digest)
+	} else {
+		logger.Infof("Untagged %s (digest %s)", displayRef, desc.Digest)
+		result.untagged(displayRef)
+	}
+}
+
+func removeRemoteModel(ctx context.Context, network *network.Options, modelRef *registry.Reference, result *RemoveResult) error {
+	registry, err := remote.NewRegistry(ctx, modelRef.Registry, network)
+	if err != nil {
+		return err
+	}
+	repository, err := registry.Repository(ctx, modelRef.Repository)
+	if err != nil {
+		return err
+	}
+	desc, err := repository.Resolve(ctx, modelRef.Reference)
+	if err != nil {
+		if errors.Is(err, errdef.ErrNotFound) {
+			return fmt.Errorf("model %s not found", util.FormatRepositoryForDisplay(modelRef.String()))
+		}
+		return fmt.Errorf("error resolving modelkit: %w", err)
+	}
+	if err := repository.Delete(ctx, desc); err != nil {
+		if errResp, ok := err.(*errcode.ErrorResponse); ok && errResp.StatusCode == http.StatusMethodNotAllowed {
+			return fmt.Errorf("removing models is unsupported by registry %s", modelRef.Registry)
+		}
+		return fmt.Errorf("failed to remove remote model: %w", err)
+	}
+	result.removed(util.FormatRepositoryForDisplay(modelRef.String()), desc.Digest)
+	return nil
+}
+
+// removeModelRef removes ref from local storage. The manifest it refers to is deleted if ref is a
+// digest, if forceDelete is set, or if ref is its only tag; otherwise, only the tag is removed.
+// It returns the manifest's descriptor and whether the manifest was deleted.
+func removeModelRef(ctx context.Context, localRepo local.LocalRepo, ref *registry.Reference, forceDelete bool) (ocispec.Descriptor, bool, error) {
+	logger := output.FromContext(ctx)
+	desc, err := oras.Resolve(ctx, localRepo, ref.Reference, oras.ResolveOptions{})
+	if err != nil {
+		if err == errdef.ErrNotFound {
+			return ocispec.DescriptorEmptyJSON, false, fmt.Errorf("model %s not found", util.FormatRepositoryForDisplay(ref.String()))
+		}
+		return ocispec.DescriptorEmptyJSON, false, fmt.Errorf("error resolving model: %s", err)
+	}
+
+	// If reference passed in is a digest, remove the manifest ignoring any tags the manifest might have
+	if err := ref.ValidateReferenceAsDigest(); err == nil || forceDelete {
+		logger.Debugf("Deleting manifest with digest %s", ref.Reference)
+		if err := localRepo.Delete(ctx, desc); err != nil {
+			return ocispec.DescriptorEmptyJSON, false, fmt.Errorf("failed to delete model: %ws", err)
+		}
+		return desc, true, nil
+	}
+
+	tags := localRepo.GetTags(desc)
+	if len(tags) <= 1 {
+		logger.Debugf("Deleting manifest tagged %s", ref.Reference)
+		if err := localRepo.Delete(ctx, desc); err != nil {
+			return ocispec.DescriptorEmptyJSON, false, fmt.Errorf("failed to delete model: %w", err)
+		}
+		return desc, true, nil
+	}
+	logger.Debugf("Found other tags for manifest: [%s]", strings.Join(tags, ", "))
+	logger.Debugf("Untagging %s", ref.Reference)
+	if err := localRepo.Untag(ctx, ref.Reference); err != nil {
+		return ocispec.DescriptorEmptyJSON, false, fmt.Errorf("failed to untag model: %w", err)
+	}
+	return desc, false, nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kit
+
+import (
+	"context"
+	"fmt"
+
+	"kitops/pkg/lib/constants"
+	"kitops/pkg/lib/repo/local"
+	"kitops/pkg/lib/repo/util"
+	"kitops/pkg/output"
+
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/errdef"
+)
+
+// TagOptions configures Tag.
+type TagOptions struct {
+	Options
+	// Source is the ModelKit in local storage to tag.
+	Source string
+	// Target is the new reference for the ModelKit.
+	Target string
+}
+
+// TagResult describes a tagged ModelKit.
+type TagResult struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Digest string `json:"digest"`
+}
+
+// Tag creates or updates a tag in local storage that refers to an existing ModelKit.
+func Tag(ctx context.Context, opts TagOptions) (*TagResult, error) {
+	ctx, configHome, err := opts.setup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sourceRef, _, err := util.ParseReference(opts.Source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse reference: %w", err)
+	}
+	targetRef, _, err := util.ParseReference(opts.Target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse reference: %w", err)
+	}
+
+	storageHome := constants.StoragePath(configHome)
+	sourceRepo, err := local.NewLocalRepo(storageHome, sourceRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open local storage: %w", err)
+	}
+	descriptor, err := oras.Resolve(ctx, sourceRepo, sourceRef.Reference, oras.ResolveOptions{})
+	if err != nil {
+		if err == errdef.ErrNotFound {
+			return nil, fmt.Errorf("model %s not found", sourceRef.String())
+		}
+		return nil, fmt.Errorf("error resolving model: %s", err)
+	}
+	if sourceRef.Registry == targetRef.Registry && sourceRef.Repository == targetRef.Repository {
+		err = sourceRepo.Tag(ctx, descriptor, targetRef.Reference)
+		if err != nil {
+			return nil, fmt.Errorf("failed to tag reference %s: %w", targetRef, err)
+		}
+	} else {
+		// Target is under a different repo name (org/repo pair); manifest needs to be pushed to _that_ local store
+		// Note that since local repos all share the same blob storage, only the manifest will need to be copied.
+		targetRepo, err := local.NewLocalRepo(storageHome, targetRef)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open local storage: %w", err)
+		}
+		_, err = oras.Copy(ctx, sourceRepo, sourceRef.Reference, targetRepo, targetRef.Reference, oras.CopyOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to tag model: %w", err)
+		}
+	}
+	output.FromContext(ctx).Infof("Modelkit %s tagged as %s", sourceRef, targetRef)
+
+	return &TagResult{
+		Source: util.FormatRepositoryForDisplay(sourceRef.String()),
+		Target: util.FormatRepositoryForDisplay(targetRef.String()),
+		Digest: descriptor.Digest.String(),
+	}, nil
+}
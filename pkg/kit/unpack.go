@@ -0,0 +1,653 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kit
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"kitops/pkg/artifact"
+	"kitops/pkg/lib/constants"
+	"kitops/pkg/lib/network"
+	"kitops/pkg/lib/repo/local"
+	"kitops/pkg/lib/repo/remote"
+	"kitops/pkg/lib/repo/util"
+	"kitops/pkg/lib/telemetry"
+	"kitops/pkg/output"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry"
+)
+
+// UnpackOptions configures Unpack.
+type UnpackOptions struct {
+	Options
+	Remote RemoteOptions
+	// Reference is the ModelKit to unpack. It must include a tag or digest. If it is not
+	// present in local storage, it is read from the remote registry.
+	Reference string
+	// Dir is the directory to unpack into. It is created if it does not exist.
+	Dir string
+	// Root, if set, confines unpacking to a directory. Dir must then be a relative path within
+	// Root, and is created and opened through it, so that neither Dir nor any unpacked file
+	// can be placed outside of Root, even by following symlinks.
+	Root *os.Root
+	// Filters limit what is unpacked, in the format [types]:[filters]; see the documentation
+	// for the --filter flag of kit unpack. A layer is unpacked if it matches any filter. If
+	// empty, all layers are unpacked.
+	Filters []string
+	// Exclude lists filters, in the same format as Filters, for layers that should not be
+	// unpacked even if they match Filters.
+	Exclude []string
+	// Explain reports whether each layer would be unpacked and why in the result's
+	// Explanations, without unpacking anything.
+	Explain bool
+	// Overwrite allows replacing existing files in Dir.
+	Overwrite bool
+	// Variant selects which ModelKit to unpack if Reference refers to an index of variants;
+	// see util.ParseVariantSelector for the format. If empty, the first variant is unpacked.
+	Variant string
+}
+
+// UnpackResult describes an unpacked ModelKit. Unpacked includes layers from referenced
+// (parent) modelkits.
+type UnpackResult struct {
+	Reference string          `json:"reference"`
+	Digest    string          `json:"digest"`
+	Directory string          `json:"directory"`
+	Unpacked  []UnpackedLayer `json:"unpacked"`
+	// Explanations describe why each layer was or was not selected, if requested.
+	Explanations []LayerExplanation `json:"explanations,omitempty"`
+}
+
+type UnpackedLayer struct {
+	Type      string `json:"type"`
+	Path      string `json:"path"`
+	MediaType string `json:"mediaType,omitempty"`
+	Digest    string `json:"digest,omitempty"`
+	Size      int64  `json:"size,omitempty"`
+}
+
+// LayerExplanation describes whether a layer is selected by the filters used for unpacking, and why.
+type LayerExplanation struct {
+	Reference string `json:"reference"`
+	Type      string `json:"type"`
+	Name      string `json:"name,omitempty"`
+	Path      string `json:"path"`
+	Digest    string `json:"digest,omitempty"`
+	Selected  bool   `json:"selected"`
+	Reason    string `json:"reason"`
+}
+
+// unpackConfig holds the resolved options for unpacking a single modelkit.
+type unpackConfig struct {
+	configHome string
+	network    *network.Options
+	modelRef   *registry.Reference
+	// root is the directory to unpack into. All files are created through root, so that
+	// paths in the ModelKit cannot escape it.
+	root      *os.Root
+	filter    layerFilter
+	overwrite bool
+	explain   bool
+	// variant selects a variant if modelRef refers to an index of variants
+	variant *util.VariantSelector
+}
+
+// Unpack fetches and unpacks a ModelKit to a directory. It returns an error if unpacking
+// fails, or if any path specified in the ModelKit is not a subdirectory of the target
+// directory.
+func Unpack(ctx context.Context, opts UnpackOptions) (*UnpackResult, error) {
+	ctx, configHome, err := opts.setup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	modelRef, extraTags, err := util.ParseReference(opts.Reference)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse reference: %w", err)
+	}
+	if len(extraTags) > 0 {
+		return nil, fmt.Errorf("can not unpack multiple tags")
+	}
+	if modelRef.Reference == "" {
+		return nil, fmt.Errorf("unpacking requires a tag or digest")
+	}
+	includeConfs, err := parseFilters(opts.Filters)
+	if err != nil {
+		return nil, err
+	}
+	excludeConfs, err := parseFilters(opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	variant, err := util.ParseVariantSelector(opts.Variant)
+	if err != nil {
+		return nil, err
+	}
+	var unpackDir string
+	if opts.Root != nil {
+		if !filepath.IsLocal(opts.Dir) {
+			return nil, fmt.Errorf("directory %s is not within %s", opts.Dir, opts.Root.Name())
+		}
+		unpackDir = filepath.Join(opts.Root.Name(), opts.Dir)
+	} else {
+		unpackDir, err = filepath.Abs(opts.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve absolute path %s: %w", opts.Dir, err)
+		}
+	}
+	network, err := opts.Remote.networkOptions(configHome)
+	if err != nil {
+		return nil, err
+	}
+	conf := &unpackConfig{
+		configHome: configHome,
+		network:    network,
+		modelRef:   modelRef,
+		filter:     layerFilter{include: includeConfs, exclude: excludeConfs},
+		overwrite:  opts.Overwrite,
+		explain:    opts.Explain,
+		variant:    variant,
+	}
+	if !opts.Explain {
+		root, err := openUnpackDir(opts.Root, opts.Dir, unpackDir)
+		if err != nil {
+			return nil, err
+		}
+		defer root.Close()
+		conf.root = root
+	}
+	output.FromContext(ctx).Debugf("Unpacking %s", modelRef.String())
+	result := &UnpackResult{
+		Reference: util.FormatRepositoryForDisplay(modelRef.String()),
+		Directory: unpackDir,
+	}
+	if err := unpackRecursive(ctx, conf, []string{}, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func unpackRecursive(ctx context.Context, conf *unpackConfig, visitedRefs []string, result *UnpackResult) error {
+	if len(visitedRefs) > constants.MaxModelRefChain {
+		return fmt.Errorf("reached maximum number of model references: [%s]", strings.Join(visitedRefs, "=>"))
+	}
+	logger := output.FromContext(ctx)
+
+	ref := conf.modelRef
+	store, err := getStoreForRef(ctx, conf)
+	if err != nil {
+		ref := util.FormatRepositoryForDisplay(conf.modelRef.String())
+		return fmt.Errorf("failed to find reference %s: %s", ref, err)
+	}
+	manifestDesc, err := store.Resolve(ctx, ref.Reference)
+	if err != nil {
+		return fmt.Errorf("failed to resolve reference: %w", err)
+	}
+	manifestDesc, err = util.ResolveVariant(ctx, store, manifestDesc, conf.variant)
+	if err != nil {
+		return fmt.Errorf("failed to select variant: %w", err)
+	}
+	if name := util.VariantName(manifestDesc); name != "" {
+		logger.Infof("Unpacking variant %s (%s)", name, manifestDesc.Digest)
+	}
+	manifest, config, err := util.GetManifestAndConfig(ctx, store, manifestDesc)
+	if err != nil {
+		return fmt.Errorf("failed to read model: %s", err)
+	}
+	if len(visitedRefs) == 0 {
+		result.Digest = manifestDesc.Digest.String()
+	}
+	if config.Model != nil && util.IsModelKitReference(config.Model.Path) {
+		logger.Infof("Unpacking referenced modelkit %s", config.Model.Path)
+		if err := unpackParent(ctx, config.Model.Path, conf, visitedRefs, result); err != nil {
+			return err
+		}
+	}
+
+	if conf.explain {
+		return explainLayers(manifest, config, conf, result)
+	}
+
+	if conf.filter.selects(config) {
+		if err := unpackKitfile(ctx, config, conf.root, conf.overwrite); err != nil {
+			return err
+		}
+		result.Unpacked = append(result.Unpacked, UnpackedLayer{Type: "kitfile", Path: constants.DefaultKitfileName})
+	}
+
+	// ModelKits pulled with filters may be missing some layers; fetch any that are needed
+	if localRepo, ok := store.(local.LocalRepo); ok {
+		layers, err := filterLayers(manifest, config, conf.filter)
+		if err != nil {
+			return fmt.Errorf("failed to read model: %w", err)
+		}
+		if err := fetchMissingLayers(ctx, localRepo, ref, manifestDesc, layers, conf.network); err != nil {
+			return err
+		}
+	}
+
+	// Since there might be multiple datasets, etc. we need to synchronously iterate
+	// through the config's relevant field to get the correct path for unpacking
+	// We need to support older ModelKits (that were packed without diffIDs and digest
+	// in the config) for now, so we need to continue using the old structure.
+	var modelPartIdx, codeIdx, datasetIdx, docsIdx int
+	for _, layerDesc := range manifest.Layers {
+		// This variable supports older-format tar layers (that don't include the
+		// layer path). For current ModelKits, this will be empty
+		var relPath string
+
+		// Grab path + layer info from the config object corresponding to this layer
+		var layerPath string
+		var layerInfo *artifact.LayerInfo
+		mediaType := constants.ParseMediaType(layerDesc.MediaType)
+		switch mediaType.BaseType {
+		case constants.ModelType:
+			if !conf.filter.selects(config.Model) {
+				continue
+			}
+			layerInfo = config.Model.LayerInfo
+			layerPath = config.Model.Path
+			logger.WithFields(layerFields(layerDesc)).Infof("Unpacking model %s to %s", config.Model.Name, config.Model.Path)
+
+		case constants.ModelPartType:
+			part := config.Model.Parts[modelPartIdx]
+			if !conf.filter.selects(part) {
+				modelPartIdx += 1
+				continue
+			}
+			layerInfo = part.LayerInfo
+			layerPath = part.Path
+			logger.WithFields(layerFields(layerDesc)).Infof("Unpacking model part %s to %s", part.Name, part.Path)
+			modelPartIdx += 1
+
+		case constants.CodeType:
+			codeEntry := config.Code[codeIdx]
+			if !conf.filter.selects(codeEntry) {
+				codeIdx += 1
+				continue
+			}
+			layerInfo = codeEntry.LayerInfo
+			layerPath = codeEntry.Path
+			logger.WithFields(layerFields(layerDesc)).Infof("Unpacking code to %s", codeEntry.Path)
+			codeIdx += 1
+
+		case constants.DatasetType:
+			datasetEntry := config.DataSets[datasetIdx]
+			if !conf.filter.selects(datasetEntry) {
+				datasetIdx += 1
+				continue
+			}
+			layerInfo = datasetEntry.LayerInfo
+			layerPath = datasetEntry.Path
+			logger.WithFields(layerFields(layerDesc)).Infof("Unpacking dataset %s to %s", datasetEntry.Name, datasetEntry.Path)
+			datasetIdx += 1
+
+		case constants.DocsType:
+			docsEntry := config.Docs[docsIdx]
+			if !conf.filter.selects(docsEntry) {
+				docsIdx += 1
+				continue
+			}
+			layerInfo = docsEntry.LayerInfo
+			layerPath = docsEntry.Path
+			logger.WithFields(layerFields(layerDesc)).Infof("Unpacking docs to %s", docsEntry.Path)
+			docsIdx += 1
+		}
+
+		if layerInfo != nil {
+			if layerInfo.Digest != layerDesc.Digest.String() {
+				return fmt.Errorf("digest in config and manifest do not match in %s", mediaType.BaseType)
+			}
+			relPath = ""
+		} else {
+			relPath = filepath.Clean(layerPath)
+		}
+
+		if err := unpackLayer(ctx, store, layerDesc, conf.root, relPath, conf.overwrite, mediaType.Compression); err != nil {
+			return fmt.Errorf("failed to unpack: %w", err)
+		}
+		result.Unpacked = append(result.Unpacked, UnpackedLayer{
+			Type:      mediaType.BaseType,
+			Path:      layerPath,
+			MediaType: layerDesc.MediaType,
+			Digest:    layerDesc.Digest.String(),
+			Size:      layerDesc.Size,
+		})
+	}
+	logger.Debugf("Unpacked %d model part layers", modelPartIdx)
+	logger.Debugf("Unpacked %d code layers", codeIdx)
+	logger.Debugf("Unpacked %d dataset layers", datasetIdx)
+	logger.Debugf("Unpacked %d docs layers", docsIdx)
+
+	return nil
+}
+
+// layerFields returns structured log fields describing a layer
+func layerFields(desc ocispec.Descriptor) output.Fields {
+	return output.Fields{
+		"digest":    desc.Digest,
+		"layerType": constants.ParseMediaType(desc.MediaType).BaseType,
+		"bytes":     desc.Size,
+	}
+}
+
+func unpackParent(ctx context.Context, ref string, confIn *unpackConfig, visitedRefs []string, result *UnpackResult) error {
+	if idx := getIndex(visitedRefs, ref); idx != -1 {
+		cycleStr := fmt.Sprintf("[%s=>%s]", strings.Join(visitedRefs[idx:], "=>"), ref)
+		return fmt.Errorf("found cycle in modelkit references: %s", cycleStr)
+	}
+
+	parentRef, _, err := util.ParseReference(ref)
+	if err != nil {
+		return err
+	}
+	conf := *confIn
+	conf.modelRef = parentRef
+	conf.variant = nil
+	// Unpack only model, ignore code/datasets
+	modelFilter, err := parseFilter("model")
+	if err != nil {
+		// Shouldn't happen, ever
+		return fmt.Errorf("failed to parse filter for parent modelkit: %w", err)
+	}
+	conf.filter.include = []filterConf{*modelFilter}
+
+	return unpackRecursive(ctx, &conf, append(visitedRefs, ref), result)
+}
+
+// explainLayers adds an explanation of whether the Kitfile and each layer in manifest are
+// selected by conf's filters to result.
+func explainLayers(manifest *ocispec.Manifest, config *artifact.KitFile, conf *unpackConfig, result *UnpackResult) error {
+	reference := util.FormatRepositoryForDisplay(conf.modelRef.String())
+	selected, reason := conf.filter.explain(config)
+	result.Explanations = append(result.Explanations, LayerExplanation{
+		Reference: reference,
+		Type:      "kitfile",
+		Path:      constants.DefaultKitfileName,
+		Selected:  selected,
+		Reason:    reason,
+	})
+	entries, err := layerEntries(manifest, config)
+	if err != nil {
+		return fmt.Errorf("failed to read model: %w", err)
+	}
+	for idx, layerDesc := range manifest.Layers {
+		explanation := LayerExplanation{
+			Reference: reference,
+			Type:      constants.ParseMediaType(layerDesc.MediaType).BaseType,
+			Digest:    layerDesc.Digest.String(),
+		}
+		_, attrs := layerAttributes(entries[idx])
+		if names := attrs["name"]; len(names) > 0 {
+			explanation.Name = names[0]
+		}
+		if paths := attrs["path"]; len(paths) > 0 {
+			explanation.Path = paths[0]
+		}
+		if entries[idx] == nil {
+			explanation.Selected, explanation.Reason = true, "layers of unknown type are not filtered"
+		} else {
+			explanation.Selected, explanation.Reason = conf.filter.explain(entries[idx])
+		}
+		result.Explanations = append(result.Explanations, explanation)
+	}
+	return nil
+}
+
+func unpackKitfile(ctx context.Context, config *artifact.KitFile, root *os.Root, overwrite bool) error {
+	configPath := filepath.Join(root.Name(), constants.DefaultKitfileName)
+	if fi, err := root.Stat(constants.DefaultKitfileName); err == nil {
+		if !overwrite {
+			return fmt.Errorf("failed to unpack config: path %s already exists", configPath)
+		} else if !fi.Mode().IsRegular() {
+			return fmt.Errorf("failed to unpack config: path %s exists and is not a regular file", configPath)
+		}
+	}
+
+	configBytes, err := config.MarshalToYAML()
+	if err != nil {
+		return fmt.Errorf("failed to unpack config: %w", err)
+	}
+
+	output.FromContext(ctx).Infof("Unpacking config to %s", configPath)
+	if err := writeFileInRoot(root, constants.DefaultKitfileName, configBytes, 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+// unpackLayer extracts a layer into root. For older ModelKits, where paths in the layer
+// are relative to the layer's path rather than the context directory, relPath is the layer's
+// path relative to root.
+func unpackLayer(ctx context.Context, store content.Storage, desc ocispec.Descriptor, root *os.Root, relPath string, overwrite bool, compression string) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, "unpackLayer", telemetry.DescriptorAttributes(desc)...)
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	rc, err := store.Fetch(ctx, desc)
+	if err != nil {
+		return fmt.Errorf("failed get layer %s: %w", desc.Digest, err)
+	}
+	var logger *output.ProgressLogger
+	rc, logger = output.FromContext(ctx).WrapUnpackReadCloser(desc.Size, rc)
+	defer rc.Close()
+
+	var cr io.ReadCloser
+	var cErr error
+	switch compression {
+	case constants.GzipCompression, constants.GzipFastestCompression:
+		cr, cErr = gzip.NewReader(rc)
+	case constants.NoneCompression:
+		cr = rc
+	}
+	if cErr != nil {
+		return fmt.Errorf("error setting up decompress: %w", cErr)
+	}
+	defer cr.Close()
+	tr := tar.NewReader(cr)
+
+	extractRoot := root
+	if relPath != "" {
+		extractDir := filepath.Dir(relPath)
+		if err := mkdirAllInRoot(root, extractDir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", extractDir, err)
+		}
+		extractRoot, err = root.OpenRoot(extractDir)
+		if err != nil {
+			return fmt.Errorf("failed to open directory %s: %w", extractDir, err)
+		}
+		defer extractRoot.Close()
+	}
+
+	if err := extractTar(tr, extractRoot, overwrite, logger); err != nil {
+		return err
+	}
+	logger.Wait()
+	return nil
+}
+
+// extractTar extracts the contents of tr into root. Entries that would be written outside of
+// root, either directly or by following a symlink, are rejected.
+func extractTar(tr *tar.Reader, root *os.Root, overwrite bool, logger *output.ProgressLogger) (err error) {
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.FromSlash(header.Name)
+		outPath := filepath.Join(root.Name(), name)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if fi, err := root.Stat(name); err == nil {
+				if !fi.IsDir() {
+					return fmt.Errorf("path '%s' already exists and is not a directory", outPath)
+				}
+			} else {
+				logger.Debugf("Creating directory %s", outPath)
+				if err := mkdirAllInRoot(root, name, header.FileInfo().Mode().Perm()); err != nil {
+					return fmt.Errorf("failed to create directory %s: %w", outPath, err)
+				}
+			}
+
+		case tar.TypeReg:
+			if fi, err := root.Stat(name); err == nil {
+				if !overwrite {
+					return fmt.Errorf("path '%s' already exists", outPath)
+				}
+				if !fi.Mode().IsRegular() {
+					return fmt.Errorf("path '%s' already exists and is not a regular file", outPath)
+				}
+			}
+			logger.Debugf("Unpacking file %s", outPath)
+			file, err := root.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_RDWR, header.FileInfo().Mode().Perm())
+			if err != nil {
+				return fmt.Errorf("failed to create file %s: %w", outPath, err)
+			}
+			defer func() {
+				err = errors.Join(err, file.Close())
+			}()
+			written, err := io.Copy(file, tr)
+			if err != nil {
+				return fmt.Errorf("failed to write file %s: %w", outPath, err)
+			}
+			if written != header.Size {
+				return fmt.Errorf("could not unpack file %s", outPath)
+			}
+
+		default:
+			return fmt.Errorf("unrecognized type in archive: %s", header.Name)
+		}
+	}
+	return nil
+}
+
+// getStoreForRef returns local storage if it contains conf.modelRef, falling back to the
+// remote registry otherwise.
+func getStoreForRef(ctx context.Context, conf *unpackConfig) (oras.Target, error) {
+	storageHome := constants.StoragePath(conf.configHome)
+	localRepo, err := local.NewLocalRepo(storageHome, conf.modelRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read local storage: %s\n", err)
+	}
+
+	if _, err := localRepo.Resolve(ctx, conf.modelRef.Reference); err == nil {
+		// Reference is present in local storage
+		return localRepo, nil
+	}
+
+	if conf.modelRef.Registry == util.DefaultRegistry {
+		return nil, fmt.Errorf("not found")
+	}
+	// Not in local storage, check remote
+	repo, err := remote.NewRepository(ctx, conf.modelRef.Registry, conf.modelRef.Repository, conf.network)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve repository %s in registry %s: %w", conf.modelRef.Repository, conf.modelRef.Registry, err)
+	}
+	if _, err := repo.Resolve(ctx, conf.modelRef.Reference); err != nil {
+		if errors.Is(err, errdef.ErrNotFound) {
+			return nil, fmt.Errorf("reference %s is not present in local storage and could not be found in remote", conf.modelRef.String())
+		}
+		return nil, fmt.Errorf("unexpected error retrieving reference from remote: %w", err)
+	}
+
+	return repo, nil
+}
+
+func getIndex(list []string, s string) int {
+	for idx, item := range list {
+		if s == item {
+			return idx
+		}
+	}
+	return -1
+}
+
+// openUnpackDir creates and opens the directory to unpack into. If parent is set, dir is
+// relative to parent and is created and opened through it; otherwise, unpackDir is used.
+func openUnpackDir(parent *os.Root, dir, unpackDir string) (*os.Root, error) {
+	if parent == nil {
+		if err := os.MkdirAll(unpackDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directory %s: %w", unpackDir, err)
+		}
+		root, err := os.OpenRoot(unpackDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open directory %s: %w", unpackDir, err)
+		}
+		return root, nil
+	}
+	if err := mkdirAllInRoot(parent, dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory %s: %w", unpackDir, err)
+	}
+	root, err := parent.OpenRoot(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open directory %s: %w", unpackDir, err)
+	}
+	return root, nil
+}
+
+// mkdirAllInRoot creates the directory name within root, along with any missing parents. Each
+// component is created through root, so symlinks cannot be used to create directories outside of it.
+func mkdirAllInRoot(root *os.Root, name string, perm os.FileMode) error {
+	name = filepath.Clean(name)
+	if name == "." {
+		return nil
+	}
+	if fi, err := root.Stat(name); err == nil {
+		if !fi.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: name, Err: syscall.ENOTDIR}
+		}
+		return nil
+	}
+	if parent := filepath.Dir(name); parent != "." {
+		if err := mkdirAllInRoot(root, parent, perm); err != nil {
+			return err
+		}
+	}
+	if err := root.Mkdir(name, perm); err != nil {
+		// Handle the directory being created concurrently
+		if fi, statErr := root.Stat(name); statErr == nil && fi.IsDir() {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// writeFileInRoot writes data to the file name within root, creating or truncating it as necessary.
+func writeFileInRoot(root *os.Root, name string, data []byte, perm os.FileMode) error {
+	file, err := root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	return errors.Join(err, file.Close())
+}
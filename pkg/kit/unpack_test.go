@@ -0,0 +1,93 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kit
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kitops/pkg/output"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractTarStaysInRoot(t *testing.T) {
+	tests := []struct {
+		name      string
+		entry     string
+		expectErr bool
+	}{
+		{name: "file in root", entry: "file.txt"},
+		{name: "file in subdirectory", entry: "subdir/file.txt"},
+		{name: "parent directory", entry: "../escape.txt", expectErr: true},
+		{name: "nested parent directory", entry: "subdir/../../escape.txt", expectErr: true},
+		{name: "absolute path", entry: "/escape.txt", expectErr: true},
+		{name: "through symlink", entry: "link/escape.txt", expectErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseDir := t.TempDir()
+			extractDir := filepath.Join(baseDir, "extract")
+			require.NoError(t, os.MkdirAll(filepath.Join(extractDir, "subdir"), 0755))
+			require.NoError(t, os.Symlink(baseDir, filepath.Join(extractDir, "link")))
+
+			buf := &bytes.Buffer{}
+			tw := tar.NewWriter(buf)
+			require.NoError(t, tw.WriteHeader(&tar.Header{Name: tt.entry, Mode: 0644, Size: 4, Typeflag: tar.TypeReg}))
+			_, err := tw.Write([]byte("test"))
+			require.NoError(t, err)
+			require.NoError(t, tw.Close())
+
+			root, err := os.OpenRoot(extractDir)
+			require.NoError(t, err)
+			defer root.Close()
+			_, plog := output.NewLogger(nil, output.LogLevelError, nil).WrapUnpackReadCloser(0, nil)
+
+			err = extractTar(tar.NewReader(buf), root, false, plog)
+			if tt.expectErr {
+				assert.Error(t, err)
+				assert.NoFileExists(t, filepath.Join(baseDir, "escape.txt"))
+			} else {
+				assert.NoError(t, err)
+				assert.FileExists(t, filepath.Join(extractDir, filepath.FromSlash(tt.entry)))
+			}
+		})
+	}
+}
+
+func TestMkdirAllInRoot(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	require.NoError(t, os.Symlink(outside, filepath.Join(dir, "escape")))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file"), []byte("test"), 0644))
+	root, err := os.OpenRoot(dir)
+	require.NoError(t, err)
+	defer root.Close()
+
+	require.NoError(t, mkdirAllInRoot(root, filepath.Join("a", "b", "c"), 0755))
+	assert.DirExists(t, filepath.Join(dir, "a", "b", "c"))
+	require.NoError(t, mkdirAllInRoot(root, filepath.Join("a", "b"), 0755), "existing directories should be accepted")
+
+	assert.Error(t, mkdirAllInRoot(root, filepath.Join("escape", "sub"), 0755))
+	assert.NoDirExists(t, filepath.Join(outside, "sub"))
+	assert.Error(t, mkdirAllInRoot(root, filepath.Join("file", "sub"), 0755))
+	assert.Error(t, mkdirAllInRoot(root, filepath.Join("..", "sub"), 0755))
+}
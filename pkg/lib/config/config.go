@@ -0,0 +1,137 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"strings"
+
+	"kitops/pkg/lib/constants"
+	"kitops/pkg/lib/ratelimit"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config represents the optional CLI configuration file stored at
+// $KITOPS_HOME/config.yaml. All fields are optional; a missing file is
+// equivalent to an empty configuration.
+type Config struct {
+	// Registries holds per-registry settings, keyed by registry hostname
+	// (including port, if any), e.g. "registry.example.com:5000"
+	Registries map[string]RegistryConfig `yaml:"registries,omitempty"`
+	// LimitRate is the default maximum combined transfer rate for pushes, pulls,
+	// and imports, e.g. "50MB/s". It can be overridden using the --limit-rate flag.
+	LimitRate string `yaml:"limitRate,omitempty"`
+	// Tracing configures exporting OpenTelemetry traces for CLI operations.
+	Tracing TracingConfig `yaml:"tracing,omitempty"`
+	// HuggingFace configures access to HuggingFace when importing repositories.
+	HuggingFace HuggingFaceConfig `yaml:"huggingface,omitempty"`
+}
+
+type HuggingFaceConfig struct {
+	// Endpoint is the URL of the HuggingFace Hub to use, e.g. a mirror or self-hosted hub. The
+	// HF_ENDPOINT environment variable takes precedence over this setting. If neither is set,
+	// https://huggingface.co is used.
+	Endpoint string `yaml:"endpoint,omitempty"`
+}
+
+// TracingConfig holds settings for exporting traces over OTLP. Tracing can also be
+// enabled using the standard OTEL_EXPORTER_OTLP_ENDPOINT environment variables.
+type TracingConfig struct {
+	// Enabled turns on exporting traces
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Endpoint is the URL of an OTLP/HTTP collector, e.g. "http://localhost:4318".
+	// If unset, the OpenTelemetry default (or environment configuration) is used.
+	Endpoint string `yaml:"endpoint,omitempty"`
+}
+
+// RegistryConfig holds settings that apply to a single remote registry.
+type RegistryConfig struct {
+	// Mirrors is an ordered list of registries that serve the same repositories
+	// as this registry. When reading from the registry, mirrors are tried in order
+	// before falling back to the registry itself.
+	Mirrors []Mirror `yaml:"mirrors,omitempty"`
+	// AllowUnverifiedTags allows tags to be resolved using mirrors if the registry itself
+	// cannot be reached. Tags are normally always resolved by the registry, as content
+	// returned by a mirror for a tag cannot be verified.
+	AllowUnverifiedTags bool `yaml:"allowUnverifiedTags,omitempty"`
+}
+
+// Mirror is a registry that mirrors the contents of another registry.
+type Mirror struct {
+	// Host is the hostname (and optionally port) of the mirror registry
+	Host string `yaml:"host"`
+	// PlainHTTP configures whether the mirror should be accessed over plain HTTP
+	PlainHTTP bool `yaml:"plainHTTP,omitempty"`
+}
+
+// LoadConfig reads the configuration file in configHome. If the file does not exist,
+// an empty configuration is returned.
+func LoadConfig(configHome string) (*Config, error) {
+	configPath := constants.ConfigPath(configHome)
+	configBytes, err := os.ReadFile(configPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &Config{}, nil
+		}
+		return nil, fmt.Errorf("failed to read config file %s: %w", configPath, err)
+	}
+	config := &Config{}
+	if err := yaml.Unmarshal(configBytes, config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", configPath, err)
+	}
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", configPath, err)
+	}
+	return config, nil
+}
+
+func (c *Config) validate() error {
+	if c.LimitRate != "" {
+		if _, err := ratelimit.ParseRate(c.LimitRate); err != nil {
+			return fmt.Errorf("invalid limitRate: %w", err)
+		}
+	}
+	if c.Tracing.Endpoint != "" {
+		if u, err := url.Parse(c.Tracing.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid tracing endpoint %s: must be a URL, e.g. http://localhost:4318", c.Tracing.Endpoint)
+		}
+	}
+	if c.HuggingFace.Endpoint != "" {
+		if u, err := url.Parse(c.HuggingFace.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid huggingface endpoint %s: must be a URL, e.g. https://huggingface.co", c.HuggingFace.Endpoint)
+		}
+	}
+	for registry, regConfig := range c.Registries {
+		for idx, mirror := range regConfig.Mirrors {
+			if mirror.Host == "" {
+				return fmt.Errorf("mirror %d for registry %s does not specify a host", idx+1, registry)
+			}
+			if strings.Contains(mirror.Host, "/") {
+				return fmt.Errorf("mirror host %s for registry %s must not contain a scheme or path", mirror.Host, registry)
+			}
+			if mirror.Host == registry {
+				return fmt.Errorf("registry %s cannot be a mirror for itself", registry)
+			}
+		}
+	}
+	return nil
+}
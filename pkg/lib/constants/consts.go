@@ -40,6 +40,7 @@ const (
 	StorageSubpath                    = "storage"
 	CacheSubpath                      = "cache"
 	CredentialsSubpath                = "credentials.json"
+	ConfigSubpath                     = "config.yaml"
 	HarnessSubpath                    = "harness"
 	HarnessProcessFile                = "process.pid"
 	HarnessLogFile                    = "harness.log"
@@ -48,6 +49,14 @@ const (
 	// Kitops-specific annotations for modelkit artifacts
 	CliVersionAnnotation = "ml.kitops.modelkit.cli-version"
 
+	// Annotations on the descriptors in an image index that groups variants of a modelkit
+	// (e.g. different quantizations or formats of the same model)
+	VariantAnnotation             = "ml.kitops.modelkit.variant"
+	VariantAnnotationPrefix       = "ml.kitops.modelkit.variant."
+	VariantFormatAnnotation       = "ml.kitops.modelkit.variant.format"
+	VariantQuantizationAnnotation = "ml.kitops.modelkit.variant.quantization"
+	VariantSizeAnnotation         = "ml.kitops.modelkit.variant.size"
+
 	// MaxModelRefChain is the maximum number of "parent" modelkits a modelkit may have
 	// by e.g. referring to another modelkit in its .model.path
 	MaxModelRefChain = 10
@@ -118,6 +127,12 @@ func IngestPath(storageBase string) string {
 	return filepath.Join(storageBase, "ingest")
 }
 
+// StorageLockPath returns the path of the file used to lock local storage while its indexes
+// are modified.
+func StorageLockPath(storageBase string) string {
+	return filepath.Join(storageBase, "storage.lock")
+}
+
 func HarnessPath(configBase string) string {
 	return filepath.Join(configBase, HarnessSubpath)
 }
@@ -126,6 +141,11 @@ func CredentialsPath(configBase string) string {
 	return filepath.Join(configBase, CredentialsSubpath)
 }
 
+// ConfigPath returns the path to the CLI configuration file within the config directory.
+func ConfigPath(configBase string) string {
+	return filepath.Join(configBase, ConfigSubpath)
+}
+
 func CachePath(configBase string) string {
 	return filepath.Join(configBase, CacheSubpath)
 }
@@ -20,4 +20,5 @@ const (
 	KitopsHomeEnvVar    = "KITOPS_HOME"
 	ClientCertEnvVar    = "KITOPS_CLIENT_CERT"
 	ClientCertKeyEnvVar = "KITOPS_CLIENT_KEY"
+	ServeTokenEnvVar    = "KITOPS_SERVE_TOKEN"
 )
@@ -96,6 +96,9 @@ func FormatMediaTypeForUser(mediaType string) string {
 	if mediaType == ocispec.MediaTypeImageManifest {
 		return "manifest"
 	}
+	if mediaType == ocispec.MediaTypeImageIndex {
+		return "index"
+	}
 	parsed := ParseMediaType(mediaType)
 	return parsed.BaseType
 }
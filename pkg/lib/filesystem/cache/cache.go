@@ -17,22 +17,41 @@
 package cache
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/fs"
-	"kitops/pkg/output"
 	"os"
 	"path/filepath"
-)
 
-var cacheHomeDir = os.TempDir()
+	"kitops/pkg/lib/constants"
+	"kitops/pkg/output"
+)
 
-func SetCacheHome(cacheHome string) {
-	cacheHomeDir = cacheHome
+// cacheHome returns the cache directory within the configuration directory set on ctx (see
+// constants.ConfigKey), or within the default configuration directory if ctx does not have one.
+func cacheHome(ctx context.Context) (string, error) {
+	if configHome, ok := ctx.Value(constants.ConfigKey{}).(string); ok && configHome != "" {
+		return constants.CachePath(configHome), nil
+	}
+	configHome, err := constants.DefaultConfigPath()
+	if err != nil {
+		return "", fmt.Errorf("failed to get default config directory: %w", err)
+	}
+	return constants.CachePath(configHome), nil
 }
 
-func cacheHome() string {
-	return cacheHomeDir
+// cacheSubdir returns the path to subDir within the cache directory for ctx, creating it if necessary.
+func cacheSubdir(ctx context.Context, subDir CacheSubDir) (string, error) {
+	home, err := cacheHome(ctx)
+	if err != nil {
+		return "", err
+	}
+	cacheSubDir := filepath.Join(home, string(subDir))
+	if err := os.MkdirAll(cacheSubDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create cache directory %s: %w", cacheSubDir, err)
+	}
+	return cacheSubDir, nil
 }
 
 type CacheSubDir string
@@ -40,20 +59,23 @@ type CacheSubDir string
 const (
 	CachePackSubdir   CacheSubDir = "pack"
 	CacheImportSubdir CacheSubDir = "import"
+	CacheUploadSubdir CacheSubDir = "upload"
+	CachePushSubdir   CacheSubDir = "push"
 )
 
 // MkCacheDir creates a directory within configHome to be used for temporary storage and returns a function that can
 // be called to remove it once it is no longer needed. If cacheKey is not empty, the cache directory will be
 // deterministic and can be used to resume operations. Otherwise the directory will be generated with a random,
 // non-colliding name.
-func MkCacheDir(subDir CacheSubDir, cacheKey string) (cacheDir string, cleanup func(), err error) {
-	cacheSubDir := filepath.Join(cacheHome(), string(subDir))
-	if err := os.MkdirAll(cacheSubDir, 0700); err != nil {
-		return "", nil, fmt.Errorf("failed to create cache directory %s: %w", cacheSubDir, err)
+func MkCacheDir(ctx context.Context, subDir CacheSubDir, cacheKey string) (cacheDir string, cleanup func(), err error) {
+	cacheSubDir, err := cacheSubdir(ctx, subDir)
+	if err != nil {
+		return "", nil, err
 	}
 	if cacheKey != "" {
+		// Directories with a key are reused if they already exist, e.g. to resume an interrupted operation
 		cacheDir = filepath.Join(cacheSubDir, cacheKey)
-		if err := os.Mkdir(cacheDir, 0700); err != nil {
+		if err := os.MkdirAll(cacheDir, 0700); err != nil {
 			return "", nil, fmt.Errorf("failed to create cache directory %s: %w", cacheDir, err)
 		}
 	} else {
@@ -66,16 +88,27 @@ func MkCacheDir(subDir CacheSubDir, cacheKey string) (cacheDir string, cleanup f
 
 	cleanup = func() {
 		if err := os.RemoveAll(cacheDir); err != nil {
-			output.Logf(output.LogLevelWarn, "Failed to remove temporary directory %s: %s", cacheDir, err)
+			output.FromContext(ctx).Logf(output.LogLevelWarn, "Failed to remove temporary directory %s: %s", cacheDir, err)
 		}
 	}
 	return cacheDir, cleanup, nil
 }
 
-func MkCacheFile(subDir CacheSubDir, basename string) (tempFile *os.File, cleanup func(), err error) {
-	cacheSubDir := filepath.Join(cacheHome(), string(subDir))
-	if err := os.MkdirAll(cacheSubDir, 0700); err != nil {
-		return nil, nil, fmt.Errorf("failed to create cache directory %s: %w", cacheSubDir, err)
+// CacheFilePath returns the path for a file named filename within a cache subdirectory, creating the
+// subdirectory if necessary. The file itself is not created. This can be used for files that need to
+// persist between runs, e.g. to resume operations.
+func CacheFilePath(ctx context.Context, subDir CacheSubDir, filename string) (string, error) {
+	cacheSubDir, err := cacheSubdir(ctx, subDir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheSubDir, filename), nil
+}
+
+func MkCacheFile(ctx context.Context, subDir CacheSubDir, basename string) (tempFile *os.File, cleanup func(), err error) {
+	cacheSubDir, err := cacheSubdir(ctx, subDir)
+	if err != nil {
+		return nil, nil, err
 	}
 	f, err := os.CreateTemp(cacheSubDir, basename)
 	if err != nil {
@@ -85,17 +118,21 @@ func MkCacheFile(subDir CacheSubDir, basename string) (tempFile *os.File, cleanu
 	tempFilePath := filepath.Join(cacheSubDir, f.Name())
 	cleanup = func() {
 		if err := f.Close(); err != nil && !errors.Is(err, fs.ErrClosed) {
-			output.Errorf("Error closing temporary file %s: %s", tempFilePath, err)
+			output.FromContext(ctx).Errorf("Error closing temporary file %s: %s", tempFilePath, err)
 		}
 		if err := os.Remove(f.Name()); err != nil && !os.IsNotExist(err) {
-			output.Errorf("Failed to remove temporary file %s: %w", tempFilePath, err)
+			output.FromContext(ctx).Errorf("Failed to remove temporary file %s: %s", tempFilePath, err)
 		}
 	}
 	return f, cleanup, nil
 }
 
-func CleanCacheDir(subDir CacheSubDir) error {
-	cacheSubDir := filepath.Join(cacheHome(), string(subDir))
+func CleanCacheDir(ctx context.Context, subDir CacheSubDir) error {
+	home, err := cacheHome(ctx)
+	if err != nil {
+		return err
+	}
+	cacheSubDir := filepath.Join(home, string(subDir))
 	ds, err := os.ReadDir(cacheSubDir)
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
@@ -118,7 +155,11 @@ func CleanCacheDir(subDir CacheSubDir) error {
 	return nil
 }
 
-func StatCache() (totalSize int64, subdirsSize map[string]int64, err error) {
+func StatCache(ctx context.Context) (totalSize int64, subdirsSize map[string]int64, err error) {
+	home, err := cacheHome(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
 	getDirSize := func(dir string) (int64, error) {
 		var dirSize int64
 		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
@@ -138,7 +179,7 @@ func StatCache() (totalSize int64, subdirsSize map[string]int64, err error) {
 		return dirSize, nil
 	}
 
-	ds, err := os.ReadDir(cacheHome())
+	ds, err := os.ReadDir(home)
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
 			return 0, map[string]int64{}, nil
@@ -147,7 +188,7 @@ func StatCache() (totalSize int64, subdirsSize map[string]int64, err error) {
 	}
 	subdirsSize = map[string]int64{}
 	for _, dirEntry := range ds {
-		size, err := getDirSize(filepath.Join(cacheHome(), dirEntry.Name()))
+		size, err := getDirSize(filepath.Join(home, dirEntry.Name()))
 		if err != nil {
 			return 0, nil, err
 		}
@@ -158,8 +199,12 @@ func StatCache() (totalSize int64, subdirsSize map[string]int64, err error) {
 	return totalSize, subdirsSize, nil
 }
 
-func ClearCache() error {
-	ds, err := os.ReadDir(cacheHome())
+func ClearCache(ctx context.Context) error {
+	home, err := cacheHome(ctx)
+	if err != nil {
+		return err
+	}
+	ds, err := os.ReadDir(home)
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
 			return nil
@@ -168,11 +213,11 @@ func ClearCache() error {
 	}
 	for _, dirEntry := range ds {
 		if dirEntry.IsDir() {
-			output.Debugf("Removing cache directory %s", dirEntry.Name())
-			os.RemoveAll(filepath.Join(cacheHome(), dirEntry.Name()))
+			output.FromContext(ctx).Debugf("Removing cache directory %s", dirEntry.Name())
+			os.RemoveAll(filepath.Join(home, dirEntry.Name()))
 		} else {
-			output.Debugf("Removing cache file %s", dirEntry.Name())
-			os.Remove(filepath.Join(cacheHome(), dirEntry.Name()))
+			output.FromContext(ctx).Debugf("Removing cache file %s", dirEntry.Name())
+			os.Remove(filepath.Join(home, dirEntry.Name()))
 		}
 	}
 	return nil
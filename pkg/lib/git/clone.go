@@ -17,82 +17,164 @@
 package git
 
 import (
+	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io/fs"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"strings"
 
 	"kitops/pkg/output"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/storage/memory"
 )
 
-func CloneRepository(repo, dest, token string) error {
-	if err := checkGit(); err != nil {
-		return err
-	}
+// CloneRepository clones the repository at repo into dest without requiring git to be installed. Only the files
+// for the specified revision (a branch, tag, ref, or full commit SHA) are fetched, without earlier history where
+// the server allows it; if revision is empty, the remote's default branch is used. Files stored in Git LFS are not
+// downloaded and are left as LFS pointer files; see LFSClient for downloading them. Returns the hash of the commit
+// that was checked out.
+func CloneRepository(ctx context.Context, repo, dest, token, revision string) (string, error) {
 	if err := checkDestination(dest); err != nil {
-		return err
+		return "", err
 	}
+	auth := authForToken(token)
 
-	cloneEnv := os.Environ()
-	if token != "" {
-		// Set up additional git config settings without overriding user's actual gitconfig.
-		// Git documentation for this "feature": https://git-scm.com/docs/git-config#ENVIRONMENT
-		cloneEnv = append(cloneEnv, fmt.Sprintf(`KIT_IMPORT_TOKEN=%s`, token))
-		cloneEnv = append(cloneEnv,
-			"GIT_CONFIG_COUNT=2",
-			"GIT_CONFIG_KEY_0=credential.username",
-			"GIT_CONFIG_VALUE_0=token",
-			"GIT_CONFIG_KEY_1=credential.helper",
-			`GIT_CONFIG_VALUE_1=!f() { test "$1" = get && echo "password=${KIT_IMPORT_TOKEN}"; }; f`,
-		)
-	} else {
-		// This is a workaround to disable interactive password prompts
-		cloneEnv = append(cloneEnv, "GIT_ASKPASS=true")
-		cloneEnv = append(cloneEnv, "GIT_TERMINAL_PROMPT=0")
+	refName, commitHash, err := resolveRevision(ctx, repo, revision, auth)
+	if err != nil {
+		return "", err
 	}
 
-	// Clone without LFS enabled to get metadata about repo first
-	cloneCmd := exec.Command("git", "clone", "--depth", "1", repo, dest)
-	cloneCmd.Env = cloneEnv
-	cloneCmd.Env = append(cloneCmd.Env, "GIT_LFS_SKIP_SMUDGE=1")
-	cloneCmd.Stderr = os.Stderr
-	cloneCmd.Stdout = os.Stdout
-
 	output.Infof("Cloning repository %s", repo)
-	if err := cloneCmd.Run(); err != nil {
-		return fmt.Errorf("error cloning repository: %w", err)
+	if refName == "" {
+		if err := fetchCommit(ctx, repo, dest, commitHash, auth); err != nil {
+			return "", err
+		}
+		return commitHash, nil
 	}
 
-	// Pull LFS files
-	lfsCmd := exec.Command("git", "lfs", "pull")
-	lfsCmd.Dir = dest
-	lfsCmd.Env = cloneEnv
-	lfsCmd.Stdout = os.Stdout
-	lfsCmd.Stderr = os.Stderr
-	output.Infof("Pulling large files")
-	if err := lfsCmd.Run(); err != nil {
-		return fmt.Errorf("error downloading LFS files: %w", err)
-	}
-	// LFS pull prints progress by overwriting a line repeatedly; once it's done
-	// we need to print a newline to avoid overwriting the last line
-	fmt.Printf("\n")
+	// Shallow clone of the requested ref
+	repository, err := gogit.PlainCloneContext(ctx, dest, false, &gogit.CloneOptions{
+		URL:           repo,
+		Auth:          auth,
+		Tags:          gogit.NoTags,
+		ReferenceName: refName,
+		SingleBranch:  true,
+		Depth:         1,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error cloning repository: %w", err)
+	}
+	head, err := repository.Head()
+	if err != nil {
+		return "", fmt.Errorf("error reading cloned repository: %w", err)
+	}
+	return head.Hash().String(), nil
+}
 
+// fetchCommit fetches only the commit commitHash from repo into dest and checks it out. If the server does not
+// allow fetching commits that are not the tip of a reference, the full repository is cloned instead.
+func fetchCommit(ctx context.Context, repo, dest, commitHash string, auth transport.AuthMethod) error {
+	repository, err := gogit.PlainInit(dest, false)
+	if err != nil {
+		return fmt.Errorf("error cloning repository: %w", err)
+	}
+	remote, err := repository.CreateRemote(&config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{repo},
+	})
+	if err != nil {
+		return fmt.Errorf("error cloning repository: %w", err)
+	}
+	err = remote.FetchContext(ctx, &gogit.FetchOptions{
+		RefSpecs: []config.RefSpec{config.RefSpec(fmt.Sprintf("%s:%s", commitHash, plumbing.NewBranchReferenceName("kit-revision")))},
+		Auth:     auth,
+		Tags:     gogit.NoTags,
+		Depth:    1,
+	})
+	if errors.Is(err, gogit.ErrExactSHA1NotSupported) {
+		output.Debugf("Repository does not support fetching commit %s directly; cloning full repository", commitHash)
+		if err := os.RemoveAll(filepath.Join(dest, gogit.GitDirName)); err != nil {
+			return fmt.Errorf("error cloning repository: %w", err)
+		}
+		repository, err = gogit.PlainCloneContext(ctx, dest, false, &gogit.CloneOptions{
+			URL:        repo,
+			Auth:       auth,
+			Tags:       gogit.NoTags,
+			NoCheckout: true,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("error cloning repository: %w", err)
+	}
+
+	worktree, err := repository.Worktree()
+	if err != nil {
+		return fmt.Errorf("error checking out commit %s: %w", commitHash, err)
+	}
+	if err := worktree.Checkout(&gogit.CheckoutOptions{Hash: plumbing.NewHash(commitHash)}); err != nil {
+		return fmt.Errorf("error checking out commit %s: %w", commitHash, err)
+	}
 	return nil
 }
 
-func checkGit() error {
-	gitCmd := exec.Command("git", "version")
-	if err := gitCmd.Run(); err != nil {
-		return fmt.Errorf("git is not installed")
+// resolveRevision finds the reference in the remote repository that matches revision. Revisions are matched as
+// full reference names (e.g. refs/pr/1), branches, and tags, in that order. If revision is a full commit SHA that
+// does not match any reference name, an empty reference name and the commit SHA are returned. If revision is empty,
+// the remote's HEAD is used.
+func resolveRevision(ctx context.Context, repo, revision string, auth transport.AuthMethod) (plumbing.ReferenceName, string, error) {
+	if revision == "" {
+		return plumbing.HEAD, "", nil
+	}
+	remote := gogit.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{repo},
+	})
+	refs, err := remote.ListContext(ctx, &gogit.ListOptions{Auth: auth})
+	if err != nil {
+		return "", "", fmt.Errorf("error listing references in repository: %w", err)
 	}
+	candidates := []plumbing.ReferenceName{
+		plumbing.ReferenceName(revision),
+		plumbing.NewBranchReferenceName(revision),
+		plumbing.NewTagReferenceName(revision),
+	}
+	for _, candidate := range candidates {
+		for _, ref := range refs {
+			if ref.Name() == candidate {
+				return candidate, "", nil
+			}
+		}
+	}
+	if isCommitSHA(revision) {
+		return "", strings.ToLower(revision), nil
+	}
+	return "", "", fmt.Errorf("revision %s not found in repository", revision)
+}
 
-	gitLFSCmd := exec.Command("git", "lfs", "version")
-	if err := gitLFSCmd.Run(); err != nil {
-		return fmt.Errorf("git-lfs is not installed")
+func authForToken(token string) transport.AuthMethod {
+	if token == "" {
+		return nil
 	}
+	return &githttp.BasicAuth{
+		Username: "token",
+		Password: token,
+	}
+}
 
-	return nil
+func isCommitSHA(revision string) bool {
+	if len(revision) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(revision)
+	return err == nil
 }
 
 func checkDestination(path string) error {
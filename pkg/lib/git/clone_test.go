@@ -0,0 +1,115 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestRepository creates a repository with one commit for each of contents, where each commit changes
+// the contents of model.txt. If allowSHAInWant is true, the repository allows fetching any commit by its SHA.
+// Returns the path to the repository and the hashes of the commits, in order.
+func createTestRepository(t *testing.T, allowSHAInWant bool, contents ...string) (string, []string) {
+	t.Helper()
+	repoDir := t.TempDir()
+	repository, err := gogit.PlainInit(repoDir, false)
+	require.NoError(t, err)
+	if allowSHAInWant {
+		cfg, err := repository.Config()
+		require.NoError(t, err)
+		cfg.Raw.Section("uploadpack").SetOption("allowReachableSHA1InWant", "true")
+		require.NoError(t, repository.SetConfig(cfg))
+	}
+	worktree, err := repository.Worktree()
+	require.NoError(t, err)
+	var hashes []string
+	for _, content := range contents {
+		require.NoError(t, os.WriteFile(filepath.Join(repoDir, "model.txt"), []byte(content), 0644))
+		_, err := worktree.Add("model.txt")
+		require.NoError(t, err)
+		hash, err := worktree.Commit("update model", &gogit.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		})
+		require.NoError(t, err)
+		hashes = append(hashes, hash.String())
+	}
+	return repoDir, hashes
+}
+
+func TestCloneRepository(t *testing.T) {
+	// Local repositories are served by running git-upload-pack
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	tests := []struct {
+		name           string
+		allowSHAInWant bool
+		revision       func(hashes []string) string
+		expectCommit   int
+		expectContents string
+		expectShallow  bool
+	}{
+		{
+			name:           "default branch",
+			revision:       func([]string) string { return "" },
+			expectCommit:   2,
+			expectContents: "third",
+			expectShallow:  true,
+		},
+		{
+			name:           "commit SHA fetches single commit",
+			allowSHAInWant: true,
+			revision:       func(hashes []string) string { return hashes[1] },
+			expectCommit:   1,
+			expectContents: "second",
+			expectShallow:  true,
+		},
+		{
+			name:           "commit SHA falls back to full clone",
+			revision:       func(hashes []string) string { return hashes[1] },
+			expectCommit:   1,
+			expectContents: "second",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repoDir, hashes := createTestRepository(t, tt.allowSHAInWant, "first", "second", "third")
+			dest := filepath.Join(t.TempDir(), "clone")
+			hash, err := CloneRepository(context.Background(), repoDir, dest, "", tt.revision(hashes))
+			require.NoError(t, err)
+			assert.Equal(t, hashes[tt.expectCommit], hash)
+			contents, err := os.ReadFile(filepath.Join(dest, "model.txt"))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectContents, string(contents))
+			if tt.expectShallow {
+				assert.FileExists(t, filepath.Join(dest, ".git", "shallow"))
+			} else {
+				assert.NoFileExists(t, filepath.Join(dest, ".git", "shallow"))
+			}
+		})
+	}
+}
@@ -0,0 +1,364 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"kitops/pkg/lib/network"
+	"kitops/pkg/lib/ratelimit"
+	"kitops/pkg/lib/telemetry"
+	"kitops/pkg/output"
+
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	lfsPointerVersionPrefix = "version https://git-lfs.github.com/spec/"
+	// LFS pointer files are small text files; anything larger cannot be a pointer
+	maxLFSPointerSize = 1024
+
+	lfsMediaType = "application/vnd.git-lfs+json"
+	// Maximum number of objects to request in a single batch API call
+	lfsBatchSize = 100
+)
+
+// initialRetryDelay is the delay before retrying a failed download. It is doubled for each subsequent retry.
+var initialRetryDelay = 2 * time.Second
+
+// LFSObject is a file in a repository whose contents are stored using Git LFS.
+type LFSObject struct {
+	// Path is the slash-separated path of the file, relative to the repository root
+	Path string
+	// OID is the sha256 digest of the file's contents
+	OID  string
+	Size int64
+}
+
+type lfsBatchRequest struct {
+	Operation string           `json:"operation"`
+	Transfers []string         `json:"transfers"`
+	Objects   []lfsBatchObject `json:"objects"`
+	HashAlgo  string           `json:"hash_algo"`
+}
+
+type lfsBatchResponse struct {
+	Transfer string           `json:"transfer,omitempty"`
+	Objects  []lfsBatchObject `json:"objects"`
+	Message  string           `json:"message,omitempty"`
+}
+
+type lfsBatchObject struct {
+	OID     string               `json:"oid"`
+	Size    int64                `json:"size"`
+	Actions map[string]lfsAction `json:"actions,omitempty"`
+	Error   *lfsObjectError      `json:"error,omitempty"`
+}
+
+type lfsAction struct {
+	Href   string            `json:"href"`
+	Header map[string]string `json:"header,omitempty"`
+}
+
+type lfsObjectError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// ParseLFSPointer parses contents as a Git LFS pointer file, returning false if it is not a valid pointer.
+func ParseLFSPointer(contents []byte) (oid string, size int64, ok bool) {
+	if len(contents) > maxLFSPointerSize || !bytes.HasPrefix(contents, []byte(lfsPointerVersionPrefix)) {
+		return "", 0, false
+	}
+	size = -1
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), " ")
+		if !found {
+			continue
+		}
+		switch key {
+		case "oid":
+			oid, _ = strings.CutPrefix(value, "sha256:")
+			if len(oid) != sha256.Size*2 || oid == value {
+				return "", 0, false
+			}
+		case "size":
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || parsed < 0 {
+				return "", 0, false
+			}
+			size = parsed
+		}
+	}
+	if oid == "" || size < 0 {
+		return "", 0, false
+	}
+	return oid, size, true
+}
+
+// FindLFSObjects walks dir and returns all files that are Git LFS pointers.
+func FindLFSObjects(dir string) ([]LFSObject, error) {
+	var objects []LFSObject
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() > maxLFSPointerSize {
+			return nil
+		}
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		oid, size, ok := ParseLFSPointer(contents)
+		if !ok {
+			return nil
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, LFSObject{Path: filepath.ToSlash(relPath), OID: oid, Size: size})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find Git LFS files: %w", err)
+	}
+	return objects, nil
+}
+
+// DownloadLFSObjects downloads the contents of LFS objects from the Git LFS server for repo using the batch API,
+// replacing the corresponding pointer files in destDir. Downloaded files are verified against their size and
+// sha256 digest before being moved into place.
+func DownloadLFSObjects(
+	ctx context.Context,
+	repo, token, destDir string,
+	objects []LFSObject,
+	maxConcurrency int,
+	rateLimiter *ratelimit.Limiter) error {
+
+	if len(objects) == 0 {
+		return nil
+	}
+	client := &http.Client{
+		Timeout:   1 * time.Hour,
+		Transport: telemetry.Transport(rateLimiter.Transport(http.DefaultTransport)),
+	}
+
+	// The same object may be referenced by multiple paths, but only needs to be downloaded once
+	pathsByOID := map[string][]string{}
+	var unique []lfsBatchObject
+	for _, obj := range objects {
+		if _, ok := pathsByOID[obj.OID]; !ok {
+			unique = append(unique, lfsBatchObject{OID: obj.OID, Size: obj.Size})
+		}
+		pathsByOID[obj.OID] = append(pathsByOID[obj.OID], obj.Path)
+	}
+
+	var toDownload []lfsBatchObject
+	for start := 0; start < len(unique); start += lfsBatchSize {
+		end := min(start+lfsBatchSize, len(unique))
+		resp, err := requestLFSBatch(ctx, client, lfsBatchURL(repo), token, unique[start:end])
+		if err != nil {
+			return err
+		}
+		for _, obj := range resp.Objects {
+			paths, ok := pathsByOID[obj.OID]
+			if !ok {
+				return fmt.Errorf("LFS server returned unexpected object %s", obj.OID)
+			}
+			if obj.Error != nil {
+				return fmt.Errorf("failed to download LFS object for %s: %s (code %d)", paths[0], obj.Error.Message, obj.Error.Code)
+			}
+			if _, ok := obj.Actions["download"]; !ok {
+				return fmt.Errorf("LFS server did not return a download link for %s", paths[0])
+			}
+			toDownload = append(toDownload, obj)
+		}
+	}
+
+	progress, plog := output.FromContext(ctx).NewDownloadProgress()
+	errs, errCtx := errgroup.WithContext(ctx)
+	errs.SetLimit(maxConcurrency)
+	for _, obj := range toDownload {
+		obj := obj
+		paths := pathsByOID[obj.OID]
+		errs.Go(func() error {
+			plog.Infof("Downloading LFS file %s", paths[0])
+			return downloadLFSObject(errCtx, client, obj, destDir, paths, progress, plog)
+		})
+	}
+	if err := errs.Wait(); err != nil {
+		return err
+	}
+	progress.Done()
+
+	return nil
+}
+
+// lfsBatchURL returns the URL of the LFS batch API for repo, following the default server discovery rules in the
+// Git LFS specification.
+func lfsBatchURL(repo string) string {
+	base := strings.TrimSuffix(repo, "/")
+	if !strings.HasSuffix(base, ".git") {
+		base = base + ".git"
+	}
+	return base + "/info/lfs/objects/batch"
+}
+
+func requestLFSBatch(ctx context.Context, client *http.Client, batchURL, token string, objects []lfsBatchObject) (*lfsBatchResponse, error) {
+	reqBody, err := json.Marshal(lfsBatchRequest{
+		Operation: "download",
+		Transfers: []string{"basic"},
+		Objects:   objects,
+		HashAlgo:  "sha256",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create LFS batch request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, batchURL, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create LFS batch request: %w", err)
+	}
+	req.Header.Set("Accept", lfsMediaType)
+	req.Header.Set("Content-Type", lfsMediaType)
+	if token != "" {
+		req.SetBasicAuth("token", token)
+	}
+
+	output.Debugf("Requesting %d LFS objects from %s", len(objects), batchURL)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling LFS batch API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody := &lfsBatchResponse{}
+	decodeErr := json.NewDecoder(resp.Body).Decode(respBody)
+	if resp.StatusCode != http.StatusOK {
+		if decodeErr == nil && respBody.Message != "" {
+			return nil, fmt.Errorf("LFS batch API returned status code %d: %s", resp.StatusCode, respBody.Message)
+		}
+		return nil, fmt.Errorf("LFS batch API returned status code %d", resp.StatusCode)
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("failed to parse LFS batch API response: %w", decodeErr)
+	}
+	if respBody.Transfer != "" && respBody.Transfer != "basic" {
+		return nil, fmt.Errorf("unsupported LFS transfer adapter %s", respBody.Transfer)
+	}
+	return respBody, nil
+}
+
+// lfsVerifier verifies that the contents of an LFS object match its sha256 OID
+type lfsVerifier struct {
+	hash.Hash
+	oid string
+}
+
+func (v *lfsVerifier) Verify() error {
+	if digest := hex.EncodeToString(v.Sum(nil)); digest != v.oid {
+		return fmt.Errorf("%w: expected sha256 %s but got %s", network.ErrDigestMismatch, v.oid, digest)
+	}
+	return nil
+}
+
+// downloadLFSObject downloads obj and writes it to each of paths (relative to destDir), replacing the pointer files.
+func downloadLFSObject(
+	ctx context.Context,
+	client *http.Client,
+	obj lfsBatchObject,
+	destDir string,
+	paths []string,
+	progress *output.DownloadProgressBar,
+	plog *output.ProgressLogger) error {
+
+	action := obj.Actions["download"]
+	header := http.Header{}
+	for key, value := range action.Header {
+		header.Set(key, value)
+	}
+	destPath := filepath.Join(destDir, filepath.FromSlash(paths[0]))
+	err := network.DownloadFile(ctx, client, &network.FileDownload{
+		URL:        action.Href,
+		Header:     header,
+		Name:       paths[0],
+		DestPath:   destPath,
+		Size:       obj.Size,
+		Verifier:   &lfsVerifier{Hash: sha256.New(), oid: obj.OID},
+		RetryDelay: initialRetryDelay,
+	}, progress, plog)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths[1:] {
+		plog.Debugf("Copying LFS file %s to %s", paths[0], path)
+		if err := copyFile(destPath, filepath.Join(destDir, filepath.FromSlash(path))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+	defer in.Close()
+	out, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+	return nil
+}
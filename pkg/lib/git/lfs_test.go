@@ -0,0 +1,222 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package git
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"kitops/pkg/lib/network"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLFSPointer(t *testing.T) {
+	const oid = "4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393"
+	tests := []struct {
+		name         string
+		contents     string
+		expectOk     bool
+		expectOID    string
+		expectedSize int64
+	}{
+		{
+			name:         "valid pointer",
+			contents:     "version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\nsize 12345\n",
+			expectOk:     true,
+			expectOID:    oid,
+			expectedSize: 12345,
+		},
+		{
+			name:     "regular file",
+			contents: "# README\n",
+		},
+		{
+			name:     "missing size",
+			contents: "version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\n",
+		},
+		{
+			name:     "unsupported hash",
+			contents: "version https://git-lfs.github.com/spec/v1\noid sha512:" + oid + "\nsize 1\n",
+		},
+		{
+			name:     "invalid size",
+			contents: "version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\nsize -1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oid, size, ok := ParseLFSPointer([]byte(tt.contents))
+			assert.Equal(t, tt.expectOk, ok)
+			assert.Equal(t, tt.expectOID, oid)
+			if tt.expectOk {
+				assert.Equal(t, tt.expectedSize, size)
+			}
+		})
+	}
+}
+
+func TestLFSBatchURL(t *testing.T) {
+	assert.Equal(t, "https://example.com/org/repo.git/info/lfs/objects/batch", lfsBatchURL("https://example.com/org/repo"))
+	assert.Equal(t, "https://example.com/org/repo.git/info/lfs/objects/batch", lfsBatchURL("https://example.com/org/repo.git/"))
+}
+
+func TestDownloadLFSObjects(t *testing.T) {
+	initialRetryDelay = 10 * time.Millisecond
+	content := []byte(strings.Repeat("model weights ", 1000))
+	sum := sha256.Sum256(content)
+	oid := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name string
+		// handler serves the object for the given attempt (starting from 1)
+		handler       func(w http.ResponseWriter, r *http.Request, attempt int32)
+		expectErr     bool
+		expectAttempt int32
+	}{
+		{
+			name: "downloads object",
+			handler: func(w http.ResponseWriter, r *http.Request, _ int32) {
+				w.Write(content)
+			},
+			expectAttempt: 1,
+		},
+		{
+			name: "retries server errors",
+			handler: func(w http.ResponseWriter, r *http.Request, attempt int32) {
+				if attempt < 3 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.Write(content)
+			},
+			expectAttempt: 3,
+		},
+		{
+			name: "resumes interrupted download",
+			handler: func(w http.ResponseWriter, r *http.Request, attempt int32) {
+				if attempt == 1 {
+					// Send half of the object, then fail the transfer
+					w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+					w.Write(content[:len(content)/2])
+					return
+				}
+				if r.Header.Get("Range") != fmt.Sprintf("bytes=%d-", len(content)/2) {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write(content[len(content)/2:])
+			},
+			expectAttempt: 2,
+		},
+		{
+			name: "does not retry client errors",
+			handler: func(w http.ResponseWriter, r *http.Request, _ int32) {
+				w.WriteHeader(http.StatusForbidden)
+			},
+			expectErr:     true,
+			expectAttempt: 1,
+		},
+		{
+			name: "does not retry digest mismatch",
+			handler: func(w http.ResponseWriter, r *http.Request, _ int32) {
+				w.Write(make([]byte, len(content)))
+			},
+			expectErr:     true,
+			expectAttempt: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			var server *httptest.Server
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/repo.git/info/lfs/objects/batch":
+					json.NewEncoder(w).Encode(lfsBatchResponse{
+						Objects: []lfsBatchObject{{
+							OID:     oid,
+							Size:    int64(len(content)),
+							Actions: map[string]lfsAction{"download": {Href: server.URL + "/object"}},
+						}},
+					})
+				case "/object":
+					tt.handler(w, r, attempts.Add(1))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			destDir := t.TempDir()
+			pointer := fmt.Sprintf("version https://git-lfs.github.com/spec/v1\noid sha256:%s\nsize %d\n", oid, len(content))
+			for _, name := range []string{"model.bin", "copy.bin"} {
+				require.NoError(t, os.WriteFile(filepath.Join(destDir, name), []byte(pointer), 0644))
+			}
+			objects := []LFSObject{
+				{Path: "model.bin", OID: oid, Size: int64(len(content))},
+				{Path: "copy.bin", OID: oid, Size: int64(len(content))},
+			}
+
+			err := DownloadLFSObjects(context.Background(), server.URL+"/repo", "", destDir, objects, 1, nil)
+			assert.Equal(t, tt.expectAttempt, attempts.Load())
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			for _, name := range []string{"model.bin", "copy.bin"} {
+				downloaded, err := os.ReadFile(filepath.Join(destDir, name))
+				require.NoError(t, err)
+				assert.Equal(t, content, downloaded)
+			}
+			assert.NoFileExists(t, filepath.Join(destDir, "model.bin"+network.PartialFileSuffix))
+		})
+	}
+}
+
+func TestDownloadLFSObjectsUnexpectedObject(t *testing.T) {
+	const oid = "4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393"
+	const otherOID = "0000000000000000000000000000000000000000000000000000000000000000"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(lfsBatchResponse{
+			Objects: []lfsBatchObject{{
+				OID:   otherOID,
+				Size:  10,
+				Error: &lfsObjectError{Code: 404, Message: "Object does not exist"},
+			}},
+		})
+	}))
+	defer server.Close()
+
+	objects := []LFSObject{{Path: "model.bin", OID: oid, Size: 10}}
+	err := DownloadLFSObjects(context.Background(), server.URL+"/repo", "", t.TempDir(), objects, 1, nil)
+	assert.ErrorContains(t, err, "unexpected object "+otherOID)
+}
@@ -0,0 +1,182 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package hf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Common license identifiers used on HuggingFace, mapped to their SPDX equivalents where the
+// two differ in more than case.
+var cardLicenseAliases = map[string]string{
+	"odbl":   "ODbL-1.0",
+	"odc-by": "ODC-By-1.0",
+	"pddl":   "PDDL-1.0",
+	"gpl":    "GPL-3.0",
+	"lgpl":   "LGPL-3.0",
+}
+
+// SPDX identifiers mapped to the license identifiers used on HuggingFace where the two differ in
+// more than case.
+var hubLicenseIDs = map[string]string{
+	"ODbL-1.0":   "odbl",
+	"ODC-By-1.0": "odc-by",
+	"PDDL-1.0":   "pddl",
+}
+
+var spdxLicenseIDs = []string{
+	"Apache-2.0", "MIT", "BSD-2-Clause", "BSD-3-Clause", "BSL-1.0", "Unlicense",
+	"GPL-2.0", "GPL-3.0", "LGPL-2.1", "LGPL-3.0", "AGPL-3.0", "MPL-2.0", "EPL-2.0",
+	"CC0-1.0", "CC-BY-2.0", "CC-BY-3.0", "CC-BY-4.0", "CC-BY-SA-3.0", "CC-BY-SA-4.0",
+	"CC-BY-NC-2.0", "CC-BY-NC-3.0", "CC-BY-NC-4.0", "CC-BY-NC-SA-3.0", "CC-BY-NC-SA-4.0",
+	"CC-BY-ND-4.0", "CC-BY-NC-ND-3.0", "CC-BY-NC-ND-4.0",
+	"ODbL-1.0", "ODC-By-1.0", "PDDL-1.0", "CDLA-Sharing-1.0", "CDLA-Permissive-1.0", "CDLA-Permissive-2.0",
+}
+
+// DatasetConfig is a dataset configuration (subset) declared in a dataset card
+type DatasetConfig struct {
+	Name string
+	// DataFiles contains the paths or glob patterns for the config's data files, for all splits.
+	DataFiles []string
+}
+
+// CardLicense returns the license declared in repository card metadata, using SPDX identifiers
+// where possible. Multiple licenses are comma-separated. An empty string is returned if the card
+// does not declare a license or declares it as 'other' or 'unknown'.
+func CardLicense(cardData map[string]any) string {
+	var licenses []string
+	for _, license := range stringValues(cardData["license"]) {
+		license = strings.TrimSpace(license)
+		switch strings.ToLower(license) {
+		case "", "other", "unknown":
+			continue
+		}
+		licenses = append(licenses, normalizeLicense(license))
+	}
+	return strings.Join(licenses, ", ")
+}
+
+// HubLicense returns the HuggingFace license identifier for an SPDX license identifier. If the
+// license is not one that HuggingFace recognizes, ok is false and the card should declare the
+// license as 'other'.
+func HubLicense(license string) (id string, ok bool) {
+	license = normalizeLicense(strings.TrimSpace(license))
+	if hubID, ok := hubLicenseIDs[license]; ok {
+		return hubID, true
+	}
+	for _, spdxID := range spdxLicenseIDs {
+		if spdxID == license {
+			return strings.ToLower(spdxID), true
+		}
+	}
+	return "", false
+}
+
+// DatasetConfigs returns the configs declared in dataset card metadata under the 'configs' key.
+func DatasetConfigs(cardData map[string]any) []DatasetConfig {
+	rawConfigs, ok := cardData["configs"].([]any)
+	if !ok {
+		return nil
+	}
+	var configs []DatasetConfig
+	for _, rawConfig := range rawConfigs {
+		configMap, ok := rawConfig.(map[string]any)
+		if !ok {
+			continue
+		}
+		name, _ := configMap["config_name"].(string)
+		if name == "" {
+			continue
+		}
+		configs = append(configs, DatasetConfig{
+			Name:      name,
+			DataFiles: dataFilePaths(configMap["data_files"]),
+		})
+	}
+	return configs
+}
+
+// CardStrings returns the value of key in card metadata as a list of strings. Values in card
+// metadata may be either a single string or a list of strings.
+func CardStrings(cardData map[string]any, key string) []string {
+	return stringValues(cardData[key])
+}
+
+func normalizeLicense(license string) string {
+	if alias, ok := cardLicenseAliases[strings.ToLower(license)]; ok {
+		return alias
+	}
+	for _, id := range spdxLicenseIDs {
+		if strings.EqualFold(id, license) {
+			return id
+		}
+	}
+	return license
+}
+
+// dataFilePaths collects paths from the data_files field of a dataset config, which may be a single
+// path, a list of paths, a list of {split, path} objects (where path is itself a path or list of paths),
+// or a map of split names to paths.
+func dataFilePaths(dataFiles any) []string {
+	switch v := dataFiles.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var paths []string
+		for _, elem := range v {
+			paths = append(paths, dataFilePaths(elem)...)
+		}
+		return paths
+	case map[string]any:
+		if p, ok := v["path"]; ok {
+			return dataFilePaths(p)
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var paths []string
+		for _, k := range keys {
+			paths = append(paths, dataFilePaths(v[k])...)
+		}
+		return paths
+	default:
+		return nil
+	}
+}
+
+func stringValues(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var values []string
+		for _, elem := range v {
+			if s, ok := elem.(string); ok {
+				values = append(values, s)
+			} else if elem != nil {
+				values = append(values, fmt.Sprint(elem))
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
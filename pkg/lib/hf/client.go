@@ -0,0 +1,124 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package hf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"kitops/pkg/output"
+)
+
+const (
+	// DefaultEndpoint is the HuggingFace Hub endpoint used when no other endpoint is configured.
+	DefaultEndpoint = "https://huggingface.co"
+
+	// Environment variables used by HuggingFace tools to configure the endpoint and credentials.
+	EndpointEnvVar  = "HF_ENDPOINT"
+	TokenEnvVar     = "HF_TOKEN"
+	TokenPathEnvVar = "HF_TOKEN_PATH"
+	HomeEnvVar      = "HF_HOME"
+)
+
+// Client accesses a HuggingFace Hub, either huggingface.co or a compatible mirror or self-hosted hub.
+type Client struct {
+	endpoint string
+	token    string
+}
+
+// NewClient returns a Client for the hub at endpoint (e.g. https://huggingface.co). If token is
+// not empty, it is used to authenticate requests.
+func NewClient(endpoint, token string) *Client {
+	return &Client{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		token:    token,
+	}
+}
+
+// Endpoint returns the endpoint for this client.
+func (c *Client) Endpoint() string {
+	return c.endpoint
+}
+
+// RepoURL returns the web URL for a repository, e.g. for recording the source of a ModelKit.
+func (c *Client) RepoURL(modelRepo string, repoType RepoType) string {
+	return fmt.Sprintf("%s/%s%s", c.endpoint, repoType.urlPrefix(), modelRepo)
+}
+
+func (c *Client) addAuth(req *http.Request) {
+	if c.token != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
+}
+
+// ResolveEndpoint returns the HuggingFace endpoint to use. The HF_ENDPOINT environment variable takes
+// precedence over configured, which is typically read from Kit's config. If neither is set, the default
+// endpoint is returned.
+func ResolveEndpoint(configured string) string {
+	if endpoint := os.Getenv(EndpointEnvVar); endpoint != "" {
+		return endpoint
+	}
+	if configured != "" {
+		return configured
+	}
+	return DefaultEndpoint
+}
+
+// LookupToken finds a HuggingFace token in the same locations as HuggingFace's tools: the HF_TOKEN
+// environment variable, then the token file saved by `huggingface-cli login` (at $HF_TOKEN_PATH,
+// $HF_HOME/token, or ~/.cache/huggingface/token). An empty string is returned if no token is found.
+func LookupToken(ctx context.Context) string {
+	if token := os.Getenv(TokenEnvVar); token != "" {
+		return token
+	}
+	tokenPath := tokenFilePath()
+	if tokenPath == "" {
+		return ""
+	}
+	tokenBytes, err := os.ReadFile(tokenPath)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			output.FromContext(ctx).Logf(output.LogLevelWarn, "Failed to read HuggingFace token from %s: %s", tokenPath, err)
+		}
+		return ""
+	}
+	output.FromContext(ctx).Debugf("Using HuggingFace token from %s", tokenPath)
+	return strings.TrimSpace(string(tokenBytes))
+}
+
+func tokenFilePath() string {
+	if tokenPath := os.Getenv(TokenPathEnvVar); tokenPath != "" {
+		return tokenPath
+	}
+	if hfHome := os.Getenv(HomeEnvVar); hfHome != "" {
+		return filepath.Join(hfHome, "token")
+	}
+	if cacheHome := os.Getenv("XDG_CACHE_HOME"); cacheHome != "" {
+		return filepath.Join(cacheHome, "huggingface", "token")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".cache", "huggingface", "token")
+}
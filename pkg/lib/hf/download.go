@@ -1,4 +1,4 @@
-// Copyright 2025 The KitOps Authors.
+// Copyright 2024 The KitOps Authors.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -18,55 +18,72 @@ package hf
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"io"
-	"io/fs"
 	"net/http"
-	"os"
+	"net/url"
 	"path/filepath"
 	"time"
 
+	kfgen "kitops/pkg/lib/kitfile/generate"
+	"kitops/pkg/lib/network"
+	"kitops/pkg/lib/ratelimit"
+	"kitops/pkg/lib/telemetry"
 	"kitops/pkg/output"
 
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/sync/semaphore"
 )
 
 const (
-	resolveURLFmt = "https://huggingface.co/%s/resolve/main/%s"
+	resolveURLFmt = "%s/%s%s/resolve/%s/%s"
 )
 
-func DownloadFiles(
+// initialRetryDelay is the delay before retrying a failed request or download. It is doubled for each
+// subsequent retry.
+var initialRetryDelay = 2 * time.Second
+
+// DownloadFiles downloads files from modelRepo at the specified revision into destDir. Each file is downloaded
+// to a partial file first, which is used to resume the download if it is interrupted (including across separate
+// calls using the same destDir), and is verified against its expected digest, if known, before being moved into
+// place. Files that already exist in destDir and match their expected digest are not downloaded again.
+func (c *Client) DownloadFiles(
 	ctx context.Context,
-	modelRepo, destDir string,
-	filepaths []string,
-	token string,
-	maxConcurrency int) error {
+	modelRepo string,
+	repoType RepoType,
+	revision, destDir string,
+	files []kfgen.FileListing,
+	maxConcurrency int,
+	rateLimiter *ratelimit.Limiter) error {
 
 	client := &http.Client{
-		Timeout: 1 * time.Hour,
+		Timeout:   1 * time.Hour,
+		Transport: telemetry.Transport(rateLimiter.Transport(http.DefaultTransport)),
 	}
 
 	sem := semaphore.NewWeighted(int64(maxConcurrency))
 	errs, errCtx := errgroup.WithContext(ctx)
 	var semErr error
 
-	progress, plog := output.NewDownloadProgress()
+	progress, plog := output.FromContext(ctx).NewDownloadProgress()
 
-	for _, f := range filepaths {
+	for _, f := range files {
 		f := f
 		if err := sem.Acquire(errCtx, 1); err != nil {
 			semErr = err
 			break
 		}
 
-		fileURL := fmt.Sprintf(resolveURLFmt, modelRepo, f)
-		destPath := filepath.Join(destDir, f)
+		fileURL := fmt.Sprintf(resolveURLFmt, c.endpoint, repoType.urlPrefix(), modelRepo, url.PathEscape(revision), f.Path)
+		destPath := filepath.Join(destDir, f.Path)
 		errs.Go(func() error {
 			defer sem.Release(1)
-			plog.Infof("Downloading file %s", f)
-			return downloadFile(errCtx, client, token, fileURL, destPath, f, progress, plog)
+			if verifyExistingFile(destPath, f, plog) {
+				plog.Infof("File %s is already downloaded", f.Path)
+				return nil
+			}
+			plog.Infof("Downloading file %s", f.Path)
+			return c.downloadFile(errCtx, client, fileURL, destPath, f, progress, plog)
 		})
 	}
 
@@ -81,63 +98,34 @@ func DownloadFiles(
 	return nil
 }
 
-func downloadFile(
+// downloadFile downloads file from srcURL to destPath, retrying if the download fails in a way that may
+// succeed later.
+func (c *Client) downloadFile(
 	ctx context.Context,
 	client *http.Client,
-	token, srcURL, destPath, filename string,
+	srcURL, destPath string,
+	file kfgen.FileListing,
 	progress *output.DownloadProgressBar,
-	plog *output.ProgressLogger) error {
+	plog *output.ProgressLogger) (err error) {
 
-	plog.Debugf("Downloading from %s", srcURL)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srcURL, nil)
-	if err != nil {
-		return fmt.Errorf("failed to resolve URL: %w", err)
-	}
-	if token != "" {
-		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("error calling API: %w", err)
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			plog.Logf(output.LogLevelWarn, "Failed to close response body: %w", err)
-		}
-	}()
+	ctx, span := telemetry.StartSpan(ctx, "hf.downloadFile", attribute.String("hf.file", file.Path), telemetry.SizeKey.Int64(file.Size))
+	defer func() { telemetry.EndSpan(span, err) }()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("received status code %d when downloading file %s from %s", resp.StatusCode, filename, srcURL)
+	header := http.Header{}
+	if c.token != "" {
+		header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
 	}
-
-	contentRC := progress.TrackDownload(resp.Body, filename, resp.ContentLength)
-	defer func() {
-		if err := contentRC.Close(); err != nil {
-			plog.Logf(output.LogLevelWarn, "TEMP: see if this is an issue: %s", err)
-		}
-	}()
-
-	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
+	dl := &network.FileDownload{
+		URL:        srcURL,
+		Header:     header,
+		Name:       file.Path,
+		DestPath:   destPath,
+		Size:       file.Size,
+		RetryDelay: initialRetryDelay,
 	}
-
-	f, err := os.OpenFile(destPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
-	}
-	defer func() {
-		if err := f.Close(); err != nil && !errors.Is(err, fs.ErrClosed) {
-			plog.Logf(output.LogLevelError, "Error closing file %s: %s", destPath, err)
-		}
-	}()
-
-	n, err := io.Copy(f, contentRC)
-	if err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
+	// A nil *fileVerifier must not be stored in the interface, as it would not be treated as nil
+	if verifier := newFileVerifier(file); verifier != nil {
+		dl.Verifier = verifier
 	}
-	if resp.ContentLength > 0 && n != resp.ContentLength {
-		return fmt.Errorf("mismatched file size: expected %d but got %d", resp.ContentLength, n)
-	}
-
-	return nil
+	return network.DownloadFile(ctx, client, dl, progress, plog)
 }
@@ -0,0 +1,172 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package hf
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	kfgen "kitops/pkg/lib/kitfile/generate"
+	"kitops/pkg/lib/network"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyFileServer serves content for any file, dropping the connection partway through the
+// first failures requests that do not use a Range header.
+type flakyFileServer struct {
+	content  []byte
+	failures int
+
+	mu       sync.Mutex
+	requests []string
+}
+
+func (s *flakyFileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Header.Get("Range"))
+	fail := s.failures > 0
+	s.failures--
+	s.mu.Unlock()
+
+	if fail {
+		w.Header().Set("Content-Length", fmt.Sprint(len(s.content)))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(s.content[:len(s.content)/2])
+		// Abort the response to simulate a dropped connection
+		panic(http.ErrAbortHandler)
+	}
+	http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(s.content))
+}
+
+func testFileContent() []byte {
+	content := make([]byte, 256*1024)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	return content
+}
+
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func gitBlobID(content []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func TestDownloadFiles(t *testing.T) {
+	initialRetryDelay = 10 * time.Millisecond
+	content := testFileContent()
+
+	tests := []struct {
+		name             string
+		file             kfgen.FileListing
+		failures         int
+		existing         []byte
+		existingPartial  []byte
+		expectErr        error
+		expectedRequests []string
+	}{
+		{
+			name:             "verifies sha256",
+			file:             kfgen.FileListing{Path: "model.safetensors", Size: int64(len(content)), SHA256: sha256Hex(content)},
+			expectedRequests: []string{""},
+		},
+		{
+			name:             "verifies git blob ID",
+			file:             kfgen.FileListing{Path: "config.json", Size: int64(len(content)), GitBlobID: gitBlobID(content)},
+			expectedRequests: []string{""},
+		},
+		{
+			name:             "resumes after dropped connection",
+			file:             kfgen.FileListing{Path: "model.safetensors", Size: int64(len(content)), SHA256: sha256Hex(content)},
+			failures:         1,
+			expectedRequests: []string{"", fmt.Sprintf("bytes=%d-", len(content)/2)},
+		},
+		{
+			name:             "resumes existing partial file",
+			file:             kfgen.FileListing{Path: "model.safetensors", Size: int64(len(content)), SHA256: sha256Hex(content)},
+			existingPartial:  content[:1000],
+			expectedRequests: []string{"bytes=1000-"},
+		},
+		{
+			name:             "skips existing verified file",
+			file:             kfgen.FileListing{Path: "model.safetensors", Size: int64(len(content)), SHA256: sha256Hex(content)},
+			existing:         content,
+			expectedRequests: nil,
+		},
+		{
+			name:             "replaces existing file with wrong contents",
+			file:             kfgen.FileListing{Path: "model.safetensors", Size: int64(len(content)), SHA256: sha256Hex(content)},
+			existing:         bytes.Repeat([]byte("x"), len(content)),
+			expectedRequests: []string{""},
+		},
+		{
+			name:             "fails on digest mismatch without retrying",
+			file:             kfgen.FileListing{Path: "model.safetensors", Size: int64(len(content)), SHA256: sha256Hex([]byte("other"))},
+			expectErr:        network.ErrDigestMismatch,
+			expectedRequests: []string{""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &flakyFileServer{content: content, failures: tt.failures}
+			ts := httptest.NewServer(server)
+			t.Cleanup(ts.Close)
+
+			destDir := t.TempDir()
+			destPath := filepath.Join(destDir, tt.file.Path)
+			if tt.existing != nil {
+				require.NoError(t, os.WriteFile(destPath, tt.existing, 0644))
+			}
+			if tt.existingPartial != nil {
+				require.NoError(t, os.WriteFile(destPath+network.PartialFileSuffix, tt.existingPartial, 0644))
+			}
+
+			client := NewClient(ts.URL, "")
+			err := client.DownloadFiles(context.Background(), "org/repo", RepoTypeModel, "main", destDir, []kfgen.FileListing{tt.file}, 1, nil)
+			assert.Equal(t, tt.expectedRequests, server.requests)
+			if tt.expectErr != nil {
+				assert.ErrorIs(t, err, tt.expectErr)
+				assert.NoFileExists(t, destPath)
+				return
+			}
+			require.NoError(t, err)
+			downloaded, err := os.ReadFile(destPath)
+			require.NoError(t, err)
+			assert.True(t, bytes.Equal(content, downloaded), "Downloaded file should match content")
+			assert.NoFileExists(t, destPath+network.PartialFileSuffix)
+		})
+	}
+}
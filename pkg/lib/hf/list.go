@@ -23,92 +23,231 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"slices"
+	"strings"
 	"time"
 
 	kfgen "kitops/pkg/lib/kitfile/generate"
 	"kitops/pkg/output"
+
+	"golang.org/x/sync/errgroup"
 )
 
 const (
-	treeURLFmt = "https://huggingface.co/api/models/%s/tree/main"
+	treeURLFmt = "%s/api/%s/%s/tree/%s"
+
+	// maxConcurrentTreeWalks limits the number of directories listed at once when the recursive
+	// tree listing is not available.
+	maxConcurrentTreeWalks = 8
 )
 
-type hfTreeResponse []struct {
+type hfTreeEntry struct {
 	Type string `json:"type"`
 	OID  string `json:"oid"`
 	Size int64  `json:"size"`
 	Path string `json:"path"`
+	// LFS is set for files stored in Git LFS; in this case, OID refers to the LFS pointer file
+	LFS *struct {
+		OID  string `json:"oid"`
+		Size int64  `json:"size"`
+	} `json:"lfs,omitempty"`
 }
 
+type hfTreeResponse []hfTreeEntry
+
 type hfErrorResponse struct {
 	Error string `json:"error"`
 }
 
-func ListFiles(ctx context.Context, modelRepo string, token string) (*kfgen.DirectoryListing, error) {
+// ListFiles lists the files in modelRepo at the specified revision (a branch, tag, or commit SHA).
+func (c *Client) ListFiles(ctx context.Context, modelRepo string, repoType RepoType, revision string) (*kfgen.DirectoryListing, error) {
 	client := &http.Client{
-		Timeout: 10 * time.Second,
+		Timeout: 30 * time.Second,
 	}
-	baseURL, err := url.Parse(fmt.Sprintf(treeURLFmt, modelRepo))
+	baseURL, err := url.Parse(fmt.Sprintf(treeURLFmt, c.endpoint, repoType.apiPath(), modelRepo, url.PathEscape(revision)))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse URL: %w", err)
 	}
 
-	return walkRepoTree(ctx, client, token, baseURL, ".")
+	entries, err := c.listTree(ctx, client, baseURL, ".")
+	if err != nil {
+		return nil, err
+	}
+	return buildDirectoryListing(entries)
 }
 
-func walkRepoTree(ctx context.Context, client *http.Client, token string, repoBaseUrl *url.URL, subDir string) (*kfgen.DirectoryListing, error) {
-	curUrl := *repoBaseUrl
-	curUrl.Path = path.Join(curUrl.Path, subDir)
+// listTree lists all entries within subDir, recursively. The recursive tree listing is used where available; if the
+// server does not support it (i.e. the contents of directories are not included in the response), directories are
+// listed separately, in parallel.
+func (c *Client) listTree(ctx context.Context, client *http.Client, repoBaseUrl *url.URL, subDir string) ([]hfTreeEntry, error) {
+	// Use JoinPath rather than editing Path directly to preserve escaping in the revision (e.g. refs%2Fpr%2F1)
+	treeUrl := repoBaseUrl.JoinPath(subDir)
+	query := treeUrl.Query()
+	query.Set("recursive", "true")
+	treeUrl.RawQuery = query.Encode()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, curUrl.String(), nil)
+	entries, err := c.listTreePages(ctx, client, treeUrl)
 	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
+		return nil, err
 	}
-	if token != "" {
-		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	// Git does not track empty directories, so any directory without entries was not listed recursively.
+	hasEntries := map[string]bool{}
+	for _, entry := range entries {
+		hasEntries[path.Dir(entry.Path)] = true
 	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("error calling API: %w", err)
+	var unlistedDirs []string
+	for _, entry := range entries {
+		if entry.Type == "directory" && !hasEntries[entry.Path] {
+			unlistedDirs = append(unlistedDirs, entry.Path)
+		}
 	}
-	repoTree, err := processTreeResponse(resp)
-	if closeErr := resp.Body.Close(); closeErr != nil {
-		output.Logf(output.LogLevelWarn, "failed to close response body: %s", closeErr)
+	if len(unlistedDirs) == 0 {
+		return entries, nil
 	}
-	if err != nil {
+	output.FromContext(ctx).Debugf("Recursive listing not available for %s; listing %d subdirectories", subDir, len(unlistedDirs))
+
+	subdirEntries := make([][]hfTreeEntry, len(unlistedDirs))
+	errs, errCtx := errgroup.WithContext(ctx)
+	errs.SetLimit(maxConcurrentTreeWalks)
+	for idx, dir := range unlistedDirs {
+		errs.Go(func() error {
+			dirEntries, err := c.listTree(errCtx, client, repoBaseUrl, dir)
+			subdirEntries[idx] = dirEntries
+			return err
+		})
+	}
+	if err := errs.Wait(); err != nil {
 		return nil, err
 	}
+	for _, dirEntries := range subdirEntries {
+		entries = append(entries, dirEntries...)
+	}
+	return entries, nil
+}
+
+// listTreePages retrieves all pages of a tree listing, following the 'next' links in the Link header of each
+// response.
+func (c *Client) listTreePages(ctx context.Context, client *http.Client, treeUrl *url.URL) ([]hfTreeEntry, error) {
+	var entries []hfTreeEntry
+	pageUrl := treeUrl
+	for pageUrl != nil {
+		resp, err := c.getWithRetry(ctx, client, pageUrl.String())
+		if err != nil {
+			return nil, err
+		}
+		repoTree, err := processTreeResponse(resp)
+		linkHeader := resp.Header.Get("Link")
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			output.FromContext(ctx).Logf(output.LogLevelWarn, "failed to close response body: %s", closeErr)
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *repoTree...)
+
+		nextUrl, err := nextPageURL(pageUrl, linkHeader)
+		if err != nil {
+			return nil, err
+		}
+		pageUrl = nextUrl
+	}
+	return entries, nil
+}
 
-	dirListing := &kfgen.DirectoryListing{
-		Name: path.Base(subDir),
-		Path: subDir,
+// nextPageURL returns the URL with relation 'next' from a Link header (RFC 8288), resolved relative to the current
+// URL, or nil if there is no next page.
+func nextPageURL(current *url.URL, linkHeader string) (*url.URL, error) {
+	for _, link := range strings.Split(linkHeader, ",") {
+		target, params, ok := strings.Cut(link, ";")
+		if !ok {
+			continue
+		}
+		isNext := false
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(key, "rel") && slices.ContainsFunc(strings.Fields(strings.Trim(value, `"`)), isNextRel) {
+				isNext = true
+			}
+		}
+		if !isNext {
+			continue
+		}
+		target = strings.TrimSpace(target)
+		target = strings.TrimSuffix(strings.TrimPrefix(target, "<"), ">")
+		next, err := current.Parse(target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid link to next page in API response: %w", err)
+		}
+		return next, nil
 	}
-	for _, elem := range *repoTree {
+	return nil, nil
+}
+
+func isNextRel(rel string) bool {
+	return strings.EqualFold(rel, "next")
+}
+
+// buildDirectoryListing converts a flat list of tree entries into a DirectoryListing.
+func buildDirectoryListing(entries []hfTreeEntry) (*kfgen.DirectoryListing, error) {
+	type dirNode struct {
+		listing kfgen.DirectoryListing
+		subdirs []*dirNode
+	}
+	root := &dirNode{listing: kfgen.DirectoryListing{Name: ".", Path: "."}}
+	dirs := map[string]*dirNode{".": root}
+	var getDir func(dirPath string) *dirNode
+	getDir = func(dirPath string) *dirNode {
+		if node, ok := dirs[dirPath]; ok {
+			return node
+		}
+		node := &dirNode{listing: kfgen.DirectoryListing{Name: path.Base(dirPath), Path: dirPath}}
+		dirs[dirPath] = node
+		parent := getDir(path.Dir(dirPath))
+		parent.subdirs = append(parent.subdirs, node)
+		return node
+	}
+
+	for _, elem := range entries {
 		switch elem.Type {
 		case "directory":
-			subDirListing, err := walkRepoTree(ctx, client, token, repoBaseUrl, elem.Path)
-			if err != nil {
-				return nil, err
-			}
-			dirListing.Subdirs = append(dirListing.Subdirs, *subDirListing)
+			getDir(elem.Path)
 		case "file":
 			name := path.Base(elem.Path)
 			if name == ".gitignore" || name == ".gitattributes" {
 				continue
 			}
-			dirListing.Files = append(dirListing.Files, kfgen.FileListing{
+			fileListing := kfgen.FileListing{
 				Name: name,
 				Path: elem.Path,
 				Size: elem.Size,
-			})
+			}
+			if elem.LFS != nil {
+				fileListing.SHA256 = elem.LFS.OID
+				if elem.LFS.Size > 0 {
+					fileListing.Size = elem.LFS.Size
+				}
+			} else {
+				fileListing.GitBlobID = elem.OID
+			}
+			dir := getDir(path.Dir(elem.Path))
+			dir.listing.Files = append(dir.listing.Files, fileListing)
 		default:
 			return nil, fmt.Errorf("unknown type in repository tree: %s", elem.Type)
 		}
 	}
 
-	return dirListing, nil
+	var toListing func(node *dirNode) kfgen.DirectoryListing
+	toListing = func(node *dirNode) kfgen.DirectoryListing {
+		listing := node.listing
+		for _, subdir := range node.subdirs {
+			listing.Subdirs = append(listing.Subdirs, toListing(subdir))
+		}
+		return listing
+	}
+	listing := toListing(root)
+	return &listing, nil
 }
-
 func processTreeResponse(resp *http.Response) (*hfTreeResponse, error) {
 	if resp.StatusCode != http.StatusOK {
 		errResp := &hfErrorResponse{}
@@ -0,0 +1,153 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package hf
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	kfgen "kitops/pkg/lib/kitfile/generate"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testTreeEntries = []hfTreeEntry{
+	{Type: "file", Path: "README.md", Size: 10, OID: "readme-oid"},
+	{Type: "directory", Path: "shards"},
+	{Type: "file", Path: "shards/model-00001-of-00002.safetensors", Size: 5, LFS: &struct {
+		OID  string `json:"oid"`
+		Size int64  `json:"size"`
+	}{OID: "shard1-sha", Size: 100}},
+	{Type: "file", Path: "shards/model-00002-of-00002.safetensors", Size: 5, OID: "shard2-oid"},
+	{Type: "directory", Path: "shards/nested"},
+	{Type: "file", Path: "shards/nested/.gitattributes", Size: 1},
+	{Type: "file", Path: "shards/nested/config.json", Size: 2, OID: "config-oid"},
+}
+
+var expectedTestListing = &kfgen.DirectoryListing{
+	Name: ".",
+	Path: ".",
+	Files: []kfgen.FileListing{
+		{Name: "README.md", Path: "README.md", Size: 10, GitBlobID: "readme-oid"},
+	},
+	Subdirs: []kfgen.DirectoryListing{{
+		Name: "shards",
+		Path: "shards",
+		Files: []kfgen.FileListing{
+			{Name: "model-00001-of-00002.safetensors", Path: "shards/model-00001-of-00002.safetensors", Size: 100, SHA256: "shard1-sha"},
+			{Name: "model-00002-of-00002.safetensors", Path: "shards/model-00002-of-00002.safetensors", Size: 5, GitBlobID: "shard2-oid"},
+		},
+		Subdirs: []kfgen.DirectoryListing{{
+			Name: "nested",
+			Path: "shards/nested",
+			Files: []kfgen.FileListing{
+				{Name: "config.json", Path: "shards/nested/config.json", Size: 2, GitBlobID: "config-oid"},
+			},
+		}},
+	}},
+}
+
+func TestListFilesRecursivePaginated(t *testing.T) {
+	initialRetryDelay = 10 * time.Millisecond
+	const pageSize = 3
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail the first request to check that it is retried
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if !assert.Equal(t, "/api/models/org/repo/tree/main", r.URL.Path) || !assert.Equal(t, "true", r.URL.Query().Get("recursive")) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		start := 0
+		if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+			start = len(cursor)
+		}
+		end := min(start+pageSize, len(testTreeEntries))
+		if end < len(testTreeEntries) {
+			next := url.URL{Path: r.URL.Path, RawQuery: url.Values{"recursive": {"true"}, "cursor": {strings.Repeat("x", end)}}.Encode()}
+			w.Header().Set("Link", `<`+next.String()+`>; rel="next"`)
+		}
+		_ = json.NewEncoder(w).Encode(testTreeEntries[start:end])
+	}))
+	t.Cleanup(server.Close)
+
+	listing, err := NewClient(server.URL, "").ListFiles(context.Background(), "org/repo", RepoTypeModel, "main")
+	require.NoError(t, err)
+	assert.Equal(t, expectedTestListing, listing)
+	assert.Equal(t, int32(4), requests.Load(), "Should retry once and request three pages")
+}
+
+func TestListFilesNonRecursive(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		dir := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/models/org/repo/tree/main"), "/")
+		// Only list direct children of the requested directory, ignoring the recursive parameter
+		var entries []hfTreeEntry
+		for _, entry := range testTreeEntries {
+			if entryDir := strings.TrimPrefix(path.Dir(entry.Path), "."); entryDir == dir {
+				entries = append(entries, entry)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(entries)
+	}))
+	t.Cleanup(server.Close)
+
+	listing, err := NewClient(server.URL, "").ListFiles(context.Background(), "org/repo", RepoTypeModel, "main")
+	require.NoError(t, err)
+	assert.Equal(t, expectedTestListing, listing)
+	assert.Equal(t, int32(3), requests.Load(), "Should list each directory once")
+}
+
+func TestNextPageURL(t *testing.T) {
+	current, err := url.Parse("https://huggingface.co/api/models/org/repo/tree/main?recursive=true")
+	require.NoError(t, err)
+	testcases := []struct {
+		header   string
+		expected string
+	}{
+		{header: "", expected: ""},
+		{header: `<https://huggingface.co/api/models/org/repo/tree/main?cursor=abc>; rel="next"`, expected: "https://huggingface.co/api/models/org/repo/tree/main?cursor=abc"},
+		{header: `</api/models/org/repo/tree/main?cursor=abc>; rel=next`, expected: "https://huggingface.co/api/models/org/repo/tree/main?cursor=abc"},
+		{header: `<https://example.com/prev>; rel="prev", <https://example.com/next>; rel="next last"`, expected: "https://example.com/next"},
+		{header: `<https://example.com/prev>; rel="prev"`, expected: ""},
+	}
+	for _, tt := range testcases {
+		t.Run(tt.header, func(t *testing.T) {
+			next, err := nextPageURL(current, tt.header)
+			require.NoError(t, err)
+			if tt.expected == "" {
+				assert.Nil(t, next)
+			} else if assert.NotNil(t, next) {
+				assert.Equal(t, tt.expected, next.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package hf
+
+import "fmt"
+
+// RepoType is the type of a HuggingFace repository
+type RepoType int
+
+const (
+	RepoTypeModel RepoType = iota
+	RepoTypeDataset
+)
+
+func (t RepoType) String() string {
+	switch t {
+	case RepoTypeModel:
+		return "model"
+	case RepoTypeDataset:
+		return "dataset"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(t))
+	}
+}
+
+// apiPath returns the path segment used for this repository type in HuggingFace API URLs
+func (t RepoType) apiPath() string {
+	if t == RepoTypeDataset {
+		return "datasets"
+	}
+	return "models"
+}
+
+// urlPrefix returns the prefix used for this repository type in HuggingFace web URLs (e.g. for
+// resolving files). Models are not prefixed.
+func (t RepoType) urlPrefix() string {
+	if t == RepoTypeDataset {
+		return "datasets/"
+	}
+	return ""
+}
@@ -0,0 +1,128 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package hf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"kitops/pkg/lib/network"
+	"kitops/pkg/output"
+)
+
+// maxRequestAttempts is the number of times API requests are attempted before giving up
+const maxRequestAttempts = 5
+
+// getWithRetry performs a GET request to reqURL, retrying with exponential backoff if the request fails or the
+// server responds with a status that indicates the request may succeed later (429 or 5xx). If the response includes
+// a Retry-After header, it is used instead of the backoff delay. The response from the last attempt is returned,
+// even if it was unsuccessful; callers are responsible for checking the status code and closing the body.
+func (c *Client) getWithRetry(ctx context.Context, client *http.Client, reqURL string) (*http.Response, error) {
+	delay := initialRetryDelay
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error creating request: %w", err)
+		}
+		c.addAuth(req)
+		resp, err := client.Do(req)
+		if attempt >= maxRequestAttempts || ctx.Err() != nil {
+			if err != nil {
+				return nil, fmt.Errorf("error calling API: %w", err)
+			}
+			return resp, nil
+		}
+
+		wait := delay
+		if err != nil {
+			output.FromContext(ctx).Debugf("Request to %s failed (attempt %d of %d): %s", reqURL, attempt, maxRequestAttempts, err)
+		} else if network.RetryableStatus(resp.StatusCode) {
+			if retryAfter, ok := network.ParseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				wait = retryAfter
+			}
+			output.FromContext(ctx).Debugf("Request to %s returned status %d (attempt %d of %d)", reqURL, resp.StatusCode, attempt, maxRequestAttempts)
+			if err := resp.Body.Close(); err != nil {
+				output.FromContext(ctx).Logf(output.LogLevelWarn, "failed to close response body: %s", err)
+			}
+		} else {
+			return resp, nil
+		}
+
+		output.FromContext(ctx).Debugf("Retrying request in %s", wait)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		delay = delay * 2
+	}
+}
+
+// putWithRetry uploads size bytes of src, starting at offset, to reqURL using a PUT request with the provided
+// headers. Like getWithRetry, requests that fail or receive a 429 or 5xx status are retried with exponential
+// backoff, honouring any Retry-After header; each attempt re-sends the data from offset. Returns the headers of
+// the successful response.
+func putWithRetry(ctx context.Context, client *http.Client, reqURL string, headers map[string]string, src io.ReaderAt, offset, size int64) (http.Header, error) {
+	delay := initialRetryDelay
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, reqURL, io.NewSectionReader(src, offset, size))
+		if err != nil {
+			return nil, fmt.Errorf("error creating request: %w", err)
+		}
+		req.ContentLength = size
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := client.Do(req)
+		if err == nil {
+			if closeErr := resp.Body.Close(); closeErr != nil {
+				output.FromContext(ctx).Logf(output.LogLevelWarn, "failed to close response body: %s", closeErr)
+			}
+			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				return resp.Header, nil
+			}
+		}
+		retryable := err != nil || network.RetryableStatus(resp.StatusCode)
+		if attempt >= maxRequestAttempts || ctx.Err() != nil || !retryable {
+			if err != nil {
+				return nil, fmt.Errorf("error uploading file: %w", err)
+			}
+			return nil, fmt.Errorf("upload failed with status %d", resp.StatusCode)
+		}
+
+		wait := delay
+		if err != nil {
+			output.FromContext(ctx).Debugf("Upload failed (attempt %d of %d): %s", attempt, maxRequestAttempts, err)
+		} else {
+			if retryAfter, ok := network.ParseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				wait = retryAfter
+			}
+			output.FromContext(ctx).Debugf("Upload returned status %d (attempt %d of %d)", resp.StatusCode, attempt, maxRequestAttempts)
+		}
+
+		output.FromContext(ctx).Debugf("Retrying upload in %s", wait)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		delay = delay * 2
+	}
+}
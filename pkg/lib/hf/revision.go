@@ -0,0 +1,78 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package hf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"kitops/pkg/output"
+)
+
+const (
+	// DefaultRevision is the revision used when none is specified.
+	DefaultRevision = "main"
+
+	revisionURLFmt = "%s/api/%s/%s/revision/%s"
+)
+
+// RevisionInfo contains information about a repository at a specific revision
+type RevisionInfo struct {
+	// SHA is the full commit SHA for the revision
+	SHA string `json:"sha"`
+	// CardData is the metadata from the repository card (the YAML header of README.md), if present
+	CardData map[string]any `json:"cardData,omitempty"`
+}
+
+// ResolveRevision resolves a revision (branch, tag, commit SHA, or ref such as refs/pr/1) in
+// modelRepo to the full commit SHA it currently points to, along with the repository's card metadata
+// at that commit.
+func (c *Client) ResolveRevision(ctx context.Context, modelRepo string, repoType RepoType, revision string) (*RevisionInfo, error) {
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+	revisionURL := fmt.Sprintf(revisionURLFmt, c.endpoint, repoType.apiPath(), modelRepo, url.PathEscape(revision))
+	resp, err := c.getWithRetry(ctx, client, revisionURL)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			output.FromContext(ctx).Logf(output.LogLevelWarn, "failed to close response body: %s", closeErr)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		errResp := &hfErrorResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(errResp); err != nil {
+			return nil, fmt.Errorf("failed to parse API error response: %w", err)
+		}
+		return nil, fmt.Errorf("got error code %d from API: %s", resp.StatusCode, errResp.Error)
+	}
+	revInfo := &RevisionInfo{}
+	if err := json.NewDecoder(resp.Body).Decode(revInfo); err != nil {
+		return nil, fmt.Errorf("failed to parse API response: %w", err)
+	}
+	if revInfo.SHA == "" {
+		return nil, fmt.Errorf("API response for revision %s does not include a commit SHA", revision)
+	}
+	return revInfo, nil
+}
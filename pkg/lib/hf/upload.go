@@ -0,0 +1,477 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package hf
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"kitops/pkg/lib/ratelimit"
+	"kitops/pkg/lib/telemetry"
+	"kitops/pkg/output"
+)
+
+const (
+	preuploadURLFmt = "%s/api/%s/%s/preupload/%s"
+	commitURLFmt    = "%s/api/%s/%s/commit/%s"
+	lfsBatchURLFmt  = "%s/%s%s.git/info/lfs/objects/batch"
+
+	lfsMediaType = "application/vnd.git-lfs+json"
+
+	// preuploadChunkSize is the maximum number of files included in a single preupload request
+	preuploadChunkSize = 250
+	// lfsBatchChunkSize is the maximum number of objects included in a single LFS batch request
+	lfsBatchChunkSize = 100
+	// sampleSize is the number of bytes from the start of each file sent to the Hub to decide
+	// whether the file should be stored using LFS
+	sampleSize = 512
+)
+
+// UploadFile is a local file to be uploaded to a repository
+type UploadFile struct {
+	// Path is the path of the file within the repository, using forward slashes
+	Path string
+	// LocalPath is the path to the file on disk
+	LocalPath string
+}
+
+// CommitInfo describes a commit created by UploadFiles
+type CommitInfo struct {
+	OID string `json:"commitOid"`
+	URL string `json:"commitUrl"`
+}
+
+type uploadFileInfo struct {
+	UploadFile
+	size   int64
+	sha256 string
+	sample []byte
+	isLFS  bool
+}
+
+type preuploadRequest struct {
+	Files []preuploadFile `json:"files"`
+}
+
+type preuploadFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Sample string `json:"sample"`
+}
+
+type preuploadResponse struct {
+	Files []struct {
+		Path         string `json:"path"`
+		UploadMode   string `json:"uploadMode"`
+		ShouldIgnore bool   `json:"shouldIgnore"`
+	} `json:"files"`
+}
+
+type lfsBatchRequest struct {
+	Operation string           `json:"operation"`
+	Transfers []string         `json:"transfers"`
+	Objects   []lfsBatchObject `json:"objects"`
+	HashAlgo  string           `json:"hash_algo"`
+}
+
+type lfsBatchObject struct {
+	OID  string `json:"oid"`
+	Size int64  `json:"size"`
+}
+
+type lfsBatchResponse struct {
+	Transfer string `json:"transfer,omitempty"`
+	Objects  []struct {
+		OID     string `json:"oid"`
+		Size    int64  `json:"size"`
+		Actions *struct {
+			Upload *lfsAction `json:"upload,omitempty"`
+			Verify *lfsAction `json:"verify,omitempty"`
+		} `json:"actions,omitempty"`
+		Error *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error,omitempty"`
+	} `json:"objects"`
+}
+
+type lfsAction struct {
+	Href   string            `json:"href"`
+	Header map[string]string `json:"header,omitempty"`
+}
+
+// lfsMultipartCompletion is sent to the upload action's href once all parts of a multipart upload are uploaded
+type lfsMultipartCompletion struct {
+	OID   string            `json:"oid"`
+	Parts []lfsUploadedPart `json:"parts"`
+}
+
+type lfsUploadedPart struct {
+	PartNumber int    `json:"partNumber"`
+	ETag       string `json:"etag"`
+}
+
+type commitOperation struct {
+	Key   string `json:"key"`
+	Value any    `json:"value"`
+}
+
+type commitHeader struct {
+	Summary     string `json:"summary"`
+	Description string `json:"description"`
+}
+
+type commitFile struct {
+	Path     string `json:"path"`
+	Content  string `json:"content"`
+	Encoding string `json:"encoding"`
+}
+
+type commitLFSFile struct {
+	Path string `json:"path"`
+	Algo string `json:"algo"`
+	OID  string `json:"oid"`
+	Size int64  `json:"size"`
+}
+
+// UploadFiles uploads files to modelRepo in a single commit on revision (a branch), using summary as the
+// commit message. The repository must already exist. As with other HuggingFace tools, the Hub decides which
+// files are stored using Git LFS; these are uploaded via the repository's LFS batch API before the commit
+// is created, while other files are included in the commit directly.
+func (c *Client) UploadFiles(
+	ctx context.Context,
+	modelRepo string,
+	repoType RepoType,
+	revision, summary string,
+	files []UploadFile,
+	rateLimiter *ratelimit.Limiter) (*CommitInfo, error) {
+
+	client := &http.Client{
+		Timeout:   1 * time.Hour,
+		Transport: telemetry.Transport(rateLimiter.Transport(http.DefaultTransport)),
+	}
+
+	var infos []*uploadFileInfo
+	for _, f := range files {
+		info, err := readUploadFileInfo(f)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+
+	infos, err := c.preupload(ctx, client, modelRepo, repoType, revision, infos)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.uploadLFSFiles(ctx, client, modelRepo, repoType, infos); err != nil {
+		return nil, err
+	}
+	return c.createCommit(ctx, client, modelRepo, repoType, revision, summary, infos)
+}
+
+// preupload asks the Hub how each file should be uploaded, marking files that should be stored in LFS.
+// Files that the Hub would ignore (e.g. due to the repository's .gitignore) are dropped from the returned
+// list.
+func (c *Client) preupload(ctx context.Context, client *http.Client, modelRepo string, repoType RepoType, revision string, infos []*uploadFileInfo) ([]*uploadFileInfo, error) {
+	preuploadURL := fmt.Sprintf(preuploadURLFmt, c.endpoint, repoType.apiPath(), modelRepo, url.PathEscape(revision))
+	byPath := map[string]*uploadFileInfo{}
+	var result []*uploadFileInfo
+	for start := 0; start < len(infos); start += preuploadChunkSize {
+		chunk := infos[start:min(start+preuploadChunkSize, len(infos))]
+		reqBody := preuploadRequest{}
+		for _, info := range chunk {
+			byPath[info.Path] = info
+			reqBody.Files = append(reqBody.Files, preuploadFile{
+				Path:   info.Path,
+				Size:   info.size,
+				Sample: base64.StdEncoding.EncodeToString(info.sample),
+			})
+		}
+		resp := &preuploadResponse{}
+		if err := c.postJSON(ctx, client, preuploadURL, "application/json", reqBody, nil, resp); err != nil {
+			return nil, fmt.Errorf("failed to prepare upload: %w", err)
+		}
+		for _, f := range resp.Files {
+			info, ok := byPath[f.Path]
+			if !ok {
+				continue
+			}
+			if f.ShouldIgnore {
+				output.FromContext(ctx).Logf(output.LogLevelWarn, "Skipping file %s: file is ignored by repository", f.Path)
+				continue
+			}
+			info.isLFS = f.UploadMode == "lfs"
+			result = append(result, info)
+		}
+	}
+	return result, nil
+}
+
+// uploadLFSFiles uploads the contents of files marked for LFS storage using the basic or multipart transfer
+// adapter, as chosen by the Hub. Objects that already exist on the Hub are not uploaded again.
+func (c *Client) uploadLFSFiles(ctx context.Context, client *http.Client, modelRepo string, repoType RepoType, infos []*uploadFileInfo) error {
+	byOID := map[string]*uploadFileInfo{}
+	var objects []lfsBatchObject
+	for _, info := range infos {
+		if !info.isLFS {
+			continue
+		}
+		if _, ok := byOID[info.sha256]; ok {
+			continue
+		}
+		byOID[info.sha256] = info
+		objects = append(objects, lfsBatchObject{OID: info.sha256, Size: info.size})
+	}
+	if len(objects) == 0 {
+		return nil
+	}
+
+	batchURL := fmt.Sprintf(lfsBatchURLFmt, c.endpoint, repoType.urlPrefix(), modelRepo)
+	headers := map[string]string{"Accept": lfsMediaType}
+	for start := 0; start < len(objects); start += lfsBatchChunkSize {
+		reqBody := lfsBatchRequest{
+			Operation: "upload",
+			Transfers: []string{"basic", "multipart"},
+			Objects:   objects[start:min(start+lfsBatchChunkSize, len(objects))],
+			HashAlgo:  "sha256",
+		}
+		batchResp := &lfsBatchResponse{}
+		if err := c.postJSON(ctx, client, batchURL, lfsMediaType, reqBody, headers, batchResp); err != nil {
+			return fmt.Errorf("failed to request LFS upload: %w", err)
+		}
+		if batchResp.Transfer != "" && batchResp.Transfer != "basic" && batchResp.Transfer != "multipart" {
+			return fmt.Errorf("unsupported LFS transfer adapter %s", batchResp.Transfer)
+		}
+		for _, obj := range batchResp.Objects {
+			info, ok := byOID[obj.OID]
+			if !ok {
+				return fmt.Errorf("LFS server returned unexpected object %s", obj.OID)
+			}
+			if obj.Error != nil {
+				return fmt.Errorf("failed to upload %s: LFS server returned error %d: %s", info.Path, obj.Error.Code, obj.Error.Message)
+			}
+			if obj.Actions == nil || obj.Actions.Upload == nil {
+				output.FromContext(ctx).Infof("File %s is already uploaded", info.Path)
+				continue
+			}
+			output.FromContext(ctx).Infof("Uploading file %s", info.Path)
+			var err error
+			if batchResp.Transfer == "multipart" {
+				err = c.uploadLFSMultipart(ctx, client, info, obj.Actions.Upload)
+			} else {
+				err = uploadLFSObject(ctx, client, info, obj.Actions.Upload)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to upload %s: %w", info.Path, err)
+			}
+			if obj.Actions.Verify != nil {
+				verifyBody := lfsBatchObject{OID: info.sha256, Size: info.size}
+				if err := c.postJSON(ctx, client, obj.Actions.Verify.Href, lfsMediaType, verifyBody, obj.Actions.Verify.Header, nil); err != nil {
+					return fmt.Errorf("failed to verify upload of %s: %w", info.Path, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// uploadLFSObject uploads info using the basic transfer adapter, sending the whole file in a single request.
+func uploadLFSObject(ctx context.Context, client *http.Client, info *uploadFileInfo, action *lfsAction) error {
+	f, err := os.Open(info.LocalPath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	_, err = putWithRetry(ctx, client, action.Href, action.Header, f, 0, info.size)
+	return err
+}
+
+// uploadLFSMultipart uploads info using the multipart transfer adapter. The upload action's header contains the
+// part size (chunk_size) and an upload URL for each part, keyed by part number starting from 1. Parts are uploaded
+// in order and retried individually, so a failure does not require re-sending parts that were already uploaded.
+// Once all parts are uploaded, the upload is completed by sending the ETag of each part to the action's href.
+func (c *Client) uploadLFSMultipart(ctx context.Context, client *http.Client, info *uploadFileInfo, action *lfsAction) error {
+	chunkSize, err := strconv.ParseInt(action.Header["chunk_size"], 10, 64)
+	if err != nil || chunkSize <= 0 {
+		return fmt.Errorf("invalid chunk size %q for multipart upload", action.Header["chunk_size"])
+	}
+	numParts := int((info.size + chunkSize - 1) / chunkSize)
+	partURLs := make([]string, numParts)
+	for i := range numParts {
+		partURL, ok := action.Header[strconv.Itoa(i+1)]
+		if !ok {
+			return fmt.Errorf("LFS server did not return an upload URL for part %d of %d", i+1, numParts)
+		}
+		partURLs[i] = partURL
+	}
+
+	f, err := os.Open(info.LocalPath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	completion := lfsMultipartCompletion{OID: info.sha256}
+	for i, partURL := range partURLs {
+		offset := int64(i) * chunkSize
+		partSize := min(chunkSize, info.size-offset)
+		output.FromContext(ctx).Debugf("Uploading part %d of %d for %s", i+1, numParts, info.Path)
+		respHeader, err := putWithRetry(ctx, client, partURL, nil, f, offset, partSize)
+		if err != nil {
+			return fmt.Errorf("failed to upload part %d of %d: %w", i+1, numParts, err)
+		}
+		etag := respHeader.Get("ETag")
+		if etag == "" {
+			return fmt.Errorf("upload of part %d of %d did not return an ETag", i+1, numParts)
+		}
+		completion.Parts = append(completion.Parts, lfsUploadedPart{PartNumber: i + 1, ETag: etag})
+	}
+
+	headers := map[string]string{"Accept": lfsMediaType}
+	if err := c.postJSON(ctx, client, action.Href, lfsMediaType, completion, headers, nil); err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	return nil
+}
+
+// createCommit creates a commit containing infos. LFS files must already be uploaded; other files are
+// sent as base64-encoded content.
+func (c *Client) createCommit(ctx context.Context, client *http.Client, modelRepo string, repoType RepoType, revision, summary string, infos []*uploadFileInfo) (*CommitInfo, error) {
+	body := &bytes.Buffer{}
+	encoder := json.NewEncoder(body)
+	if err := encoder.Encode(commitOperation{Key: "header", Value: commitHeader{Summary: summary}}); err != nil {
+		return nil, fmt.Errorf("failed to encode commit: %w", err)
+	}
+	for _, info := range infos {
+		var op commitOperation
+		if info.isLFS {
+			op = commitOperation{Key: "lfsFile", Value: commitLFSFile{
+				Path: info.Path,
+				Algo: "sha256",
+				OID:  info.sha256,
+				Size: info.size,
+			}}
+		} else {
+			contents, err := os.ReadFile(info.LocalPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", info.LocalPath, err)
+			}
+			op = commitOperation{Key: "file", Value: commitFile{
+				Path:     info.Path,
+				Content:  base64.StdEncoding.EncodeToString(contents),
+				Encoding: "base64",
+			}}
+		}
+		if err := encoder.Encode(op); err != nil {
+			return nil, fmt.Errorf("failed to encode commit: %w", err)
+		}
+	}
+
+	commitURL := fmt.Sprintf(commitURLFmt, c.endpoint, repoType.apiPath(), modelRepo, url.PathEscape(revision))
+	commitInfo := &CommitInfo{}
+	if err := c.post(ctx, client, commitURL, "application/x-ndjson", body, nil, commitInfo); err != nil {
+		return nil, fmt.Errorf("failed to create commit: %w", err)
+	}
+	return commitInfo, nil
+}
+
+// postJSON sends reqBody as JSON to reqURL and decodes the response into respBody, if it is not nil.
+func (c *Client) postJSON(ctx context.Context, client *http.Client, reqURL, contentType string, reqBody any, headers map[string]string, respBody any) error {
+	reqBytes, err := json.Marshal(reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	return c.post(ctx, client, reqURL, contentType, bytes.NewReader(reqBytes), headers, respBody)
+}
+
+func (c *Client) post(ctx context.Context, client *http.Client, reqURL, contentType string, reqBody io.Reader, headers map[string]string, respBody any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, reqBody)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	c.addAuth(req)
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling API: %w", err)
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			output.FromContext(ctx).Logf(output.LogLevelWarn, "failed to close response body: %s", closeErr)
+		}
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		errResp := &hfErrorResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(errResp); err != nil || errResp.Error == "" {
+			return fmt.Errorf("got error code %d from API", resp.StatusCode)
+		}
+		return fmt.Errorf("got error code %d from API: %s", resp.StatusCode, errResp.Error)
+	}
+	if respBody == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(respBody); err != nil {
+		return fmt.Errorf("failed to parse API response: %w", err)
+	}
+	return nil
+}
+
+// readUploadFileInfo computes the size, SHA256 digest, and sample needed to upload f
+func readUploadFileInfo(f UploadFile) (*uploadFileInfo, error) {
+	file, err := os.Open(f.LocalPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.LocalPath, err)
+	}
+	defer file.Close()
+
+	sample := make([]byte, sampleSize)
+	n, err := io.ReadFull(file, sample)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("failed to read %s: %w", f.LocalPath, err)
+	}
+	sample = sample[:n]
+
+	hash := sha256.New()
+	hash.Write(sample)
+	rest, err := io.Copy(hash, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.LocalPath, err)
+	}
+	return &uploadFileInfo{
+		UploadFile: f,
+		size:       int64(n) + rest,
+		sha256:     hex.EncodeToString(hash.Sum(nil)),
+		sample:     sample,
+	}, nil
+}
@@ -0,0 +1,199 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package hf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lfsUploadServer stubs the Hub endpoints used by UploadFiles. LFS objects are uploaded using the transfer
+// adapter in transfer; for multipart uploads, parts are chunkSize bytes. The first failures requests to upload
+// data are rejected with a 503.
+type lfsUploadServer struct {
+	t         *testing.T
+	url       string
+	transfer  string
+	chunkSize int
+	failures  int
+
+	mu        sync.Mutex
+	uploads   map[string][]byte
+	completed []lfsMultipartCompletion
+	commits   int
+}
+
+func (s *lfsUploadServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/preupload/main"):
+		req := &preuploadRequest{}
+		require.NoError(s.t, json.NewDecoder(r.Body).Decode(req))
+		resp := map[string]any{}
+		var files []map[string]any
+		for _, f := range req.Files {
+			files = append(files, map[string]any{"path": f.Path, "uploadMode": "lfs"})
+		}
+		resp["files"] = files
+		json.NewEncoder(w).Encode(resp)
+
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, ".git/info/lfs/objects/batch"):
+		req := &lfsBatchRequest{}
+		require.NoError(s.t, json.NewDecoder(r.Body).Decode(req))
+		assert.Equal(s.t, []string{"basic", "multipart"}, req.Transfers)
+		var objects []map[string]any
+		for _, obj := range req.Objects {
+			upload := &lfsAction{Href: fmt.Sprintf("%s/upload/%s", s.url, obj.OID)}
+			if s.transfer == "multipart" {
+				upload.Href = fmt.Sprintf("%s/complete/%s", s.url, obj.OID)
+				upload.Header = map[string]string{"chunk_size": strconv.Itoa(s.chunkSize)}
+				for part := 1; int64((part-1)*s.chunkSize) < obj.Size; part++ {
+					upload.Header[strconv.Itoa(part)] = fmt.Sprintf("%s/upload/%s/%d", s.url, obj.OID, part)
+				}
+			}
+			objects = append(objects, map[string]any{
+				"oid":     obj.OID,
+				"size":    obj.Size,
+				"actions": map[string]any{"upload": upload},
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"transfer": s.transfer, "objects": objects})
+
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/upload/"):
+		if s.failures > 0 {
+			s.failures--
+			_, _ = io.Copy(io.Discard, r.Body)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		data, err := io.ReadAll(r.Body)
+		require.NoError(s.t, err)
+		s.uploads[r.URL.Path] = data
+		w.Header().Set("ETag", fmt.Sprintf(`"etag-%s"`, r.URL.Path))
+
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/complete/"):
+		completion := lfsMultipartCompletion{}
+		require.NoError(s.t, json.NewDecoder(r.Body).Decode(&completion))
+		s.completed = append(s.completed, completion)
+
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/commit/main"):
+		s.commits++
+		json.NewEncoder(w).Encode(CommitInfo{OID: "abc123", URL: s.url + "/commit/abc123"})
+
+	default:
+		s.t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestUploadFilesLFS(t *testing.T) {
+	initialRetryDelay = 10 * time.Millisecond
+	content := testFileContent()
+	oid := sha256Hex(content)
+
+	tests := []struct {
+		name          string
+		transfer      string
+		failures      int
+		expectUploads []string
+	}{
+		{
+			name:          "basic transfer",
+			transfer:      "basic",
+			expectUploads: []string{"/upload/" + oid},
+		},
+		{
+			name:          "basic transfer retries failed upload",
+			transfer:      "basic",
+			failures:      2,
+			expectUploads: []string{"/upload/" + oid},
+		},
+		{
+			name:     "multipart transfer",
+			transfer: "multipart",
+			failures: 1,
+			expectUploads: []string{
+				"/upload/" + oid + "/1",
+				"/upload/" + oid + "/2",
+				"/upload/" + oid + "/3",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &lfsUploadServer{
+				t:         t,
+				transfer:  tt.transfer,
+				chunkSize: 100 * 1024,
+				failures:  tt.failures,
+				uploads:   map[string][]byte{},
+			}
+			server := httptest.NewServer(stub)
+			defer server.Close()
+			stub.url = server.URL
+
+			localPath := filepath.Join(t.TempDir(), "model.safetensors")
+			require.NoError(t, os.WriteFile(localPath, content, 0644))
+			client := NewClient(server.URL, "token")
+			commit, err := client.UploadFiles(context.Background(), "org/model", RepoTypeModel, "main", "Upload model",
+				[]UploadFile{{Path: "model.safetensors", LocalPath: localPath}}, nil)
+			require.NoError(t, err)
+			assert.Equal(t, "abc123", commit.OID)
+			assert.Equal(t, 1, stub.commits)
+
+			var uploaded []string
+			var data []byte
+			for path := range stub.uploads {
+				uploaded = append(uploaded, path)
+			}
+			slices.Sort(uploaded)
+			assert.Equal(t, tt.expectUploads, uploaded)
+			for _, path := range uploaded {
+				data = append(data, stub.uploads[path]...)
+			}
+			assert.Equal(t, content, data)
+
+			if tt.transfer != "multipart" {
+				assert.Empty(t, stub.completed)
+				return
+			}
+			require.Len(t, stub.completed, 1)
+			assert.Equal(t, oid, stub.completed[0].OID)
+			var expectParts []lfsUploadedPart
+			for i, path := range tt.expectUploads {
+				expectParts = append(expectParts, lfsUploadedPart{PartNumber: i + 1, ETag: fmt.Sprintf(`"etag-%s"`, path)})
+			}
+			assert.Equal(t, expectParts, stub.completed[0].Parts)
+		})
+	}
+}
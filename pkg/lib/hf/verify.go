@@ -0,0 +1,109 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package hf
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+
+	kfgen "kitops/pkg/lib/kitfile/generate"
+	"kitops/pkg/lib/network"
+	"kitops/pkg/output"
+)
+
+// fileVerifier computes the digest of a file as it is downloaded and compares it to the expected digest
+// from the repository listing.
+type fileVerifier struct {
+	hash      hash.Hash
+	algorithm string
+	expected  string
+	// For git blob IDs, the hash is computed over a header (including the file size) followed by the file contents
+	gitBlob     bool
+	gitBlobSize int64
+}
+
+// newFileVerifier returns a fileVerifier for file, using the LFS sha256 digest if available and the git blob ID
+// otherwise. If no digest is known for file, nil is returned; a nil *fileVerifier does not verify anything.
+func newFileVerifier(file kfgen.FileListing) *fileVerifier {
+	var v *fileVerifier
+	switch {
+	case file.SHA256 != "":
+		v = &fileVerifier{hash: sha256.New(), algorithm: "sha256", expected: file.SHA256}
+	case file.GitBlobID != "":
+		v = &fileVerifier{hash: sha1.New(), algorithm: "git blob ID", expected: file.GitBlobID, gitBlob: true, gitBlobSize: file.Size}
+	default:
+		return nil
+	}
+	v.Reset()
+	return v
+}
+
+func (v *fileVerifier) Write(p []byte) (int, error) {
+	return v.hash.Write(p)
+}
+
+func (v *fileVerifier) Reset() {
+	if v == nil {
+		return
+	}
+	v.hash.Reset()
+	if v.gitBlob {
+		fmt.Fprintf(v.hash, "blob %d\x00", v.gitBlobSize)
+	}
+}
+
+func (v *fileVerifier) Verify() error {
+	if v == nil {
+		return nil
+	}
+	actual := hex.EncodeToString(v.hash.Sum(nil))
+	if actual != v.expected {
+		return fmt.Errorf("%w: expected %s %s but got %s", network.ErrDigestMismatch, v.algorithm, v.expected, actual)
+	}
+	return nil
+}
+
+// verifyExistingFile returns true if the file at path already exists and matches its expected digest. Files that
+// exist but do not match are removed. If no digest is known, only the file's size is checked.
+func verifyExistingFile(path string, file kfgen.FileListing, plog *output.ProgressLogger) bool {
+	stat, err := os.Stat(path)
+	if err != nil || !stat.Mode().IsRegular() {
+		return false
+	}
+	matches := stat.Size() == file.Size
+	if verifier := newFileVerifier(file); matches && verifier != nil {
+		f, err := os.Open(path)
+		if err != nil {
+			return false
+		}
+		_, err = io.Copy(verifier.hash, f)
+		f.Close()
+		matches = err == nil && verifier.Verify() == nil
+	}
+	if !matches {
+		plog.Debugf("Existing file %s does not match expected contents; downloading again", file.Path)
+		if err := os.Remove(path); err != nil {
+			plog.Logf(output.LogLevelWarn, "Failed to remove file %s: %s", path, err)
+		}
+	}
+	return matches
+}
@@ -0,0 +1,242 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package generate
+
+import (
+	"fmt"
+	"strings"
+
+	"kitops/pkg/output"
+)
+
+// dominantShareThreshold is the share of a directory's contents that a single file type must make up
+// for the whole directory to be packed as a layer of that type. Directories with more evenly mixed
+// contents are split into multiple layers instead.
+const dominantShareThreshold = 0.9
+
+// Explanation describes why a path was added to a generated Kitfile as a particular type of layer.
+type Explanation struct {
+	Path   string `json:"path"`
+	Layer  string `json:"layer"`
+	Reason string `json:"reason"`
+}
+
+// Layer types used in explanations
+const (
+	layerModel     = "model"
+	layerModelPart = "model part"
+	layerDataset   = "dataset"
+	layerDocs      = "docs"
+	layerCode      = "code"
+)
+
+// explainer collects explanations for the decisions made while generating a Kitfile.
+type explainer struct {
+	explanations []Explanation
+	// pending records reasons for paths whose layer type is decided later, e.g. model files that may
+	// become either the model or a model part.
+	pending map[string]string
+}
+
+func newExplainer() *explainer {
+	return &explainer{pending: map[string]string{}}
+}
+
+func (e *explainer) add(path, layer, reason string) {
+	output.Logf(output.LogLevelTrace, "Adding %s as %s: %s", path, layer, reason)
+	e.explanations = append(e.explanations, Explanation{Path: path, Layer: layer, Reason: reason})
+}
+
+// deferReason records the reason for path being considered, to be used when its layer type is decided.
+func (e *explainer) deferReason(path, reason string) {
+	e.pending[path] = reason
+}
+
+// addPending adds an explanation for path using the reason recorded by deferReason, if any.
+func (e *explainer) addPending(path, layer, extraReason string) {
+	reason := e.pending[path]
+	switch {
+	case reason == "":
+		reason = extraReason
+	case extraReason != "":
+		reason = reason + "; " + extraReason
+	}
+	e.add(path, layer, reason)
+}
+
+// removeLayer drops all explanations for layer, e.g. when layers are replaced by a catch-all layer.
+func (e *explainer) removeLayer(layer string) {
+	var kept []Explanation
+	for _, exp := range e.explanations {
+		if exp.Layer != layer {
+			kept = append(kept, exp)
+		}
+	}
+	e.explanations = kept
+}
+
+// dirSummary records the number and total size of files of each type within a directory tree.
+type dirSummary struct {
+	counts [int(fileTypeUnknown) + 1]int
+	bytes  [int(fileTypeUnknown) + 1]int64
+}
+
+func summarizeDir(dir DirectoryListing) dirSummary {
+	summary := dirSummary{}
+	summary.addDir(dir)
+	return summary
+}
+
+func (s *dirSummary) addDir(dir DirectoryListing) {
+	for _, file := range dir.Files {
+		fType := determineFileType(file.Name)
+		s.counts[int(fType)]++
+		s.bytes[int(fType)] += file.Size
+	}
+	for _, subdir := range dir.Subdirs {
+		s.addDir(subdir)
+	}
+}
+
+// totalFiles returns the number of files in the directory tree.
+func (s dirSummary) totalFiles() int {
+	total := 0
+	for _, count := range s.counts {
+		total += count
+	}
+	return total
+}
+
+// totalBytes returns the combined size of all files in the directory tree.
+func (s dirSummary) totalBytes() int64 {
+	var total int64
+	for _, size := range s.bytes {
+		total += size
+	}
+	return total
+}
+
+// typeCount returns the number of distinct file types in the directory tree, not counting metadata.
+// Metadata files are packed along with whatever type of content they describe, and so do not make a
+// directory mixed.
+func (s dirSummary) typeCount() int {
+	count := 0
+	for fType, n := range s.counts {
+		if n > 0 && fileType(fType) != fileTypeMetadata {
+			count++
+		}
+	}
+	return count
+}
+
+// shares returns the share of the directory tree's contents made up by each file type, not counting
+// metadata. Shares are computed from file sizes, or from numbers of files if all files are empty.
+func (s dirSummary) shares() [int(fileTypeUnknown) + 1]float64 {
+	shares := [int(fileTypeUnknown) + 1]float64{}
+	var totalBytes int64
+	totalCount := 0
+	for fType := range s.counts {
+		if fileType(fType) == fileTypeMetadata {
+			continue
+		}
+		totalBytes += s.bytes[fType]
+		totalCount += s.counts[fType]
+	}
+	for fType := range s.counts {
+		if fileType(fType) == fileTypeMetadata {
+			continue
+		}
+		if totalBytes > 0 {
+			shares[fType] = float64(s.bytes[fType]) / float64(totalBytes)
+		} else if totalCount > 0 {
+			shares[fType] = float64(s.counts[fType]) / float64(totalCount)
+		}
+	}
+	return shares
+}
+
+// dominantType returns the file type with the largest share of the directory tree's contents, along
+// with its share. If the directory only contains metadata files (or is empty), fileTypeMetadata is
+// returned with a share of zero.
+func (s dirSummary) dominantType() (fileType, float64) {
+	dominant, dominantShare := fileTypeMetadata, 0.0
+	for fType, share := range s.shares() {
+		if s.counts[fType] > 0 && fileType(fType) != fileTypeMetadata && share >= dominantShare {
+			if dominant == fileTypeMetadata || share > dominantShare {
+				dominant, dominantShare = fileType(fType), share
+			}
+		}
+	}
+	return dominant, dominantShare
+}
+
+// uniqueDominantType returns the file type with the largest share of the directory tree's contents. If
+// the directory only contains metadata files, or several types have the same largest share, it returns false.
+func (s dirSummary) uniqueDominantType() (fileType, bool) {
+	dominant, dominantShare := s.dominantType()
+	if dominant == fileTypeMetadata {
+		return dominant, false
+	}
+	for fType, share := range s.shares() {
+		if fileType(fType) != dominant && s.counts[fType] > 0 && share == dominantShare {
+			return dominant, false
+		}
+	}
+	return dominant, true
+}
+
+// describe returns a summary of the directory tree's contents by share of each file type, e.g.
+// "55% model weights, 45% datasets".
+func (s dirSummary) describe() string {
+	var parts []string
+	for fType, share := range s.shares() {
+		if s.counts[fType] == 0 || fileType(fType) == fileTypeMetadata {
+			continue
+		}
+		if share < 0.01 {
+			parts = append(parts, fmt.Sprintf("<1%% %s", fileType(fType)))
+		} else {
+			parts = append(parts, fmt.Sprintf("%.0f%% %s", share*100, fileType(fType)))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (t fileType) String() string {
+	switch t {
+	case fileTypeModel:
+		return "model weights"
+	case fileTypeDataset:
+		return "datasets"
+	case fileTypeCode:
+		return "code"
+	case fileTypeDocs:
+		return "documentation"
+	case fileTypeMetadata:
+		return "metadata"
+	default:
+		return "code or unknown files"
+	}
+}
+
+// fileCount formats a number of files for explanations, e.g. "1 file" or "3 files"
+func fileCount(n int) string {
+	if n == 1 {
+		return "1 file"
+	}
+	return fmt.Sprintf("%d files", n)
+}
@@ -0,0 +1,173 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package generate
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"kitops/pkg/artifact"
+	"kitops/pkg/lib/constants"
+	"kitops/pkg/output"
+)
+
+// Matches the shard suffix in data file names, e.g. '-00000-of-00004' in 'train-00000-of-00004.parquet',
+// optionally followed by a hash as used by older versions of the HuggingFace datasets library.
+var shardSuffixRegexp = regexp.MustCompile(`[-_]\d+(-of-\d+)?(-[0-9a-f]{8,})?$`)
+
+// GenerateDatasetKitfile generates a Kitfile for a directory that contains a dataset rather than a
+// model. Data files (e.g. parquet or arrow shards) are grouped into one DataSet per directory that
+// contains them, which usually corresponds to a split or config of the dataset; data files at the
+// root of the directory are added as separate datasets. Each DataSet's parameters describe the format,
+// number of shards, and splits detected from file names. As with GenerateKitfile, packageOpt can be
+// used to define the package section of the Kitfile.
+func GenerateDatasetKitfile(dir *DirectoryListing, packageOpt *artifact.Package) (*artifact.KitFile, error) {
+	output.Logf(output.LogLevelTrace, "Generating dataset Kitfile in %s", dir.Path)
+	kitfile := &artifact.KitFile{
+		ManifestVersion: "1.0.0",
+	}
+	if packageOpt != nil {
+		kitfile.Package = *packageOpt
+	}
+
+	includeCatchallSection := false
+	var unprocessedDirPaths []string
+	for _, file := range dir.Files {
+		if constants.IsDefaultKitfileName(file.Name) {
+			continue
+		}
+		lowerName := strings.ToLower(file.Name)
+		if strings.HasPrefix(lowerName, "readme") {
+			kitfile.Docs = append(kitfile.Docs, artifact.Docs{Path: file.Path, Description: "Readme file"})
+			continue
+		} else if strings.HasPrefix(lowerName, "license") {
+			kitfile.Docs = append(kitfile.Docs, artifact.Docs{Path: file.Path, Description: "License file"})
+			continue
+		}
+
+		switch determineFileType(file.Path) {
+		case fileTypeDataset:
+			kitfile.DataSets = append(kitfile.DataSets, artifact.DataSet{
+				Name:       splitName(file.Name, ""),
+				Path:       file.Path,
+				Parameters: shardParameters([]FileListing{file}, ""),
+			})
+		case fileTypeMetadata:
+			kitfile.DataSets = append(kitfile.DataSets, artifact.DataSet{Path: file.Path})
+		case fileTypeDocs:
+			kitfile.Docs = append(kitfile.Docs, artifact.Docs{Path: file.Path})
+		default:
+			output.Logf(output.LogLevelTrace, "File %s is either code or unknown type. Will be added as a catch-all section", file.Path)
+			includeCatchallSection = true
+		}
+	}
+
+	for _, subDir := range dir.Subdirs {
+		if subDir.Name == "docs" {
+			kitfile.Docs = append(kitfile.Docs, artifact.Docs{Path: subDir.Path})
+			continue
+		}
+		datasets := datasetsInDir(subDir)
+		if len(datasets) == 0 {
+			output.Logf(output.LogLevelTrace, "No data files found in directory %s", subDir.Path)
+			unprocessedDirPaths = append(unprocessedDirPaths, subDir.Path)
+			continue
+		}
+		kitfile.DataSets = append(kitfile.DataSets, datasets...)
+	}
+
+	if includeCatchallSection || len(unprocessedDirPaths) > 5 {
+		kitfile.Code = []artifact.Code{{Path: "."}}
+	} else {
+		for _, path := range unprocessedDirPaths {
+			kitfile.Code = append(kitfile.Code, artifact.Code{Path: path})
+		}
+	}
+
+	return kitfile, nil
+}
+
+// datasetsInDir returns a DataSet for dir if it directly contains data files. Otherwise, subdirectories
+// are searched for data files. Directories that do not contain any data files are not included.
+func datasetsInDir(dir DirectoryListing) []artifact.DataSet {
+	var dataFiles []FileListing
+	for _, file := range dir.Files {
+		if determineFileType(file.Name) == fileTypeDataset {
+			dataFiles = append(dataFiles, file)
+		}
+	}
+	if len(dataFiles) > 0 {
+		output.Logf(output.LogLevelTrace, "Interpreting directory %s as a dataset directory", dir.Path)
+		return []artifact.DataSet{{
+			Name:       dir.Path,
+			Path:       dir.Path,
+			Parameters: shardParameters(dataFiles, dir.Name),
+		}}
+	}
+	var datasets []artifact.DataSet
+	for _, subDir := range dir.Subdirs {
+		datasets = append(datasets, datasetsInDir(subDir)...)
+	}
+	return datasets
+}
+
+// shardParameters describes a set of data files in the format used for DataSet parameters.
+func shardParameters(files []FileListing, dirName string) map[string]any {
+	formats := map[string]bool{}
+	splits := map[string]bool{}
+	for _, file := range files {
+		formats[strings.TrimPrefix(path.Ext(file.Name), ".")] = true
+		if split := splitName(file.Name, dirName); split != "" {
+			splits[split] = true
+		}
+	}
+	params := map[string]any{
+		"shards": len(files),
+	}
+	if formatList := sortedKeys(formats); len(formatList) == 1 {
+		params["format"] = formatList[0]
+	} else {
+		params["format"] = formatList
+	}
+	if len(splits) > 0 {
+		params["splits"] = sortedKeys(splits)
+	}
+	return params
+}
+
+// splitName guesses the dataset split (e.g. 'train') for a data file from its name. For files that
+// are named only by shard index (e.g. '0000.parquet' or 'data-00000-of-00001.arrow'), the name of the
+// containing directory is used instead.
+func splitName(filename, dirName string) string {
+	name := strings.TrimSuffix(filename, path.Ext(filename))
+	name = shardSuffixRegexp.ReplaceAllString(name, "")
+	if name == "" || name == "data" || strings.Trim(name, "0123456789") == "" {
+		return dirName
+	}
+	return name
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
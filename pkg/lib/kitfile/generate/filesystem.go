@@ -33,6 +33,12 @@ type FileListing struct {
 	Name string
 	Path string
 	Size int64
+	// SHA256 is the expected sha256 digest of the file's contents, if known (e.g. for Git LFS files
+	// in remote repositories). It is not set for files read from the local filesystem.
+	SHA256 string
+	// GitBlobID is the expected Git blob ID (sha1) of the file, if known. It is not set for files read
+	// from the local filesystem.
+	GitBlobID string
 }
 
 func DirectoryListingFromFS(contextDir string) (*DirectoryListing, error) {
@@ -63,13 +63,20 @@ var metadataSuffixes = []string{
 }
 
 var datasetSuffixes = []string{
-	".tar", ".zip", ".parquet", ".csv",
+	".tar", ".zip", ".parquet", ".arrow", ".csv", ".tsv", ".jsonl",
 }
 
 // Generate a basic Kitfile by looking at the contents of a directory. Parameter
 // packageOpt can be used to define metadata for the Kitfile (i.e. the package
 // section), which is left empty if the parameter is nil.
 func GenerateKitfile(dir *DirectoryListing, packageOpt *artifact.Package) (*artifact.KitFile, error) {
+	kitfile, _, err := GenerateKitfileWithExplanations(dir, packageOpt)
+	return kitfile, err
+}
+
+// GenerateKitfileWithExplanations generates a Kitfile as in GenerateKitfile, and additionally returns
+// an explanation of why each path in the Kitfile was added as its layer type.
+func GenerateKitfileWithExplanations(dir *DirectoryListing, packageOpt *artifact.Package) (*artifact.KitFile, []Explanation, error) {
 	output.Logf(output.LogLevelTrace, "Generating Kitfile in %s", dir.Path)
 	kitfile := &artifact.KitFile{
 		ManifestVersion: "1.0.0",
@@ -77,10 +84,11 @@ func GenerateKitfile(dir *DirectoryListing, packageOpt *artifact.Package) (*arti
 	if packageOpt != nil {
 		kitfile.Package = *packageOpt
 	}
+	exp := newExplainer()
 
 	// We can make sure all files are included by including a layer with path '.'
 	// However, we only want to do this if it is necessary
-	includeCatchallSection := false
+	var catchallFiles []string
 	// Dirs we don't know how to handle automatically.
 	var unprocessedDirPaths []string
 	// Metadata files; we want these to be either model parts (if there is a model)
@@ -99,14 +107,14 @@ func GenerateKitfile(dir *DirectoryListing, packageOpt *artifact.Package) (*arti
 
 		// Check for "special" files (e.g. readme, license)
 		if strings.HasPrefix(strings.ToLower(file.Name), "readme") {
-			output.Logf(output.LogLevelTrace, "Found readme file '%s'", file.Name)
+			exp.add(file.Name, layerDocs, "readme file")
 			kitfile.Docs = append(kitfile.Docs, artifact.Docs{
 				Path:        file.Name,
 				Description: "Readme file",
 			})
 			continue
 		} else if strings.HasPrefix(strings.ToLower(file.Name), "license") {
-			output.Logf(output.LogLevelTrace, "Found license file '%s'", file.Name)
+			exp.add(file.Name, layerDocs, "license file")
 			kitfile.Docs = append(kitfile.Docs, artifact.Docs{
 				Path:        file.Name,
 				Description: "License file",
@@ -124,8 +132,9 @@ func GenerateKitfile(dir *DirectoryListing, packageOpt *artifact.Package) (*arti
 		// Try to determine type based on file extension
 		// To support multi-part models, we need to collect all paths and decide
 		// which one is the model and which one(s) are parts
-		switch determineFileType(file.Path) {
+		switch fType := determineFileType(file.Path); fType {
 		case fileTypeModel:
+			exp.deferReason(file.Path, fmt.Sprintf("file extension indicates %s", fType))
 			modelFiles = append(modelFiles, file)
 		case fileTypeMetadata:
 			// Metadata should be included in either Model or Datasets, depending on
@@ -133,37 +142,40 @@ func GenerateKitfile(dir *DirectoryListing, packageOpt *artifact.Package) (*arti
 			output.Logf(output.LogLevelTrace, "Detected metadata file '%s'", file.Path)
 			metadataFiles = append(metadataFiles, file)
 		case fileTypeDocs:
+			exp.add(file.Path, layerDocs, fmt.Sprintf("file extension indicates %s", fType))
 			kitfile.Docs = append(kitfile.Docs, artifact.Docs{Path: file.Path})
 		case fileTypeDataset:
+			exp.add(file.Path, layerDataset, fmt.Sprintf("file extension indicates %s", fType))
 			kitfile.DataSets = append(kitfile.DataSets, artifact.DataSet{Path: file.Path})
 		default:
 			output.Logf(output.LogLevelTrace, "File %s is either code or unknown type. Will be added as a catch-all section", file.Path)
 			// File is either code or unknown; we'll have to include it in a catch-all section
-			includeCatchallSection = true
+			catchallFiles = append(catchallFiles, file.Path)
 		}
 	}
 
+	var dirMetadataFiles []FileListing
 	for _, subDir := range dir.Subdirs {
-		dirModelFiles, err := addDirToKitfile(kitfile, subDir)
-		if err != nil {
-			output.Logf(output.LogLevelTrace, "Failed to determine type for directory %s: %s", subDir.Path, err)
-			unprocessedDirPaths = append(unprocessedDirPaths, subDir.Path)
-		}
+		dirModelFiles, dirMetadata, codePaths := addDirToKitfile(kitfile, subDir, exp)
 		modelFiles = append(modelFiles, dirModelFiles...)
-		continue
+		dirMetadataFiles = append(dirMetadataFiles, dirMetadata...)
+		unprocessedDirPaths = append(unprocessedDirPaths, codePaths...)
 	}
+	metadataFiles = append(metadataFiles, dirMetadataFiles...)
 
 	if len(modelFiles) > 0 {
-		if err := addModelToKitfile(kitfile, modelFiles); err != nil {
-			return nil, fmt.Errorf("failed to add model to Kitfile: %w", err)
+		if err := addModelToKitfile(kitfile, modelFiles, exp); err != nil {
+			return nil, nil, fmt.Errorf("failed to add model to Kitfile: %w", err)
 		}
 		output.Logf(output.LogLevelTrace, "Adding metadata files as model parts")
 		for _, metadataFile := range metadataFiles {
+			exp.add(metadataFile.Path, layerModelPart, "metadata file included with model")
 			kitfile.Model.Parts = append(kitfile.Model.Parts, artifact.ModelPart{Path: metadataFile.Path})
 		}
 	} else {
 		output.Logf(output.LogLevelTrace, "No model detected; adding metadata files as dataset layers")
 		for _, metadataFile := range metadataFiles {
+			exp.add(metadataFile.Path, layerDataset, "metadata file included as dataset since there is no model")
 			kitfile.DataSets = append(kitfile.DataSets, artifact.DataSet{Path: metadataFile.Path})
 		}
 	}
@@ -171,12 +183,19 @@ func GenerateKitfile(dir *DirectoryListing, packageOpt *artifact.Package) (*arti
 	// Decide how to handle remaining paths. Either package them in one large code layer with basePath
 	// or as separate layers for each directory.
 	output.Logf(output.LogLevelTrace, "Unable to process %d paths in %s", len(unprocessedDirPaths), dir.Path)
-	if includeCatchallSection || len(unprocessedDirPaths) > 5 {
+	if len(catchallFiles) > 0 || len(unprocessedDirPaths) > 5 {
 		output.Logf(output.LogLevelTrace, "Adding catch-all code layer to include files in %s", dir.Path)
 		// Overwrite any code layers we added before; this is cleaner than e.g. having a layer for '.' and a layer for 'src'
 		kitfile.Code = []artifact.Code{{Path: "."}}
+		exp.removeLayer(layerCode)
+		if len(catchallFiles) > 0 {
+			exp.add(".", layerCode, fmt.Sprintf("catch-all layer for %s that are code or of unknown type (%s)", fileCount(len(catchallFiles)), strings.Join(catchallFiles, ", ")))
+		} else {
+			exp.add(".", layerCode, fmt.Sprintf("catch-all layer for %d directories that are code or of unknown type", len(unprocessedDirPaths)))
+		}
 	} else {
 		for _, path := range unprocessedDirPaths {
+			exp.addPending(path, layerCode, "")
 			kitfile.Code = append(kitfile.Code, artifact.Code{Path: path})
 		}
 	}
@@ -193,83 +212,177 @@ func GenerateKitfile(dir *DirectoryListing, packageOpt *artifact.Package) (*arti
 		kitfile.Package.License = detectedLicenseType
 	}
 
-	return kitfile, nil
+	return kitfile, exp.explanations, nil
 }
 
-func addDirToKitfile(kitfile *artifact.KitFile, dir DirectoryListing) (modelFiles []FileListing, err error) {
+// addDirToKitfile classifies the contents of dir, recursing into subdirectories, and adds layers for
+// it to kitfile. Directories whose contents are all (or mostly) of one type are added as a single
+// layer of that type. Directories with mixed contents are split into multiple layers: subdirectories
+// are classified on their own, and files are classified individually.
+//
+// Model files are returned rather than added to the Kitfile, as which file is the model and which are
+// parts depends on all model files found. Metadata files that are not part of another layer are returned
+// to be handled with top-level metadata files, and paths that should be packed as code are returned to be
+// added as code layers (or included in a catch-all layer) later.
+func addDirToKitfile(kitfile *artifact.KitFile, dir DirectoryListing, exp *explainer) (modelFiles, metadataFiles []FileListing, codePaths []string) {
 	switch dir.Name {
 	case "docs":
-		output.Logf(output.LogLevelTrace, "Directory %s interpreted as documentation", dir.Name)
+		exp.add(dir.Path, layerDocs, fmt.Sprintf("directory name '%s' indicates documentation", dir.Name))
 		kitfile.Docs = append(kitfile.Docs, artifact.Docs{
 			Path: dir.Path,
 		})
-		return nil, nil
+		return nil, nil, nil
 	case "src", "pkg", "lib", "build":
-		output.Logf(output.LogLevelTrace, "Directory %s interpreted as code", dir.Name)
+		exp.add(dir.Path, layerCode, fmt.Sprintf("directory name '%s' indicates code", dir.Name))
 		kitfile.Code = append(kitfile.Code, artifact.Code{
 			Path: dir.Path,
 		})
-		return nil, nil
+		return nil, nil, nil
 	}
 
-	// Sort entries in the directory to try and figure out what it contains. We'll reuse the
-	// fact that the fileTypes are enumerated using iota (and so are ints) to index correctly.
-	// Avoid using maps here since they iterate in a random order.
-	directoryContents := [int(fileTypeUnknown) + 1][]string{}
-	for _, subdir := range dir.Subdirs {
-		// We can, in the future, recurse deeper into the directory tree here. For now, treat secondary dirs as unknowns
-		directoryContents[int(fileTypeUnknown)] = append(directoryContents[int(fileTypeUnknown)], subdir.Path)
+	summary := summarizeDir(dir)
+	if summary.totalFiles() == 0 {
+		output.Logf(output.LogLevelTrace, "Skipping empty directory %s", dir.Path)
+		return nil, nil, nil
 	}
-
-	var metadataFiles []FileListing
-	for _, file := range dir.Files {
-		fileType := determineFileType(file.Name)
-		if fileType == fileTypeModel {
-			modelFiles = append(modelFiles, file)
-		}
-		if fileType == fileTypeMetadata {
-			metadataFiles = append(metadataFiles, file)
+	overallFiletype, share := summary.dominantType()
+	var reason string
+	switch {
+	case summary.typeCount() <= 1:
+		contents := overallFiletype.String()
+		if overallFiletype != fileTypeMetadata && summary.counts[int(fileTypeMetadata)] > 0 {
+			contents = contents + " and metadata"
 		}
-		directoryContents[int(fileType)] = append(directoryContents[int(fileType)], file.Path)
+		reason = fmt.Sprintf("directory contains only %s (%s, %s)", contents, fileCount(summary.totalFiles()), output.FormatBytes(summary.totalBytes()))
+	case share >= dominantShareThreshold:
+		reason = fmt.Sprintf("%s make up most of the directory (%s)", overallFiletype, summary.describe())
+	default:
+		output.Logf(output.LogLevelTrace, "Detected mixed contents within directory %s (%s)", dir.Path, summary.describe())
+		return splitDirIntoLayers(kitfile, dir, summary, exp)
 	}
 
-	// Try to detect directories that contain e.g. only datasets so we can add them
-	overallFiletype := fileTypeUnknown
-	directoryHasMixedContents := false
-	for fType, files := range directoryContents {
-		if len(files) > 0 && fileType(fType) != fileTypeMetadata {
-			if overallFiletype != fileTypeUnknown {
-				output.Logf(output.LogLevelTrace, "Detected mixed contents within directory %s", dir.Path)
-				directoryHasMixedContents = true
-			}
-			overallFiletype = fileType(fType)
-		}
-	}
-	if directoryHasMixedContents {
-		return modelFiles, fmt.Errorf("mixed content in directory; unable to determine type")
-	}
 	switch overallFiletype {
 	case fileTypeModel:
 		output.Logf(output.LogLevelTrace, "Interpreting directory %s as a model directory", dir.Path)
-		// Include any metadata files as modelParts later
-		modelFiles = append(modelFiles, metadataFiles...)
+		if len(dir.Subdirs) == 0 && summary.typeCount() == 1 {
+			// Include individual model files so that the model can be split into parts; metadata files are
+			// included as model parts later
+			for _, file := range dir.Files {
+				switch determineFileType(file.Name) {
+				case fileTypeModel:
+					exp.deferReason(file.Path, fmt.Sprintf("in %s: %s", dir.Path, reason))
+					modelFiles = append(modelFiles, file)
+				case fileTypeMetadata:
+					metadataFiles = append(metadataFiles, file)
+				}
+			}
+			return modelFiles, metadataFiles, nil
+		}
+		// The model is spread across subdirectories or is mixed with other files; include the whole directory
+		exp.deferReason(dir.Path, reason)
+		return []FileListing{{Name: dir.Name, Path: dir.Path, Size: summary.bytes[int(fileTypeModel)]}}, nil, nil
 	case fileTypeDataset:
 		output.Logf(output.LogLevelTrace, "Interpreting directory %s as a dataset directory", dir.Path)
+		exp.add(dir.Path, layerDataset, reason)
 		kitfile.DataSets = append(kitfile.DataSets, artifact.DataSet{Path: dir.Path})
 	case fileTypeDocs:
 		output.Logf(output.LogLevelTrace, "Interpreting directory %s as a docs directory", dir.Path)
+		exp.add(dir.Path, layerDocs, reason)
 		kitfile.Docs = append(kitfile.Docs, artifact.Docs{Path: dir.Path})
+	case fileTypeMetadata:
+		// Directories containing only metadata (e.g. configuration files) are handled as code
+		exp.deferReason(dir.Path, reason)
+		return nil, nil, []string{dir.Path}
 	default:
 		output.Logf(output.LogLevelTrace, "Could not determine type for directory %s", dir.Path)
-		// If it's overall code, metadata, or unknown, just return it as unprocessed and let it be added as a Code section
+		// If it's overall code or unknown, just return it as unprocessed and let it be added as a Code section
 		// later
-		return modelFiles, fmt.Errorf("directory should be handled as Code")
+		exp.deferReason(dir.Path, reason)
+		return nil, nil, []string{dir.Path}
 	}
 
-	return modelFiles, nil
+	return nil, nil, nil
+}
+
+// splitDirIntoLayers adds layers for a directory with mixed contents. Each subdirectory is classified
+// separately, and each file is added according to its own type. If the directory contains code or files
+// of unknown type, the directory itself is added as a code layer; since layers exclude paths that are
+// packed in other layers, this code layer only includes the files not classified otherwise.
+func splitDirIntoLayers(kitfile *artifact.KitFile, dir DirectoryListing, summary dirSummary, exp *explainer) (modelFiles, metadataFiles []FileListing, codePaths []string) {
+	mixedReason := fmt.Sprintf("mixed directory %s (%s) is split into layers by type", dir.Path, summary.describe())
+	for _, subDir := range dir.Subdirs {
+		subModelFiles, subMetadata, subCodePaths := addDirToKitfile(kitfile, subDir, exp)
+		modelFiles = append(modelFiles, subModelFiles...)
+		metadataFiles = append(metadataFiles, subMetadata...)
+		codePaths = append(codePaths, subCodePaths...)
+	}
+
+	var dirMetadataFiles, unknownFiles []FileListing
+	for _, file := range dir.Files {
+		switch fType := determineFileType(file.Name); fType {
+		case fileTypeModel:
+			exp.deferReason(file.Path, fmt.Sprintf("file extension indicates %s; %s", fType, mixedReason))
+			modelFiles = append(modelFiles, file)
+		case fileTypeDataset:
+			exp.add(file.Path, layerDataset, fmt.Sprintf("file extension indicates %s; %s", fType, mixedReason))
+			kitfile.DataSets = append(kitfile.DataSets, artifact.DataSet{Path: file.Path})
+		case fileTypeDocs:
+			exp.add(file.Path, layerDocs, fmt.Sprintf("file type indicates %s; %s", fType, mixedReason))
+			kitfile.Docs = append(kitfile.Docs, artifact.Docs{Path: file.Path})
+		case fileTypeMetadata:
+			dirMetadataFiles = append(dirMetadataFiles, file)
+		default:
+			unknownFiles = append(unknownFiles, file)
+		}
+	}
+
+	if len(unknownFiles) > 0 {
+		// Metadata files are left in the directory's code layer along with the code they likely configure
+		var names []string
+		for _, file := range unknownFiles {
+			names = append(names, file.Name)
+		}
+		exp.deferReason(dir.Path, fmt.Sprintf("includes %s that are code or of unknown type (%s); %s", fileCount(len(unknownFiles)), strings.Join(names, ", "), mixedReason))
+		codePaths = append(codePaths, dir.Path)
+		return modelFiles, metadataFiles, codePaths
+	}
+	if len(dirMetadataFiles) == 0 {
+		return modelFiles, metadataFiles, codePaths
+	}
+
+	// Metadata files describe the main contents of the directory they are in, so they are packed with the
+	// directory's dominant type. If no type is dominant, they are left in the directory's code layer.
+	dominant, ok := summary.uniqueDominantType()
+	metadataReason := fmt.Sprintf("metadata file included with %s, the main contents of %s", dominant, dir.Path)
+	switch {
+	case ok && dominant == fileTypeModel:
+		metadataFiles = append(metadataFiles, dirMetadataFiles...)
+	case ok && dominant == fileTypeDataset:
+		for _, file := range dirMetadataFiles {
+			exp.add(file.Path, layerDataset, metadataReason)
+			kitfile.DataSets = append(kitfile.DataSets, artifact.DataSet{Path: file.Path})
+		}
+	case ok && dominant == fileTypeDocs:
+		for _, file := range dirMetadataFiles {
+			exp.add(file.Path, layerDocs, metadataReason)
+			kitfile.Docs = append(kitfile.Docs, artifact.Docs{Path: file.Path})
+		}
+	default:
+		var names []string
+		for _, file := range dirMetadataFiles {
+			names = append(names, file.Name)
+		}
+		exp.deferReason(dir.Path, fmt.Sprintf("includes metadata (%s) for contents of no single type; %s", strings.Join(names, ", "), mixedReason))
+		codePaths = append(codePaths, dir.Path)
+	}
+	return modelFiles, metadataFiles, codePaths
 }
 
 func determineFileType(filename string) fileType {
+	baseName := strings.ToLower(filepath.Base(filename))
+	if strings.HasPrefix(baseName, "readme") || strings.HasPrefix(baseName, "license") {
+		return fileTypeDocs
+	}
 	if anySuffix(filename, modelWeightsSuffixes) {
 		return fileTypeModel
 	}
@@ -288,13 +401,14 @@ func determineFileType(filename string) fileType {
 
 }
 
-func addModelToKitfile(kitfile *artifact.KitFile, files []FileListing) error {
+func addModelToKitfile(kitfile *artifact.KitFile, files []FileListing, exp *explainer) error {
 	if len(files) == 0 {
 		return nil
 	}
 
 	if len(files) == 1 {
 		file := files[0]
+		exp.addPending(file.Path, layerModel, "")
 		kitfile.Model = &artifact.Model{
 			Path: file.Path,
 			Name: strings.TrimSuffix(file.Path, filepath.Ext(file.Name)),
@@ -320,6 +434,7 @@ func addModelToKitfile(kitfile *artifact.KitFile, files []FileListing) error {
 	// If the biggest file is 1.5x the average, make it the model and the rest parts; otherwise, add
 	// all parts in lexical order
 	if largestSize > averageSize+(averageSize/2) {
+		exp.addPending(largestFile.Path, layerModel, fmt.Sprintf("largest of %d model paths", len(files)))
 		kitfile.Model = &artifact.Model{
 			Path: largestFile.Path,
 		}
@@ -328,15 +443,18 @@ func addModelToKitfile(kitfile *artifact.KitFile, files []FileListing) error {
 			if file == largestFile {
 				continue
 			}
+			exp.addPending(file.Path, layerModelPart, "smaller than the main model")
 			kitfile.Model.Parts = append(kitfile.Model.Parts, artifact.ModelPart{
 				Path: file.Path,
 			})
 		}
 	} else {
+		exp.addPending(files[0].Path, layerModel, fmt.Sprintf("first of %d similarly sized model paths", len(files)))
 		kitfile.Model = &artifact.Model{
 			Path: files[0].Path,
 		}
 		for _, file := range files[1:] {
+			exp.addPending(file.Path, layerModelPart, "one of multiple similarly sized model paths")
 			kitfile.Model.Parts = append(kitfile.Model.Parts, artifact.ModelPart{
 				Path: file.Path,
 			})
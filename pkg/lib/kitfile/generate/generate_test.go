@@ -0,0 +1,190 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package generate
+
+import (
+	"path"
+	"testing"
+
+	"kitops/pkg/artifact"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const gib = int64(1024 * 1024 * 1024)
+
+// dirListing builds a DirectoryListing for dirPath from files (paths relative to dirPath mapped to sizes) and subdirs.
+func dirListing(dirPath string, files map[string]int64, subdirs ...DirectoryListing) DirectoryListing {
+	dir := DirectoryListing{Name: path.Base(dirPath), Path: dirPath, Subdirs: subdirs}
+	for _, name := range sortedFileNames(files) {
+		dir.Files = append(dir.Files, FileListing{Name: name, Path: path.Join(dirPath, name), Size: files[name]})
+	}
+	return dir
+}
+
+func sortedFileNames(files map[string]int64) []string {
+	names := map[string]bool{}
+	for name := range files {
+		names[name] = true
+	}
+	return sortedKeys(names)
+}
+
+func TestGenerateKitfileClassifiesDirectories(t *testing.T) {
+	tests := []struct {
+		name     string
+		dir      DirectoryListing
+		expected *artifact.KitFile
+	}{
+		{
+			name: "directory mostly containing model weights is packed as model",
+			dir: dirListing(".", nil,
+				dirListing("checkpoint", map[string]int64{"train.py": 2048, "config.json": 1024},
+					dirListing("checkpoint/step-1000", map[string]int64{"model.safetensors": 10 * gib, "optimizer.pt": 10 * gib}),
+				),
+			),
+			expected: &artifact.KitFile{
+				ManifestVersion: "1.0.0",
+				Model:           &artifact.Model{Name: "checkpoint", Path: "checkpoint"},
+			},
+		},
+		{
+			name: "mixed directory is split into layers",
+			dir: dirListing(".", nil,
+				dirListing("project", map[string]int64{"model.safetensors": 5 * gib, "train.csv": 4 * gib, "main.py": 4096, "settings.yaml": 512},
+					dirListing("project/reports", map[string]int64{"results.md": 1024, "results.pdf": 2048}),
+					dirListing("project/eval", map[string]int64{"test-0.parquet": gib, "test-1.parquet": gib}),
+				),
+			),
+			expected: &artifact.KitFile{
+				ManifestVersion: "1.0.0",
+				Model:           &artifact.Model{Name: "project/model", Path: "project/model.safetensors"},
+				DataSets:        []artifact.DataSet{{Path: "project/eval"}, {Path: "project/train.csv"}},
+				Docs:            []artifact.Docs{{Path: "project/reports"}},
+				Code:            []artifact.Code{{Path: "project"}},
+			},
+		},
+		{
+			name: "metadata in mixed directory of mostly model weights is added to model",
+			dir: dirListing(".", nil,
+				dirListing("mixed", map[string]int64{"weights.gguf": 2 * gib, "data.csv": gib, "config.json": 100}),
+			),
+			expected: &artifact.KitFile{
+				ManifestVersion: "1.0.0",
+				Model: &artifact.Model{
+					Name:  "mixed/weights",
+					Path:  "mixed/weights.gguf",
+					Parts: []artifact.ModelPart{{Path: "mixed/config.json"}},
+				},
+				DataSets: []artifact.DataSet{{Path: "mixed/data.csv"}},
+			},
+		},
+		{
+			name: "metadata in mixed directory of mostly datasets is added to datasets",
+			dir: dirListing(".", nil,
+				dirListing("model", map[string]int64{"weights.gguf": gib}),
+				dirListing("mixed", map[string]int64{"data.csv": 2 * gib, "notes.md": gib, "schema.json": 100}),
+			),
+			expected: &artifact.KitFile{
+				ManifestVersion: "1.0.0",
+				Model:           &artifact.Model{Name: "model/weights", Path: "model/weights.gguf"},
+				DataSets:        []artifact.DataSet{{Path: "mixed/data.csv"}, {Path: "mixed/schema.json"}},
+				Docs:            []artifact.Docs{{Path: "mixed/notes.md"}},
+			},
+		},
+		{
+			name: "metadata in mixed directory without a dominant type is packed as code",
+			dir: dirListing(".", nil,
+				dirListing("model", map[string]int64{"weights.gguf": gib}),
+				dirListing("mixed", map[string]int64{"data.csv": gib, "notes.md": gib, "config.json": 100}),
+			),
+			expected: &artifact.KitFile{
+				ManifestVersion: "1.0.0",
+				Model:           &artifact.Model{Name: "model/weights", Path: "model/weights.gguf"},
+				DataSets:        []artifact.DataSet{{Path: "mixed/data.csv"}},
+				Docs:            []artifact.Docs{{Path: "mixed/notes.md"}},
+				Code:            []artifact.Code{{Path: "mixed"}},
+			},
+		},
+		{
+			name: "nested dataset directories are packed as one dataset",
+			dir: dirListing(".", nil,
+				dirListing("data", map[string]int64{"README.md": 100},
+					dirListing("data/train", map[string]int64{"part-0.parquet": gib, "part-1.parquet": gib}),
+					dirListing("data/test", map[string]int64{"part-0.parquet": gib}),
+				),
+			),
+			expected: &artifact.KitFile{
+				ManifestVersion: "1.0.0",
+				DataSets:        []artifact.DataSet{{Path: "data"}},
+			},
+		},
+		{
+			name: "directories of code and configuration are packed as code",
+			dir: dirListing(".", nil,
+				dirListing("configs", map[string]int64{"train.yaml": 100, "eval.yaml": 100}),
+				dirListing("scripts", map[string]int64{"run.sh": 100},
+					dirListing("scripts/helpers", map[string]int64{"util.py": 100}),
+				),
+				dirListing("empty", nil),
+			),
+			expected: &artifact.KitFile{
+				ManifestVersion: "1.0.0",
+				Code:            []artifact.Code{{Path: "configs"}, {Path: "scripts"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kitfile, err := GenerateKitfile(&tt.dir, nil)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.expected, kitfile)
+		})
+	}
+}
+
+func TestGenerateKitfileExplanations(t *testing.T) {
+	dir := dirListing(".", map[string]int64{"README.md": 100},
+		dirListing("checkpoint", map[string]int64{"model.safetensors": 19 * gib, "train.py": gib}),
+		dirListing("data", map[string]int64{"train.csv": gib}),
+	)
+	_, explanations, err := GenerateKitfileWithExplanations(&dir, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []Explanation{
+		{Path: "README.md", Layer: "docs", Reason: "readme file"},
+		{Path: "data", Layer: "dataset", Reason: "directory contains only datasets (1 file, 1.0 GiB)"},
+		{Path: "checkpoint", Layer: "model", Reason: "model weights make up most of the directory (95% model weights, 5% code or unknown files)"},
+	}, explanations)
+}
+
+func TestDirSummary(t *testing.T) {
+	summary := summarizeDir(dirListing("dir", map[string]int64{"model.gguf": 3000, "data.csv": 995, "notes.md": 5, "config.json": 10000}))
+	fType, share := summary.dominantType()
+	assert.Equal(t, fileTypeModel, fType)
+	assert.InDelta(t, 0.75, share, 0.001)
+	assert.Equal(t, 3, summary.typeCount())
+	assert.Equal(t, "75% model weights, 25% datasets, <1% documentation", summary.describe())
+
+	empty := summarizeDir(dirListing("dir", map[string]int64{"a.bin": 0, "b.bin": 0, "c.csv": 0}))
+	fType, share = empty.dominantType()
+	assert.Equal(t, fileTypeModel, fType)
+	assert.InDelta(t, 2.0/3.0, share, 0.001)
+}
@@ -19,6 +19,7 @@ package kitfile
 import (
 	"archive/tar"
 	"compress/gzip"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -32,40 +33,57 @@ import (
 	"kitops/pkg/lib/constants"
 	"kitops/pkg/lib/filesystem"
 	"kitops/pkg/lib/filesystem/cache"
+	"kitops/pkg/lib/telemetry"
 	"kitops/pkg/output"
 
 	"github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-// compressLayer compresses an *artifact.ModelLayer to a gzipped tar file. In order to return
+// compressLayer compresses an *artifact.ModelLayer to a gzipped tar file. The layer path and the
+// names of files within the tar are relative to contextDir, and files are only read from within
+// contextDir (symlinks may not escape it). In order to return
 // a descriptor (including hash) for the compressed file, the layer is saved to a temporary file
 // on disk and must be moved to an appropriate location. It is the responsibility of the caller
 // to clean up the temporary file when it is no longer needed.
-func compressLayer(path string, mediaType constants.MediaType, ignore filesystem.IgnorePaths) (tempFilePath string, desc ocispec.Descriptor, layerInfo *artifact.LayerInfo, err error) {
+func compressLayer(ctx context.Context, contextDir, path string, mediaType constants.MediaType, ignore filesystem.IgnorePaths) (tempFilePath string, desc ocispec.Descriptor, layerInfo *artifact.LayerInfo, err error) {
 	// Clean path to ensure consistent format (./path vs path/ vs path)
 	path = filepath.Clean(path)
 
+	_, span := telemetry.StartSpan(ctx, "compressLayer",
+		attribute.String("kitops.layer.path", path),
+		telemetry.MediaTypeKey.String(mediaType.String()))
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	logger := output.FromContext(ctx)
+
 	if layerIgnored, err := ignore.Matches(path, path); err != nil {
 		return "", ocispec.DescriptorEmptyJSON, nil, err
 	} else if layerIgnored {
-		output.Errorf("Warning: %s layer path %s ignored by kitignore", mediaType.BaseType, path)
+		logger.Errorf("Warning: %s layer path %s ignored by kitignore", mediaType.BaseType, path)
+	}
+
+	root, err := os.OpenRoot(contextDir)
+	if err != nil {
+		return "", ocispec.DescriptorEmptyJSON, nil, fmt.Errorf("failed to open context directory: %w", err)
 	}
+	defer root.Close()
 
-	totalSize, err := getTotalSize(path, ignore)
+	totalSize, err := getTotalSize(root, path, ignore)
 	if err != nil {
 		return "", ocispec.DescriptorEmptyJSON, nil, fmt.Errorf("error processing %s: %w", mediaType.BaseType, err)
 	}
 	if totalSize == 0 {
-		output.Logf(output.LogLevelWarn, "No files detected in %s layer with path %s", mediaType.BaseType, path)
+		logger.Logf(output.LogLevelWarn, "No files detected in %s layer with path %s", mediaType.BaseType, path)
 	}
 
-	tempFile, tempFileCleanup, err := cache.MkCacheFile(cache.CachePackSubdir, "kitops_layer_")
+	tempFile, tempFileCleanup, err := cache.MkCacheFile(ctx, cache.CachePackSubdir, "kitops_layer_")
 	if err != nil {
 		return "", ocispec.DescriptorEmptyJSON, nil, fmt.Errorf("failed to create temporary file: %w", err)
 	}
 	tempFileName := tempFile.Name()
-	output.Debugf("Compressing layer to temporary file %s", tempFileName)
+	logger.Debugf("Compressing layer to temporary file %s", tempFileName)
 
 	digester := digest.Canonical.Digester()
 	var diffIdDigester digest.Digester
@@ -91,23 +109,25 @@ func compressLayer(path string, mediaType constants.MediaType, ignore filesystem
 		tarWriter = tar.NewWriter(fileWriter)
 		diffIdDigester = digester
 	}
-	progressTarWriter, plog := output.TarProgress(totalSize, tarWriter)
+	progressTarWriter, plog := logger.TarProgress(totalSize, tarWriter)
 
-	if err := writeLayerToTar(path, ignore, progressTarWriter, plog); err != nil {
+	if err := writeLayerToTar(root, path, ignore, progressTarWriter, plog); err != nil {
 		// Don't care about these errors since we'll be deleting the file anyways
 		_ = progressTarWriter.Close()
 		_ = tarWriter.Close()
 		if compressedWriter != nil {
 			_ = compressedWriter.Close()
 		}
+		plog.Wait()
 		tempFileCleanup()
+		return "", ocispec.DescriptorEmptyJSON, nil, fmt.Errorf("error processing %s: %w", mediaType.BaseType, err)
 	}
 	plog.Wait()
 
-	callAndPrintError(progressTarWriter.Close, "Failed to close writer: %s")
-	callAndPrintError(tarWriter.Close, "Failed to close tar writer: %s")
+	callAndPrintError(logger, progressTarWriter.Close, "Failed to close writer: %s")
+	callAndPrintError(logger, tarWriter.Close, "Failed to close tar writer: %s")
 	if compressedWriter != nil {
-		callAndPrintError(compressedWriter.Close, "Failed to close compression writer: %s")
+		callAndPrintError(logger, compressedWriter.Close, "Failed to close compression writer: %s")
 	}
 
 	tempFileInfo, err := tempFile.Stat()
@@ -115,7 +135,7 @@ func compressLayer(path string, mediaType constants.MediaType, ignore filesystem
 		tempFileCleanup()
 		return "", ocispec.DescriptorEmptyJSON, nil, fmt.Errorf("failed to stat temporary file: %w", err)
 	}
-	callAndPrintError(tempFile.Close, "Failed to close temporary file: %s")
+	callAndPrintError(logger, tempFile.Close, "Failed to close temporary file: %s")
 
 	desc = ocispec.Descriptor{
 		MediaType: mediaType.String(),
@@ -126,12 +146,15 @@ func compressLayer(path string, mediaType constants.MediaType, ignore filesystem
 		Digest: digester.Digest().String(),
 		DiffId: diffIdDigester.Digest().String(),
 	}
+	span.SetAttributes(telemetry.DescriptorAttributes(desc)...)
 	return tempFileName, desc, layerInfo, nil
 }
 
-func writeLayerToTar(basePath string, ignore filesystem.IgnorePaths, tarWriter *output.ProgressTar, plog *output.ProgressLogger) error {
+// writeLayerToTar writes basePath and its parent directories to tarWriter. All paths are resolved
+// within root.
+func writeLayerToTar(root *os.Root, basePath string, ignore filesystem.IgnorePaths, tarWriter *output.ProgressTar, plog *output.ProgressLogger) error {
 	// Make sure target path exists; otherwise we'll miss it while walking below
-	_, err := os.Stat(basePath)
+	_, err := root.Stat(basePath)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return fmt.Errorf("path %s does not exist", basePath)
@@ -153,13 +176,19 @@ func writeLayerToTar(basePath string, ignore filesystem.IgnorePaths, tarWriter *
 		return true
 	}
 
-	filepath.Walk(".", func(file string, fi os.FileInfo, err error) error {
+	return fs.WalkDir(root.FS(), ".", func(fsPath string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
-		if file == "." {
+		if fsPath == "." {
 			return nil
 		}
+		// Names in the tar file and ignore matching use paths relative to the context directory
+		file := filepath.FromSlash(fsPath)
+		fi, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", file, err)
+		}
 		// Skip anything that's not a regular file or directory
 		if !fi.Mode().IsRegular() && !fi.Mode().IsDir() {
 			return nil
@@ -190,10 +219,8 @@ func writeLayerToTar(basePath string, ignore filesystem.IgnorePaths, tarWriter *
 		if fi.IsDir() {
 			return nil
 		}
-		return writeFileToTar(file, fi, tarWriter, plog)
+		return writeFileToTar(root, file, fi, tarWriter, plog)
 	})
-
-	return nil
 }
 
 func writeHeaderToTar(name string, fi os.FileInfo, ptw *output.ProgressTar, plog *output.ProgressLogger) error {
@@ -210,8 +237,8 @@ func writeHeaderToTar(name string, fi os.FileInfo, ptw *output.ProgressTar, plog
 	return nil
 }
 
-func writeFileToTar(file string, fi os.FileInfo, ptw *output.ProgressTar, plog *output.ProgressLogger) error {
-	f, err := os.Open(file)
+func writeFileToTar(root *os.Root, file string, fi os.FileInfo, ptw *output.ProgressTar, plog *output.ProgressLogger) error {
+	f, err := root.Open(file)
 	if err != nil {
 		return fmt.Errorf("failed to open file for archiving: %w", err)
 	}
@@ -226,8 +253,8 @@ func writeFileToTar(file string, fi os.FileInfo, ptw *output.ProgressTar, plog *
 	return nil
 }
 
-func getTotalSize(basePath string, ignore filesystem.IgnorePaths) (int64, error) {
-	pathInfo, err := os.Stat(basePath)
+func getTotalSize(root *os.Root, basePath string, ignore filesystem.IgnorePaths) (int64, error) {
+	pathInfo, err := root.Stat(basePath)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return 0, fmt.Errorf("path %s does not exist", basePath)
@@ -239,10 +266,11 @@ func getTotalSize(basePath string, ignore filesystem.IgnorePaths) (int64, error)
 		return pathInfo.Size(), nil
 	} else if pathInfo.IsDir() {
 		var total int64
-		err := filepath.WalkDir(basePath, func(file string, d fs.DirEntry, err error) error {
+		err := fs.WalkDir(root.FS(), filepath.ToSlash(basePath), func(fsPath string, d fs.DirEntry, err error) error {
 			if err != nil {
 				return err
 			}
+			file := filepath.FromSlash(fsPath)
 			if shouldIgnore, err := ignore.Matches(file, basePath); err != nil {
 				return fmt.Errorf("failed to match %s against ignore file: %w", file, err)
 			} else if shouldIgnore {
@@ -271,9 +299,9 @@ func getTotalSize(basePath string, ignore filesystem.IgnorePaths) (int64, error)
 
 // callAndPrintError is a wrapper to print an error message for a function that
 // may return an error. The error is printed and then discarded.
-func callAndPrintError(f func() error, msg string) {
+func callAndPrintError(logger *output.Logger, f func() error, msg string) {
 	if err := f(); err != nil {
-		output.Errorf(msg, err)
+		logger.Errorf(msg, err)
 	}
 }
 
@@ -27,7 +27,6 @@ import (
 	"kitops/pkg/artifact"
 	"kitops/pkg/lib/constants"
 	"kitops/pkg/lib/filesystem"
-	"kitops/pkg/lib/filesystem/cache"
 	"kitops/pkg/lib/repo/local"
 	"kitops/pkg/lib/repo/util"
 	"kitops/pkg/output"
@@ -38,11 +37,12 @@ import (
 	"oras.land/oras-go/v2"
 )
 
-// SaveModel saves an *artifact.Model to the provided oras.Target, compressing layers. It attempts to block
-// modelkits that include paths that leave the base context directory, allowing only subdirectories of the root
-// context to be included in the modelkit.
-func SaveModel(ctx context.Context, localRepo local.LocalRepo, kitfile *artifact.KitFile, ignore filesystem.IgnorePaths, compression string) (*ocispec.Descriptor, error) {
-	layerDescs, err := saveKitfileLayers(ctx, localRepo, kitfile, ignore, compression)
+// SaveModel saves an *artifact.Model to the provided oras.Target, compressing layers. Paths in the Kitfile
+// are resolved relative to contextDir. Files are read through an *os.Root for contextDir, so modelkits cannot
+// include paths (or follow symlinks) that leave the base context directory. Any annotations provided are
+// added to the manifest alongside the default ones.
+func SaveModel(ctx context.Context, localRepo local.LocalRepo, kitfile *artifact.KitFile, contextDir string, ignore filesystem.IgnorePaths, compression string, annotations map[string]string) (*ocispec.Descriptor, error) {
+	layerDescs, err := saveKitfileLayers(ctx, localRepo, kitfile, contextDir, ignore, compression)
 	if err != nil {
 		return nil, err
 	}
@@ -52,17 +52,13 @@ func SaveModel(ctx context.Context, localRepo local.LocalRepo, kitfile *artifact
 		return nil, err
 	}
 
-	manifest := createManifest(configDesc, layerDescs)
+	manifest := createManifest(configDesc, layerDescs, annotations)
 
 	manifestDesc, err := saveModelManifest(ctx, localRepo, manifest)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := cache.CleanCacheDir(cache.CachePackSubdir); err != nil {
-		output.Logf(output.LogLevelWarn, "Failed to clean cache directory: %s", err)
-	}
-
 	return manifestDesc, nil
 }
 
@@ -87,15 +83,15 @@ func saveConfig(ctx context.Context, localRepo local.LocalRepo, kitfile *artifac
 		if err != nil {
 			return ocispec.DescriptorEmptyJSON, err
 		}
-		output.Infof("Saved configuration: %s", desc.Digest)
+		output.FromContext(ctx).WithFields(output.Fields{"digest": desc.Digest, "layerType": constants.ConfigType, "bytes": desc.Size}).Infof("Saved configuration: %s", desc.Digest)
 	} else {
-		output.Infof("Configuration already exists in storage: %s", desc.Digest)
+		output.FromContext(ctx).Infof("Configuration already exists in storage: %s", desc.Digest)
 	}
 
 	return desc, nil
 }
 
-func saveKitfileLayers(ctx context.Context, localRepo local.LocalRepo, kitfile *artifact.KitFile, ignore filesystem.IgnorePaths, compression string) ([]ocispec.Descriptor, error) {
+func saveKitfileLayers(ctx context.Context, localRepo local.LocalRepo, kitfile *artifact.KitFile, contextDir string, ignore filesystem.IgnorePaths, compression string) ([]ocispec.Descriptor, error) {
 	var layers []ocispec.Descriptor
 	if kitfile.Model != nil {
 		if kitfile.Model.Path != "" && !util.IsModelKitReference(kitfile.Model.Path) {
@@ -103,7 +99,7 @@ func saveKitfileLayers(ctx context.Context, localRepo local.LocalRepo, kitfile *
 				BaseType:    constants.ModelType,
 				Compression: compression,
 			}
-			layer, layerInfo, err := saveContentLayer(ctx, localRepo, kitfile.Model.Path, mediaType, ignore)
+			layer, layerInfo, err := saveContentLayer(ctx, localRepo, contextDir, kitfile.Model.Path, mediaType, ignore)
 			if err != nil {
 				return nil, err
 			}
@@ -115,7 +111,7 @@ func saveKitfileLayers(ctx context.Context, localRepo local.LocalRepo, kitfile *
 				BaseType:    constants.ModelPartType,
 				Compression: compression,
 			}
-			layer, layerInfo, err := saveContentLayer(ctx, localRepo, part.Path, mediaType, ignore)
+			layer, layerInfo, err := saveContentLayer(ctx, localRepo, contextDir, part.Path, mediaType, ignore)
 			if err != nil {
 				return nil, err
 			}
@@ -128,7 +124,7 @@ func saveKitfileLayers(ctx context.Context, localRepo local.LocalRepo, kitfile *
 			BaseType:    constants.CodeType,
 			Compression: compression,
 		}
-		layer, layerInfo, err := saveContentLayer(ctx, localRepo, code.Path, mediaType, ignore)
+		layer, layerInfo, err := saveContentLayer(ctx, localRepo, contextDir, code.Path, mediaType, ignore)
 		if err != nil {
 			return nil, err
 		}
@@ -140,7 +136,7 @@ func saveKitfileLayers(ctx context.Context, localRepo local.LocalRepo, kitfile *
 			BaseType:    constants.DatasetType,
 			Compression: compression,
 		}
-		layer, layerInfo, err := saveContentLayer(ctx, localRepo, dataset.Path, mediaType, ignore)
+		layer, layerInfo, err := saveContentLayer(ctx, localRepo, contextDir, dataset.Path, mediaType, ignore)
 		if err != nil {
 			return nil, err
 		}
@@ -152,7 +148,7 @@ func saveKitfileLayers(ctx context.Context, localRepo local.LocalRepo, kitfile *
 			BaseType:    constants.DocsType,
 			Compression: compression,
 		}
-		layer, layerInfo, err := saveContentLayer(ctx, localRepo, docs.Path, mediaType, ignore)
+		layer, layerInfo, err := saveContentLayer(ctx, localRepo, contextDir, docs.Path, mediaType, ignore)
 		if err != nil {
 			return nil, err
 		}
@@ -163,24 +159,24 @@ func saveKitfileLayers(ctx context.Context, localRepo local.LocalRepo, kitfile *
 	return layers, nil
 }
 
-func saveContentLayer(ctx context.Context, localRepo local.LocalRepo, path string, mediaType constants.MediaType, ignore filesystem.IgnorePaths) (ocispec.Descriptor, *artifact.LayerInfo, error) {
+func saveContentLayer(ctx context.Context, localRepo local.LocalRepo, contextDir, path string, mediaType constants.MediaType, ignore filesystem.IgnorePaths) (ocispec.Descriptor, *artifact.LayerInfo, error) {
 	// We want to store a gzipped tar file in store, but to do so we need a descriptor, so we have to compress
 	// to a temporary file. Ideally, we'd also add this to the internal store by moving the file to avoid
 	// copying if possible.
-	tempPath, desc, info, err := compressLayer(path, mediaType, ignore)
+	tempPath, desc, info, err := compressLayer(ctx, contextDir, path, mediaType, ignore)
 	if err != nil {
 		return ocispec.DescriptorEmptyJSON, nil, err
 	}
 	defer func() {
 		if err := os.Remove(tempPath); err != nil && !errors.Is(err, os.ErrNotExist) {
-			output.Errorf("Failed to remove temporary file %s: %s", tempPath, err)
+			output.FromContext(ctx).Errorf("Failed to remove temporary file %s: %s", tempPath, err)
 		}
 	}()
 
 	if exists, err := localRepo.Exists(ctx, desc); err != nil {
 		return ocispec.DescriptorEmptyJSON, nil, err
 	} else if exists {
-		output.Infof("Already saved %s layer: %s", mediaType.BaseType, desc.Digest)
+		output.FromContext(ctx).WithFields(output.Fields{"digest": desc.Digest, "layerType": mediaType.BaseType, "bytes": desc.Size}).Infof("Already saved %s layer: %s", mediaType.BaseType, desc.Digest)
 		return desc, info, nil
 	}
 
@@ -190,7 +186,7 @@ func saveContentLayer(ctx context.Context, localRepo local.LocalRepo, path strin
 	if err := os.Rename(tempPath, blobPath); err != nil {
 		// This may fail on some systems (e.g. linux where / and /home are different partitions)
 		// Fallback to regular push which is basically a copy
-		output.Debugf("Failed to move temp file into storage (will copy instead): %s", err)
+		output.FromContext(ctx).Debugf("Failed to move temp file into storage (will copy instead): %s", err)
 		file, err := os.Open(tempPath)
 		if err != nil {
 			return ocispec.DescriptorEmptyJSON, nil, fmt.Errorf("failed to open temporary file: %w", err)
@@ -210,7 +206,7 @@ func saveContentLayer(ctx context.Context, localRepo local.LocalRepo, path strin
 		return ocispec.DescriptorEmptyJSON, nil, fmt.Errorf("failed to move layer to storage: file is not stored")
 	}
 
-	output.Infof("Saved %s layer: %s", mediaType.BaseType, desc.Digest)
+	output.FromContext(ctx).WithFields(output.Fields{"digest": desc.Digest, "layerType": mediaType.BaseType, "bytes": desc.Size}).Infof("Saved %s layer: %s", mediaType.BaseType, desc.Digest)
 	return desc, info, nil
 }
 
@@ -234,14 +230,14 @@ func saveModelManifest(ctx context.Context, store oras.Target, manifest ocispec.
 		if err != nil {
 			return nil, err
 		}
-		output.Infof("Saved manifest to storage: %s", desc.Digest)
+		output.FromContext(ctx).WithFields(output.Fields{"digest": desc.Digest, "bytes": desc.Size}).Infof("Saved manifest to storage: %s", desc.Digest)
 	} else {
-		output.Infof("Manifest already exists in storage: %s", desc.Digest)
+		output.FromContext(ctx).Infof("Manifest already exists in storage: %s", desc.Digest)
 	}
 	return &desc, nil
 }
 
-func createManifest(configDesc ocispec.Descriptor, layerDescs []ocispec.Descriptor) ocispec.Manifest {
+func createManifest(configDesc ocispec.Descriptor, layerDescs []ocispec.Descriptor, annotations map[string]string) ocispec.Manifest {
 	manifest := ocispec.Manifest{
 		Versioned: specs.Versioned{SchemaVersion: 2},
 		Config:    configDesc,
@@ -250,6 +246,9 @@ func createManifest(configDesc ocispec.Descriptor, layerDescs []ocispec.Descript
 			constants.CliVersionAnnotation: constants.Version,
 		},
 	}
+	for k, v := range annotations {
+		manifest.Annotations[k] = v
+	}
 
 	return manifest
 }
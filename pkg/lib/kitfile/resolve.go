@@ -22,8 +22,8 @@ import (
 	"strings"
 
 	"kitops/pkg/artifact"
-	"kitops/pkg/cmd/options"
 	"kitops/pkg/lib/constants"
+	"kitops/pkg/lib/network"
 	"kitops/pkg/lib/repo/local"
 	"kitops/pkg/lib/repo/remote"
 	"kitops/pkg/lib/repo/util"
@@ -51,7 +51,7 @@ func GetKitfileForRef(ctx context.Context, configHome string, ref *registry.Refe
 		return localKitfile, nil
 	}
 
-	repository, err := remote.NewRepository(ctx, ref.Registry, ref.Repository, options.DefaultNetworkOptions(configHome))
+	repository, err := remote.NewRepository(ctx, ref.Registry, ref.Repository, network.DefaultOptions(ctx, configHome))
 	if err != nil {
 		return nil, err
 	}
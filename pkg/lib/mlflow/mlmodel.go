@@ -0,0 +1,223 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package mlflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// MLmodelFileName is the name of the file that describes an MLflow model
+	MLmodelFileName = "MLmodel"
+	// RequirementsFileName is the name of the pip requirements file saved alongside MLflow models
+	RequirementsFileName = "requirements.txt"
+
+	pyfuncFlavor        = "python_function"
+	defaultCondaEnvFile = "conda.yaml"
+)
+
+// frameworkNames maps MLflow flavors to the name of the framework they correspond to
+var frameworkNames = map[string]string{
+	"catboost":              "CatBoost",
+	"diviner":               "Diviner",
+	"fastai":                "fastai",
+	"h2o":                   "H2O",
+	"keras":                 "Keras",
+	"langchain":             "LangChain",
+	"lightgbm":              "LightGBM",
+	"onnx":                  "ONNX",
+	"paddle":                "PaddlePaddle",
+	"pmdarima":              "pmdarima",
+	"prophet":               "Prophet",
+	"pytorch":               "PyTorch",
+	"sentence_transformers": "Sentence Transformers",
+	"sklearn":               "Scikit-learn",
+	"spacy":                 "spaCy",
+	"spark":                 "Spark MLlib",
+	"statsmodels":           "statsmodels",
+	"tensorflow":            "TensorFlow",
+	"transformers":          "Transformers",
+	"xgboost":               "XGBoost",
+	pyfuncFlavor:            "Python",
+}
+
+var windowsDriveRegexp = regexp.MustCompile(`^/[a-zA-Z]:/`)
+
+// MLModel is the contents of an MLflow MLmodel file
+type MLModel struct {
+	ArtifactPath          string                    `yaml:"artifact_path,omitempty"`
+	Flavors               map[string]map[string]any `yaml:"flavors"`
+	MLflowVersion         string                    `yaml:"mlflow_version,omitempty"`
+	ModelUUID             string                    `yaml:"model_uuid,omitempty"`
+	RunID                 string                    `yaml:"run_id,omitempty"`
+	UTCTimeCreated        string                    `yaml:"utc_time_created,omitempty"`
+	Signature             *Signature                `yaml:"signature,omitempty"`
+	SavedInputExampleInfo map[string]any            `yaml:"saved_input_example_info,omitempty"`
+	Metadata              map[string]any            `yaml:"metadata,omitempty"`
+}
+
+// Signature is an MLflow model signature. Inputs, outputs, and params are stored as JSON-encoded strings.
+type Signature struct {
+	Inputs  string `yaml:"inputs,omitempty"`
+	Outputs string `yaml:"outputs,omitempty"`
+	Params  string `yaml:"params,omitempty"`
+}
+
+// ReadMLModel reads the MLmodel file in modelDir.
+func ReadMLModel(modelDir string) (*MLModel, error) {
+	mlmodelPath := filepath.Join(modelDir, MLmodelFileName)
+	f, err := os.Open(mlmodelPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MLmodel file: %w", err)
+	}
+	defer f.Close()
+	mlmodel := &MLModel{}
+	if err := yaml.NewDecoder(f).Decode(mlmodel); err != nil {
+		return nil, fmt.Errorf("failed to parse MLmodel file %s: %w", mlmodelPath, err)
+	}
+	if len(mlmodel.Flavors) == 0 {
+		return nil, fmt.Errorf("MLmodel file %s does not define any flavors", mlmodelPath)
+	}
+	return mlmodel, nil
+}
+
+// Flavor returns the primary flavor of the model, i.e. the flavor used to save it. The generic python_function
+// flavor is only returned if the model has no other flavors.
+func (m *MLModel) Flavor() string {
+	var flavors []string
+	for flavor := range m.Flavors {
+		if flavor != pyfuncFlavor {
+			flavors = append(flavors, flavor)
+		}
+	}
+	if len(flavors) == 0 {
+		return pyfuncFlavor
+	}
+	slices.Sort(flavors)
+	return flavors[0]
+}
+
+// Framework returns the name of the framework corresponding to the model's primary flavor.
+func (m *MLModel) Framework() string {
+	flavor := m.Flavor()
+	if name, ok := frameworkNames[flavor]; ok {
+		return name
+	}
+	return flavor
+}
+
+// CondaEnvFile returns the path of the conda environment file for the model, relative to the model directory.
+func (m *MLModel) CondaEnvFile() string {
+	switch env := m.Flavors[pyfuncFlavor]["env"].(type) {
+	case string:
+		// Older versions of MLflow store the conda environment file directly
+		return env
+	case map[string]any:
+		if conda, ok := env["conda"].(string); ok && conda != "" {
+			return conda
+		}
+	}
+	return defaultCondaEnvFile
+}
+
+// Parse decodes the inputs, outputs, and params in the signature. Fields that are not set are omitted.
+func (s *Signature) Parse() (map[string]any, error) {
+	result := map[string]any{}
+	fields := map[string]string{
+		"inputs":  s.Inputs,
+		"outputs": s.Outputs,
+		"params":  s.Params,
+	}
+	for name, value := range fields {
+		if value == "" || value == "null" {
+			continue
+		}
+		var parsed any
+		if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+			return nil, fmt.Errorf("failed to parse signature %s: %w", name, err)
+		}
+		result[name] = parsed
+	}
+	return result, nil
+}
+
+// LocalPath returns the filesystem path for an MLflow model or artifact location, which may be a local path
+// or a file:// URI. Other artifact stores are not supported.
+func LocalPath(location string) (string, error) {
+	u, err := url.Parse(location)
+	// Single-letter schemes are Windows drive letters (e.g. C:\models)
+	if err != nil || len(u.Scheme) <= 1 {
+		return location, nil
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported MLflow artifact location %s: only local paths and file:// URIs are supported", location)
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		return "", fmt.Errorf("unsupported MLflow artifact location %s: file:// URIs must refer to the local host", location)
+	}
+	path := u.Path
+	if windowsDriveRegexp.MatchString(path) {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path), nil
+}
+
+// FindModelDirs returns the directories under root that contain an MLmodel file, as slash-separated paths
+// relative to root. Directories inside a model directory are not searched.
+func FindModelDirs(root string) ([]string, error) {
+	var modelDirs []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if _, err := os.Stat(filepath.Join(path, MLmodelFileName)); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		modelDirs = append(modelDirs, filepath.ToSlash(relPath))
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for MLflow models in %s: %w", root, err)
+	}
+	return modelDirs, nil
+}
+
+// IsFileURI returns true if location is a file:// URI
+func IsFileURI(location string) bool {
+	return strings.HasPrefix(strings.ToLower(location), "file://")
+}
@@ -0,0 +1,144 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package mlflow
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMLmodel = `artifact_path: model
+flavors:
+  python_function:
+    env:
+      conda: env/conda.yaml
+      virtualenv: python_env.yaml
+    loader_module: mlflow.sklearn
+    model_path: model.pkl
+    python_version: 3.10.12
+  sklearn:
+    code: null
+    pickled_model: model.pkl
+    serialization_format: cloudpickle
+    sklearn_version: 1.3.0
+mlflow_version: 2.9.2
+model_uuid: 7c1a2f1e4a5e4f0c9f1e2d3c4b5a6978
+run_id: 3f2e1d0c9b8a7f6e5d4c3b2a1f0e9d8c
+saved_input_example_info:
+  artifact_path: input_example.json
+  pandas_orient: split
+  type: dataframe
+signature:
+  inputs: '[{"type": "double", "name": "sepal length (cm)", "required": true}]'
+  outputs: '[{"type": "tensor", "tensor-spec": {"dtype": "int64", "shape": [-1]}}]'
+  params: null
+utc_time_created: '2024-01-01 00:00:00.000000'
+`
+
+func TestReadMLModel(t *testing.T) {
+	modelDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(modelDir, MLmodelFileName), []byte(testMLmodel), 0644))
+
+	mlmodel, err := ReadMLModel(modelDir)
+	require.NoError(t, err)
+	assert.Equal(t, "sklearn", mlmodel.Flavor())
+	assert.Equal(t, "Scikit-learn", mlmodel.Framework())
+	assert.Equal(t, "env/conda.yaml", mlmodel.CondaEnvFile())
+	assert.Equal(t, "3f2e1d0c9b8a7f6e5d4c3b2a1f0e9d8c", mlmodel.RunID)
+
+	signature, err := mlmodel.Signature.Parse()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"inputs":  []any{map[string]any{"type": "double", "name": "sepal length (cm)", "required": true}},
+		"outputs": []any{map[string]any{"type": "tensor", "tensor-spec": map[string]any{"dtype": "int64", "shape": []any{float64(-1)}}}},
+	}, signature)
+}
+
+func TestFlavor(t *testing.T) {
+	tests := []struct {
+		name            string
+		flavors         map[string]map[string]any
+		expectFlavor    string
+		expectFramework string
+	}{
+		{
+			name:            "python function only",
+			flavors:         map[string]map[string]any{"python_function": {}},
+			expectFlavor:    "python_function",
+			expectFramework: "Python",
+		},
+		{
+			name:            "pytorch",
+			flavors:         map[string]map[string]any{"python_function": {}, "pytorch": {}},
+			expectFlavor:    "pytorch",
+			expectFramework: "PyTorch",
+		},
+		{
+			name:            "unknown flavor",
+			flavors:         map[string]map[string]any{"python_function": {}, "custom_flavor": {}},
+			expectFlavor:    "custom_flavor",
+			expectFramework: "custom_flavor",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mlmodel := &MLModel{Flavors: tt.flavors}
+			assert.Equal(t, tt.expectFlavor, mlmodel.Flavor())
+			assert.Equal(t, tt.expectFramework, mlmodel.Framework())
+			assert.Equal(t, "conda.yaml", mlmodel.CondaEnvFile())
+		})
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+	path, err := LocalPath("file:///tmp/mlruns/0/run/artifacts/model")
+	if assert.NoError(t, err) {
+		assert.Equal(t, filepath.FromSlash("/tmp/mlruns/0/run/artifacts/model"), path)
+	}
+	path, err = LocalPath("./mlruns/0/run/artifacts")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "./mlruns/0/run/artifacts", path)
+	}
+	if runtime.GOOS == "windows" {
+		path, err = LocalPath("file:///C:/mlruns/model")
+		if assert.NoError(t, err) {
+			assert.Equal(t, `C:\mlruns\model`, path)
+		}
+	}
+	_, err = LocalPath("runs:/3f2e1d0c9b8a/model")
+	assert.ErrorContains(t, err, "only local paths and file:// URIs are supported")
+	_, err = LocalPath("s3://bucket/mlruns/model")
+	assert.ErrorContains(t, err, "only local paths and file:// URIs are supported")
+}
+
+func TestFindModelDirs(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"model", "other/nested", "model/submodel"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, dir, MLmodelFileName), []byte(testMLmodel), 0644))
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "plots"), 0755))
+
+	modelDirs, err := FindModelDirs(root)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"model", "other/nested"}, modelDirs)
+}
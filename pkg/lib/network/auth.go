@@ -22,8 +22,8 @@ import (
 	"net/http"
 	"net/url"
 
-	"kitops/pkg/cmd/options"
 	"kitops/pkg/lib/constants"
+	"kitops/pkg/lib/telemetry"
 
 	"oras.land/oras-go/v2/registry/remote/auth"
 	"oras.land/oras-go/v2/registry/remote/credentials"
@@ -39,7 +39,7 @@ func NewCredentialStore(storePath string) (credentials.Store, error) {
 
 // ClientWithAuth returns a default *auth.Client using the provided credentials
 // store
-func ClientWithAuth(store credentials.Store, opts *options.NetworkOptions) (*auth.Client, error) {
+func ClientWithAuth(store credentials.Store, opts *Options) (*auth.Client, error) {
 	client, err := DefaultClient(opts)
 	if err != nil {
 		return nil, err
@@ -51,7 +51,7 @@ func ClientWithAuth(store credentials.Store, opts *options.NetworkOptions) (*aut
 
 // DefaultClient returns an *auth.Client with a default User-Agent header and TLS
 // configured from opts (optionally disabling TLS verification)
-func DefaultClient(opts *options.NetworkOptions) (*auth.Client, error) {
+func DefaultClient(opts *Options) (*auth.Client, error) {
 	transport := http.DefaultTransport.(*http.Transport).Clone()
 	transport.TLSClientConfig.InsecureSkipVerify = !opts.TLSVerify
 	if opts.Proxy != "" {
@@ -71,7 +71,7 @@ func DefaultClient(opts *options.NetworkOptions) (*auth.Client, error) {
 
 	client := &auth.Client{
 		Client: &http.Client{
-			Transport: retry.NewTransport(transport),
+			Transport: retry.NewTransport(telemetry.Transport(opts.RateLimiter.Transport(transport))),
 		},
 		Cache: auth.NewCache(),
 		Header: http.Header{
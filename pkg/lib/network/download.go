@@ -0,0 +1,285 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"kitops/pkg/output"
+)
+
+const (
+	// PartialFileSuffix is added to the path of files while they are downloaded; they are renamed once they
+	// are complete and verified.
+	PartialFileSuffix = ".partial"
+
+	maxDownloadAttempts = 5
+)
+
+// ErrDigestMismatch is returned when a downloaded file does not match its expected digest
+var ErrDigestMismatch = errors.New("digest mismatch")
+
+// DownloadVerifier verifies the contents of a file as it is downloaded.
+type DownloadVerifier interface {
+	// Write adds downloaded contents of the file
+	io.Writer
+	// Verify checks the contents written so far, returning an error wrapping ErrDigestMismatch if they do
+	// not match the expected digest
+	Verify() error
+	// Reset discards all contents written so far
+	Reset()
+}
+
+// FileDownload describes a file to download using DownloadFile.
+type FileDownload struct {
+	// URL and Header are used to request the file
+	URL    string
+	Header http.Header
+	// Name identifies the file in logs and errors
+	Name string
+	// DestPath is where the file is written to
+	DestPath string
+	// Size is the expected size of the file, or zero if it is not known
+	Size int64
+	// Verifier, if not nil, verifies the file's contents before it is moved to DestPath
+	Verifier DownloadVerifier
+	// RetryDelay is the delay before retrying a failed download. It is doubled for each subsequent retry.
+	RetryDelay time.Duration
+}
+
+// StatusError is returned when a download receives an unexpected HTTP status code
+type StatusError struct {
+	StatusCode int
+	Name       string
+	// RetryAfter is the delay requested by the server via the Retry-After header, if any
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("received status code %d when downloading file %s", e.StatusCode, e.Name)
+}
+
+// DownloadFile downloads a file to dl.DestPath. The file is downloaded to a partial file first, which is used to
+// resume the download if it is interrupted (including across separate calls using the same DestPath), and is
+// verified before being moved into place. Downloads that fail in a way that may succeed later are retried with
+// exponential backoff.
+func DownloadFile(
+	ctx context.Context,
+	client *http.Client,
+	dl *FileDownload,
+	progress *output.DownloadProgressBar,
+	plog *output.ProgressLogger) error {
+
+	delay := dl.RetryDelay
+	for attempt := 1; ; attempt++ {
+		err := downloadFile(ctx, client, dl, progress, plog)
+		if err == nil {
+			return nil
+		}
+		// Digest mismatches are not retried, as the file was downloaded completely and is unlikely to change
+		var statusErr *StatusError
+		if attempt >= maxDownloadAttempts || ctx.Err() != nil || errors.Is(err, ErrDigestMismatch) ||
+			(errors.As(err, &statusErr) && !RetryableStatus(statusErr.StatusCode)) {
+			return err
+		}
+		wait := delay
+		if statusErr != nil && statusErr.RetryAfter > 0 {
+			wait = statusErr.RetryAfter
+		}
+		plog.Logf(output.LogLevelWarn, "Failed to download file %s (attempt %d of %d): %s. Retrying in %s", dl.Name, attempt, maxDownloadAttempts, err, wait)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		delay = delay * 2
+	}
+}
+
+func downloadFile(
+	ctx context.Context,
+	client *http.Client,
+	dl *FileDownload,
+	progress *output.DownloadProgressBar,
+	plog *output.ProgressLogger) (err error) {
+
+	if err := os.MkdirAll(filepath.Dir(dl.DestPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	partialPath := dl.DestPath + PartialFileSuffix
+	f, err := os.OpenFile(partialPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer func() {
+		if f == nil {
+			return
+		}
+		if err := f.Close(); err != nil {
+			plog.Logf(output.LogLevelError, "Error closing file %s: %s", partialPath, err)
+		}
+	}()
+
+	// Resume from any previous partial download; the existing contents need to be included in the digest.
+	// The verifier may contain data from a previous attempt, so it is reset first.
+	if dl.Verifier != nil {
+		dl.Verifier.Reset()
+	}
+	offset, err := resumeOffset(f, dl)
+	if err != nil {
+		return err
+	}
+
+	if dl.Size <= 0 || offset < dl.Size {
+		plog.Debugf("Downloading from %s", dl.URL)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, dl.URL, nil)
+		if err != nil {
+			return fmt.Errorf("failed to resolve URL: %w", err)
+		}
+		for key, values := range dl.Header {
+			req.Header[key] = values
+		}
+		if offset > 0 {
+			plog.Debugf("Resuming download of %s from byte %d", dl.Name, offset)
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("error downloading file %s: %w", dl.Name, err)
+		}
+		defer func() {
+			if err := resp.Body.Close(); err != nil {
+				plog.Logf(output.LogLevelWarn, "Failed to close response body: %s", err)
+			}
+		}()
+
+		switch {
+		case resp.StatusCode == http.StatusPartialContent && offset > 0:
+			// Resuming download
+		case resp.StatusCode == http.StatusOK:
+			// Server does not support range requests (or we're starting from the beginning)
+			if offset > 0 {
+				plog.Debugf("Server does not support resuming downloads; restarting download of %s", dl.Name)
+				if err := restartPartialFile(f, dl.Verifier); err != nil {
+					return err
+				}
+				offset = 0
+			}
+		case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+			// Partial file is not consistent with the file on the server; start over on the next attempt
+			if err := restartPartialFile(f, dl.Verifier); err != nil {
+				return err
+			}
+			return fmt.Errorf("could not resume download of file %s", dl.Name)
+		default:
+			statusErr := &StatusError{StatusCode: resp.StatusCode, Name: dl.Name}
+			if retryAfter, ok := ParseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				statusErr.RetryAfter = retryAfter
+			}
+			return statusErr
+		}
+
+		contentRC := progress.TrackDownload(resp.Body, dl.Name, resp.ContentLength)
+		defer contentRC.Close()
+
+		var dest io.Writer = f
+		if dl.Verifier != nil {
+			dest = io.MultiWriter(f, dl.Verifier)
+		}
+		n, err := io.Copy(dest, contentRC)
+		if err != nil {
+			return fmt.Errorf("failed to write file: %w", err)
+		}
+		if resp.ContentLength > 0 && n != resp.ContentLength {
+			return fmt.Errorf("mismatched file size: expected %d but got %d", resp.ContentLength, n)
+		}
+		offset = offset + n
+	}
+
+	if dl.Size > 0 && offset != dl.Size {
+		if err := restartPartialFile(f, dl.Verifier); err != nil {
+			return err
+		}
+		return fmt.Errorf("mismatched file size for %s: expected %d but got %d", dl.Name, dl.Size, offset)
+	}
+	if dl.Verifier != nil {
+		if err := dl.Verifier.Verify(); err != nil {
+			if restartErr := restartPartialFile(f, dl.Verifier); restartErr != nil {
+				return restartErr
+			}
+			return fmt.Errorf("failed to verify file %s: %w", dl.Name, err)
+		}
+	}
+
+	closeErr := f.Close()
+	f = nil
+	if closeErr != nil {
+		return fmt.Errorf("failed to write file: %w", closeErr)
+	}
+	if err := os.Rename(partialPath, dl.DestPath); err != nil {
+		return fmt.Errorf("failed to move downloaded file into place: %w", err)
+	}
+	return nil
+}
+
+// resumeOffset returns the offset to resume downloading dl from, based on the current contents of the partial
+// file f. Existing contents are added to dl's verifier. If the partial file cannot be used (e.g. because it is
+// larger than expected), it is truncated and the download starts from the beginning.
+func resumeOffset(f *os.File, dl *FileDownload) (int64, error) {
+	stat, err := f.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat file: %w", err)
+	}
+	size := stat.Size()
+	if size == 0 {
+		return 0, nil
+	}
+	if dl.Size > 0 && size > dl.Size {
+		return 0, restartPartialFile(f, dl.Verifier)
+	}
+	if dl.Verifier != nil {
+		if _, err := io.Copy(dl.Verifier, io.LimitReader(f, size)); err != nil {
+			return 0, fmt.Errorf("failed to read partial file: %w", err)
+		}
+	}
+	if _, err := f.Seek(size, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to read partial file: %w", err)
+	}
+	return size, nil
+}
+
+// restartPartialFile truncates a partial file and resets verifier, if not nil, so that the file can be
+// downloaded from the beginning.
+func restartPartialFile(f *os.File, verifier DownloadVerifier) error {
+	if err := f.Truncate(0); err != nil {
+		return fmt.Errorf("failed to reset partial file: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to reset partial file: %w", err)
+	}
+	if verifier != nil {
+		verifier.Reset()
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package network
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"kitops/pkg/lib/config"
+	"kitops/pkg/lib/constants"
+	"kitops/pkg/lib/ratelimit"
+	"kitops/pkg/output"
+)
+
+// Options configures connections to remote registries. Commands fill it in from their flags
+// (see the cmd/options package) before calling CompleteWithConfigHome.
+type Options struct {
+	PlainHTTP         bool
+	TLSVerify         bool
+	CredentialsPath   string
+	ClientCertPath    string
+	ClientCertKeyPath string
+	Concurrency       int
+	// ParallelChunks is the number of byte ranges a single large blob is split into when
+	// downloading. Values less than 2 disable ranged downloads.
+	ParallelChunks int
+	Proxy          string
+	// LimitRate is the maximum combined transfer rate (e.g. "50MB/s"). If empty, the rate
+	// from the config file is used.
+	LimitRate string
+	// RateLimiter is shared by all clients created from these options so that the rate limit
+	// applies to the combined throughput of all concurrent transfers.
+	RateLimiter *ratelimit.Limiter
+	// Registries contains per-registry configuration (e.g. mirrors) read from the
+	// config file
+	Registries map[string]config.RegistryConfig
+}
+
+// CompleteWithConfigHome fills in credentials and config file settings from configHome and
+// validates the options.
+func (o *Options) CompleteWithConfigHome(configHome string) error {
+	o.CredentialsPath = constants.CredentialsPath(configHome)
+
+	cfg, err := config.LoadConfig(configHome)
+	if err != nil {
+		return err
+	}
+	o.Registries = cfg.Registries
+	if o.LimitRate == "" {
+		o.LimitRate = cfg.LimitRate
+	}
+	rateLimiter, err := ratelimit.NewFromString(o.LimitRate)
+	if err != nil {
+		return fmt.Errorf("invalid argument for limit-rate: %w", err)
+	}
+	o.RateLimiter = rateLimiter
+
+	if certPath := os.Getenv(constants.ClientCertEnvVar); certPath != "" {
+		o.ClientCertPath = certPath
+	}
+	if certKeyPath := os.Getenv(constants.ClientCertKeyEnvVar); certKeyPath != "" {
+		o.ClientCertKeyPath = certKeyPath
+	}
+	if o.Concurrency < 1 {
+		return fmt.Errorf("invalid argument for concurrency (%d): must be at least 1", o.Concurrency)
+	}
+
+	return nil
+}
+
+// DefaultOptions returns options for connecting to registries with TLS, using the credentials
+// and config file in configHome.
+func DefaultOptions(ctx context.Context, configHome string) *Options {
+	opts := &Options{
+		PlainHTTP:       false,
+		TLSVerify:       true,
+		CredentialsPath: constants.CredentialsPath(configHome),
+	}
+	if cfg, err := config.LoadConfig(configHome); err != nil {
+		output.FromContext(ctx).Logf(output.LogLevelWarn, "Ignoring config file: %s", err)
+	} else {
+		opts.Registries = cfg.Registries
+		if rateLimiter, err := ratelimit.NewFromString(cfg.LimitRate); err == nil {
+			opts.RateLimiter = rateLimiter
+		}
+	}
+	return opts
+}
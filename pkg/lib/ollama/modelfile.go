@@ -0,0 +1,139 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ollama
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Modelfile describes an Ollama Modelfile, which can be used to create a model using 'ollama create'.
+type Modelfile struct {
+	// From is the path to the model weights
+	From string
+	// Adapters are paths to (LoRA) adapters to apply to the model
+	Adapters []string
+	Template string
+	System   string
+	// Parameters are the model's default parameters. Values are strings, numbers, or booleans, or lists of
+	// these for parameters that can be specified multiple times (e.g. stop).
+	Parameters map[string]any
+	Licenses   []string
+	Messages   []Message
+}
+
+// Message is a message in the conversation history for a model
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// String formats m in the Modelfile format. An error is returned if any value cannot be represented in a
+// Modelfile.
+func (m *Modelfile) String() (string, error) {
+	sb := &strings.Builder{}
+	writeLine := func(command string, args ...string) {
+		sb.WriteString(command)
+		for _, arg := range args {
+			sb.WriteString(" ")
+			sb.WriteString(arg)
+		}
+		sb.WriteString("\n")
+	}
+
+	if m.From == "" {
+		return "", fmt.Errorf("modelfile must specify model weights")
+	}
+	writeLine("FROM", m.From)
+	for _, adapter := range m.Adapters {
+		writeLine("ADAPTER", adapter)
+	}
+	if m.Template != "" {
+		value, err := quote(m.Template)
+		if err != nil {
+			return "", fmt.Errorf("invalid template: %w", err)
+		}
+		writeLine("TEMPLATE", value)
+	}
+	if m.System != "" {
+		value, err := quote(m.System)
+		if err != nil {
+			return "", fmt.Errorf("invalid system prompt: %w", err)
+		}
+		writeLine("SYSTEM", value)
+	}
+	keys := make([]string, 0, len(m.Parameters))
+	for key := range m.Parameters {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		values, ok := m.Parameters[key].([]any)
+		if !ok {
+			values = []any{m.Parameters[key]}
+		}
+		for _, value := range values {
+			formatted, err := formatParameter(value)
+			if err != nil {
+				return "", fmt.Errorf("invalid value for parameter %s: %w", key, err)
+			}
+			writeLine("PARAMETER", key, formatted)
+		}
+	}
+	for _, license := range m.Licenses {
+		value, err := quote(license)
+		if err != nil {
+			return "", fmt.Errorf("invalid license: %w", err)
+		}
+		writeLine("LICENSE", value)
+	}
+	for _, message := range m.Messages {
+		value, err := quote(message.Content)
+		if err != nil {
+			return "", fmt.Errorf("invalid message: %w", err)
+		}
+		writeLine("MESSAGE", message.Role, value)
+	}
+	return sb.String(), nil
+}
+
+func formatParameter(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return quote(v)
+	case bool, int, int64, float64:
+		return fmt.Sprintf("%v", v), nil
+	default:
+		return "", fmt.Errorf("unsupported type %T", value)
+	}
+}
+
+// quote quotes a value for use in a Modelfile. Values that span multiple lines or contain quotes are enclosed
+// in triple quotes, which Modelfiles do not provide a way to escape.
+func quote(value string) (string, error) {
+	if strings.Contains(value, `"""`) {
+		return "", fmt.Errorf(`value cannot contain '"""'`)
+	}
+	if !strings.ContainsAny(value, "\"\n") {
+		return `"` + value + `"`, nil
+	}
+	if strings.HasSuffix(value, `"`) {
+		return "", fmt.Errorf("multi-line value cannot end with a quote")
+	}
+	return `"""` + value + `"""`, nil
+}
@@ -0,0 +1,194 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ollama
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	DefaultRegistry  = "registry.ollama.ai"
+	DefaultNamespace = "library"
+	DefaultTag       = "latest"
+
+	// ModelsDirEnvVar is the environment variable Ollama uses to configure where models are stored
+	ModelsDirEnvVar = "OLLAMA_MODELS"
+
+	MediaTypeModel     = "application/vnd.ollama.image.model"
+	MediaTypeAdapter   = "application/vnd.ollama.image.adapter"
+	MediaTypeProjector = "application/vnd.ollama.image.projector"
+	MediaTypeTemplate  = "application/vnd.ollama.image.template"
+	MediaTypeSystem    = "application/vnd.ollama.image.system"
+	MediaTypeParams    = "application/vnd.ollama.image.params"
+	MediaTypeMessages  = "application/vnd.ollama.image.messages"
+	MediaTypeLicense   = "application/vnd.ollama.image.license"
+
+	invalidModelNameFmt = "invalid Ollama model name %s: %s"
+)
+
+var (
+	digestRegexp   = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+	namePartRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+	hostPartRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9.-]*(:[0-9]+)?$`)
+)
+
+// Manifest is the manifest Ollama stores for each model in its models directory
+type Manifest struct {
+	SchemaVersion int     `json:"schemaVersion"`
+	MediaType     string  `json:"mediaType"`
+	Config        Layer   `json:"config"`
+	Layers        []Layer `json:"layers"`
+}
+
+// Layer is a blob referenced by an Ollama manifest
+type Layer struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+// ModelConfig is the subset of the config blob of an Ollama model that describes the model
+type ModelConfig struct {
+	ModelFormat   string   `json:"model_format"`
+	ModelFamily   string   `json:"model_family"`
+	ModelFamilies []string `json:"model_families"`
+	// ModelType is the parameter size of the model, e.g. 8.0B
+	ModelType string `json:"model_type"`
+	// FileType is the quantization of the model, e.g. Q4_K_M
+	FileType string `json:"file_type"`
+}
+
+// ModelName is the name of a model in Ollama, in the format [host/][namespace/]model[:tag]
+type ModelName struct {
+	Host      string
+	Namespace string
+	Model     string
+	Tag       string
+}
+
+// ParseModelName parses an Ollama model name, filling in defaults for the host, namespace, and tag if they
+// are not specified.
+func ParseModelName(name string) (ModelName, error) {
+	result := ModelName{
+		Host:      DefaultRegistry,
+		Namespace: DefaultNamespace,
+		Tag:       DefaultTag,
+	}
+	path := name
+	if idx := strings.LastIndex(name, ":"); idx > strings.LastIndex(name, "/") {
+		path = name[:idx]
+		result.Tag = name[idx+1:]
+	}
+	parts := strings.Split(path, "/")
+	switch len(parts) {
+	case 1:
+		result.Model = parts[0]
+	case 2:
+		result.Namespace, result.Model = parts[0], parts[1]
+	case 3:
+		result.Host, result.Namespace, result.Model = parts[0], parts[1], parts[2]
+	default:
+		return ModelName{}, fmt.Errorf(invalidModelNameFmt, name, "expected format [host/][namespace/]model[:tag]")
+	}
+	if !hostPartRegexp.MatchString(result.Host) {
+		return ModelName{}, fmt.Errorf(invalidModelNameFmt, name, "invalid host")
+	}
+	for _, part := range []string{result.Namespace, result.Model, result.Tag} {
+		if !namePartRegexp.MatchString(part) {
+			return ModelName{}, fmt.Errorf(invalidModelNameFmt, name, fmt.Sprintf("invalid name component '%s'", part))
+		}
+	}
+	return result, nil
+}
+
+// String returns the short form of the model name as displayed by Ollama, omitting the host and namespace if
+// they are the defaults.
+func (n ModelName) String() string {
+	switch {
+	case n.Host != DefaultRegistry:
+		return fmt.Sprintf("%s/%s/%s:%s", n.Host, n.Namespace, n.Model, n.Tag)
+	case n.Namespace != DefaultNamespace:
+		return fmt.Sprintf("%s/%s:%s", n.Namespace, n.Model, n.Tag)
+	default:
+		return fmt.Sprintf("%s:%s", n.Model, n.Tag)
+	}
+}
+
+// ModelsDir returns the directory where Ollama stores models, as configured by the OLLAMA_MODELS environment
+// variable (default ~/.ollama/models).
+func ModelsDir() (string, error) {
+	if dir := os.Getenv(ModelsDirEnvVar); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine Ollama models directory: %w", err)
+	}
+	return filepath.Join(home, ".ollama", "models"), nil
+}
+
+// BlobPath returns the path of the blob with the specified digest in modelsDir.
+func BlobPath(modelsDir, digest string) (string, error) {
+	if !digestRegexp.MatchString(digest) {
+		return "", fmt.Errorf("invalid digest %s", digest)
+	}
+	return filepath.Join(modelsDir, "blobs", BlobFileName(digest)), nil
+}
+
+// BlobFileName returns the name of the file Ollama uses to store the blob with the specified digest.
+func BlobFileName(digest string) string {
+	return strings.Replace(digest, ":", "-", 1)
+}
+
+// ReadManifest reads the manifest for the model name from modelsDir.
+func ReadManifest(modelsDir string, name ModelName) (*Manifest, error) {
+	manifestPath := filepath.Join(modelsDir, "manifests", name.Host, name.Namespace, name.Model, name.Tag)
+	manifestBytes, err := os.ReadFile(manifestPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("model %s not found in Ollama models directory %s", name, modelsDir)
+		}
+		return nil, fmt.Errorf("failed to read manifest for model %s: %w", name, err)
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(manifestBytes, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest for model %s: %w", name, err)
+	}
+	return manifest, nil
+}
+
+// ReadModelConfig reads the config blob referenced by manifest from modelsDir.
+func ReadModelConfig(modelsDir string, manifest *Manifest) (*ModelConfig, error) {
+	configPath, err := BlobPath(modelsDir, manifest.Config.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model config: %w", err)
+	}
+	configBytes, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model config: %w", err)
+	}
+	config := &ModelConfig{}
+	if err := json.Unmarshal(configBytes, config); err != nil {
+		return nil, fmt.Errorf("failed to parse model config: %w", err)
+	}
+	return config, nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ollama
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseModelName(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    ModelName
+		expectedStr string
+		expectErr   bool
+	}{
+		{
+			input:       "llama3.2",
+			expected:    ModelName{Host: DefaultRegistry, Namespace: DefaultNamespace, Model: "llama3.2", Tag: DefaultTag},
+			expectedStr: "llama3.2:latest",
+		},
+		{
+			input:       "llama3.2:1b",
+			expected:    ModelName{Host: DefaultRegistry, Namespace: DefaultNamespace, Model: "llama3.2", Tag: "1b"},
+			expectedStr: "llama3.2:1b",
+		},
+		{
+			input:       "myorg/mymodel:q4_K_M",
+			expected:    ModelName{Host: DefaultRegistry, Namespace: "myorg", Model: "mymodel", Tag: "q4_K_M"},
+			expectedStr: "myorg/mymodel:q4_K_M",
+		},
+		{
+			input:       "localhost:5000/myorg/mymodel",
+			expected:    ModelName{Host: "localhost:5000", Namespace: "myorg", Model: "mymodel", Tag: DefaultTag},
+			expectedStr: "localhost:5000/myorg/mymodel:latest",
+		},
+		{input: "a/b/c/d", expectErr: true},
+		{input: "../mymodel", expectErr: true},
+		{input: "mymodel:", expectErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			name, err := ParseModelName(tt.input)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.expected, name)
+				assert.Equal(t, tt.expectedStr, name.String())
+			}
+		})
+	}
+}
+
+func TestModelfileString(t *testing.T) {
+	modelfile := &Modelfile{
+		From:     "./blobs/sha256-1234",
+		Adapters: []string{"./blobs/sha256-5678"},
+		Template: "{{ .System }}\n{{ .Prompt }}",
+		System:   "You are a helpful assistant.",
+		Parameters: map[string]any{
+			"temperature": 0.7,
+			"stop":        []any{"<|eot_id|>", "<|end|>"},
+			"num_ctx":     float64(4096),
+		},
+		Licenses: []string{"MIT License\n\nCopyright"},
+		Messages: []Message{{Role: "user", Content: "Hello"}},
+	}
+	expected := `FROM ./blobs/sha256-1234
+ADAPTER ./blobs/sha256-5678
+TEMPLATE """{{ .System }}
+{{ .Prompt }}"""
+SYSTEM "You are a helpful assistant."
+PARAMETER num_ctx 4096
+PARAMETER stop "<|eot_id|>"
+PARAMETER stop "<|end|>"
+PARAMETER temperature 0.7
+LICENSE """MIT License
+
+Copyright"""
+MESSAGE user "Hello"
+`
+	actual, err := modelfile.String()
+	if assert.NoError(t, err) {
+		assert.Equal(t, expected, actual)
+	}
+
+	_, err = (&Modelfile{}).String()
+	assert.Error(t, err, "Modelfile without weights should be invalid")
+	_, err = (&Modelfile{From: "model.gguf", System: `Contains """ triple quotes`}).String()
+	assert.Error(t, err, "Values cannot contain triple quotes")
+}
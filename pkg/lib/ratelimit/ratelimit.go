@@ -0,0 +1,178 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/time/rate"
+)
+
+// maxBurst is the largest number of bytes that can be transferred at once without waiting
+// for the limiter.
+const maxBurst = 1 << 20
+
+var rateRegexp = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)\s*([a-zA-Z]*?)(?:/s)?$`)
+
+var rateUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   1 << 10,
+	"kib": 1 << 10,
+	"kb":  1000,
+	"m":   1 << 20,
+	"mib": 1 << 20,
+	"mb":  1000 * 1000,
+	"g":   1 << 30,
+	"gib": 1 << 30,
+	"gb":  1000 * 1000 * 1000,
+}
+
+// Limiter limits the combined throughput of all readers and HTTP transports created
+// from it to a fixed number of bytes per second. A nil *Limiter does not limit
+// throughput.
+type Limiter struct {
+	limiter *rate.Limiter
+}
+
+// New returns a Limiter that allows bytesPerSecond bytes per second. If bytesPerSecond is
+// not positive, nil is returned.
+func New(bytesPerSecond int64) *Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	burst := int(min(bytesPerSecond, maxBurst))
+	return &Limiter{
+		limiter: rate.NewLimiter(rate.Limit(bytesPerSecond), burst),
+	}
+}
+
+// NewFromString parses a rate string (see ParseRate) and returns a Limiter for it. If
+// limitRate is empty, nil is returned.
+func NewFromString(limitRate string) (*Limiter, error) {
+	if limitRate == "" {
+		return nil, nil
+	}
+	bytesPerSecond, err := ParseRate(limitRate)
+	if err != nil {
+		return nil, err
+	}
+	return New(bytesPerSecond), nil
+}
+
+// ParseRate parses a transfer rate such as '50MB/s', '512KiB/s', or '1G' into bytes per second.
+// Units KB, MB, and GB are decimal (powers of 1000) while K, KiB, M, MiB, G, and GiB are binary
+// (powers of 1024). A number without units is interpreted as bytes per second.
+func ParseRate(s string) (int64, error) {
+	matches := rateRegexp.FindStringSubmatch(strings.TrimSpace(s))
+	if matches == nil {
+		return 0, fmt.Errorf("invalid rate %q: expected a number with optional unit, e.g. 50MB/s", s)
+	}
+	value, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %q: %w", s, err)
+	}
+	multiplier, ok := rateUnits[strings.ToLower(matches[2])]
+	if !ok {
+		return 0, fmt.Errorf("invalid rate %q: unknown unit %s", s, matches[2])
+	}
+	bytesPerSecond := int64(value * multiplier)
+	if bytesPerSecond < 1 {
+		return 0, fmt.Errorf("invalid rate %q: must be at least 1 byte per second", s)
+	}
+	return bytesPerSecond, nil
+}
+
+// Reader returns an io.Reader that reads from r, waiting as necessary to stay within the limit.
+func (l *Limiter) Reader(ctx context.Context, r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &limitedReader{ctx: ctx, r: r, limiter: l.limiter}
+}
+
+// ReadCloser is the same as Reader, but preserves the Close method of rc.
+func (l *Limiter) ReadCloser(ctx context.Context, rc io.ReadCloser) io.ReadCloser {
+	if l == nil {
+		return rc
+	}
+	return &limitedReadCloser{
+		limitedReader: limitedReader{ctx: ctx, r: rc, limiter: l.limiter},
+		closer:        rc,
+	}
+}
+
+// Transport wraps an http.RoundTripper so that request and response bodies are limited.
+func (l *Limiter) Transport(rt http.RoundTripper) http.RoundTripper {
+	if l == nil {
+		return rt
+	}
+	return &limitedTransport{base: rt, limiter: l}
+}
+
+type limitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if burst := lr.limiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := lr.r.Read(p)
+	if n > 0 {
+		if waitErr := lr.limiter.WaitN(lr.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+type limitedReadCloser struct {
+	limitedReader
+	closer io.Closer
+}
+
+func (lrc *limitedReadCloser) Close() error {
+	return lrc.closer.Close()
+}
+
+type limitedTransport struct {
+	base    http.RoundTripper
+	limiter *Limiter
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if req.Body != nil && req.Body != http.NoBody {
+		req = req.Clone(ctx)
+		req.Body = t.limiter.ReadCloser(ctx, req.Body)
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = t.limiter.ReadCloser(ctx, resp.Body)
+	return resp, nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		input     string
+		expected  int64
+		expectErr bool
+	}{
+		{input: "1000", expected: 1000},
+		{input: "50MB/s", expected: 50 * 1000 * 1000},
+		{input: "50mb/s", expected: 50 * 1000 * 1000},
+		{input: "512KiB/s", expected: 512 * 1024},
+		{input: "512k", expected: 512 * 1024},
+		{input: "1.5G", expected: 1536 * 1024 * 1024},
+		{input: "2 GB/s", expected: 2 * 1000 * 1000 * 1000},
+		{input: "100B/s", expected: 100},
+		{input: "", expectErr: true},
+		{input: "fast", expectErr: true},
+		{input: "10XB/s", expectErr: true},
+		{input: "-5MB/s", expectErr: true},
+		{input: "0", expectErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			actual, err := ParseRate(tt.input)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestLimiterIsShared(t *testing.T) {
+	// With a rate of 100KiB/s and an initial burst of 100KiB, reading 300KiB across
+	// three readers should take at least two seconds in total regardless of concurrency.
+	const bytesPerSecond = 100 * 1024
+	limiter := New(bytesPerSecond)
+	ctx := context.Background()
+
+	start := time.Now()
+	done := make(chan error)
+	for i := 0; i < 3; i++ {
+		go func() {
+			r := limiter.Reader(ctx, bytes.NewReader(make([]byte, bytesPerSecond)))
+			_, err := io.Copy(io.Discard, r)
+			done <- err
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, <-done)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 1900*time.Millisecond)
+}
+
+func TestNilLimiter(t *testing.T) {
+	var limiter *Limiter
+	r := bytes.NewReader([]byte("test"))
+	assert.Same(t, r, limiter.Reader(context.Background(), r))
+}
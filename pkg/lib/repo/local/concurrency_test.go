@@ -0,0 +1,282 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package local
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"testing"
+
+	"kitops/pkg/lib/constants"
+	"kitops/pkg/lib/network"
+
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/memory"
+	"oras.land/oras-go/v2/content/oci"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry"
+)
+
+// pushTestModel pushes a minimal modelkit named name to target. All modelkits share one layer.
+func pushTestModel(ctx context.Context, target oras.Target, name string) (ocispec.Descriptor, error) {
+	pushBlob := func(mediaType string, blob []byte) (ocispec.Descriptor, error) {
+		desc := content.NewDescriptorFromBytes(mediaType, blob)
+		err := target.Push(ctx, desc, bytes.NewReader(blob))
+		if errors.Is(err, errdef.ErrAlreadyExists) {
+			err = nil
+		}
+		return desc, err
+	}
+	configDesc, err := pushBlob(constants.ModelConfigMediaType.String(), []byte(fmt.Sprintf(`{"package":{"name":%q}}`, name)))
+	if err != nil {
+		return ocispec.DescriptorEmptyJSON, err
+	}
+	sharedDesc, err := pushBlob("application/vnd.kitops.modelkit.dataset.v1.tar", []byte("shared layer"))
+	if err != nil {
+		return ocispec.DescriptorEmptyJSON, err
+	}
+	uniqueDesc, err := pushBlob("application/vnd.kitops.modelkit.model.v1.tar", []byte("model "+name))
+	if err != nil {
+		return ocispec.DescriptorEmptyJSON, err
+	}
+	manifest := ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    configDesc,
+		Layers:    []ocispec.Descriptor{sharedDesc, uniqueDesc},
+	}
+	manifestBytes, err := json.Marshal(manifest)
+	if err != nil {
+		return ocispec.DescriptorEmptyJSON, err
+	}
+	return pushBlob(ocispec.MediaTypeImageManifest, manifestBytes)
+}
+
+// TestConcurrentStorageOperations runs pack, pull and tag operations in parallel against
+// the same local storage. Each operation opens its own LocalRepo, as separate kit processes
+// would, and no tags or manifests should be lost.
+func TestConcurrentStorageOperations(t *testing.T) {
+	const workers = 10
+	ctx := context.Background()
+	storagePath := t.TempDir()
+	modelRef := &registry.Reference{Registry: "example.com", Repository: "test/model"}
+
+	remote := memory.New()
+	for i := range workers {
+		desc, err := pushTestModel(ctx, remote, fmt.Sprintf("remote-%d", i))
+		require.NoError(t, err)
+		require.NoError(t, remote.Tag(ctx, desc, fmt.Sprintf("pulled-%d", i)))
+	}
+	baseRepo, err := NewLocalRepo(storagePath, modelRef)
+	require.NoError(t, err)
+	baseDesc, err := pushTestModel(ctx, baseRepo, "base")
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*3)
+	run := func(op func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := op(); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	for i := range workers {
+		run(func() error {
+			repo, err := NewLocalRepo(storagePath, modelRef)
+			if err != nil {
+				return err
+			}
+			desc, err := pushTestModel(ctx, repo, fmt.Sprintf("packed-%d", i))
+			if err != nil {
+				return err
+			}
+			return repo.Tag(ctx, desc, fmt.Sprintf("packed-%d", i))
+		})
+		run(func() error {
+			repo, err := NewLocalRepo(storagePath, modelRef)
+			if err != nil {
+				return err
+			}
+			ref := *modelRef
+			ref.Reference = fmt.Sprintf("pulled-%d", i)
+			_, err = repo.PullModel(ctx, remote, ref, nil, &network.Options{Concurrency: 2})
+			return err
+		})
+		run(func() error {
+			repo, err := NewLocalRepo(storagePath, modelRef)
+			if err != nil {
+				return err
+			}
+			return repo.Tag(ctx, baseDesc, fmt.Sprintf("tagged-%d", i))
+		})
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	repo, err := NewLocalRepo(storagePath, modelRef)
+	require.NoError(t, err)
+	assert.Len(t, repo.GetAllModels(), 2*workers+1)
+	assert.Len(t, repo.GetTags(baseDesc), workers)
+	sharedIndex, err := oci.New(storagePath)
+	require.NoError(t, err)
+	for _, prefix := range []string{"packed", "pulled", "tagged"} {
+		for i := range workers {
+			tag := fmt.Sprintf("%s-%d", prefix, i)
+			desc, err := repo.Resolve(ctx, tag)
+			if !assert.NoError(t, err, "tag %s should exist", tag) {
+				continue
+			}
+			_, err = sharedIndex.Resolve(ctx, desc.Digest.String())
+			assert.NoError(t, err, "manifest for %s should be in shared index", tag)
+		}
+	}
+}
+
+// TestConcurrentStorageProcesses re-executes the test binary as several processes that push
+// and tag modelkits in the same local storage. Each process must see every other process's
+// changes when it saves index.json, and no process may read a partially-written index.
+func TestConcurrentStorageProcesses(t *testing.T) {
+	const workers = 5
+	ctx := context.Background()
+	modelRef := &registry.Reference{Registry: "example.com", Repository: "test/model"}
+
+	if storagePath := os.Getenv("KIT_TEST_STORAGE_PATH"); storagePath != "" {
+		worker := os.Getenv("KIT_TEST_STORAGE_WORKER")
+		for i := range 5 {
+			repo, err := NewLocalRepo(storagePath, modelRef)
+			require.NoError(t, err)
+			name := fmt.Sprintf("process-%s-%d", worker, i)
+			desc, err := pushTestModel(ctx, repo, name)
+			require.NoError(t, err)
+			require.NoError(t, repo.Tag(ctx, desc, name))
+		}
+		return
+	}
+
+	storagePath := t.TempDir()
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := range workers {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestConcurrentStorageProcesses$")
+		cmd.Env = append(os.Environ(),
+			"KIT_TEST_STORAGE_PATH="+storagePath,
+			"KIT_TEST_STORAGE_WORKER="+strconv.Itoa(i))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if output, err := cmd.CombinedOutput(); err != nil {
+				errs <- fmt.Errorf("worker %d failed: %w\n%s", i, err, output)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	repo, err := NewLocalRepo(storagePath, modelRef)
+	require.NoError(t, err)
+	assert.Len(t, repo.GetAllModels(), workers*5)
+	sharedIndex, err := oci.New(storagePath)
+	require.NoError(t, err)
+	for i := range workers {
+		for j := range 5 {
+			tag := fmt.Sprintf("process-%d-%d", i, j)
+			desc, err := repo.Resolve(ctx, tag)
+			if !assert.NoError(t, err, "tag %s should exist", tag) {
+				continue
+			}
+			_, err = sharedIndex.Resolve(ctx, desc.Digest.String())
+			assert.NoError(t, err, "manifest for %s should be in shared index", tag)
+		}
+	}
+	assert.NoFileExists(t, indexBackupPath(storagePath))
+}
+
+func TestRecoverInterruptedIndexSave(t *testing.T) {
+	ctx := context.Background()
+	modelRef := &registry.Reference{Registry: "example.com", Repository: "test/model"}
+
+	tests := []struct {
+		name         string
+		indexContent func(saved []byte) []byte
+		wantIndex    func(saved []byte) []byte
+	}{
+		{
+			name:         "restores backup if index was partially written",
+			indexContent: func(saved []byte) []byte { return saved[:len(saved)/2] },
+			wantIndex:    func(saved []byte) []byte { return saved },
+		},
+		{
+			name:         "restores backup if index was not written",
+			indexContent: func(saved []byte) []byte { return nil },
+			wantIndex:    func(saved []byte) []byte { return saved },
+		},
+		{
+			name:         "keeps index if it was written completely",
+			indexContent: func(saved []byte) []byte { return []byte(`{"schemaVersion":2,"manifests":[]}`) },
+			wantIndex:    func(saved []byte) []byte { return []byte(`{"schemaVersion":2,"manifests":[]}`) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storagePath := t.TempDir()
+			repo, err := NewLocalRepo(storagePath, modelRef)
+			require.NoError(t, err)
+			desc, err := pushTestModel(ctx, repo, "model")
+			require.NoError(t, err)
+			indexPath := constants.IndexJsonPath(storagePath)
+			saved, err := os.ReadFile(indexPath)
+			require.NoError(t, err)
+
+			// Simulate kit being interrupted while saving index.json
+			require.NoError(t, os.Rename(indexPath, indexBackupPath(storagePath)))
+			if content := tt.indexContent(saved); content != nil {
+				require.NoError(t, os.WriteFile(indexPath, content, 0666))
+			}
+
+			repo, err = NewLocalRepo(storagePath, modelRef)
+			require.NoError(t, err)
+			assert.Len(t, repo.GetAllModels(), 1)
+			indexBytes, err := os.ReadFile(indexPath)
+			require.NoError(t, err)
+			assert.Equal(t, string(tt.wantIndex(saved)), string(indexBytes))
+			assert.NoFileExists(t, indexBackupPath(storagePath))
+			_, err = repo.Fetch(ctx, desc)
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package local
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"kitops/pkg/lib/constants"
+
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content/oci"
+)
+
+// errLocked is returned by tryLockFile if the file is already locked
+var errLocked = errors.New("file is locked")
+
+// lockStorage acquires an advisory lock on local storage at storagePath, blocking until it is
+// available. An exclusive lock is required to modify index files; a shared lock is sufficient
+// to read them. The lock is held until the returned function is called.
+//
+// Locks are held on an open file, so separate calls conflict with each other even within the
+// same process. If a previous process was interrupted while saving index.json, the lock is
+// upgraded to an exclusive one so that the index can be recovered (see saveIndex).
+func lockStorage(storagePath string, exclusive bool) (unlock func(), err error) {
+	if err := os.MkdirAll(storagePath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	if _, err := os.Stat(indexBackupPath(storagePath)); err == nil {
+		exclusive = true
+	}
+	lockPath := constants.StorageLockPath(storagePath)
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file %s: %w", lockPath, err)
+	}
+	if err := lockFile(f, exclusive); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock local storage: %w", err)
+	}
+	unlock = func() {
+		_ = unlockFile(f)
+		f.Close()
+	}
+	if exclusive {
+		if err := recoverIndex(storagePath); err != nil {
+			unlock()
+			return nil, err
+		}
+	} else if _, err := os.Stat(indexBackupPath(storagePath)); err == nil {
+		// Another process was interrupted after we checked above; retry to recover the index
+		unlock()
+		return lockStorage(storagePath, true)
+	}
+	return unlock, nil
+}
+
+// LockStorage acquires an exclusive lock on local storage at storagePath, blocking until it is
+// available. It can be used to serialize updates to files shared by kit processes that are
+// stored outside the OCI layout (e.g. in the cache directory). The lock is held until the
+// returned function is called.
+func LockStorage(storagePath string) (unlock func(), err error) {
+	return lockStorage(storagePath, true)
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory as path and
+// renames it into place, so that readers never see a partially-written file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) (err error) {
+	dir, base := filepath.Split(path)
+	tmpFile, err := os.CreateTemp(dir, "."+base+"-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+	defer func() {
+		if err != nil {
+			os.Remove(tmpPath)
+		}
+	}()
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// indexBackupPath returns the path the OCI index.json in storagePath is moved to while it
+// is being saved.
+func indexBackupPath(storagePath string) string {
+	return constants.IndexJsonPath(storagePath) + ".old"
+}
+
+// saveIndex writes the index.json for store, which must have been opened on storagePath with
+// AutoSaveIndex disabled. The OCI store rewrites index.json in place, so the existing index is
+// moved aside first and only removed once the new one has been written and synced; if kit is
+// interrupted before then, recoverIndex restores it. Local storage must be locked exclusively.
+func saveIndex(storagePath string, store *oci.Store) error {
+	indexPath := constants.IndexJsonPath(storagePath)
+	backupPath := indexBackupPath(storagePath)
+	if err := os.Rename(indexPath, backupPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to back up index: %w", err)
+	}
+	if err := store.SaveIndex(); err != nil {
+		if restoreErr := os.Rename(backupPath, indexPath); restoreErr != nil && !errors.Is(restoreErr, fs.ErrNotExist) {
+			return errors.Join(fmt.Errorf("failed to save index: %w", err), fmt.Errorf("failed to restore index: %w", restoreErr))
+		}
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+	f, err := os.Open(indexPath)
+	if err != nil {
+		return fmt.Errorf("failed to open saved index: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync saved index: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to sync saved index: %w", err)
+	}
+	if err := os.Remove(backupPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove index backup: %w", err)
+	}
+	return nil
+}
+
+// recoverIndex restores the index.json backed up by an interrupted call to saveIndex. If the
+// new index was written completely, it is kept and the backup is removed instead. Local
+// storage must be locked exclusively.
+func recoverIndex(storagePath string) error {
+	indexPath := constants.IndexJsonPath(storagePath)
+	backupPath := indexBackupPath(storagePath)
+	if _, err := os.Stat(backupPath); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to check for index backup: %w", err)
+	}
+	if indexBytes, err := os.ReadFile(indexPath); err == nil && json.Valid(indexBytes) {
+		if err := os.Remove(backupPath); err != nil {
+			return fmt.Errorf("failed to remove index backup: %w", err)
+		}
+		return nil
+	}
+	if err := os.Rename(backupPath, indexPath); err != nil {
+		return fmt.Errorf("failed to restore index from backup: %w", err)
+	}
+	return nil
+}
+
+// initStorage creates the OCI layout and an empty index.json in storagePath if they do not
+// exist, so that opening an OCI store never has to write them in place. Local storage must be
+// locked exclusively.
+func initStorage(storagePath string) error {
+	layoutPath := filepath.Join(storagePath, ocispec.ImageLayoutFile)
+	if _, err := os.Stat(layoutPath); errors.Is(err, fs.ErrNotExist) {
+		layoutBytes, err := json.Marshal(ocispec.ImageLayout{Version: ocispec.ImageLayoutVersion})
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(layoutPath, layoutBytes, 0666); err != nil {
+			return fmt.Errorf("failed to write OCI layout: %w", err)
+		}
+	}
+	indexPath := constants.IndexJsonPath(storagePath)
+	if _, err := os.Stat(indexPath); errors.Is(err, fs.ErrNotExist) {
+		index := ocispec.Index{
+			Versioned: specs.Versioned{SchemaVersion: 2},
+			Manifests: []ocispec.Descriptor{},
+		}
+		indexBytes, err := json.Marshal(index)
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(indexPath, indexBytes, 0666); err != nil {
+			return fmt.Errorf("failed to write index: %w", err)
+		}
+	}
+	return nil
+}
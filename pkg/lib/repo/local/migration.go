@@ -44,7 +44,8 @@ func MigrateStorage(ctx context.Context, baseStoragePath string) error {
 	if err != nil {
 		return fmt.Errorf("failed to migrate local storage: %w", err)
 	}
-	pb := output.GenericProgressBar("Migrating", "Migration done!", int64(len(localStores)))
+	logger := output.FromContext(ctx)
+	pb := logger.GenericProgressBar("Migrating", "Migration done!", int64(len(localStores)))
 	for _, localStore := range localStores {
 		repoName := localStore.GetRepo()
 		localRepo, err := newLocalRepoForName(baseStoragePath, repoName)
@@ -61,7 +62,7 @@ func MigrateStorage(ctx context.Context, baseStoragePath string) error {
 				tagOrDigest = tag
 			}
 
-			output.Debugf("Migrating model %s with reference %s to new storage", repoName, tagOrDigest)
+			logger.Debugf("Migrating model %s with reference %s to new storage", repoName, tagOrDigest)
 			_, err := oras.Copy(ctx, localStore, tagOrDigest, localRepo, tagOrDigest, oras.DefaultCopyOptions)
 			if err != nil {
 				return fmt.Errorf("failed to migrate modelkit %s:%s: %w", repoName, tagOrDigest, err)
@@ -88,11 +89,11 @@ func MigrateStorage(ctx context.Context, baseStoragePath string) error {
 		storeRepo := localStore.GetRepo()
 		baseSubDir := strings.Split(storeRepo, "/")[0]
 		rmDir := filepath.Join(baseStoragePath, baseSubDir)
-		output.Debugf("Removing storage directory %s", rmDir)
+		logger.Debugf("Removing storage directory %s", rmDir)
 		if err := os.RemoveAll(rmDir); err != nil && !errors.Is(err, fs.ErrNotExist) {
 			return fmt.Errorf("failed to clean up directory %s after migration: %s", rmDir, err)
 		}
 	}
-	output.Debugf("Migration done!")
+	logger.Debugf("Migration done!")
 	return nil
 }
@@ -0,0 +1,90 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/oci"
+)
+
+// MissingBlobs returns the blobs in blobs that are not present in store. ModelKits pulled
+// with filters only store some of their layers; the presence of each blob in local storage
+// determines which layers are available and which need to be fetched from a registry.
+func MissingBlobs(ctx context.Context, store content.ReadOnlyStorage, blobs []ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+	var missing []ocispec.Descriptor
+	for _, blob := range blobs {
+		exists, err := store.Exists(ctx, blob)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check local storage for %s: %w", blob.Digest, err)
+		}
+		if !exists {
+			missing = append(missing, blob)
+		}
+	}
+	return missing, nil
+}
+
+// deletePartialManifest deletes a manifest that may be missing some of its blobs. The OCI
+// store's garbage collection fails when it encounters a missing blob, so it is disabled and
+// blobs that are no longer referenced by any other manifest are deleted manually. Returns false
+// if the manifest is not partial, in which case nothing is deleted.
+func deletePartialManifest(ctx context.Context, store *oci.Store, desc ocispec.Descriptor) (bool, error) {
+	manifestBytes, err := content.FetchAll(ctx, store, desc)
+	if err != nil {
+		return false, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	manifest := &ocispec.Manifest{}
+	if err := json.Unmarshal(manifestBytes, manifest); err != nil {
+		return false, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	blobs := append([]ocispec.Descriptor{manifest.Config}, manifest.Layers...)
+	missing, err := MissingBlobs(ctx, store, blobs)
+	if err != nil {
+		return false, err
+	}
+	if len(missing) == 0 {
+		return false, nil
+	}
+
+	store.AutoGC = false
+	if err := store.Delete(ctx, desc); err != nil {
+		return false, err
+	}
+	for _, blob := range blobs {
+		if exists, err := store.Exists(ctx, blob); err != nil {
+			return false, err
+		} else if !exists {
+			continue
+		}
+		predecessors, err := store.Predecessors(ctx, blob)
+		if err != nil {
+			return false, err
+		}
+		if len(predecessors) > 0 {
+			continue
+		}
+		if err := store.Delete(ctx, blob); err != nil {
+			return false, fmt.Errorf("failed to delete blob %s: %w", blob.Digest, err)
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package local
+
+import (
+	"context"
+	"testing"
+
+	"kitops/pkg/lib/network"
+	"kitops/pkg/lib/repo/util"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/v2/content/memory"
+	"oras.land/oras-go/v2/registry"
+)
+
+// TestPartialPull pulls modelkits with only some of their layers and checks that missing
+// layers are reported, can be pulled later, and do not prevent deleting the modelkit.
+func TestPartialPull(t *testing.T) {
+	ctx := context.Background()
+	storagePath := t.TempDir()
+	modelRef := &registry.Reference{Registry: "example.com", Repository: "test/model"}
+	network := &network.Options{Concurrency: 2}
+
+	remote := memory.New()
+	for _, name := range []string{"a", "b"} {
+		desc, err := pushTestModel(ctx, remote, name)
+		require.NoError(t, err)
+		require.NoError(t, remote.Tag(ctx, desc, name))
+	}
+	repo, err := NewLocalRepo(storagePath, modelRef)
+	require.NoError(t, err)
+	pull := func(tag string, layers []ocispec.Descriptor) *ocispec.Manifest {
+		ref := *modelRef
+		ref.Reference = tag
+		desc, err := repo.PullModel(ctx, remote, ref, layers, network)
+		require.NoError(t, err)
+		manifest, err := util.GetManifest(ctx, repo, desc)
+		require.NoError(t, err)
+		return manifest
+	}
+
+	// Layers are [shared dataset, unique model]; pull only the model layer of a
+	manifestA := pull("a", manifestLayers(t, remote, "a")[1:])
+	missing, err := MissingBlobs(ctx, repo, manifestA.Layers)
+	require.NoError(t, err)
+	assert.Equal(t, manifestA.Layers[:1], missing)
+
+	// Deleting a partial modelkit removes the blobs that are present
+	descA, err := repo.Resolve(ctx, "a")
+	require.NoError(t, err)
+	require.NoError(t, repo.Delete(ctx, descA))
+	missing, err = MissingBlobs(ctx, repo, []ocispec.Descriptor{manifestA.Config, manifestA.Layers[1]})
+	require.NoError(t, err)
+	assert.Len(t, missing, 2, "blobs of a should be deleted")
+
+	// Pulling again without filters fetches the missing layers
+	manifestB := pull("b", manifestLayers(t, remote, "b")[1:])
+	missing, err = MissingBlobs(ctx, repo, manifestB.Layers)
+	require.NoError(t, err)
+	assert.Equal(t, manifestB.Layers[:1], missing)
+	pull("b", nil)
+	missing, err = MissingBlobs(ctx, repo, manifestB.Layers)
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+}
+
+func manifestLayers(t *testing.T, target *memory.Store, reference string) []ocispec.Descriptor {
+	t.Helper()
+	ctx := context.Background()
+	desc, err := target.Resolve(ctx, reference)
+	require.NoError(t, err)
+	manifest, err := util.GetManifest(ctx, target, desc)
+	require.NoError(t, err)
+	return manifest.Layers
+}
@@ -0,0 +1,322 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"kitops/pkg/lib/constants"
+	"kitops/pkg/output"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"golang.org/x/sync/errgroup"
+	"oras.land/oras-go/v2"
+)
+
+// minDownloadChunkSize is the smallest byte range that will be downloaded as a separate
+// chunk. Blobs smaller than twice this size are always downloaded in one request.
+const minDownloadChunkSize int64 = 64 << 20
+
+// numDownloadChunks returns the number of byte ranges a blob of the given size should be
+// split into, given the maximum number of parallel chunks requested.
+func numDownloadChunks(size int64, maxChunks int) int {
+	if maxChunks < 2 {
+		return 1
+	}
+	numChunks := size / minDownloadChunkSize
+	if numChunks < 1 {
+		return 1
+	}
+	if numChunks > int64(maxChunks) {
+		return maxChunks
+	}
+	return int(numChunks)
+}
+
+// errRangesNotSupported is returned by downloadFileChunked if byte ranges of a blob cannot be
+// fetched from the remote. Nothing has been written to the ingest file when it is returned.
+var errRangesNotSupported = errors.New("remote does not support range requests")
+
+// chunkedDownloadState records which byte ranges of a chunked download have been written to the
+// ingest file, so that an interrupted download only needs to fetch the missing ranges. It is
+// stored next to the ingest file.
+type chunkedDownloadState struct {
+	NumChunks int   `json:"numChunks"`
+	Completed []int `json:"completed"`
+	path      string
+	mu        sync.Mutex
+}
+
+// downloadChunk is a byte range of a blob downloaded as part of a chunked download
+type downloadChunk struct {
+	index  int
+	start  int64
+	length int64
+}
+
+func chunkStatePath(ingestFilename string) string {
+	return ingestFilename + ".chunks"
+}
+
+// loadChunkedDownloadState reads the state of a chunked download from path. If the state cannot
+// be read, nil is returned.
+func loadChunkedDownloadState(path string) *chunkedDownloadState {
+	stateBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	state := &chunkedDownloadState{}
+	if err := json.Unmarshal(stateBytes, state); err != nil || state.NumChunks < 1 {
+		return nil
+	}
+	state.path = path
+	return state
+}
+
+// chunks splits a blob of the given size into byte ranges, returning those that have not been
+// downloaded yet. The last range includes any remainder left by dividing size evenly.
+func (s *chunkedDownloadState) chunks(size int64) (missing []downloadChunk, completedBytes int64) {
+	chunkSize := size / int64(s.NumChunks)
+	for i := 0; i < s.NumChunks; i++ {
+		chunk := downloadChunk{index: i, start: int64(i) * chunkSize, length: chunkSize}
+		if i == s.NumChunks-1 {
+			chunk.length = size - chunk.start
+		}
+		if slices.Contains(s.Completed, i) {
+			completedBytes += chunk.length
+		} else {
+			missing = append(missing, chunk)
+		}
+	}
+	return missing, completedBytes
+}
+
+// complete records chunk index as downloaded. Chunks may complete concurrently.
+func (s *chunkedDownloadState) complete(index int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Completed = append(s.Completed, index)
+	return s.save()
+}
+
+func (s *chunkedDownloadState) save() error {
+	stateBytes, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal download state: %w", err)
+	}
+	// Write to a temporary file first so that an interruption never leaves a truncated state file
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, stateBytes, 0644); err != nil {
+		return fmt.Errorf("failed to save download state: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to save download state: %w", err)
+	}
+	return nil
+}
+
+// downloadChunks returns the number of byte ranges desc should be downloaded in. An interrupted
+// chunked download is continued with its original number of chunks, and an interrupted sequential
+// download is continued sequentially, regardless of parallelChunks.
+func (l *localRepo) downloadChunks(desc ocispec.Descriptor, parallelChunks int) int {
+	ingestFilename := filepath.Join(constants.IngestPath(l.storagePath), desc.Digest.Encoded())
+	if state := loadChunkedDownloadState(chunkStatePath(ingestFilename)); state != nil {
+		return state.NumChunks
+	}
+	if stat, err := os.Stat(ingestFilename); err == nil && stat.Size() > 0 {
+		return 1
+	}
+	return numDownloadChunks(desc.Size, parallelChunks)
+}
+
+// downloadFileChunked downloads a blob by splitting it into numChunks byte ranges that are
+// fetched in parallel and written to their offsets in the ingest file. Completed ranges are
+// recorded in a state file next to the ingest file, so that if the download is interrupted, it
+// can be continued later by fetching only the missing ranges. Once all ranges are downloaded, the
+// file is verified against the descriptor's digest and moved into storage.
+//
+// The first range is read from blob; other ranges are fetched from src and seeked to their start
+// offset. If src does not support range requests, errRangesNotSupported is returned and blob is
+// left open and unread so that the caller can download it sequentially instead.
+func (l *localRepo) downloadFileChunked(ctx context.Context, src oras.ReadOnlyTarget, desc ocispec.Descriptor, blob io.ReadSeekCloser, numChunks int, p *output.PullProgress) (ingestErr error) {
+	ingestDir := constants.IngestPath(l.storagePath)
+	ingestFilename := filepath.Join(ingestDir, desc.Digest.Encoded())
+	statePath := chunkStatePath(ingestFilename)
+	ingestFile, err := os.OpenFile(ingestFilename, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		blob.Close()
+		return fmt.Errorf("failed to open ingest file for writing: %w", err)
+	}
+	// The lock is released when the file is closed
+	if err := tryLockFile(ingestFile); err != nil {
+		ingestFile.Close()
+		if !errors.Is(err, errLocked) {
+			blob.Close()
+			return fmt.Errorf("failed to lock ingest file: %w", err)
+		}
+		// Another process is downloading the same blob; download a separate copy rather than waiting
+		p.Debugf("Download for digest %s is in progress elsewhere, downloading to a new file", desc.Digest.String())
+		return l.downloadFile(desc, blob, p)
+	}
+	defer func() {
+		if err := ingestFile.Close(); err != nil && !errors.Is(err, fs.ErrClosed) {
+			p.Logf(output.LogLevelError, "Error closing temporary ingest file: %s", err)
+		}
+	}()
+
+	state := loadChunkedDownloadState(statePath)
+	if stat, err := ingestFile.Stat(); err != nil {
+		blob.Close()
+		return fmt.Errorf("failed to stat ingest file: %w", err)
+	} else if state == nil || stat.Size() != desc.Size {
+		state = &chunkedDownloadState{NumChunks: numChunks, path: statePath}
+	} else {
+		p.Debugf("Resuming chunked download for digest %s", desc.Digest.String())
+	}
+	missing, completedBytes := state.chunks(desc.Size)
+
+	// Open every missing range before writing anything, so that if the remote does not support
+	// range requests, the caller can still fall back to downloading the blob sequentially.
+	readers := make([]io.ReadCloser, len(missing))
+	for idx, chunk := range missing {
+		if chunk.start == 0 {
+			readers[idx] = blob
+			continue
+		}
+		reader, err := openRange(ctx, src, desc, chunk.start)
+		if err != nil {
+			for _, r := range readers[:idx] {
+				if r != blob {
+					r.Close()
+				}
+			}
+			if errors.Is(err, errRangesNotSupported) {
+				// Any recorded progress cannot be used by a sequential download
+				os.Remove(statePath)
+				if err := ingestFile.Truncate(0); err != nil {
+					blob.Close()
+					return fmt.Errorf("failed to reset ingest file: %w", err)
+				}
+				return err
+			}
+			blob.Close()
+			return err
+		}
+		readers[idx] = reader
+	}
+	if len(missing) == 0 || missing[0].start != 0 {
+		blob.Close()
+	}
+
+	// Save the state before allocating the file, so that a partially written file is never
+	// mistaken for an interrupted sequential download
+	if err := state.save(); err != nil {
+		closeAll(readers)
+		return err
+	}
+	if err := ingestFile.Truncate(desc.Size); err != nil {
+		closeAll(readers)
+		return fmt.Errorf("failed to allocate ingest file: %w", err)
+	}
+
+	pwriter := p.ProxyWriter(io.Discard, desc.Digest.Encoded(), desc.Size, completedBytes)
+	// Ranges are not cancelled when one of them fails: any range that completes is recorded and
+	// does not need to be downloaded again when the download is retried.
+	var errs errgroup.Group
+	for idx, chunk := range missing {
+		reader := readers[idx]
+		errs.Go(func() error {
+			defer reader.Close()
+			start, end := chunk.start, chunk.start+chunk.length-1
+			p.Logf(output.LogLevelTrace, "Downloading range %d-%d of %s", start, end, desc.Digest)
+			chunkWriter := io.MultiWriter(io.NewOffsetWriter(ingestFile, start), pwriter)
+			if n, err := io.CopyN(chunkWriter, reader, chunk.length); err != nil {
+				return fmt.Errorf("failed to write range %d-%d (got %d bytes): %w", start, end, n, err)
+			}
+			if err := ingestFile.Sync(); err != nil {
+				return fmt.Errorf("failed to write range %d-%d: %w", start, end, err)
+			}
+			return state.complete(chunk.index)
+		})
+	}
+	if err := errs.Wait(); err != nil {
+		return err
+	}
+
+	if _, err := ingestFile.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read downloaded file: %w", err)
+	}
+	verifier := desc.Digest.Verifier()
+	if _, err := io.Copy(verifier, ingestFile); err != nil {
+		return fmt.Errorf("failed to verify downloaded file: %w", err)
+	}
+	if !verifier.Verified() {
+		// The downloaded ranges cannot be trusted, so the download has to start over
+		os.Remove(statePath)
+		ingestFile.Truncate(0)
+		return fmt.Errorf("downloaded file hash does not match descriptor")
+	}
+	if err := ingestFile.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary ingest file: %w", err)
+	}
+
+	blobPath := l.BlobPath(desc)
+	if err := os.Rename(ingestFilename, blobPath); err != nil {
+		return fmt.Errorf("failed to move downloaded file into storage: %w", err)
+	}
+	if err := os.Chmod(blobPath, 0600); err != nil {
+		return fmt.Errorf("failed to set permissions on blob: %w", err)
+	}
+	if err := os.Remove(statePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		p.Logf(output.LogLevelWarn, "Failed to remove download state file: %s", err)
+	}
+
+	return nil
+}
+
+// openRange fetches desc from src and seeks to offset start.
+func openRange(ctx context.Context, src oras.ReadOnlyTarget, desc ocispec.Descriptor, start int64) (io.ReadCloser, error) {
+	fetched, err := src.Fetch(ctx, desc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch range at offset %d: %w", start, err)
+	}
+	seekBlob, ok := fetched.(io.ReadSeekCloser)
+	if !ok {
+		fetched.Close()
+		return nil, errRangesNotSupported
+	}
+	if _, err := seekBlob.Seek(start, io.SeekStart); err != nil {
+		seekBlob.Close()
+		return nil, fmt.Errorf("%w: %w", errRangesNotSupported, err)
+	}
+	return seekBlob, nil
+}
+
+func closeAll(readers []io.ReadCloser) {
+	for _, r := range readers {
+		r.Close()
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package local
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"kitops/pkg/lib/constants"
+	"kitops/pkg/output"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/registry"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+func TestNumDownloadChunks(t *testing.T) {
+	tests := []struct {
+		name      string
+		size      int64
+		maxChunks int
+		expected  int
+	}{
+		{name: "chunked downloads disabled", size: 10 * minDownloadChunkSize, maxChunks: 1, expected: 1},
+		{name: "blob smaller than a chunk", size: minDownloadChunkSize - 1, maxChunks: 4, expected: 1},
+		{name: "blob smaller than two chunks", size: 2*minDownloadChunkSize - 1, maxChunks: 4, expected: 1},
+		{name: "exactly two chunks", size: 2 * minDownloadChunkSize, maxChunks: 4, expected: 2},
+		{name: "partial chunk is not split off", size: 3*minDownloadChunkSize + 1, maxChunks: 4, expected: 3},
+		{name: "limited by max chunks", size: 100 * minDownloadChunkSize, maxChunks: 4, expected: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, numDownloadChunks(tt.size, tt.maxChunks))
+		})
+	}
+}
+
+// rangeServer serves blobs from a registry's blob endpoint and records the ranges requested
+type rangeServer struct {
+	blobs map[string][]byte
+	// ignoreRange makes the server advertise range support but always return the whole blob
+	ignoreRange bool
+	// interruptRange makes the server send only part of the response for a request with this
+	// Range header, once
+	interruptRange string
+
+	mu     sync.Mutex
+	ranges []string
+}
+
+func (s *rangeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	blob, ok := s.blobs[r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	rangeHeader := r.Header.Get("Range")
+	interrupt := false
+	if rangeHeader != "" {
+		s.mu.Lock()
+		s.ranges = append(s.ranges, rangeHeader)
+		if rangeHeader == s.interruptRange {
+			interrupt = true
+			s.interruptRange = ""
+		}
+		s.mu.Unlock()
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Accept-Ranges", "bytes")
+	switch {
+	case s.ignoreRange:
+		w.Header().Set("Content-Length", fmt.Sprint(len(blob)))
+		w.Write(blob)
+	case interrupt:
+		var start int64
+		fmt.Sscanf(rangeHeader, "bytes=%d-", &start)
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(blob)-1, len(blob)))
+		w.Header().Set("Content-Length", fmt.Sprint(int64(len(blob))-start))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(blob[start : start+1])
+	default:
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(blob))
+	}
+}
+
+func (s *rangeServer) requestedRanges() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.ranges...)
+}
+
+func setupChunkedDownload(t *testing.T, srv *rangeServer) (*localRepo, *remote.Repository) {
+	server := httptest.NewServer(srv)
+	t.Cleanup(server.Close)
+	src, err := remote.NewRepository(strings.TrimPrefix(server.URL, "http://") + "/test/repo")
+	require.NoError(t, err)
+	src.PlainHTTP = true
+
+	repo, err := NewLocalRepo(t.TempDir(), &registry.Reference{Registry: "example.com", Repository: "test/repo"})
+	require.NoError(t, err)
+	l := repo.(*localRepo)
+	require.NoError(t, l.ensurePullDirs())
+	return l, src
+}
+
+func fetchSeekable(t *testing.T, src *remote.Repository, desc ocispec.Descriptor) io.ReadSeekCloser {
+	blob, err := src.Fetch(context.Background(), desc)
+	require.NoError(t, err)
+	seekBlob, ok := blob.(io.ReadSeekCloser)
+	require.True(t, ok, "remote should return a seekable blob")
+	return seekBlob
+}
+
+func TestDownloadFileChunked(t *testing.T) {
+	ctx := context.Background()
+	// 100 bytes in 3 chunks: ranges start at 0, 33 and 66, and the last range is 34 bytes long
+	blobContent := []byte(strings.Repeat("0123456789", 10))
+	desc := content.NewDescriptorFromBytes("application/octet-stream", blobContent)
+	p := output.FromContext(ctx).NewPullProgress(ctx)
+
+	t.Run("downloads ranges", func(t *testing.T) {
+		srv := &rangeServer{blobs: map[string][]byte{desc.Digest.String(): blobContent}}
+		l, src := setupChunkedDownload(t, srv)
+
+		err := l.downloadFileChunked(ctx, src, desc, fetchSeekable(t, src, desc), 3, p)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"bytes=33-99", "bytes=66-99"}, srv.requestedRanges())
+		stored, err := os.ReadFile(l.BlobPath(desc))
+		require.NoError(t, err)
+		assert.Equal(t, blobContent, stored)
+		ingestFilename := filepath.Join(constants.IngestPath(l.storagePath), desc.Digest.Encoded())
+		assert.NoFileExists(t, ingestFilename)
+		assert.NoFileExists(t, chunkStatePath(ingestFilename))
+	})
+
+	t.Run("resumes interrupted download", func(t *testing.T) {
+		srv := &rangeServer{blobs: map[string][]byte{desc.Digest.String(): blobContent}, interruptRange: "bytes=33-99"}
+		l, src := setupChunkedDownload(t, srv)
+
+		err := l.downloadFileChunked(ctx, src, desc, fetchSeekable(t, src, desc), 3, p)
+		require.Error(t, err)
+		ingestFilename := filepath.Join(constants.IngestPath(l.storagePath), desc.Digest.Encoded())
+		state := loadChunkedDownloadState(chunkStatePath(ingestFilename))
+		require.NotNil(t, state)
+		assert.ElementsMatch(t, []int{0, 2}, state.Completed)
+		assert.Equal(t, 3, l.downloadChunks(desc, 1), "interrupted download should continue in chunks")
+
+		// Continuing the download only requests the missing range
+		srv.ranges = nil
+		err = l.pullNode(ctx, src, desc, 1, p)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"bytes=33-99"}, srv.requestedRanges())
+		stored, err := os.ReadFile(l.BlobPath(desc))
+		require.NoError(t, err)
+		assert.Equal(t, blobContent, stored)
+		assert.NoFileExists(t, chunkStatePath(ingestFilename))
+	})
+
+	t.Run("digest mismatch", func(t *testing.T) {
+		corrupted := bytes.Clone(blobContent)
+		corrupted[50] = 'x'
+		srv := &rangeServer{blobs: map[string][]byte{desc.Digest.String(): corrupted}}
+		l, src := setupChunkedDownload(t, srv)
+
+		err := l.downloadFileChunked(ctx, src, desc, fetchSeekable(t, src, desc), 3, p)
+		assert.ErrorContains(t, err, "hash does not match")
+		assert.NoFileExists(t, l.BlobPath(desc))
+		ingestFilename := filepath.Join(constants.IngestPath(l.storagePath), desc.Digest.Encoded())
+		assert.NoFileExists(t, chunkStatePath(ingestFilename), "corrupt ranges should not be resumed")
+	})
+
+	t.Run("server ignores range", func(t *testing.T) {
+		srv := &rangeServer{blobs: map[string][]byte{desc.Digest.String(): blobContent}, ignoreRange: true}
+		l, src := setupChunkedDownload(t, srv)
+
+		blob := fetchSeekable(t, src, desc)
+		err := l.downloadFileChunked(ctx, src, desc, blob, 3, p)
+		assert.ErrorIs(t, err, errRangesNotSupported)
+		ingestFilename := filepath.Join(constants.IngestPath(l.storagePath), desc.Digest.Encoded())
+		assert.NoFileExists(t, chunkStatePath(ingestFilename))
+
+		// The blob is left unread so that it can be downloaded sequentially
+		require.NoError(t, l.resumeAndDownloadFile(desc, blob, p))
+		stored, err := os.ReadFile(l.BlobPath(desc))
+		require.NoError(t, err)
+		assert.Equal(t, blobContent, stored)
+	})
+}
@@ -25,19 +25,25 @@ import (
 	"os"
 	"path/filepath"
 
-	"kitops/pkg/cmd/options"
 	"kitops/pkg/lib/constants"
+	"kitops/pkg/lib/network"
 	"kitops/pkg/lib/repo/util"
+	"kitops/pkg/lib/telemetry"
 	"kitops/pkg/output"
 
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/sync/semaphore"
 	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content/oci"
 	"oras.land/oras-go/v2/registry"
 )
 
-func (l *localRepo) PullModel(ctx context.Context, src oras.ReadOnlyTarget, ref registry.Reference, opts *options.NetworkOptions) (ocispec.Descriptor, error) {
+// PullModel pulls the manifest that ref refers to from src into local storage, along with its
+// config and layers. If layers is non-nil, only those layers are pulled and the ModelKit is
+// stored partially (see MissingBlobs); pulling again with different layers adds to the layers
+// already present.
+func (l *localRepo) PullModel(ctx context.Context, src oras.ReadOnlyTarget, ref registry.Reference, layers []ocispec.Descriptor, opts *network.Options) (ocispec.Descriptor, error) {
 	// Only support pulling image manifests
 	desc, err := src.Resolve(ctx, ref.Reference)
 	if err != nil {
@@ -51,15 +57,18 @@ func (l *localRepo) PullModel(ctx context.Context, src oras.ReadOnlyTarget, ref
 		return ocispec.DescriptorEmptyJSON, fmt.Errorf("failed to set up directories for pull: %w", err)
 	}
 
-	progress := output.NewPullProgress(ctx)
+	progress := output.FromContext(ctx).NewPullProgress(ctx)
 
 	manifest, err := util.GetManifest(ctx, src, desc)
 	if err != nil {
 		return ocispec.DescriptorEmptyJSON, err
 	}
 
+	if layers == nil {
+		layers = manifest.Layers
+	}
 	toPull := []ocispec.Descriptor{manifest.Config}
-	toPull = append(toPull, manifest.Layers...)
+	toPull = append(toPull, layers...)
 	toPull = append(toPull, desc)
 	sem := semaphore.NewWeighted(int64(opts.Concurrency))
 	errs, errCtx := errgroup.WithContext(ctx)
@@ -87,7 +96,7 @@ func (l *localRepo) PullModel(ctx context.Context, src oras.ReadOnlyTarget, ref
 		}
 		errs.Go(func() error {
 			defer sem.Release(1)
-			return fmtErr(pullDesc, l.pullNode(errCtx, src, pullDesc, progress))
+			return fmtErr(pullDesc, l.pullNode(errCtx, src, pullDesc, opts.ParallelChunks, progress))
 		})
 	}
 	if err := errs.Wait(); err != nil {
@@ -97,30 +106,39 @@ func (l *localRepo) PullModel(ctx context.Context, src oras.ReadOnlyTarget, ref
 		return ocispec.DescriptorEmptyJSON, fmt.Errorf("failed to acquire lock: %w", semErr)
 	}
 
-	// Special handling to make sure local (scoped) repo contains the just-pulled manifest
-	if err := l.localIndex.addManifest(desc); err != nil {
-		return ocispec.DescriptorEmptyJSON, fmt.Errorf("failed to add manifest to index: %w", err)
-	}
-	// This is a workaround to add the manifest to the main index as well; this is necessary for garbage collection to work
-	if err := l.Store.Tag(ctx, desc, desc.Digest.String()); err != nil {
-		return ocispec.DescriptorEmptyJSON, fmt.Errorf("failed to add manifest to shared index: %w", err)
-	}
+	err = l.update(ctx, func(store *oci.Store) error {
+		// Special handling to make sure local (scoped) repo contains the just-pulled manifest
+		if err := l.localIndex.addManifest(desc); err != nil {
+			return fmt.Errorf("failed to add manifest to index: %w", err)
+		}
+		// This is a workaround to add the manifest to the main index as well; this is necessary for garbage collection to work
+		if err := store.Tag(ctx, desc, desc.Digest.String()); err != nil {
+			return fmt.Errorf("failed to add manifest to shared index: %w", err)
+		}
 
-	if !util.ReferenceIsDigest(ref.Reference) {
-		if err := l.localIndex.tag(desc, ref.Reference); err != nil {
-			return ocispec.DescriptorEmptyJSON, fmt.Errorf("failed to save tag: %w", err)
+		if !util.ReferenceIsDigest(ref.Reference) {
+			if err := l.localIndex.tag(desc, ref.Reference); err != nil {
+				return fmt.Errorf("failed to save tag: %w", err)
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return ocispec.DescriptorEmptyJSON, err
 	}
 	progress.Done()
 
-	if err := l.cleanupIngestDir(); err != nil {
-		output.Logln(output.LogLevelWarn, err)
+	if err := l.cleanupIngestDir(toPull); err != nil {
+		output.FromContext(ctx).Logln(output.LogLevelWarn, err)
 	}
 
 	return desc, nil
 }
 
-func (l *localRepo) pullNode(ctx context.Context, src oras.ReadOnlyTarget, desc ocispec.Descriptor, p *output.PullProgress) error {
+func (l *localRepo) pullNode(ctx context.Context, src oras.ReadOnlyTarget, desc ocispec.Descriptor, parallelChunks int, p *output.PullProgress) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, "pullNode", telemetry.DescriptorAttributes(desc)...)
+	defer func() { telemetry.EndSpan(span, err) }()
+
 	if exists, err := l.Exists(ctx, desc); err != nil {
 		return fmt.Errorf("failed to check local storage: %w", err)
 	} else if exists {
@@ -132,6 +150,14 @@ func (l *localRepo) pullNode(ctx context.Context, src oras.ReadOnlyTarget, desc
 		return fmt.Errorf("failed to fetch: %w", err)
 	}
 	if seekBlob, ok := blob.(io.ReadSeekCloser); ok {
+		if numChunks := l.downloadChunks(desc, parallelChunks); numChunks > 1 {
+			p.Logf(output.LogLevelTrace, "Remote supports range requests, downloading %s in %d chunks", desc.Digest, numChunks)
+			err := l.downloadFileChunked(ctx, src, desc, seekBlob, numChunks, p)
+			if !errors.Is(err, errRangesNotSupported) {
+				return err
+			}
+			p.Debugf("Failed to download %s in chunks (%s), downloading sequentially", desc.Digest, err)
+		}
 		p.Logf(output.LogLevelTrace, "Remote supports range requests, using resumable download")
 		return l.resumeAndDownloadFile(desc, seekBlob, p)
 	} else {
@@ -146,6 +172,19 @@ func (l *localRepo) resumeAndDownloadFile(desc ocispec.Descriptor, blob io.ReadS
 	if err != nil {
 		return fmt.Errorf("failed to open ingest file for writing: %w", err)
 	}
+	// The lock is released when the file is closed
+	if err := tryLockFile(ingestFile); err != nil {
+		ingestFile.Close()
+		if !errors.Is(err, errLocked) {
+			return fmt.Errorf("failed to lock ingest file: %w", err)
+		}
+		// Another process is downloading the same blob; download a separate copy rather than waiting
+		p.Debugf("Download for digest %s is in progress elsewhere, downloading to a new file", desc.Digest.String())
+		if _, err := blob.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to seek in remote resource: %w", err)
+		}
+		return l.downloadFile(desc, blob, p)
+	}
 	defer func() {
 		if err := ingestFile.Close(); err != nil && !errors.Is(err, fs.ErrClosed) {
 			p.Logf(output.LogLevelError, "Error closing temporary ingest file: %s", err)
@@ -243,22 +282,39 @@ func (l *localRepo) ensurePullDirs() error {
 	return os.MkdirAll(ingestPath, 0755)
 }
 
-func (l *localRepo) cleanupIngestDir() error {
+// cleanupIngestDir removes any leftover resumable ingest files for descriptors in pulled. Files
+// that are locked belong to downloads in progress in other processes, and are left alone.
+func (l *localRepo) cleanupIngestDir(pulled []ocispec.Descriptor) error {
 	ingestPath := constants.IngestPath(l.storagePath)
-	err := filepath.WalkDir(ingestPath, func(path string, d fs.DirEntry, err error) error {
+	for _, desc := range pulled {
+		ingestFilename := filepath.Join(ingestPath, desc.Digest.Encoded())
+		ingestFile, err := os.Open(ingestFilename)
 		if err != nil {
-			return err
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return fmt.Errorf("failed to clean up ingest directory: %w", err)
 		}
-		if d.IsDir() {
-			return nil
+		if err := tryLockFile(ingestFile); err != nil {
+			ingestFile.Close()
+			if errors.Is(err, errLocked) {
+				continue
+			}
+			return fmt.Errorf("failed to clean up ingest directory: %w", err)
 		}
-		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
-			return err
+		removeErr := os.Remove(ingestFilename)
+		if err := os.Remove(chunkStatePath(ingestFilename)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			ingestFile.Close()
+			return fmt.Errorf("failed to clean up ingest directory: %w", err)
+		}
+		ingestFile.Close()
+		if removeErr != nil && !errors.Is(removeErr, fs.ErrNotExist) {
+			// Some platforms (i.e. Windows) do not allow removing open files
+			removeErr = os.Remove(ingestFilename)
+		}
+		if removeErr != nil && !errors.Is(removeErr, fs.ErrNotExist) {
+			return fmt.Errorf("failed to clean up ingest directory: %w", removeErr)
 		}
-		return nil
-	})
-	if err != nil {
-		return fmt.Errorf("failed to clean up ingest directory: %w", err)
 	}
 	return nil
 }
@@ -27,9 +27,10 @@ import (
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync"
 
-	"kitops/pkg/cmd/options"
 	"kitops/pkg/lib/constants"
+	"kitops/pkg/lib/network"
 
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"oras.land/oras-go/v2"
@@ -44,7 +45,8 @@ type LocalRepo interface {
 	BlobPath(ocispec.Descriptor) string
 	GetAllModels() []ocispec.Descriptor
 	GetTags(ocispec.Descriptor) []string
-	PullModel(context.Context, oras.ReadOnlyTarget, registry.Reference, *options.NetworkOptions) (ocispec.Descriptor, error)
+	TagVariant(ctx context.Context, variant ocispec.Descriptor, reference string) (ocispec.Descriptor, error)
+	PullModel(ctx context.Context, src oras.ReadOnlyTarget, ref registry.Reference, layers []ocispec.Descriptor, opts *network.Options) (ocispec.Descriptor, error)
 	oras.Target
 	content.Deleter
 	content.Untagger
@@ -53,7 +55,10 @@ type LocalRepo interface {
 type localRepo struct {
 	storagePath string
 	nameRef     string
-	localIndex  *localIndex
+	// mu guards localIndex within this process; local storage is locked (see lockStorage)
+	// to prevent concurrent modifications by other processes.
+	mu         sync.RWMutex
+	localIndex *localIndex
 	*oci.Store
 }
 
@@ -67,10 +72,27 @@ func newLocalRepoForName(storagePath, name string) (LocalRepo, error) {
 	repo.storagePath = storagePath
 	repo.nameRef = name
 
+	// If index.json does not exist yet, we need to create it; otherwise, we only need to make
+	// sure that no other process is writing indexes while we read them.
+	_, statErr := os.Stat(constants.IndexJsonPath(storagePath))
+	exclusive := errors.Is(statErr, fs.ErrNotExist)
+	unlock, err := lockStorage(storagePath, exclusive)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if exclusive {
+		if err := initStorage(storagePath); err != nil {
+			return nil, err
+		}
+	}
+
 	store, err := oci.New(storagePath)
 	if err != nil {
 		return nil, err
 	}
+	// Changes to index.json are only made through update(), which saves it safely
+	store.AutoSaveIndex = false
 	repo.Store = store
 
 	// Initialize repo-specific index.json
@@ -84,26 +106,13 @@ func newLocalRepoForName(storagePath, name string) (LocalRepo, error) {
 }
 
 func GetAllLocalRepos(storagePath string) ([]LocalRepo, error) {
-	entries, err := os.ReadDir(storagePath)
+	repoNames, err := listRepoNames(storagePath)
 	if err != nil {
-		if errors.Is(err, fs.ErrNotExist) {
-			return nil, nil
-		}
-		return nil, fmt.Errorf("failed to read local storage: %w", err)
+		return nil, err
 	}
 
 	var repos []LocalRepo
-	for _, dirEntry := range entries {
-		if dirEntry.IsDir() {
-			continue
-		}
-		if !constants.FileIsLocalIndex(dirEntry.Name()) {
-			continue
-		}
-		repoName, err := constants.RepoForIndexJsonPath(dirEntry.Name())
-		if err != nil {
-			return nil, err
-		}
+	for _, repoName := range repoNames {
 		repo, err := newLocalRepoForName(storagePath, repoName)
 		if err != nil {
 			return nil, err
@@ -128,25 +137,58 @@ func (r *localRepo) BlobPath(desc ocispec.Descriptor) string {
 	return filepath.Join(r.storagePath, ocispec.ImageBlobsDir, desc.Digest.Algorithm().String(), desc.Digest.Encoded())
 }
 
-func (l *localRepo) Delete(ctx context.Context, target ocispec.Descriptor) error {
-	if target.MediaType != ocispec.MediaTypeImageManifest {
-		return l.Store.Delete(ctx, target)
+// update calls fn to modify indexes in local storage. Storage is locked while fn runs, and the
+// repo's index is reloaded beforehand so that changes made by other processes are preserved.
+// As the shared OCI index.json may also have changed, fn is passed a newly-opened OCI store to
+// use for any changes to it; its index is saved once fn returns, even if fn fails partway, so
+// that it stays consistent with blobs that were already deleted.
+func (l *localRepo) update(ctx context.Context, fn func(store *oci.Store) error) error {
+	unlock, err := lockStorage(l.storagePath, true)
+	if err != nil {
+		return err
 	}
+	defer unlock()
 
-	canDelete, err := canSafelyDeleteManifest(ctx, l.storagePath, target)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.localIndex.reload(); err != nil {
+		return err
+	}
+	store, err := oci.NewWithContext(ctx, l.storagePath)
 	if err != nil {
-		return fmt.Errorf("failed to check if manifest can be deleted: %w", err)
+		return err
 	}
-	if canDelete {
-		if err := l.Store.Delete(ctx, target); err != nil {
-			return err
-		}
+	store.AutoSaveIndex = false
+	fnErr := fn(store)
+	if err := saveIndex(l.storagePath, store); err != nil {
+		return errors.Join(fnErr, err)
 	}
-	return l.localIndex.delete(target)
+	return fnErr
+}
+
+func (l *localRepo) Delete(ctx context.Context, target ocispec.Descriptor) error {
+	return l.update(ctx, func(store *oci.Store) error {
+		if !isManifest(target) {
+			return store.Delete(ctx, target)
+		}
+
+		canDelete, err := canSafelyDeleteManifest(l.storagePath, target)
+		if err != nil {
+			return fmt.Errorf("failed to check if manifest can be deleted: %w", err)
+		}
+		if canDelete {
+			if err := deleteManifest(ctx, store, target); err != nil {
+				return err
+			}
+		}
+		return l.localIndex.delete(target)
+	})
 }
 
 func (l *localRepo) Exists(ctx context.Context, target ocispec.Descriptor) (bool, error) {
-	if target.MediaType == ocispec.MediaTypeImageManifest {
+	if isManifest(target) {
+		l.mu.RLock()
+		defer l.mu.RUnlock()
 		return l.localIndex.exists(target), nil
 	} else {
 		return l.Store.Exists(ctx, target)
@@ -154,53 +196,88 @@ func (l *localRepo) Exists(ctx context.Context, target ocispec.Descriptor) (bool
 }
 
 func (l *localRepo) Fetch(ctx context.Context, target ocispec.Descriptor) (io.ReadCloser, error) {
-	if target.MediaType == ocispec.MediaTypeImageManifest {
-		if exists := l.localIndex.exists(target); !exists {
-			return nil, errdef.ErrNotFound
-		}
+	if exists, err := l.Exists(ctx, target); err != nil {
+		return nil, err
+	} else if !exists && isManifest(target) {
+		return nil, errdef.ErrNotFound
 	}
 	return l.Store.Fetch(ctx, target)
 }
 
 func (l *localRepo) Push(ctx context.Context, expected ocispec.Descriptor, content io.Reader) error {
-	if expected.MediaType == ocispec.MediaTypeImageManifest {
-		// Attempting to push a manifest to oci.Store will return an error if it already exists.
-		// Normally, clients check before pushing, but in our case, the manifest may exist in the
-		// oci.Store but not the local index. As a result, we have to check if it exists before pushing.
-		exists, err := l.Store.Exists(ctx, expected)
-		if err != nil {
-			return err
-		}
-		if !exists {
-			if err := l.Store.Push(ctx, expected, content); err != nil {
+	if isManifest(expected) {
+		return l.update(ctx, func(store *oci.Store) error {
+			// Attempting to push a manifest to oci.Store will return an error if it already exists.
+			// Normally, clients check before pushing, but in our case, the manifest may exist in the
+			// oci.Store but not the local index. As a result, we have to check if it exists before pushing.
+			exists, err := store.Exists(ctx, expected)
+			if err != nil {
 				return err
 			}
-		}
-		return l.localIndex.addManifest(expected)
+			if !exists {
+				if err := store.Push(ctx, expected, content); err != nil {
+					return err
+				}
+			}
+			return l.localIndex.addManifest(expected)
+		})
 	} else {
 		return l.Store.Push(ctx, expected, content)
 	}
 }
 
 func (l *localRepo) Resolve(_ context.Context, reference string) (ocispec.Descriptor, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
 	return l.localIndex.resolve(reference)
 }
 
-func (l *localRepo) Tag(_ context.Context, desc ocispec.Descriptor, reference string) error {
+func (l *localRepo) Tag(ctx context.Context, desc ocispec.Descriptor, reference string) error {
 	// TODO: should we tag it in the general index.json too?
-	return l.localIndex.tag(desc, reference)
+	return l.update(ctx, func(_ *oci.Store) error {
+		return l.localIndex.tag(desc, reference)
+	})
 }
 
-func (l *localRepo) Untag(_ context.Context, reference string) error {
-	return l.localIndex.untag(reference)
+func (l *localRepo) Untag(ctx context.Context, reference string) error {
+	return l.update(ctx, func(_ *oci.Store) error {
+		return l.localIndex.untag(reference)
+	})
 }
 
 func (l *localRepo) GetAllModels() []ocispec.Descriptor {
-	return l.localIndex.Manifests
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return slices.Clone(l.localIndex.Manifests)
 }
 
 func (l *localRepo) GetTags(desc ocispec.Descriptor) []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
 	return l.localIndex.listTags(desc)
 }
 
+// deleteManifest deletes a manifest or index from store. Variants in an index are tracked in
+// the repository's index independently of the index itself, so they are not garbage collected
+// when it is deleted. Manifests that were only partially pulled are handled separately, as the
+// store cannot garbage collect blobs that are missing.
+func deleteManifest(ctx context.Context, store *oci.Store, desc ocispec.Descriptor) error {
+	if desc.MediaType == ocispec.MediaTypeImageIndex {
+		autoGC := store.AutoGC
+		store.AutoGC = false
+		defer func() { store.AutoGC = autoGC }()
+		return store.Delete(ctx, desc)
+	}
+	if deleted, err := deletePartialManifest(ctx, store, desc); err != nil || deleted {
+		return err
+	}
+	return store.Delete(ctx, desc)
+}
+
+// isManifest returns true if desc describes a manifest or index, which are tracked in the
+// repository's index rather than only in the shared OCI store.
+func isManifest(desc ocispec.Descriptor) bool {
+	return desc.MediaType == ocispec.MediaTypeImageManifest || desc.MediaType == ocispec.MediaTypeImageIndex
+}
+
 var _ LocalRepo = (*localRepo)(nil)
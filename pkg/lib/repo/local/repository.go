@@ -39,23 +39,31 @@ type localIndex struct {
 }
 
 func newLocalIndex(storagePath, repoName string) (*localIndex, error) {
-	li := &localIndex{}
-	indexPath := constants.IndexJsonPathForRepo(storagePath, repoName)
-	index, err := parseIndex(indexPath)
-	if err != nil {
+	li := &localIndex{
+		indexPath: constants.IndexJsonPathForRepo(storagePath, repoName),
+		modelTags: emptyTagsIndex(constants.TagIndexPathForRepo(storagePath, repoName)),
+	}
+	if err := li.reload(); err != nil {
 		return nil, err
 	}
-	li.indexPath = indexPath
-	li.Index = *index
+	return li, nil
+}
 
-	tagsIndexPath := constants.TagIndexPathForRepo(storagePath, repoName)
-	tags, err := parseTagsIndex(tagsIndexPath)
+// reload re-reads the index and tags index from disk, discarding any in-memory state. To
+// avoid losing changes made by other processes, it should be called with local storage
+// locked before modifying the index.
+func (li *localIndex) reload() error {
+	index, err := parseIndex(li.indexPath)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	tags, err := parseTagsIndex(li.modelTags.tagsIndexPath)
+	if err != nil {
+		return err
+	}
+	li.Index = *index
 	li.modelTags = tags
-
-	return li, nil
+	return nil
 }
 
 func (li *localIndex) addManifest(manifestDesc ocispec.Descriptor) error {
@@ -101,7 +109,7 @@ func (li *localIndex) save() error {
 	if err != nil {
 		return fmt.Errorf("failed to marshal index: %w", err)
 	}
-	if err := os.WriteFile(li.indexPath, indexJson, 0666); err != nil {
+	if err := writeFileAtomic(li.indexPath, indexJson, 0666); err != nil {
 		return fmt.Errorf("failed to save index: %w", err)
 	}
 	return nil
@@ -221,7 +229,7 @@ func (ti *tagsIndex) save() error {
 	if err != nil {
 		return fmt.Errorf("failed to marshal tags index: %w", err)
 	}
-	if err := os.WriteFile(ti.tagsIndexPath, jsonBytes, 0666); err != nil {
+	if err := writeFileAtomic(ti.tagsIndexPath, jsonBytes, 0666); err != nil {
 		return fmt.Errorf("failed to save tags index: %w", err)
 	}
 	return nil
@@ -17,12 +17,15 @@
 package local
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"net/url"
 	"os"
 
+	"kitops/pkg/lib/constants"
+
 	"github.com/opencontainers/image-spec/specs-go"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"oras.land/oras-go/v2/registry"
@@ -63,19 +66,49 @@ func parseTagsIndex(tagsIndexPath string) (*tagsIndex, error) {
 	return tags, nil
 }
 
+// listRepoNames returns the names of all repositories that have an index in local storage.
+func listRepoNames(storagePath string) ([]string, error) {
+	entries, err := os.ReadDir(storagePath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read local storage: %w", err)
+	}
+
+	var repoNames []string
+	for _, dirEntry := range entries {
+		if dirEntry.IsDir() {
+			continue
+		}
+		if !constants.FileIsLocalIndex(dirEntry.Name()) {
+			continue
+		}
+		repoName, err := constants.RepoForIndexJsonPath(dirEntry.Name())
+		if err != nil {
+			return nil, err
+		}
+		repoNames = append(repoNames, repoName)
+	}
+	return repoNames, nil
+}
+
 // canSafelyDeleteManifest returns true if a manifest can be safely deleted, i.e. if
 // at most one local repository refers to it. Otherwise, deleting the manifest will
-// delete it from all repositories, which is not what's intended.
-func canSafelyDeleteManifest(ctx context.Context, storagePath string, desc ocispec.Descriptor) (bool, error) {
-	allRepos, err := GetAllLocalRepos(storagePath)
+// delete it from all repositories, which is not what's intended. Indexes are read
+// directly, so local storage should already be locked by the caller.
+func canSafelyDeleteManifest(storagePath string, desc ocispec.Descriptor) (bool, error) {
+	repoNames, err := listRepoNames(storagePath)
 	if err != nil {
 		return false, err
 	}
 	refCount := 0
-	for _, repo := range allRepos {
-		if exists, err := repo.Exists(ctx, desc); err != nil {
+	for _, repoName := range repoNames {
+		index, err := newLocalIndex(storagePath, repoName)
+		if err != nil {
 			return false, err
-		} else if exists {
+		}
+		if index.exists(desc) {
 			refCount += 1
 		}
 	}
@@ -0,0 +1,144 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package local
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"kitops/pkg/lib/repo/util"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content/oci"
+	"oras.land/oras-go/v2/errdef"
+)
+
+// TagVariant adds the modelkit described by variant to the index of variants tagged reference,
+// replacing any existing variant with the same name, and returns the descriptor of the updated
+// index. If reference does not exist, a new index is created; if it refers to a single variant,
+// a new index containing both variants is created. References to modelkits that are not variants
+// are not replaced, and an error is returned instead. The variant must already be stored in the
+// repository and carry variant annotations.
+func (l *localRepo) TagVariant(ctx context.Context, variant ocispec.Descriptor, reference string) (ocispec.Descriptor, error) {
+	if util.VariantName(variant) == "" {
+		return ocispec.DescriptorEmptyJSON, fmt.Errorf("modelkit %s is not a variant", variant.Digest)
+	}
+	var indexDesc ocispec.Descriptor
+	err := l.update(ctx, func(store *oci.Store) error {
+		if !l.localIndex.hasManifest(variant) {
+			return fmt.Errorf("%s: %s: %w", variant.Digest, variant.MediaType, errdef.ErrNotFound)
+		}
+		var existing *ocispec.Index
+		current, err := l.localIndex.resolve(reference)
+		if err != nil && !errors.Is(err, errdef.ErrNotFound) {
+			return err
+		}
+		currentIsIndex := err == nil && current.MediaType == ocispec.MediaTypeImageIndex
+		switch {
+		case errors.Is(err, errdef.ErrNotFound):
+			// New index
+		case currentIsIndex:
+			existing, err = util.GetIndex(ctx, store, current)
+			if err != nil {
+				return err
+			}
+		case util.VariantName(current) != "":
+			existing = &ocispec.Index{Manifests: []ocispec.Descriptor{current}}
+		default:
+			return fmt.Errorf("tag %s refers to modelkit %s, which is not a variant", reference, current.Digest)
+		}
+		index, replaced := util.AddVariant(existing, variant)
+		indexBytes, err := json.Marshal(index)
+		if err != nil {
+			return fmt.Errorf("failed to marshal index: %w", err)
+		}
+		indexDesc = ocispec.Descriptor{
+			MediaType: ocispec.MediaTypeImageIndex,
+			Digest:    digest.FromBytes(indexBytes),
+			Size:      int64(len(indexBytes)),
+		}
+		if exists, err := store.Exists(ctx, indexDesc); err != nil {
+			return err
+		} else if !exists {
+			if err := store.Push(ctx, indexDesc, bytes.NewReader(indexBytes)); err != nil {
+				return fmt.Errorf("failed to save index: %w", err)
+			}
+		}
+		if err := l.localIndex.addManifest(indexDesc); err != nil {
+			return err
+		}
+		if err := l.localIndex.tag(indexDesc, reference); err != nil {
+			return err
+		}
+
+		// Drop the previous index and any replaced variant if nothing else refers to them, rather
+		// than leaving them as untagged modelkits. Other variants are kept as they are included
+		// in the new index. The previous index is dropped first, as it refers to the replaced variant.
+		if currentIsIndex && current.Digest != indexDesc.Digest {
+			if err := l.deleteUnreferenced(ctx, store, current); err != nil {
+				return err
+			}
+		}
+		if replaced != nil && replaced.Digest != variant.Digest {
+			if err := l.deleteUnreferenced(ctx, store, *replaced); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return ocispec.DescriptorEmptyJSON, err
+	}
+	return indexDesc, nil
+}
+
+// deleteUnreferenced deletes the manifest or index described by desc from the repository if it
+// is not tagged and is not a variant in any index in the repository. Storage must already be
+// locked by the caller.
+func (l *localRepo) deleteUnreferenced(ctx context.Context, store *oci.Store, desc ocispec.Descriptor) error {
+	if !l.localIndex.hasManifest(desc) || len(l.localIndex.listTags(desc)) > 0 {
+		return nil
+	}
+	for _, manifestDesc := range l.localIndex.Manifests {
+		if manifestDesc.MediaType != ocispec.MediaTypeImageIndex || manifestDesc.Digest == desc.Digest {
+			continue
+		}
+		index, err := util.GetIndex(ctx, store, manifestDesc)
+		if err != nil {
+			return err
+		}
+		for _, variantDesc := range index.Manifests {
+			if variantDesc.Digest == desc.Digest {
+				return nil
+			}
+		}
+	}
+	canDelete, err := canSafelyDeleteManifest(l.storagePath, desc)
+	if err != nil {
+		return fmt.Errorf("failed to check if %s can be deleted: %w", desc.Digest, err)
+	}
+	if canDelete {
+		if err := deleteManifest(ctx, store, desc); err != nil {
+			return err
+		}
+	}
+	return l.localIndex.delete(desc)
+}
@@ -0,0 +1,212 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"kitops/pkg/output"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry"
+)
+
+// maxMirrorManifestSize is the maximum size of a manifest that will be read from a
+// mirror when fetching by reference. Manifests are read into memory to be verified.
+const maxMirrorManifestSize int64 = 4 << 20
+
+// mirroredRepository is a Repository that reads content from a list of mirrors,
+// falling back to the upstream repository if no mirror can serve a request. All
+// content served by a mirror is verified against its digest so that a mirror cannot
+// substitute different content. As content for a tag cannot be verified, tags are
+// always resolved by the upstream repository, and only the resulting digest is fetched
+// from mirrors. Operations that modify the repository (and Exists, which is used to
+// decide what to push) always use the upstream repository.
+type mirroredRepository struct {
+	*Repository
+	mirrors []*Repository
+	// allowUnverifiedTags allows tags to be resolved by mirrors if the upstream repository
+	// cannot be reached.
+	allowUnverifiedTags bool
+}
+
+func (r *mirroredRepository) Resolve(ctx context.Context, reference string) (ocispec.Descriptor, error) {
+	if !isDigestReference(reference) {
+		desc, err := r.Repository.Resolve(ctx, reference)
+		if err == nil || !r.useUnverifiedTag(ctx, reference, err) {
+			return desc, err
+		}
+	}
+	var desc ocispec.Descriptor
+	err := r.fromEndpoints(ctx, reference, output.FromContext(ctx).Debugf, func(repo *Repository, isMirror bool) error {
+		resolved, err := repo.Resolve(ctx, reference)
+		if err != nil {
+			return err
+		}
+		if isMirror {
+			if err := verifyDigestReference(resolved, reference); err != nil {
+				return err
+			}
+		}
+		desc = resolved
+		return nil
+	})
+	return desc, err
+}
+
+func (r *mirroredRepository) FetchReference(ctx context.Context, reference string) (ocispec.Descriptor, io.ReadCloser, error) {
+	if !isDigestReference(reference) {
+		// Resolve the tag upstream so that the manifest fetched from a mirror can be verified
+		resolved, err := r.Repository.Resolve(ctx, reference)
+		if err == nil {
+			reference = resolved.Digest.String()
+		} else if !r.useUnverifiedTag(ctx, reference, err) {
+			return ocispec.Descriptor{}, nil, err
+		}
+	}
+	var desc ocispec.Descriptor
+	var rc io.ReadCloser
+	err := r.fromEndpoints(ctx, reference, output.FromContext(ctx).Debugf, func(repo *Repository, isMirror bool) error {
+		fetchedDesc, fetched, err := repo.FetchReference(ctx, reference)
+		if err != nil {
+			return err
+		}
+		if !isMirror {
+			desc, rc = fetchedDesc, fetched
+			return nil
+		}
+		defer fetched.Close()
+		if err := verifyDigestReference(fetchedDesc, reference); err != nil {
+			return err
+		}
+		if fetchedDesc.Size > maxMirrorManifestSize {
+			return fmt.Errorf("content size %d exceeds maximum of %d bytes", fetchedDesc.Size, maxMirrorManifestSize)
+		}
+		contentBytes, err := content.ReadAll(fetched, fetchedDesc)
+		if err != nil {
+			return fmt.Errorf("failed to verify content: %w", err)
+		}
+		desc, rc = fetchedDesc, io.NopCloser(bytes.NewReader(contentBytes))
+		return nil
+	})
+	return desc, rc, err
+}
+
+func (r *mirroredRepository) Fetch(ctx context.Context, target ocispec.Descriptor) (io.ReadCloser, error) {
+	var rc io.ReadCloser
+	err := r.fromEndpoints(ctx, target.Digest.String(), output.FromContext(ctx).SafeDebugf, func(repo *Repository, isMirror bool) error {
+		fetched, err := repo.Fetch(ctx, target)
+		if err != nil {
+			return err
+		}
+		if _, ok := fetched.(io.ReadSeekCloser); isMirror && !ok {
+			fetched = &verifyingReadCloser{
+				ReadCloser: fetched,
+				verifier:   target.Digest.Verifier(),
+			}
+		}
+		// Seekable blobs are returned as-is so that partial downloads can be resumed; consumers
+		// of seekable blobs verify the complete (resumed) content against the digest themselves.
+		rc = fetched
+		return nil
+	})
+	return rc, err
+}
+
+// fromEndpoints calls fn for each mirror in order, and then for the upstream repository,
+// stopping at the first call that does not return an error. The error from the upstream
+// repository is returned if all endpoints fail.
+func (r *mirroredRepository) fromEndpoints(ctx context.Context, target string, logf func(string, ...any), fn func(repo *Repository, isMirror bool) error) error {
+	for _, mirror := range r.mirrors {
+		err := fn(mirror, true)
+		if err == nil {
+			logf("Using mirror %s for %s", mirror.Reference.Registry, target)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		logf("Mirror %s could not provide %s: %s", mirror.Reference.Registry, target, err)
+	}
+	logf("Using upstream registry %s for %s", r.Reference.Registry, target)
+	return fn(r.Repository, false)
+}
+
+// useUnverifiedTag returns whether a tag that the upstream repository failed to resolve with
+// resolveErr should be resolved by mirrors instead. This is only allowed if configured, and only
+// if the upstream repository could not be reached; tags that do not exist upstream are not
+// resolved by mirrors.
+func (r *mirroredRepository) useUnverifiedTag(ctx context.Context, reference string, resolveErr error) bool {
+	if !r.allowUnverifiedTags || ctx.Err() != nil || errors.Is(resolveErr, errdef.ErrNotFound) {
+		return false
+	}
+	output.FromContext(ctx).Logf(output.LogLevelWarn,
+		"Could not resolve tag %s using registry %s: %s. Resolving it using mirrors instead; the mirror's content for this tag cannot be verified",
+		reference, r.Reference.Registry, resolveErr)
+	return true
+}
+
+func isDigestReference(reference string) bool {
+	ref := registry.Reference{Reference: reference}
+	_, err := ref.Digest()
+	return err == nil
+}
+
+// verifyDigestReference checks that a descriptor returned for a reference matches that reference
+// if the reference is a digest. Tags can only reach mirrors if unverified tags are allowed.
+func verifyDigestReference(desc ocispec.Descriptor, reference string) error {
+	ref := registry.Reference{Reference: reference}
+	refDigest, err := ref.Digest()
+	if err != nil {
+		return nil
+	}
+	if desc.Digest != refDigest {
+		return fmt.Errorf("mirror returned digest %s for reference %s: %w", desc.Digest, reference, content.ErrMismatchedDigest)
+	}
+	return nil
+}
+
+// verifyingReadCloser verifies the content it reads against a digest, returning
+// an error instead of io.EOF if the digest does not match.
+type verifyingReadCloser struct {
+	io.ReadCloser
+	verifier digest.Verifier
+}
+
+func (v *verifyingReadCloser) Read(p []byte) (int, error) {
+	n, err := v.ReadCloser.Read(p)
+	v.verifier.Write(p[:n])
+	if err == io.EOF && !v.verifier.Verified() {
+		return n, fmt.Errorf("content from mirror does not match digest: %w", content.ErrMismatchedDigest)
+	}
+	return n, err
+}
+
+func hostsFor(repos []*Repository) []string {
+	var hosts []string
+	for _, repo := range repos {
+		hosts = append(hosts, repo.Reference.Registry)
+	}
+	return hosts
+}
@@ -0,0 +1,261 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package remote
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"kitops/pkg/lib/network"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/v2/content"
+)
+
+const testMirrorRepo = "test/repo"
+
+// testBlobServer serves a single blob at its digest, counting requests. If content
+// is nil, all requests return 404.
+type testBlobServer struct {
+	*httptest.Server
+	requests atomic.Int32
+}
+
+func newTestBlobServer(t *testing.T, dgst digest.Digest, blobContent []byte) *testBlobServer {
+	s := &testBlobServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		blobPath := "/v2/" + testMirrorRepo + "/blobs/" + dgst.String()
+		manifestPath := "/v2/" + testMirrorRepo + "/manifests/" + dgst.String()
+		if blobContent == nil || (r.URL.Path != blobPath && r.URL.Path != manifestPath) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", ocispec.MediaTypeImageManifest)
+		w.Header().Set("Docker-Content-Digest", dgst.String())
+		w.Write(blobContent)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// newTestTagServer serves a single manifest both at its digest and at the provided tag.
+func newTestTagServer(t *testing.T, tag string, manifestContent []byte) *testBlobServer {
+	dgst := digest.FromBytes(manifestContent)
+	s := &testBlobServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		switch r.URL.Path {
+		case "/v2/" + testMirrorRepo + "/manifests/" + tag, "/v2/" + testMirrorRepo + "/manifests/" + dgst.String():
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", ocispec.MediaTypeImageManifest)
+		w.Header().Set("Docker-Content-Digest", dgst.String())
+		w.Header().Set("Content-Length", strconv.Itoa(len(manifestContent)))
+		if r.Method != http.MethodHead {
+			w.Write(manifestContent)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testBlobServer) host() string {
+	return strings.TrimPrefix(s.URL, "http://")
+}
+
+func newTestMirroredRepo(t *testing.T, upstream *testBlobServer, mirrors ...*testBlobServer) *mirroredRepository {
+	opts := &network.Options{
+		PlainHTTP:       true,
+		CredentialsPath: filepath.Join(t.TempDir(), "credentials.json"),
+	}
+	upstreamRepo, err := newRepository(context.Background(), upstream.host(), testMirrorRepo, opts)
+	require.NoError(t, err)
+	repo := &mirroredRepository{Repository: upstreamRepo}
+	for _, mirror := range mirrors {
+		mirrorRepo, err := newRepository(context.Background(), mirror.host(), testMirrorRepo, opts)
+		require.NoError(t, err)
+		repo.mirrors = append(repo.mirrors, mirrorRepo)
+	}
+	return repo
+}
+
+func TestMirroredRepositoryFetch(t *testing.T) {
+	blobContent := []byte(`{"test": "content"}`)
+	desc := content.NewDescriptorFromBytes("application/octet-stream", blobContent)
+	tamperedContent := []byte(`{"test": "CONTENT"}`)
+
+	tests := []struct {
+		name             string
+		mirrorContent    [][]byte
+		expectErr        bool
+		expectUpstream   bool
+		expectMirrorHits []bool
+	}{
+		{
+			name:             "uses first mirror",
+			mirrorContent:    [][]byte{blobContent, blobContent},
+			expectMirrorHits: []bool{true, false},
+		},
+		{
+			name:             "falls back to second mirror",
+			mirrorContent:    [][]byte{nil, blobContent},
+			expectMirrorHits: []bool{true, true},
+		},
+		{
+			name:             "falls back to upstream",
+			mirrorContent:    [][]byte{nil},
+			expectUpstream:   true,
+			expectMirrorHits: []bool{true},
+		},
+		{
+			name:             "rejects mismatched content",
+			mirrorContent:    [][]byte{tamperedContent},
+			expectErr:        true,
+			expectMirrorHits: []bool{true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := newTestBlobServer(t, desc.Digest, blobContent)
+			var mirrors []*testBlobServer
+			for _, mirrorContent := range tt.mirrorContent {
+				mirrors = append(mirrors, newTestBlobServer(t, desc.Digest, mirrorContent))
+			}
+			repo := newTestMirroredRepo(t, upstream, mirrors...)
+
+			rc, err := repo.Fetch(context.Background(), desc)
+			require.NoError(t, err)
+			defer rc.Close()
+			fetched, err := io.ReadAll(rc)
+			if tt.expectErr {
+				assert.ErrorIs(t, err, content.ErrMismatchedDigest)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, blobContent, fetched)
+			}
+			assert.Equal(t, tt.expectUpstream, upstream.requests.Load() > 0, "unexpected upstream requests")
+			for idx, mirror := range mirrors {
+				assert.Equal(t, tt.expectMirrorHits[idx], mirror.requests.Load() > 0, "unexpected requests for mirror %d", idx)
+			}
+		})
+	}
+}
+
+func TestMirroredRepositoryFetchReferenceVerifies(t *testing.T) {
+	manifestContent := []byte(`{"schemaVersion": 2}`)
+	desc := content.NewDescriptorFromBytes(ocispec.MediaTypeImageManifest, manifestContent)
+	tamperedContent := []byte(`{"schemaVersion": 3}`)
+
+	upstream := newTestBlobServer(t, desc.Digest, manifestContent)
+	mirror := newTestBlobServer(t, desc.Digest, tamperedContent)
+	repo := newTestMirroredRepo(t, upstream, mirror)
+
+	fetchedDesc, rc, err := repo.FetchReference(context.Background(), desc.Digest.String())
+	require.NoError(t, err)
+	defer rc.Close()
+	fetched, err := io.ReadAll(rc)
+	require.NoError(t, err)
+
+	assert.Equal(t, desc.Digest, fetchedDesc.Digest)
+	assert.Equal(t, manifestContent, fetched)
+	assert.Greater(t, mirror.requests.Load(), int32(0), "mirror should be tried first")
+	assert.Greater(t, upstream.requests.Load(), int32(0), "upstream should be used when mirror content does not match")
+}
+
+func TestMirroredRepositoryResolvesTagsUpstream(t *testing.T) {
+	const tag = "latest"
+	upstreamManifest := []byte(`{"schemaVersion": 2}`)
+	mirrorManifest := []byte(`{"schemaVersion": 2, "annotations": {"from": "mirror"}}`)
+
+	tests := []struct {
+		name                string
+		upstreamDown        bool
+		allowUnverifiedTags bool
+		expectErr           bool
+		expectManifest      []byte
+	}{
+		{
+			name:           "uses upstream digest for tag",
+			expectManifest: upstreamManifest,
+		},
+		{
+			name:                "uses upstream digest for tag when unverified tags are allowed",
+			allowUnverifiedTags: true,
+			expectManifest:      upstreamManifest,
+		},
+		{
+			name:         "fails when upstream is unreachable",
+			upstreamDown: true,
+			expectErr:    true,
+		},
+		{
+			name:                "uses mirror when upstream is unreachable and unverified tags are allowed",
+			upstreamDown:        true,
+			allowUnverifiedTags: true,
+			expectManifest:      mirrorManifest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := newTestTagServer(t, tag, upstreamManifest)
+			mirror := newTestTagServer(t, tag, mirrorManifest)
+			// Serve the upstream manifest from a second mirror to check that mirrors are used
+			// when fetching by digest
+			digestMirror := newTestBlobServer(t, digest.FromBytes(upstreamManifest), upstreamManifest)
+			repo := newTestMirroredRepo(t, upstream, mirror, digestMirror)
+			repo.allowUnverifiedTags = tt.allowUnverifiedTags
+			if tt.upstreamDown {
+				upstream.Close()
+			}
+
+			resolved, err := repo.Resolve(context.Background(), tag)
+			if tt.expectErr {
+				assert.Error(t, err)
+			} else if assert.NoError(t, err) {
+				assert.Equal(t, digest.FromBytes(tt.expectManifest), resolved.Digest)
+			}
+
+			fetchedDesc, rc, err := repo.FetchReference(context.Background(), tag)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			defer rc.Close()
+			fetched, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			assert.Equal(t, digest.FromBytes(tt.expectManifest), fetchedDesc.Digest)
+			assert.Equal(t, tt.expectManifest, fetched)
+			if !tt.upstreamDown {
+				assert.Greater(t, digestMirror.requests.Load(), int32(0), "manifest should be fetched from mirror by digest")
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package remote
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"kitops/pkg/output"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/registry"
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+// BlobMounter is implemented by repositories that support mounting blobs from another
+// repository on the same registry.
+type BlobMounter interface {
+	// MountBlob attempts to mount the blob described by desc from repository fromRepo. It
+	// returns true if the blob was mounted, and false if the registry could not mount it;
+	// in the latter case the blob must be uploaded normally.
+	MountBlob(ctx context.Context, desc ocispec.Descriptor, fromRepo string) (bool, error)
+}
+
+// MountBlob attempts a cross-repository blob mount as per the distribution spec. If the
+// registry does not support mounting or does not have the blob in fromRepo, it responds
+// by starting a regular upload session; this session is cancelled and false is returned.
+func (r *Repository) MountBlob(ctx context.Context, desc ocispec.Descriptor, fromRepo string) (bool, error) {
+	ctx = auth.AppendRepositoryScope(ctx, r.Reference, auth.ActionPull, auth.ActionPush)
+	fromRef := r.Reference
+	fromRef.Repository = fromRepo
+	ctx = auth.AppendRepositoryScope(ctx, fromRef, auth.ActionPull)
+
+	mountUrl := buildRepositoryBlobMountURL(r.PlainHttp, r.Reference, desc, fromRepo)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, mountUrl, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := r.client().Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to mount blob: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		return true, nil
+	case http.StatusAccepted:
+		// Registry started an upload session instead; cancel it so that it isn't left dangling
+		location, err := resp.Location()
+		if err != nil {
+			return false, nil
+		}
+		r.cancelUploadSession(ctx, location, req.Header.Get("Authorization"))
+		return false, nil
+	default:
+		return false, handleRemoteError(resp)
+	}
+}
+
+// cancelUploadSession deletes an upload session that will not be used. Errors are logged
+// and otherwise ignored, as registries eventually clean up abandoned sessions.
+func (r *Repository) cancelUploadSession(ctx context.Context, location *url.URL, authHeader string) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, location.String(), nil)
+	if err != nil {
+		return
+	}
+	if authHeader != "" {
+		req.Header.Set("Authorization", authHeader)
+	}
+	resp, err := r.client().Do(req)
+	if err != nil {
+		output.FromContext(ctx).SafeDebugf("Failed to cancel upload session: %s", err)
+		return
+	}
+	resp.Body.Close()
+}
+
+func buildRepositoryBlobMountURL(plainHTTP bool, ref registry.Reference, desc ocispec.Descriptor, fromRepo string) string {
+	query := url.Values{}
+	query.Set("mount", desc.Digest.String())
+	query.Set("from", fromRepo)
+	return fmt.Sprintf("%s?%s", buildRepositoryBlobUploadURL(plainHTTP, ref), query.Encode())
+}
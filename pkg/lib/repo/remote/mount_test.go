@@ -0,0 +1,87 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package remote
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kitops/pkg/lib/network"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/v2/content"
+)
+
+func TestMountBlob(t *testing.T) {
+	desc := content.NewDescriptorFromBytes("application/octet-stream", []byte("shared layer"))
+	const sessionPath = "/v2/test/dest/blobs/uploads/session-id"
+
+	tests := []struct {
+		name            string
+		mountStatus     int
+		expectMounted   bool
+		expectErr       bool
+		expectCancelled bool
+	}{
+		{name: "mounts blob", mountStatus: http.StatusCreated, expectMounted: true},
+		{name: "cancels upload session when mount fails", mountStatus: http.StatusAccepted, expectCancelled: true},
+		{name: "returns error on error response", mountStatus: http.StatusForbidden, expectErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cancelled := false
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == http.MethodPost && r.URL.Path == "/v2/test/dest/blobs/uploads/":
+					assert.Equal(t, desc.Digest.String(), r.URL.Query().Get("mount"))
+					assert.Equal(t, "test/source", r.URL.Query().Get("from"))
+					if tt.mountStatus == http.StatusAccepted {
+						w.Header().Set("Location", sessionPath)
+					}
+					w.WriteHeader(tt.mountStatus)
+				case r.Method == http.MethodDelete && r.URL.Path == sessionPath:
+					cancelled = true
+					w.WriteHeader(http.StatusNoContent)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer srv.Close()
+
+			opts := &network.Options{
+				PlainHTTP:       true,
+				CredentialsPath: filepath.Join(t.TempDir(), "credentials.json"),
+			}
+			repo, err := newRepository(context.Background(), strings.TrimPrefix(srv.URL, "http://"), "test/dest", opts)
+			require.NoError(t, err)
+
+			mounted, err := repo.MountBlob(context.Background(), desc, "test/source")
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectMounted, mounted)
+			assert.Equal(t, tt.expectCancelled, cancelled)
+		})
+	}
+}
@@ -19,8 +19,8 @@ package remote
 import (
 	"context"
 	"fmt"
+	"strings"
 
-	"kitops/pkg/cmd/options"
 	"kitops/pkg/lib/network"
 	"kitops/pkg/output"
 
@@ -29,8 +29,8 @@ import (
 )
 
 // NewRegistry returns a new *remote.Registry for hostname, with credentials and TLS
-// configured.
-func NewRegistry(hostname string, opts *options.NetworkOptions) (*remote.Registry, error) {
+// configured. Requests are logged using the logger from ctx.
+func NewRegistry(ctx context.Context, hostname string, opts *network.Options) (*remote.Registry, error) {
 	reg, err := remote.NewRegistry(hostname)
 	if err != nil {
 		return nil, err
@@ -45,13 +45,45 @@ func NewRegistry(hostname string, opts *options.NetworkOptions) (*remote.Registr
 	if err != nil {
 		return nil, err
 	}
-	reg.Client = output.WrapClient(authClient)
+	reg.Client = output.FromContext(ctx).WrapClient(authClient)
 
 	return reg, nil
 }
 
-func NewRepository(ctx context.Context, hostname, repository string, opts *options.NetworkOptions) (registry.Repository, error) {
-	reg, err := NewRegistry(hostname, opts)
+// NewRepository returns a registry.Repository for the repository on hostname. If mirrors
+// are configured for hostname, the returned repository reads content from the mirrors
+// in order before falling back to hostname itself; all writes go to hostname.
+func NewRepository(ctx context.Context, hostname, repository string, opts *network.Options) (registry.Repository, error) {
+	upstream, err := newRepository(ctx, hostname, repository, opts)
+	if err != nil {
+		return nil, err
+	}
+	mirrorConfigs := opts.Registries[hostname].Mirrors
+	if len(mirrorConfigs) == 0 {
+		return upstream, nil
+	}
+
+	var mirrors []*Repository
+	for _, mirrorConfig := range mirrorConfigs {
+		mirrorOpts := *opts
+		mirrorOpts.PlainHTTP = mirrorConfig.PlainHTTP
+		mirror, err := newRepository(ctx, mirrorConfig.Host, repository, &mirrorOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure mirror %s: %w", mirrorConfig.Host, err)
+		}
+		mirrors = append(mirrors, mirror)
+	}
+	output.FromContext(ctx).WithFields(output.Fields{"registry": hostname}).Debugf("Using mirrors for %s: %s", hostname, strings.Join(hostsFor(mirrors), ", "))
+
+	return &mirroredRepository{
+		Repository:          upstream,
+		mirrors:             mirrors,
+		allowUnverifiedTags: opts.Registries[hostname].AllowUnverifiedTags,
+	}, nil
+}
+
+func newRepository(ctx context.Context, hostname, repository string, opts *network.Options) (*Repository, error) {
+	reg, err := NewRegistry(ctx, hostname, opts)
 	if err != nil {
 		return nil, fmt.Errorf("could not resolve registry: %w", err)
 	}
@@ -18,6 +18,7 @@ package remote
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -27,6 +28,7 @@ import (
 	"strconv"
 	"strings"
 
+	"kitops/pkg/lib/telemetry"
 	"kitops/pkg/output"
 
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
@@ -44,13 +46,27 @@ type Repository struct {
 
 // Push pushes the content, matching the expected descriptor.
 func (r *Repository) Push(ctx context.Context, expected ocispec.Descriptor, content io.Reader) error {
-	if expected.MediaType == ocispec.MediaTypeImageManifest {
-		// If it's a manifest, we can just use the regular implementation
+	if expected.MediaType == ocispec.MediaTypeImageManifest || expected.MediaType == ocispec.MediaTypeImageIndex {
+		// If it's a manifest or index, we can just use the regular implementation
 		return r.Repository.Push(ctx, expected, content)
 	}
 
 	// Otherwise, push a blob according to the OCI spec
 	ctx = auth.AppendRepositoryScope(ctx, r.Reference, auth.ActionPull, auth.ActionPush)
+
+	// If a previous chunked upload of this blob was interrupted, try to continue it
+	if session := r.resumeUploadSession(ctx, expected); session != nil {
+		blobUrl, err := r.resumeBlobUpload(ctx, session, expected, content)
+		if err == nil {
+			output.FromContext(ctx).SafeDebugf("Blob uploaded, available at url %s", blobUrl)
+			return nil
+		}
+		if !errors.Is(err, errResumeRejected) {
+			return err
+		}
+		output.FromContext(ctx).SafeDebugf("Starting new upload for %s: %s", expected.Digest, err)
+	}
+
 	sessionURL, postResp, err := r.initiateUploadSession(ctx)
 	if err != nil {
 		return err
@@ -60,11 +76,40 @@ func (r *Repository) Push(ctx context.Context, expected ocispec.Descriptor, cont
 	if err != nil {
 		return err
 	}
-	output.SafeDebugf("Blob uploaded, available at url %s", blobUrl)
+	output.FromContext(ctx).SafeDebugf("Blob uploaded, available at url %s", blobUrl)
 
 	return nil
 }
 
+// resumeBlobUpload continues the upload of a blob in a saved session. If the registry rejects the resumed
+// upload (e.g. because the session expired after its status was checked), the saved session is removed and
+// content is rewound to where it started, returning an error wrapping errResumeRejected so that the caller
+// can start a new upload. If content cannot be rewound, the error does not wrap errResumeRejected, and
+// retrying the push starts a new upload.
+func (r *Repository) resumeBlobUpload(ctx context.Context, session *uploadSession, expected ocispec.Descriptor, content io.Reader) (string, error) {
+	seeker, canRewind := content.(io.Seeker)
+	var start int64
+	if canRewind {
+		var err error
+		if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			canRewind = false
+		}
+	}
+
+	blobUrl, err := r.uploadBlobChunked(ctx, session, expected, content)
+	if err == nil || !errors.Is(err, errResumeRejected) {
+		return blobUrl, err
+	}
+	session.remove(ctx)
+	if !canRewind {
+		return "", fmt.Errorf("%w; retry the push to start a new upload", err)
+	}
+	if _, seekErr := seeker.Seek(start, io.SeekStart); seekErr != nil {
+		return "", fmt.Errorf("%w; retry the push to start a new upload", err)
+	}
+	return "", err
+}
+
 func (r *Repository) initiateUploadSession(ctx context.Context) (*url.URL, *http.Response, error) {
 	uploadUrl := buildRepositoryBlobUploadURL(r.PlainHttp, r.Reference)
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadUrl, nil)
@@ -98,19 +143,29 @@ func (r *Repository) initiateUploadSession(ctx context.Context) (*url.URL, *http
 	if origPort == "443" && locationHostname == origHostname && locationPort == "" {
 		location.Host = locationHostname + ":" + origPort
 	}
-	output.SafeDebugf("Using location %s for blob upload", path.Join(location.Hostname(), location.Path))
+	output.FromContext(ctx).SafeDebugf("Using location %s for blob upload", path.Join(location.Hostname(), location.Path))
 
 	return location, resp, nil
 }
 
-func (r *Repository) uploadBlob(ctx context.Context, location *url.URL, postResp *http.Response, expected ocispec.Descriptor, content io.Reader) (string, error) {
-	output.SafeDebugf("Size: %d", expected.Size)
-	uploadFormat := getUploadFormat(location.Hostname(), expected.Size)
+func (r *Repository) uploadBlob(ctx context.Context, location *url.URL, postResp *http.Response, expected ocispec.Descriptor, content io.Reader) (_ string, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "uploadBlob", telemetry.DescriptorAttributes(expected)...)
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	output.FromContext(ctx).SafeDebugf("Size: %d", expected.Size)
+	uploadFormat := getUploadFormat(ctx, location.Hostname(), expected.Size)
 	switch uploadFormat {
 	case uploadMonolithicPut:
 		return r.uploadBlobMonolithic(ctx, location, postResp, expected, content)
 	case uploadChunkedPatch:
-		return r.uploadBlobChunked(ctx, location, postResp, expected, content)
+		session := &uploadSession{
+			Location:   location.String(),
+			Digest:     expected.Digest,
+			Size:       expected.Size,
+			authHeader: postResp.Request.Header.Get("Authorization"),
+			statePath:  r.uploadSessionStatePath(ctx, expected),
+		}
+		return r.uploadBlobChunked(ctx, session, expected, content)
 	default:
 		return "", fmt.Errorf("unknown registry %s, cannot upload", location.Hostname())
 	}
@@ -118,7 +173,10 @@ func (r *Repository) uploadBlob(ctx context.Context, location *url.URL, postResp
 
 // uploadBlobMonolithic performs a monolithic blob upload as per the distribution spec. The content of the blob is uploaded
 // in one PUT request at the provided location.
-func (r *Repository) uploadBlobMonolithic(ctx context.Context, location *url.URL, postResp *http.Response, expected ocispec.Descriptor, content io.Reader) (string, error) {
+func (r *Repository) uploadBlobMonolithic(ctx context.Context, location *url.URL, postResp *http.Response, expected ocispec.Descriptor, content io.Reader) (_ string, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "uploadBlobMonolithic", telemetry.DescriptorAttributes(expected)...)
+	defer func() { telemetry.EndSpan(span, err) }()
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodPut, location.String(), content)
 	if err != nil {
 		return "", err
@@ -143,7 +201,7 @@ func (r *Repository) uploadBlobMonolithic(ctx context.Context, location *url.URL
 		req.Header.Set("Authorization", auth)
 	}
 
-	output.SafeDebugf("Uploading blob as one chunk")
+	output.FromContext(ctx).SafeDebugf("Uploading blob as one chunk")
 	// TODO: Handle warnings from remote
 	// References:
 	//   - https://github.com/opencontainers/distribution-spec/blob/v1.1.0-rc4/spec.md#warnings
@@ -160,7 +218,7 @@ func (r *Repository) uploadBlobMonolithic(ctx context.Context, location *url.URL
 
 	blobLocation, err := resp.Location()
 	if err != nil {
-		output.Errorf("Warning: remote registry did not return blob location")
+		output.FromContext(ctx).Errorf("Warning: remote registry did not return blob location")
 	}
 
 	return blobLocation.String(), nil
@@ -170,16 +228,30 @@ func (r *Repository) uploadBlobMonolithic(ctx context.Context, location *url.URL
 // in size and uploaded sequentially through PATCH requests. Once entire blob is uploaded, a PUT request marks the upload as complete.
 // Note that the distribution spec 1) requires blobs to uploaded in-order, and 2) does not have a way of specifying maximum blob
 // size.
-func (r *Repository) uploadBlobChunked(ctx context.Context, location *url.URL, postResp *http.Response, expected ocispec.Descriptor, content io.Reader) (string, error) {
+//
+// The upload starts at session.Offset, skipping that many bytes of content. After each chunk is acknowledged by the registry, the
+// session is saved so that an interrupted upload can be resumed (see resumeUploadSession).
+func (r *Repository) uploadBlobChunked(ctx context.Context, session *uploadSession, expected ocispec.Descriptor, content io.Reader) (_ string, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "uploadBlobChunked", telemetry.DescriptorAttributes(expected)...)
+	defer func() { telemetry.EndSpan(span, err) }()
+
 	// TODO: Handle 'OCI-Chunk-Min-Length' header in post response
-	numChunks := int(math.Ceil(float64(expected.Size) / float64(uploadChunkDefaultSize)))
-	authHeader := postResp.Request.Header.Get("Authorization")
+	if session.Offset > 0 {
+		if err := skipContent(content, session.Offset); err != nil {
+			return "", fmt.Errorf("failed to resume upload: %w", err)
+		}
+	}
+	numChunks := int(math.Ceil(float64(expected.Size-session.Offset) / float64(uploadChunkDefaultSize)))
+	authHeader := session.authHeader
 
-	rangeStart := int64(0)
-	rangeEnd := min(uploadChunkDefaultSize-1, expected.Size-1)
-	nextLocation := location
+	rangeStart := session.Offset
+	rangeEnd := min(rangeStart+uploadChunkDefaultSize-1, expected.Size-1)
+	nextLocation, err := url.Parse(session.Location)
+	if err != nil {
+		return "", fmt.Errorf("invalid upload location: %w", err)
+	}
 	for i := 0; i < numChunks; i++ {
-		output.SafeDebugf("Uploading chunk %d/%d, range %d-%d", i+1, numChunks, rangeStart, rangeEnd)
+		output.FromContext(ctx).SafeDebugf("Uploading chunk %d/%d, range %d-%d", i+1, numChunks, rangeStart, rangeEnd)
 
 		bodyLength := rangeEnd - rangeStart + 1
 		lr := io.LimitReader(content, int64(bodyLength))
@@ -204,6 +276,9 @@ func (r *Repository) uploadBlobChunked(ctx context.Context, location *url.URL, p
 		}
 		if resp.StatusCode != http.StatusAccepted {
 			defer resp.Body.Close()
+			if session.resumed && i == 0 {
+				return "", fmt.Errorf("%w: %w", errResumeRejected, handleRemoteError(resp))
+			}
 			return "", handleRemoteError(resp)
 		}
 		resp.Body.Close()
@@ -233,6 +308,11 @@ func (r *Repository) uploadBlobChunked(ctx context.Context, location *url.URL, p
 			return "", fmt.Errorf("mismatch in range header: expected 0-%d, actual 0-%d", rangeEnd, curEnd)
 		}
 
+		// Save progress in case the upload is interrupted
+		session.Location = nextLocation.String()
+		session.Offset = rangeEnd + 1
+		session.save(ctx)
+
 		// Prepare next range
 		rangeStart = rangeEnd + 1
 		rangeEnd = min(expected.Size-1, rangeEnd+uploadChunkDefaultSize)
@@ -248,12 +328,12 @@ func (r *Repository) uploadBlobChunked(ctx context.Context, location *url.URL, p
 	q := req.URL.Query()
 	q.Set("digest", expected.Digest.String())
 	req.URL.RawQuery = q.Encode()
-	// Reuse credentials from POST request that initiated upload
-	if auth := postResp.Request.Header.Get("Authorization"); auth != "" {
-		req.Header.Set("Authorization", auth)
+	// Reuse credentials from request that initiated upload
+	if authHeader != "" {
+		req.Header.Set("Authorization", authHeader)
 	}
 
-	output.SafeDebugf("Finalizing upload")
+	output.FromContext(ctx).SafeDebugf("Finalizing upload")
 	resp, err := r.client().Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to finalize blob upload: %w", err)
@@ -261,12 +341,16 @@ func (r *Repository) uploadBlobChunked(ctx context.Context, location *url.URL, p
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusCreated {
+		if session.resumed && numChunks == 0 {
+			return "", fmt.Errorf("%w: %w", errResumeRejected, handleRemoteError(resp))
+		}
 		return "", handleRemoteError(resp)
 	}
+	session.remove(ctx)
 
 	blobLocation, err := resp.Location()
 	if err != nil {
-		output.Errorf("Warning: remote registry did not return blob location")
+		output.FromContext(ctx).Errorf("Warning: remote registry did not return blob location")
 	}
 
 	return blobLocation.String(), nil
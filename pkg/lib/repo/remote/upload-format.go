@@ -17,8 +17,10 @@
 package remote
 
 import (
-	"kitops/pkg/output"
+	"context"
 	"regexp"
+
+	"kitops/pkg/output"
 )
 
 type uploadFormat int
@@ -38,8 +40,8 @@ var (
 	googleContainerRegistryRegexp = regexp.MustCompile(`.*\.?gcr.io$`)
 )
 
-func getUploadFormat(registry string, size int64) uploadFormat {
-	output.SafeDebugf("Getting upload format for: %s", registry)
+func getUploadFormat(ctx context.Context, registry string, size int64) uploadFormat {
+	output.FromContext(ctx).SafeDebugf("Getting upload format for: %s", registry)
 	switch {
 	case registry == "ghcr.io":
 		// ghcr.io returns 416 is a PATCH has Content-Length greater than 4.0 MiB for some reason
@@ -17,6 +17,7 @@
 package remote
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -73,7 +74,7 @@ func TestGetUploadFormat(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.registry, func(t *testing.T) {
-			actualFormat := getUploadFormat(tt.registry, tt.size)
+			actualFormat := getUploadFormat(context.Background(), tt.registry, tt.size)
 			assert.Equal(t, tt.expectedFormat, actualFormat)
 		})
 	}
@@ -95,9 +96,9 @@ func TestGetUploadFormatGoogleArtifactRegistry(t *testing.T) {
 	}
 
 	for _, registry := range testRegistries {
-		uploadFormatSmall := getUploadFormat(registry, 100)
+		uploadFormatSmall := getUploadFormat(context.Background(), registry, 100)
 		assert.Equal(t, uploadMonolithicPut, uploadFormatSmall, "Small layers should use monolithic put")
-		uploadFormatLarge := getUploadFormat(registry, uploadChunkDefaultSize)
+		uploadFormatLarge := getUploadFormat(context.Background(), registry, uploadChunkDefaultSize)
 		assert.Equal(t, uploadMonolithicPut, uploadFormatLarge, "Large layers should use monolithic put")
 	}
 }
@@ -0,0 +1,184 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"kitops/pkg/lib/filesystem/cache"
+	"kitops/pkg/output"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// uploadSession tracks the state of a chunked blob upload. It is saved to the cache directory
+// after every acknowledged chunk so that an interrupted upload can be continued by a later push.
+type uploadSession struct {
+	// Location is the URL to use for the next request in the upload session
+	Location string `json:"location"`
+	// Offset is the number of bytes acknowledged by the registry
+	Offset int64 `json:"offset"`
+	// Digest and Size describe the blob being uploaded
+	Digest digest.Digest `json:"digest"`
+	Size   int64         `json:"size"`
+
+	authHeader string
+	statePath  string
+	// resumed is set for sessions restored from saved state, whose first request may be rejected
+	// if the registry has expired the session
+	resumed bool
+}
+
+// errResumeRejected is returned when the registry rejects the first request of a resumed upload session
+var errResumeRejected = errors.New("registry rejected resumed upload")
+
+// uploadSessionStatePath returns the path used to save upload session state for a blob in this
+// repository. If the path cannot be determined, an empty string is returned and sessions will
+// not be saved.
+func (r *Repository) uploadSessionStatePath(ctx context.Context, desc ocispec.Descriptor) string {
+	key := digest.FromString(fmt.Sprintf("%s/%s@%s", r.Reference.Host(), r.Reference.Repository, desc.Digest))
+	statePath, err := cache.CacheFilePath(ctx, cache.CacheUploadSubdir, key.Encoded()+".json")
+	if err != nil {
+		output.FromContext(ctx).SafeDebugf("Upload for %s will not be resumable: %s", desc.Digest, err)
+		return ""
+	}
+	return statePath
+}
+
+// resumeUploadSession checks for a saved upload session for desc and queries the registry for its
+// status. If the session is still active, it is returned with its offset set to the last byte
+// acknowledged by the registry. Otherwise, any saved state is removed and nil is returned, indicating
+// a new upload should be started.
+func (r *Repository) resumeUploadSession(ctx context.Context, desc ocispec.Descriptor) *uploadSession {
+	statePath := r.uploadSessionStatePath(ctx, desc)
+	if statePath == "" {
+		return nil
+	}
+	stateBytes, err := os.ReadFile(statePath)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			output.FromContext(ctx).SafeDebugf("Failed to read upload session for %s: %s", desc.Digest, err)
+		}
+		return nil
+	}
+	session := &uploadSession{statePath: statePath}
+	if err := json.Unmarshal(stateBytes, session); err != nil || session.Digest != desc.Digest || session.Size != desc.Size {
+		output.FromContext(ctx).SafeDebugf("Ignoring invalid upload session for %s", desc.Digest)
+		session.remove(ctx)
+		return nil
+	}
+
+	if err := r.getUploadStatus(ctx, session); err != nil {
+		output.FromContext(ctx).SafeDebugf("Could not resume upload for %s, starting new upload: %s", desc.Digest, err)
+		session.remove(ctx)
+		return nil
+	}
+	output.FromContext(ctx).SafeDebugf("Resuming upload for %s at offset %d", desc.Digest, session.Offset)
+	session.resumed = true
+	return session
+}
+
+// getUploadStatus queries the registry for the status of an upload session, updating the session's
+// location and offset to match the response.
+func (r *Repository) getUploadStatus(ctx context.Context, session *uploadSession) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, session.Location, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := r.client().Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to get upload status: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return handleRemoteError(resp)
+	}
+
+	// The Range header is omitted if no data has been uploaded yet
+	var offset int64 = 0
+	if respRange := resp.Header.Get("Range"); respRange != "" {
+		startEnd := strings.Split(respRange, "-")
+		if len(startEnd) != 2 || startEnd[0] != "0" {
+			return fmt.Errorf("server returned invalid Range header: %s", respRange)
+		}
+		end, err := strconv.ParseInt(startEnd[1], 10, 0)
+		if err != nil {
+			return fmt.Errorf("server returned invalid Range header: %s", respRange)
+		}
+		offset = end + 1
+	}
+	if offset > session.Size {
+		return fmt.Errorf("server reports %d bytes uploaded for blob of size %d", offset, session.Size)
+	}
+	location, err := resp.Location()
+	if err != nil {
+		return fmt.Errorf("missing Location header in response")
+	}
+
+	session.Location = location.String()
+	session.Offset = offset
+	session.authHeader = resp.Request.Header.Get("Authorization")
+	return nil
+}
+
+// save writes the current state of the session to disk. Errors are logged but not returned, as
+// failing to save a session only prevents resuming the upload.
+func (s *uploadSession) save(ctx context.Context) {
+	if s.statePath == "" {
+		return
+	}
+	stateBytes, err := json.Marshal(s)
+	if err != nil {
+		output.FromContext(ctx).SafeDebugf("Failed to save upload session: %s", err)
+		return
+	}
+	if err := os.WriteFile(s.statePath, stateBytes, 0600); err != nil {
+		output.FromContext(ctx).SafeDebugf("Failed to save upload session: %s", err)
+	}
+}
+
+// remove deletes any saved state for the session.
+func (s *uploadSession) remove(ctx context.Context) {
+	if s.statePath == "" {
+		return
+	}
+	if err := os.Remove(s.statePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		output.FromContext(ctx).SafeDebugf("Failed to remove upload session: %s", err)
+	}
+}
+
+// skipContent advances content by offset bytes, seeking if possible.
+func skipContent(content io.Reader, offset int64) error {
+	if seeker, ok := content.(io.Seeker); ok {
+		_, err := seeker.Seek(offset, io.SeekCurrent)
+		return err
+	}
+	if _, err := io.CopyN(io.Discard, content, offset); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kitops/pkg/lib/constants"
+	"kitops/pkg/lib/network"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/v2/content"
+)
+
+func TestResumeUploadSession(t *testing.T) {
+	blobContent := []byte("0123456789abcdefghij")
+	desc := content.NewDescriptorFromBytes("application/octet-stream", blobContent)
+	const uploadPath = "/v2/test/repo/blobs/uploads/session-id"
+	const acknowledged = 10
+
+	tests := []struct {
+		name          string
+		sessionActive bool
+	}{
+		{name: "resumes active session", sessionActive: true},
+		{name: "discards expired session", sessionActive: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), constants.ConfigKey{}, t.TempDir())
+			var patched bytes.Buffer
+			var patchRange string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != uploadPath || !tt.sessionActive {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.Header().Set("Location", uploadPath)
+				switch r.Method {
+				case http.MethodGet:
+					w.Header().Set("Range", fmt.Sprintf("0-%d", acknowledged-1))
+					w.WriteHeader(http.StatusNoContent)
+				case http.MethodPatch:
+					patchRange = r.Header.Get("Content-Range")
+					io.Copy(&patched, r.Body)
+					w.Header().Set("Range", fmt.Sprintf("0-%d", acknowledged+patched.Len()-1))
+					w.WriteHeader(http.StatusAccepted)
+				case http.MethodPut:
+					assert.Equal(t, desc.Digest.String(), r.URL.Query().Get("digest"))
+					w.WriteHeader(http.StatusCreated)
+				}
+			}))
+			defer srv.Close()
+
+			opts := &network.Options{
+				PlainHTTP:       true,
+				CredentialsPath: filepath.Join(t.TempDir(), "credentials.json"),
+			}
+			repo, err := newRepository(ctx, strings.TrimPrefix(srv.URL, "http://"), "test/repo", opts)
+			require.NoError(t, err)
+
+			saved := &uploadSession{
+				Location:  srv.URL + uploadPath,
+				Offset:    acknowledged,
+				Digest:    desc.Digest,
+				Size:      desc.Size,
+				statePath: repo.uploadSessionStatePath(ctx, desc),
+			}
+			saved.save(ctx)
+			require.FileExists(t, saved.statePath)
+
+			session := repo.resumeUploadSession(ctx, desc)
+			if !tt.sessionActive {
+				assert.Nil(t, session)
+				assert.NoFileExists(t, saved.statePath)
+				return
+			}
+			require.NotNil(t, session)
+			assert.Equal(t, int64(acknowledged), session.Offset)
+
+			_, err = repo.uploadBlobChunked(ctx, session, desc, bytes.NewReader(blobContent))
+			require.NoError(t, err)
+			assert.Equal(t, blobContent[acknowledged:], patched.Bytes())
+			assert.Equal(t, fmt.Sprintf("%d-%d", acknowledged, len(blobContent)-1), patchRange)
+			assert.NoFileExists(t, saved.statePath, "upload session should be removed after upload completes")
+		})
+	}
+}
+
+func TestPushRestartsRejectedUploadSession(t *testing.T) {
+	blobContent := []byte("0123456789abcdefghij")
+	desc := content.NewDescriptorFromBytes("application/octet-stream", blobContent)
+	const uploadsPath = "/v2/test/repo/blobs/uploads/"
+	const expiredPath = uploadsPath + "expired-session"
+	const newPath = uploadsPath + "new-session"
+
+	ctx := context.WithValue(context.Background(), constants.ConfigKey{}, t.TempDir())
+	var uploaded bytes.Buffer
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == expiredPath && r.Method == http.MethodGet:
+			// Session still looks active when its status is checked...
+			w.Header().Set("Location", expiredPath)
+			w.Header().Set("Range", "0-9")
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == expiredPath:
+			// ...but expires before the upload is continued
+			w.WriteHeader(http.StatusNotFound)
+		case r.URL.Path == uploadsPath && r.Method == http.MethodPost:
+			w.Header().Set("Location", newPath)
+			w.WriteHeader(http.StatusAccepted)
+		case r.URL.Path == newPath && r.Method == http.MethodPut:
+			assert.Equal(t, desc.Digest.String(), r.URL.Query().Get("digest"))
+			io.Copy(&uploaded, r.Body)
+			w.Header().Set("Location", "/v2/test/repo/blobs/"+desc.Digest.String())
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	opts := &network.Options{
+		PlainHTTP:       true,
+		CredentialsPath: filepath.Join(t.TempDir(), "credentials.json"),
+	}
+	repo, err := newRepository(ctx, strings.TrimPrefix(srv.URL, "http://"), "test/repo", opts)
+	require.NoError(t, err)
+
+	saved := &uploadSession{
+		Location:  srv.URL + expiredPath,
+		Offset:    10,
+		Digest:    desc.Digest,
+		Size:      desc.Size,
+		statePath: repo.uploadSessionStatePath(ctx, desc),
+	}
+	saved.save(ctx)
+	require.FileExists(t, saved.statePath)
+
+	err = repo.Push(ctx, desc, bytes.NewReader(blobContent))
+	require.NoError(t, err)
+	assert.Equal(t, blobContent, uploaded.Bytes(), "upload should restart from the beginning of the blob")
+	assert.NoFileExists(t, saved.statePath, "rejected upload session should be removed")
+}
@@ -223,12 +223,17 @@ func GetConfig(ctx context.Context, store oras.ReadOnlyTarget, configDesc ocispe
 	return config, nil
 }
 
-// ResolveManifest returns the manifest for a reference (tag), if present in the target store
+// ResolveManifest returns the manifest for a reference (tag), if present in the target store. If the
+// reference refers to an index of variants, the first (default) variant is returned.
 func ResolveManifest(ctx context.Context, store oras.Target, reference string) (ocispec.Descriptor, *ocispec.Manifest, error) {
 	desc, err := store.Resolve(ctx, reference)
 	if err != nil {
 		return ocispec.DescriptorEmptyJSON, nil, fmt.Errorf("reference %s not found in repository: %w", reference, err)
 	}
+	desc, err = ResolveVariant(ctx, store, desc, nil)
+	if err != nil {
+		return ocispec.DescriptorEmptyJSON, nil, err
+	}
 	manifest, err := GetManifest(ctx, store, desc)
 	if err != nil {
 		return ocispec.DescriptorEmptyJSON, nil, err
@@ -0,0 +1,54 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package util
+
+import (
+	"context"
+
+	"kitops/pkg/output"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/registry"
+)
+
+// SetModelKitResult sets the result of the current command (see output.SetResult) to a
+// description of the modelkit identified by ref and manifestDesc in store. If structured
+// output is not enabled, this is a no-op. If the manifest cannot be read, layers are
+// omitted from the result.
+func SetModelKitResult(ctx context.Context, store oras.ReadOnlyTarget, ref *registry.Reference, manifestDesc ocispec.Descriptor) {
+	if !output.StructuredOutput() {
+		return
+	}
+	output.SetResult(ModelKitResult(ctx, store, ref, manifestDesc))
+}
+
+// ModelKitResult returns an *output.ModelKitResult describing the modelkit with manifest
+// manifestDesc in store, referred to by ref. If the manifest cannot be read, layers are
+// omitted from the result.
+func ModelKitResult(ctx context.Context, store oras.ReadOnlyTarget, ref *registry.Reference, manifestDesc ocispec.Descriptor) *output.ModelKitResult {
+	manifest, err := GetManifest(ctx, store, manifestDesc)
+	if err != nil {
+		output.FromContext(ctx).Debugf("Failed to read manifest for result: %s", err)
+		manifest = nil
+	}
+	var tags []string
+	if ref.Reference != "" && !ReferenceIsDigest(ref.Reference) {
+		tags = []string{ref.Reference}
+	}
+	return output.NewModelKitResult(FormatRepositoryForDisplay(ref.String()), manifestDesc, manifest, tags)
+}
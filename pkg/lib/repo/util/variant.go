@@ -0,0 +1,273 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package util
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"kitops/pkg/lib/constants"
+
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+)
+
+const (
+	// PreferSmallest selects the smallest matching variant
+	PreferSmallest = "smallest"
+	// PreferLargest selects the largest matching variant
+	PreferLargest = "largest"
+)
+
+// VariantSelector chooses a modelkit from an image index of variants. A variant is selected
+// if its descriptor has all annotations in Match; if several variants match, Prefer is used
+// to choose between them, falling back to the order of the index.
+type VariantSelector struct {
+	Match  map[string]string
+	Prefer string
+	// selector is the string the selector was parsed from, for messages
+	selector string
+}
+
+// ParseVariantSelector parses a variant selector from a comma-separated list of terms. Each
+// term is one of
+//   - key=value: select variants with annotation key set to value (see VariantAnnotationKey)
+//   - "smallest" or "largest": prefer the smallest or largest matching variant
+//   - name: select the variant with this name
+//
+// For example, "q4" selects the variant named q4, while "format=gguf,smallest" selects the
+// smallest GGUF variant. Returns nil if s is empty.
+func ParseVariantSelector(s string) (*VariantSelector, error) {
+	if s == "" {
+		return nil, nil
+	}
+	selector := &VariantSelector{Match: map[string]string{}, selector: s}
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		key, value, isKeyValue := strings.Cut(term, "=")
+		switch {
+		case term == "":
+			return nil, fmt.Errorf("invalid variant selector %q: empty term", s)
+		case isKeyValue:
+			if key == "" || value == "" {
+				return nil, fmt.Errorf("invalid variant selector %q: term %q must be in the format key=value", s, term)
+			}
+			selector.Match[VariantAnnotationKey(key)] = value
+		case term == PreferSmallest || term == PreferLargest:
+			if selector.Prefer != "" && selector.Prefer != term {
+				return nil, fmt.Errorf("invalid variant selector %q: cannot prefer both %s and %s", s, selector.Prefer, term)
+			}
+			selector.Prefer = term
+		default:
+			selector.Match[constants.VariantAnnotation] = term
+		}
+	}
+	return selector, nil
+}
+
+// ParseVariantAnnotations parses annotations for a new variant from a comma-separated list of
+// key=value terms (see VariantAnnotationKey). A term without '=' is used as the variant's
+// name. A name is required, and the variant's size may not be set as it is calculated when
+// packing.
+func ParseVariantAnnotations(s string) (map[string]string, error) {
+	annotations := map[string]string{}
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		key, value, isKeyValue := strings.Cut(term, "=")
+		if !isKeyValue {
+			key, value = "name", term
+		}
+		if key == "" || value == "" {
+			return nil, fmt.Errorf("invalid variant %q: term %q must be a name or in the format key=value", s, term)
+		}
+		annotationKey := VariantAnnotationKey(key)
+		if annotationKey == constants.VariantSizeAnnotation {
+			return nil, fmt.Errorf("invalid variant %q: size is set automatically", s)
+		}
+		if _, exists := annotations[annotationKey]; exists {
+			return nil, fmt.Errorf("invalid variant %q: %s is specified more than once", s, key)
+		}
+		annotations[annotationKey] = value
+	}
+	if annotations[constants.VariantAnnotation] == "" {
+		return nil, fmt.Errorf("invalid variant %q: a name is required", s)
+	}
+	return annotations, nil
+}
+
+// VariantAnnotationKey returns the annotation used for key in variant selectors and
+// annotations. "name" refers to the variant's name; other keys without a '.' are prefixed
+// with VariantAnnotationPrefix (e.g. format refers to ml.kitops.modelkit.variant.format).
+// Keys that contain a '.' are used as-is.
+func VariantAnnotationKey(key string) string {
+	switch {
+	case key == "name":
+		return constants.VariantAnnotation
+	case strings.Contains(key, "."):
+		return key
+	default:
+		return constants.VariantAnnotationPrefix + key
+	}
+}
+
+func (s *VariantSelector) String() string {
+	if s == nil {
+		return "<default>"
+	}
+	return s.selector
+}
+
+func (s *VariantSelector) matches(desc ocispec.Descriptor) bool {
+	if s == nil {
+		return true
+	}
+	for key, value := range s.Match {
+		if desc.Annotations[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// VariantName returns the name of the variant described by desc, or an empty string if it
+// is not a variant.
+func VariantName(desc ocispec.Descriptor) string {
+	return desc.Annotations[constants.VariantAnnotation]
+}
+
+// VariantSize returns the total size of the layers in the variant described by desc, if
+// available.
+func VariantSize(desc ocispec.Descriptor) (int64, bool) {
+	size, err := strconv.ParseInt(desc.Annotations[constants.VariantSizeAnnotation], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return size, true
+}
+
+// SelectVariant returns the descriptor of the variant in index chosen by selector. If
+// selector is nil, the first variant in the index is returned.
+func SelectVariant(index *ocispec.Index, selector *VariantSelector) (ocispec.Descriptor, error) {
+	var candidates []ocispec.Descriptor
+	var available []string
+	for _, desc := range index.Manifests {
+		if desc.MediaType != ocispec.MediaTypeImageManifest {
+			continue
+		}
+		available = append(available, VariantName(desc))
+		if selector.matches(desc) {
+			candidates = append(candidates, desc)
+		}
+	}
+	if len(candidates) == 0 {
+		if len(available) == 0 {
+			return ocispec.DescriptorEmptyJSON, fmt.Errorf("index does not contain any modelkits")
+		}
+		return ocispec.DescriptorEmptyJSON, fmt.Errorf("no variant matches %s (available variants: %s)", selector, strings.Join(available, ", "))
+	}
+	if selector == nil || selector.Prefer == "" {
+		return candidates[0], nil
+	}
+	// Variants with an unknown size are sorted last; otherwise, the index order is kept
+	slices.SortStableFunc(candidates, func(a, b ocispec.Descriptor) int {
+		sizeA, okA := VariantSize(a)
+		sizeB, okB := VariantSize(b)
+		switch {
+		case !okA || !okB:
+			if okA == okB {
+				return 0
+			} else if okA {
+				return -1
+			}
+			return 1
+		case selector.Prefer == PreferLargest:
+			return cmp.Compare(sizeB, sizeA)
+		default:
+			return cmp.Compare(sizeA, sizeB)
+		}
+	})
+	return candidates[0], nil
+}
+
+// ResolveVariant returns the descriptor of the modelkit manifest to use for desc. If desc
+// describes an image index of variants, one is chosen using selector (see SelectVariant).
+// If desc describes a manifest and selector is not nil, desc must be a variant that matches
+// selector.
+func ResolveVariant(ctx context.Context, store oras.ReadOnlyTarget, desc ocispec.Descriptor, selector *VariantSelector) (ocispec.Descriptor, error) {
+	if desc.MediaType != ocispec.MediaTypeImageIndex {
+		if selector.matches(desc) {
+			return desc, nil
+		}
+		if name := VariantName(desc); name != "" {
+			return ocispec.DescriptorEmptyJSON, fmt.Errorf("modelkit %s is variant %s, which does not match %s", desc.Digest, name, selector)
+		}
+		return ocispec.DescriptorEmptyJSON, fmt.Errorf("modelkit %s does not have variants", desc.Digest)
+	}
+	index, err := GetIndex(ctx, store, desc)
+	if err != nil {
+		return ocispec.DescriptorEmptyJSON, err
+	}
+	return SelectVariant(index, selector)
+}
+
+// GetIndex returns the image index described by desc.
+func GetIndex(ctx context.Context, store content.Fetcher, desc ocispec.Descriptor) (*ocispec.Index, error) {
+	indexBytes, err := content.FetchAll(ctx, store, desc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index %s: %w", desc.Digest, err)
+	}
+	index := &ocispec.Index{}
+	if err := json.Unmarshal(indexBytes, index); err != nil {
+		return nil, fmt.Errorf("failed to parse index %s: %w", desc.Digest, err)
+	}
+	return index, nil
+}
+
+// AddVariant returns a copy of index with the variant described by desc added. An existing
+// variant with the same name is replaced, and its descriptor is returned. If index is nil, a new
+// index is created.
+func AddVariant(index *ocispec.Index, desc ocispec.Descriptor) (*ocispec.Index, *ocispec.Descriptor) {
+	newIndex := &ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Annotations: map[string]string{
+			constants.CliVersionAnnotation: constants.Version,
+		},
+	}
+	var replaced *ocispec.Descriptor
+	if index != nil {
+		for _, existing := range index.Manifests {
+			if VariantName(existing) == VariantName(desc) {
+				previous := existing
+				replaced = &previous
+				existing = desc
+			}
+			newIndex.Manifests = append(newIndex.Manifests, existing)
+		}
+	}
+	if replaced == nil {
+		newIndex.Manifests = append(newIndex.Manifests, desc)
+	}
+	return newIndex, replaced
+}
@@ -0,0 +1,153 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package util
+
+import (
+	"testing"
+
+	"kitops/pkg/lib/constants"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVariantSelector(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectMatch  map[string]string
+		expectPrefer string
+		expectErr    bool
+	}{
+		{input: "q4", expectMatch: map[string]string{constants.VariantAnnotation: "q4"}},
+		{input: "name=smallest", expectMatch: map[string]string{constants.VariantAnnotation: "smallest"}},
+		{
+			input:        "format=gguf, smallest",
+			expectMatch:  map[string]string{constants.VariantFormatAnnotation: "gguf"},
+			expectPrefer: PreferSmallest,
+		},
+		{
+			input:       "quantization=q8_0,org.example.precision=int8",
+			expectMatch: map[string]string{constants.VariantQuantizationAnnotation: "q8_0", "org.example.precision": "int8"},
+		},
+		{input: "largest", expectMatch: map[string]string{}, expectPrefer: PreferLargest},
+		{input: "smallest,largest", expectErr: true},
+		{input: "format=", expectErr: true},
+		{input: "q4,,q8", expectErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			selector, err := ParseVariantSelector(tt.input)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectMatch, selector.Match)
+			assert.Equal(t, tt.expectPrefer, selector.Prefer)
+		})
+	}
+
+	selector, err := ParseVariantSelector("")
+	assert.NoError(t, err)
+	assert.Nil(t, selector)
+}
+
+func TestParseVariantAnnotations(t *testing.T) {
+	annotations, err := ParseVariantAnnotations("q4,quantization=q4_0,format=gguf")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		constants.VariantAnnotation:             "q4",
+		constants.VariantQuantizationAnnotation: "q4_0",
+		constants.VariantFormatAnnotation:       "gguf",
+	}, annotations)
+
+	for _, input := range []string{"format=gguf", "q4,size=10", "name=q4,name=q8", "q4,=x"} {
+		_, err := ParseVariantAnnotations(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestSelectVariant(t *testing.T) {
+	variant := func(name, format, size string) ocispec.Descriptor {
+		annotations := map[string]string{
+			constants.VariantAnnotation:       name,
+			constants.VariantFormatAnnotation: format,
+		}
+		if size != "" {
+			annotations[constants.VariantSizeAnnotation] = size
+		}
+		return ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Annotations: annotations}
+	}
+	index := &ocispec.Index{Manifests: []ocispec.Descriptor{
+		variant("fp16", "safetensors", "1600"),
+		variant("unknown", "gguf", ""),
+		variant("q8", "gguf", "800"),
+		variant("q4", "gguf", "400"),
+	}}
+
+	tests := []struct {
+		selector  string
+		expected  string
+		expectErr bool
+	}{
+		{selector: "", expected: "fp16"},
+		{selector: "q8", expected: "q8"},
+		{selector: "format=gguf", expected: "unknown"},
+		{selector: "format=gguf,smallest", expected: "q4"},
+		{selector: "format=gguf,largest", expected: "q8"},
+		{selector: "largest", expected: "fp16"},
+		{selector: "format=onnx", expectErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			selector, err := ParseVariantSelector(tt.selector)
+			require.NoError(t, err)
+			desc, err := SelectVariant(index, selector)
+			if tt.expectErr {
+				assert.ErrorContains(t, err, "available variants: fp16, unknown, q8, q4")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, VariantName(desc))
+		})
+	}
+}
+
+func TestAddVariant(t *testing.T) {
+	named := func(name, dgst string) ocispec.Descriptor {
+		return ocispec.Descriptor{
+			MediaType:   ocispec.MediaTypeImageManifest,
+			Digest:      digest.Digest("sha256:" + dgst),
+			Annotations: map[string]string{constants.VariantAnnotation: name},
+		}
+	}
+	index, replaced := AddVariant(nil, named("q4", "aaaa"))
+	assert.Nil(t, replaced)
+	index, replaced = AddVariant(index, named("q8", "bbbb"))
+	assert.Nil(t, replaced)
+	index, replaced = AddVariant(index, named("q4", "cccc"))
+	require.NotNil(t, replaced)
+	assert.Equal(t, "sha256:aaaa", replaced.Digest.String())
+
+	assert.Equal(t, ocispec.MediaTypeImageIndex, index.MediaType)
+	require.Len(t, index.Manifests, 2)
+	assert.Equal(t, "q4", VariantName(index.Manifests[0]))
+	assert.Equal(t, "sha256:cccc", index.Manifests[0].Digest.String())
+	assert.Equal(t, "q8", VariantName(index.Manifests[1]))
+}
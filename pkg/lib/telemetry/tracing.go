@@ -0,0 +1,188 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package telemetry provides optional OpenTelemetry tracing for CLI operations.
+// Tracing is disabled unless enabled in the config file or an OTLP endpoint is
+// configured through the standard OTEL_EXPORTER_OTLP_* environment variables; when
+// disabled, all functions in this package are no-ops.
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"kitops/pkg/lib/config"
+	"kitops/pkg/lib/constants"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName      = "kitops"
+	serviceName     = "kit"
+	shutdownTimeout = 5 * time.Second
+
+	otlpEndpointEnvVar       = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	otlpTracesEndpointEnvVar = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+	sdkDisabledEnvVar        = "OTEL_SDK_DISABLED"
+	defaultTracesPath        = "/v1/traces"
+)
+
+// Attribute keys used for spans covering operations on blobs.
+const (
+	DigestKey    = attribute.Key("oci.digest")
+	SizeKey      = attribute.Key("oci.size")
+	MediaTypeKey = attribute.Key("oci.media_type")
+)
+
+var (
+	provider    *sdktrace.TracerProvider
+	commandSpan trace.Span
+)
+
+// Enabled returns whether tracing should be enabled given the config file settings
+// and the current environment.
+func Enabled(cfg config.TracingConfig) bool {
+	if strings.EqualFold(os.Getenv(sdkDisabledEnvVar), "true") {
+		return false
+	}
+	return cfg.Enabled || cfg.Endpoint != "" ||
+		os.Getenv(otlpEndpointEnvVar) != "" || os.Getenv(otlpTracesEndpointEnvVar) != ""
+}
+
+// Setup configures the global tracer provider to export spans over OTLP/HTTP if
+// tracing is enabled. It must be paired with a call to Shutdown to ensure spans
+// are flushed before the process exits.
+func Setup(ctx context.Context, cfg config.TracingConfig) error {
+	if !Enabled(cfg) {
+		return nil
+	}
+	var exporterOpts []otlptracehttp.Option
+	if cfg.Endpoint != "" {
+		endpoint, err := tracesEndpointURL(cfg.Endpoint)
+		if err != nil {
+			return err
+		}
+		exporterOpts = append(exporterOpts, otlptracehttp.WithEndpointURL(endpoint))
+	}
+	exporter, err := otlptracehttp.New(ctx, exporterOpts...)
+	if err != nil {
+		return fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(constants.Version),
+	))
+	if err != nil {
+		return fmt.Errorf("failed to create trace resource: %w", err)
+	}
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return nil
+}
+
+// StartCommand starts the root span for a CLI command. All spans started from the
+// returned context are children of the command span.
+func StartCommand(ctx context.Context, commandPath string) context.Context {
+	ctx, commandSpan = tracer().Start(ctx, commandPath, trace.WithSpanKind(trace.SpanKindInternal))
+	return ctx
+}
+
+// Shutdown ends the command span (if any), recording cmdErr on it, and flushes all
+// pending spans to the exporter.
+func Shutdown(cmdErr error) error {
+	if commandSpan != nil {
+		EndSpan(commandSpan, cmdErr)
+		commandSpan = nil
+	}
+	if provider == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := provider.Shutdown(ctx)
+	provider = nil
+	if err != nil {
+		return fmt.Errorf("failed to export traces: %w", err)
+	}
+	return nil
+}
+
+// StartSpan starts a span as a child of any span in ctx.
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// EndSpan records err (if non-nil) on span and ends it.
+func EndSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, context.Canceled) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// DescriptorAttributes returns the digest, size, and media type attributes for desc.
+func DescriptorAttributes(desc ocispec.Descriptor) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		DigestKey.String(desc.Digest.String()),
+		SizeKey.Int64(desc.Size),
+		MediaTypeKey.String(desc.MediaType),
+	}
+}
+
+// Transport wraps rt so that each round trip is recorded as a span. Spans are
+// children of the span in the request's context.
+func Transport(rt http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(rt)
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// tracesEndpointURL converts the configured endpoint to the URL spans are sent to,
+// appending the default OTLP/HTTP traces path if the endpoint does not include a path.
+func tracesEndpointURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid tracing endpoint %s: %w", endpoint, err)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = defaultTracesPath
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"kitops/pkg/lib/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+	"oras.land/oras-go/v2/content"
+)
+
+// collector is a minimal stand-in for an OTLP/HTTP collector that records received spans.
+type collector struct {
+	mu    sync.Mutex
+	spans []*tracepb.Span
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != defaultTracesPath {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	req := &collectortrace.ExportTraceServiceRequest{}
+	if err := proto.Unmarshal(body, req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			c.spans = append(c.spans, ss.Spans...)
+		}
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *collector) span(name string) *tracepb.Span {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, span := range c.spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	return nil
+}
+
+func spanAttributes(span *tracepb.Span) map[string]any {
+	attrs := map[string]any{}
+	for _, kv := range span.Attributes {
+		switch v := kv.Value.Value.(type) {
+		case *commonpb.AnyValue_StringValue:
+			attrs[kv.Key] = v.StringValue
+		case *commonpb.AnyValue_IntValue:
+			attrs[kv.Key] = v.IntValue
+		}
+	}
+	return attrs
+}
+
+func TestTracingExportsSpans(t *testing.T) {
+	for _, env := range []string{otlpEndpointEnvVar, otlpTracesEndpointEnvVar, sdkDisabledEnvVar} {
+		t.Setenv(env, "")
+	}
+	coll := &collector{}
+	collectorSrv := httptest.NewServer(coll)
+	defer collectorSrv.Close()
+	registrySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer registrySrv.Close()
+
+	require.NoError(t, Setup(context.Background(), config.TracingConfig{Enabled: true, Endpoint: collectorSrv.URL}))
+	ctx := StartCommand(context.Background(), "kit push")
+
+	desc := content.NewDescriptorFromBytes("application/vnd.kitops.modelkit.model.v1.tar", []byte("model"))
+	spanCtx, span := StartSpan(ctx, "uploadBlob", DescriptorAttributes(desc)...)
+	client := &http.Client{Transport: Transport(http.DefaultTransport)}
+	req, err := http.NewRequestWithContext(spanCtx, http.MethodHead, registrySrv.URL+"/v2/", nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	EndSpan(span, errors.New("upload failed"))
+
+	require.NoError(t, Shutdown(nil))
+
+	cmdSpan := coll.span("kit push")
+	require.NotNil(t, cmdSpan, "command span should be exported")
+	uploadSpan := coll.span("uploadBlob")
+	require.NotNil(t, uploadSpan, "uploadBlob span should be exported")
+	httpSpan := coll.span("HTTP HEAD")
+	require.NotNil(t, httpSpan, "HTTP client span should be exported")
+
+	assert.Equal(t, cmdSpan.SpanId, uploadSpan.ParentSpanId)
+	assert.Equal(t, uploadSpan.SpanId, httpSpan.ParentSpanId)
+	assert.Equal(t, tracepb.Status_STATUS_CODE_ERROR, uploadSpan.Status.Code)
+	assert.Equal(t, map[string]any{
+		string(DigestKey):    desc.Digest.String(),
+		string(SizeKey):      desc.Size,
+		string(MediaTypeKey): desc.MediaType,
+	}, spanAttributes(uploadSpan))
+}
+
+func TestTracingDisabled(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.TracingConfig
+		env     map[string]string
+		enabled bool
+	}{
+		{name: "disabled by default", enabled: false},
+		{name: "enabled in config", cfg: config.TracingConfig{Enabled: true}, enabled: true},
+		{name: "enabled by endpoint in config", cfg: config.TracingConfig{Endpoint: "http://localhost:4318"}, enabled: true},
+		{name: "enabled by environment", env: map[string]string{otlpEndpointEnvVar: "http://localhost:4318"}, enabled: true},
+		{name: "enabled by traces environment", env: map[string]string{otlpTracesEndpointEnvVar: "http://localhost:4318/v1/traces"}, enabled: true},
+		{
+			name:    "SDK disabled overrides config",
+			cfg:     config.TracingConfig{Enabled: true},
+			env:     map[string]string{sdkDisabledEnvVar: "true"},
+			enabled: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, env := range []string{otlpEndpointEnvVar, otlpTracesEndpointEnvVar, sdkDisabledEnvVar} {
+				t.Setenv(env, tt.env[env])
+			}
+			assert.Equal(t, tt.enabled, Enabled(tt.cfg))
+		})
+	}
+}
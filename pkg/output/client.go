@@ -25,6 +25,7 @@ import (
 
 type LoggingClient struct {
 	remote.Client
+	logger *Logger
 }
 
 func (c *LoggingClient) Do(req *http.Request) (*http.Response, error) {
@@ -32,9 +33,9 @@ func (c *LoggingClient) Do(req *http.Request) (*http.Response, error) {
 	resp, err := c.Client.Do(req)
 	duration := float64(time.Since(start)) / float64(time.Millisecond)
 	if err != nil {
-		SafeLogf(LogLevelTrace, "%s %s -> ERROR -- duration %.2f ms", req.Method, req.URL, duration)
+		c.logger.SafeLogf(LogLevelTrace, "%s %s -> ERROR -- duration %.2f ms", req.Method, req.URL, duration)
 	} else {
-		SafeLogf(LogLevelTrace, "%s %s -> %d -- duration %.2f ms", req.Method, req.URL, resp.StatusCode, duration)
+		c.logger.SafeLogf(LogLevelTrace, "%s %s -> %d -- duration %.2f ms", req.Method, req.URL, resp.StatusCode, duration)
 	}
 	return resp, err
 }
@@ -49,3 +50,15 @@ func WrapClient(c remote.Client) remote.Client {
 	}
 	return c
 }
+
+// WrapClient returns a remote.Client that logs every request to l at a 'trace' level.
+// If l would not print 'trace' logs, this is a no-op.
+func (l *Logger) WrapClient(c remote.Client) remote.Client {
+	if l.shouldPrint(LogLevelTrace) {
+		return &LoggingClient{
+			Client: c,
+			logger: l,
+		}
+	}
+	return c
+}
@@ -24,8 +24,11 @@ import (
 
 var (
 	logLevel                  = LogLevelInfo
+	logFormat                 = LogFormatText
+	logCommand                = ""
 	progressStyle             = "plain"
 	progressEnabled           = true
+	progressEvents            = false
 	stdout          io.Writer = os.Stdout
 	stderr          io.Writer = os.Stderr
 )
@@ -52,9 +55,31 @@ func SetLogLevelFromString(level string) error {
 	return nil
 }
 
+// SetLogFormat configures the format of log lines. In the json format, each log line
+// is a JSON object and progress bars are replaced by periodic progress events.
+func SetLogFormat(format string) error {
+	switch LogFormat(format) {
+	case LogFormatText:
+		logFormat = LogFormatText
+		progressEvents = false
+	case LogFormatJSON:
+		logFormat = LogFormatJSON
+		progressEnabled = false
+		progressEvents = true
+	default:
+		return fmt.Errorf("invalid log format '%s'. Options are 'text', 'json'", format)
+	}
+	return nil
+}
+
+// SetLogCommand sets the command name included in JSON log lines.
+func SetLogCommand(command string) {
+	logCommand = command
+}
+
 func SetProgressBars(style string) {
 	progressStyle = style
-	progressEnabled = shouldPrintProgress()
+	progressEnabled = !StructuredOutput() && logFormat == LogFormatText && shouldPrintProgress()
 }
 
 func ProgressEnabled() bool {
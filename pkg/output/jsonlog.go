@@ -0,0 +1,81 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type LogFormat string
+
+const (
+	LogFormatText LogFormat = "text"
+	LogFormatJSON LogFormat = "json"
+)
+
+// Fields are structured values attached to a log line, e.g. a digest or number of bytes.
+// Fields are included as keys in JSON log lines and are ignored for text logs, so the
+// message itself should remain meaningful without them.
+type Fields map[string]any
+
+// FieldLogger logs messages with a set of structured fields.
+type FieldLogger struct {
+	fields Fields
+	logger *Logger
+}
+
+// WithFields returns a logger that attaches fields to each line it logs.
+func WithFields(fields Fields) *FieldLogger {
+	return &FieldLogger{fields: fields}
+}
+
+func (fl *FieldLogger) Infof(s string, args ...any) {
+	fl.Logf(LogLevelInfo, s, args...)
+}
+
+func (fl *FieldLogger) Debugf(s string, args ...any) {
+	fl.Logf(LogLevelDebug, s, args...)
+}
+
+func (fl *FieldLogger) Logf(level LogLevel, s string, args ...any) {
+	fl.logger.logf(level, fl.fields, s, args...)
+}
+
+// writeJSONLog writes a single log line as a JSON object. Fields cannot override the
+// standard keys (time, level, command, message).
+func writeJSONLog(w io.Writer, level LogLevel, msg string, fields Fields) {
+	entry := make(map[string]any, len(fields)+4)
+	for k, v := range fields {
+		entry[k] = v
+	}
+	entry["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	entry["level"] = level.String()
+	entry["message"] = strings.TrimRight(msg, "\n")
+	if logCommand != "" {
+		entry["command"] = logCommand
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		fmt.Fprintf(w, `{"level":"error","message":%q}`+"\n", "failed to format log line: "+err.Error())
+		return
+	}
+	fmt.Fprintln(w, string(line))
+}
@@ -0,0 +1,69 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONLogFormat(t *testing.T) {
+	stdoutBuf := &bytes.Buffer{}
+	SetOut(stdoutBuf)
+	require.NoError(t, SetLogFormat("json"))
+	SetLogCommand("kit test")
+	t.Cleanup(func() {
+		SetLogFormat("text")
+		SetLogCommand("")
+	})
+
+	WithFields(Fields{"digest": "sha256:abc", "bytes": 42}).Infof("Saved %s layer", "model")
+	Debugf("not printed at info level")
+
+	// A reader tracking progress should emit a final progress event once complete
+	rc := newProgressEventReadCloser(io.NopCloser(strings.NewReader("0123456789")), newProgressTracker("pull", "", "sha256:abc", 10, 0))
+	_, err := io.ReadAll(rc)
+	require.NoError(t, err)
+
+	var lines []map[string]any
+	scanner := bufio.NewScanner(stdoutBuf)
+	for scanner.Scan() {
+		line := map[string]any{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line), "each log line should be a JSON object")
+		lines = append(lines, line)
+	}
+	require.Len(t, lines, 2)
+
+	assert.Equal(t, "info", lines[0]["level"])
+	assert.Equal(t, "kit test", lines[0]["command"])
+	assert.Equal(t, "Saved model layer", lines[0]["message"])
+	assert.Equal(t, "sha256:abc", lines[0]["digest"])
+	assert.Equal(t, float64(42), lines[0]["bytes"])
+	assert.NotEmpty(t, lines[0]["time"])
+
+	assert.Equal(t, "complete", lines[1]["event"])
+	assert.Equal(t, "pull", lines[1]["operation"])
+	assert.Equal(t, float64(10), lines[1]["bytes"])
+	assert.Equal(t, float64(10), lines[1]["totalBytes"])
+}
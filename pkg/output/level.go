@@ -25,10 +25,12 @@ import (
 	"golang.org/x/term"
 )
 
+// LogLevel is the severity of a log message. Levels are ordered from least to most severe, and
+// the zero value is LogLevelInfo.
 type LogLevel int
 
 const (
-	LogLevelTrace LogLevel = iota
+	LogLevelTrace LogLevel = iota - 2
 	LogLevelDebug
 	LogLevelInfo
 	LogLevelWarn
@@ -54,11 +56,32 @@ func init() {
 	}
 }
 
+func (l LogLevel) String() string {
+	switch l {
+	case LogLevelTrace:
+		return "trace"
+	case LogLevelDebug:
+		return "debug"
+	case LogLevelInfo:
+		return "info"
+	case LogLevelWarn:
+		return "warn"
+	case LogLevelError:
+		return "error"
+	default:
+		return fmt.Sprintf("LogLevel(%d)", int(l))
+	}
+}
+
 func (l LogLevel) shouldPrint(atLevel LogLevel) bool {
 	return l <= atLevel
 }
 
 func (l LogLevel) getOutput() io.Writer {
+	if StructuredOutput() {
+		// Keep stdout clear for the result document
+		return stderr
+	}
 	switch l {
 	case LogLevelError, LogLevelWarn:
 		return stderr
@@ -0,0 +1,194 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package output
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ProgressEvent describes the progress of a single transfer, e.g. pushing or pulling
+// a blob or packing a layer.
+type ProgressEvent struct {
+	// Operation is the kind of transfer: pack, unpack, push, pull, or download
+	Operation string `json:"operation"`
+	// Name identifies the transfer when there is no digest, e.g. a file being downloaded
+	Name       string `json:"name,omitempty"`
+	Digest     string `json:"digest,omitempty"`
+	Bytes      int64  `json:"bytes"`
+	TotalBytes int64  `json:"totalBytes,omitempty"`
+	// Done is true for the final event of a transfer
+	Done bool `json:"done"`
+}
+
+// ProgressFunc is called with progress updates for transfers. It may be called
+// concurrently for different transfers.
+type ProgressFunc func(ProgressEvent)
+
+// Logger handles log messages and progress for operations that are run with a context
+// carrying it (see WithLogger). This allows code that embeds Kit to capture output for a
+// single operation rather than using the process-wide configuration set by the CLI.
+//
+// The default logger (see DefaultLogger) uses the process-wide configuration. Functions
+// that accept a context should log via FromContext(ctx) so that either can be used.
+type Logger struct {
+	mu       sync.Mutex
+	level    LogLevel
+	out      io.Writer
+	progress ProgressFunc
+}
+
+type loggerKey struct{}
+
+var defaultLogger = &Logger{}
+
+// NewLogger returns a logger that writes messages at or above level to out and passes
+// progress updates to progress. Either out or progress may be nil to discard messages or
+// progress, respectively.
+func NewLogger(out io.Writer, level LogLevel, progress ProgressFunc) *Logger {
+	return &Logger{
+		level:    level,
+		out:      out,
+		progress: progress,
+	}
+}
+
+// DefaultLogger returns the logger that writes to the process-wide output configured
+// via SetOut, SetLogLevel, etc.
+func DefaultLogger() *Logger {
+	return defaultLogger
+}
+
+// WithLogger returns a copy of ctx carrying logger.
+func WithLogger(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// HasLogger returns true if ctx carries a logger set via WithLogger.
+func HasLogger(ctx context.Context) bool {
+	_, ok := ctx.Value(loggerKey{}).(*Logger)
+	return ok
+}
+
+// FromContext returns the logger carried by ctx, or the default logger if there is none.
+func FromContext(ctx context.Context) *Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*Logger); ok && logger != nil {
+		return logger
+	}
+	return defaultLogger
+}
+
+func (l *Logger) isDefault() bool {
+	return l == nil || l == defaultLogger
+}
+
+func (l *Logger) Infoln(s any) {
+	l.logln(LogLevelInfo, nil, s)
+}
+
+func (l *Logger) Infof(s string, args ...any) {
+	l.logf(LogLevelInfo, nil, s, args...)
+}
+
+func (l *Logger) Errorln(s any) {
+	l.logln(LogLevelError, nil, s)
+}
+
+func (l *Logger) Errorf(s string, args ...any) {
+	l.logf(LogLevelError, nil, s, args...)
+}
+
+func (l *Logger) Debugln(s any) {
+	l.logln(LogLevelDebug, nil, s)
+}
+
+func (l *Logger) Debugf(s string, args ...any) {
+	l.logf(LogLevelDebug, nil, s, args...)
+}
+
+func (l *Logger) Logln(level LogLevel, s any) {
+	l.logln(level, nil, s)
+}
+
+func (l *Logger) Logf(level LogLevel, s string, args ...any) {
+	l.logf(level, nil, s, args...)
+}
+
+// SafeDebugf is the same as Debugf except that, for the default logger, it will only
+// print if progress bars are disabled to avoid confusing output.
+func (l *Logger) SafeDebugf(s string, args ...any) {
+	l.SafeLogf(LogLevelDebug, s, args...)
+}
+
+// SafeLogf is the same as Logf except that, for the default logger, it will only
+// print if progress bars are disabled to avoid confusing output.
+func (l *Logger) SafeLogf(level LogLevel, s string, args ...any) {
+	if l.isDefault() {
+		SafeLogf(level, s, args...)
+		return
+	}
+	l.logf(level, nil, s, args...)
+}
+
+// WithFields returns a logger that attaches fields to each line it logs.
+func (l *Logger) WithFields(fields Fields) *FieldLogger {
+	return &FieldLogger{fields: fields, logger: l}
+}
+
+func (l *Logger) shouldPrint(level LogLevel) bool {
+	if l.isDefault() {
+		return logLevel.shouldPrint(level)
+	}
+	return l.out != nil && l.level.shouldPrint(level)
+}
+
+func (l *Logger) logln(level LogLevel, fields Fields, s any) {
+	if l.isDefault() {
+		loglnTo(level.getOutput(), level, fields, s)
+		return
+	}
+	if l.shouldPrint(level) {
+		l.write(level, formatln(s))
+	}
+}
+
+func (l *Logger) logf(level LogLevel, fields Fields, s string, args ...any) {
+	if l.isDefault() {
+		logfTo(level.getOutput(), level, fields, s, args...)
+		return
+	}
+	if l.shouldPrint(level) {
+		l.write(level, formatf(s, args...))
+	}
+}
+
+// write writes a formatted line to the logger's output, prefixed with its level. Lines
+// may be logged concurrently, so writes are serialized.
+func (l *Logger) write(level LogLevel, line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(l.out, "[%-5s] %s", strings.ToUpper(level.String()), line)
+}
+
+func (l *Logger) emitProgress(event ProgressEvent) {
+	if l.progress != nil {
+		l.progress(event)
+	}
+}
@@ -43,12 +43,14 @@ func Errorf(s string, args ...any) {
 
 // Fatalln is the equivalent of Errorln except it returns a basic error to signal the command has failed
 func Fatalln(s any) error {
+	commandError = fmt.Sprint(s)
 	Logln(LogLevelError, s)
 	return errors.New("failed to run")
 }
 
 // Fatalf is the equivalent of Errorf except it returns a basic error to signal the command has failed
 func Fatalf(s string, args ...any) error {
+	commandError = fmt.Sprintf(s, args...)
 	Logf(LogLevelError, s, args...)
 	return errors.New("failed to run")
 }
@@ -74,37 +76,55 @@ func SafeDebugf(s string, args ...any) {
 }
 
 func Logln(level LogLevel, s any) {
-	loglnTo(level.getOutput(), level, s)
+	loglnTo(level.getOutput(), level, nil, s)
 }
 
-func loglnTo(output io.Writer, level LogLevel, s any) {
+func loglnTo(output io.Writer, level LogLevel, fields Fields, s any) {
 	if logLevel.shouldPrint(level) {
-		str := fmt.Sprintln(s)
-		// Capitalize first letter in string for nicer output, in case it's not already capitalized
-		str = strings.ToUpper(str[:1]) + str[1:]
+		str := formatln(s)
+		if logFormat == LogFormatJSON {
+			writeJSONLog(output, level, str, fields)
+			return
+		}
 		str = level.getPrefix() + str
 		fmt.Fprint(output, str)
 	}
 }
 
 func Logf(level LogLevel, s string, args ...any) {
-	logfTo(level.getOutput(), level, s, args...)
+	logfTo(level.getOutput(), level, nil, s, args...)
 }
 
-func logfTo(output io.Writer, level LogLevel, s string, args ...any) {
+func logfTo(output io.Writer, level LogLevel, fields Fields, s string, args ...any) {
 	if logLevel.shouldPrint(level) {
-		// Avoid printing incomplete lines
-		if !strings.HasSuffix(s, "\n") {
-			s = s + "\n"
+		str := formatf(s, args...)
+		if logFormat == LogFormatJSON {
+			writeJSONLog(output, level, str, fields)
+			return
 		}
-		str := fmt.Sprintf(s, args...)
-		// Capitalize first letter in string for nicer output, in case it's not already capitalized
-		str = strings.ToUpper(str[:1]) + str[1:]
 		str = level.getPrefix() + str
 		fmt.Fprint(output, str)
 	}
 }
 
+// formatln formats s as a complete log line.
+func formatln(s any) string {
+	str := fmt.Sprintln(s)
+	// Capitalize first letter in string for nicer output, in case it's not already capitalized
+	return strings.ToUpper(str[:1]) + str[1:]
+}
+
+// formatf formats s with args as a complete log line.
+func formatf(s string, args ...any) string {
+	// Avoid printing incomplete lines
+	if !strings.HasSuffix(s, "\n") {
+		s = s + "\n"
+	}
+	str := fmt.Sprintf(s, args...)
+	// Capitalize first letter in string for nicer output, in case it's not already capitalized
+	return strings.ToUpper(str[:1]) + str[1:]
+}
+
 func SafeLogln(level LogLevel, s any) {
 	if !progressEnabled {
 		Logln(level, s)
@@ -123,6 +143,18 @@ func SafeLogf(level LogLevel, s string, args ...any) {
 // method should be called.
 type ProgressLogger struct {
 	output io.Writer
+	// logger is set when progress is reported to a non-default Logger, in which case
+	// lines are logged to it instead of output.
+	logger *Logger
+}
+
+// writerFor returns the writer log lines at level should be written to. If no progress
+// bar is in use, this is the default output for the level.
+func (pw *ProgressLogger) writerFor(level LogLevel) io.Writer {
+	if pw.output == nil {
+		return level.getOutput()
+	}
+	return pw.output
 }
 
 // Wait will call Wait() on the underlying mpb.Progress, if present. Otherwise,
@@ -134,37 +166,33 @@ func (pw *ProgressLogger) Wait() {
 }
 
 func (pw *ProgressLogger) Infoln(s any) {
-	if logLevel.shouldPrint(LogLevelInfo) {
-		loglnTo(pw.output, LogLevelInfo, s)
-	}
+	pw.Logln(LogLevelInfo, s)
 }
 
 func (pw *ProgressLogger) Infof(s string, args ...any) {
-	if logLevel.shouldPrint(LogLevelInfo) {
-		logfTo(pw.output, LogLevelInfo, s, args...)
-	}
+	pw.Logf(LogLevelInfo, s, args...)
 }
 
 func (pw *ProgressLogger) Debugln(s any) {
-	if logLevel.shouldPrint(LogLevelDebug) {
-		loglnTo(pw.output, LogLevelDebug, s)
-	}
+	pw.Logln(LogLevelDebug, s)
 }
 
 func (pw *ProgressLogger) Debugf(s string, args ...any) {
-	if logLevel.shouldPrint(LogLevelDebug) {
-		logfTo(pw.output, LogLevelDebug, s, args...)
-	}
+	pw.Logf(LogLevelDebug, s, args...)
 }
 
 func (pw *ProgressLogger) Logln(level LogLevel, s any) {
-	if logLevel.shouldPrint(level) {
-		loglnTo(pw.output, level, s)
+	if !pw.logger.isDefault() {
+		pw.logger.logln(level, nil, s)
+	} else if logLevel.shouldPrint(level) {
+		loglnTo(pw.writerFor(level), level, nil, s)
 	}
 }
 
 func (pw *ProgressLogger) Logf(level LogLevel, s string, args ...any) {
-	if logLevel.shouldPrint(level) {
-		logfTo(pw.output, level, s, args...)
+	if !pw.logger.isDefault() {
+		pw.logger.logf(level, nil, s, args...)
+	} else if logLevel.shouldPrint(level) {
+		logfTo(pw.writerFor(level), level, nil, s, args...)
 	}
 }
@@ -0,0 +1,191 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package output
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+)
+
+var (
+	// progressEventInterval is the minimum time between progress events for a single transfer
+	progressEventInterval = 5 * time.Second
+	// progressFuncInterval is the minimum time between calls to a Logger's ProgressFunc for
+	// a single transfer
+	progressFuncInterval = 250 * time.Millisecond
+)
+
+// progressTracker emits periodic progress events, either as JSON log lines or to a Logger's
+// ProgressFunc. It is used instead of progress bars when the log format is JSON or when
+// progress is reported to a non-default Logger.
+type progressTracker struct {
+	mu        sync.Mutex
+	logger    *Logger
+	interval  time.Duration
+	operation string
+	name      string
+	digest    string
+	total     int64
+	current   int64
+	lastEvent time.Time
+	done      bool
+}
+
+func newProgressTracker(operation, name, digest string, total, offset int64) *progressTracker {
+	return &progressTracker{
+		operation: operation,
+		name:      name,
+		digest:    digest,
+		total:     total,
+		current:   offset,
+		interval:  progressEventInterval,
+		lastEvent: time.Now(),
+	}
+}
+
+// newProgressTracker returns a tracker that reports progress to l's ProgressFunc
+func (l *Logger) newProgressTracker(operation, name, digest string, total, offset int64) *progressTracker {
+	tracker := newProgressTracker(operation, name, digest, total, offset)
+	tracker.logger = l
+	tracker.interval = progressFuncInterval
+	return tracker
+}
+
+// add records n bytes of progress, emitting an event if progressEventInterval has passed
+// since the last one or if the transfer is complete.
+func (t *progressTracker) add(n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.current += int64(n)
+	if t.done {
+		return
+	}
+	if t.total > 0 && t.current >= t.total {
+		t.emit("complete")
+		t.done = true
+	} else if time.Since(t.lastEvent) >= t.interval {
+		t.emit("progress")
+	}
+}
+
+// finish emits a final event if one has not been emitted already.
+func (t *progressTracker) finish() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.done {
+		t.emit("complete")
+		t.done = true
+	}
+}
+
+func (t *progressTracker) emit(event string) {
+	t.lastEvent = time.Now()
+	if t.logger != nil {
+		t.logger.emitProgress(ProgressEvent{
+			Operation:  t.operation,
+			Name:       t.name,
+			Digest:     t.digest,
+			Bytes:      t.current,
+			TotalBytes: t.total,
+			Done:       event == "complete",
+		})
+		return
+	}
+	fields := Fields{
+		"event":     event,
+		"operation": t.operation,
+		"bytes":     t.current,
+	}
+	if t.name != "" {
+		fields["name"] = t.name
+	}
+	if t.digest != "" {
+		fields["digest"] = t.digest
+	}
+	if t.total > 0 {
+		fields["totalBytes"] = t.total
+	}
+	logfTo(LogLevelInfo.getOutput(), LogLevelInfo, fields, "%s %s: %s / %s", t.operation, event, FormatBytes(t.current), FormatBytes(t.total))
+}
+
+// eventRepo wraps oras.Target to emit progress events on Push() operations.
+type eventRepo struct {
+	oras.Target
+	// logger receives progress events, if set. Otherwise, events are logged as JSON
+	logger *Logger
+}
+
+func (r *eventRepo) Push(ctx context.Context, expected ocispec.Descriptor, content io.Reader) error {
+	var tracker *progressTracker
+	if r.logger != nil {
+		tracker = r.logger.newProgressTracker("push", "", expected.Digest.String(), expected.Size, 0)
+	} else {
+		tracker = newProgressTracker("push", "", expected.Digest.String(), expected.Size, 0)
+	}
+	return r.Target.Push(ctx, expected, &progressEventReader{Reader: content, tracker: tracker})
+}
+
+type progressEventReader struct {
+	io.Reader
+	tracker *progressTracker
+}
+
+func (r *progressEventReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.tracker.add(n)
+	if err == io.EOF {
+		r.tracker.finish()
+	}
+	return n, err
+}
+
+type progressEventReadCloser struct {
+	progressEventReader
+	closer io.Closer
+}
+
+func (rc *progressEventReadCloser) Close() error {
+	return rc.closer.Close()
+}
+
+func newProgressEventReadCloser(rc io.ReadCloser, tracker *progressTracker) io.ReadCloser {
+	return &progressEventReadCloser{
+		progressEventReader: progressEventReader{Reader: rc, tracker: tracker},
+		closer:              rc,
+	}
+}
+
+type progressEventWriter struct {
+	io.Writer
+	tracker *progressTracker
+}
+
+func (w *progressEventWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.tracker.add(n)
+	return n, err
+}
+
+func (w *progressEventWriter) Close() error {
+	w.tracker.finish()
+	return nil
+}
@@ -56,8 +56,15 @@ func barStyle() mpb.BarStyleComposer {
 	}
 }
 
+// GenericProgressBar returns a progress bar for the default Logger. See Logger.GenericProgressBar.
 func GenericProgressBar(name, doneMsg string, total int64) *ProgressBar {
-	if !progressEnabled {
+	return DefaultLogger().GenericProgressBar(name, doneMsg, total)
+}
+
+// GenericProgressBar returns a progress bar that counts up to total steps. Progress bars are
+// only shown in the terminal for the default Logger; for other Loggers, this is a no-op.
+func (l *Logger) GenericProgressBar(name, doneMsg string, total int64) *ProgressBar {
+	if !l.isDefault() || !progressEnabled {
 		return &ProgressBar{}
 	}
 	p := mpb.New(
@@ -122,11 +129,26 @@ func (w *wrappedRepo) Push(ctx context.Context, expected ocispec.Descriptor, con
 	return w.Target.Push(ctx, expected, proxyReader)
 }
 
-// WrapTarget wraps an oras.Target so that calls to Push print a progress bar.
-// If output is configured to not print progress bars, this is a no-op.
+// WrapTarget wraps an oras.Target so that progress of calls to Push is reported to the default
+// Logger. See Logger.WrapTarget.
 func WrapTarget(wrap oras.Target) (oras.Target, *ProgressLogger) {
+	return DefaultLogger().WrapTarget(wrap)
+}
+
+// WrapTarget wraps an oras.Target so that progress of calls to Push is reported to l. For the
+// default Logger, a progress bar is printed unless output is configured to not print progress bars.
+func (l *Logger) WrapTarget(wrap oras.Target) (oras.Target, *ProgressLogger) {
+	if !l.isDefault() {
+		if l.progress == nil {
+			return wrap, &ProgressLogger{logger: l}
+		}
+		return &eventRepo{Target: wrap, logger: l}, &ProgressLogger{logger: l}
+	}
+	if progressEvents {
+		return &eventRepo{Target: wrap}, &ProgressLogger{}
+	}
 	if !progressEnabled {
-		return wrap, &ProgressLogger{stdout}
+		return wrap, &ProgressLogger{}
 	}
 	p := mpb.New(
 		mpb.WithWidth(60),
@@ -135,12 +157,28 @@ func WrapTarget(wrap oras.Target) (oras.Target, *ProgressLogger) {
 	return &wrappedRepo{
 		Target:   wrap,
 		progress: p,
-	}, &ProgressLogger{p}
+	}, &ProgressLogger{output: p}
 }
 
+// WrapUnpackReadCloser wraps rc so that progress reading it is reported to the default Logger.
+// See Logger.WrapUnpackReadCloser.
 func WrapUnpackReadCloser(size int64, rc io.ReadCloser) (io.ReadCloser, *ProgressLogger) {
+	return DefaultLogger().WrapUnpackReadCloser(size, rc)
+}
+
+// WrapUnpackReadCloser wraps rc so that progress reading it is reported to l.
+func (l *Logger) WrapUnpackReadCloser(size int64, rc io.ReadCloser) (io.ReadCloser, *ProgressLogger) {
+	if !l.isDefault() {
+		if l.progress == nil {
+			return rc, &ProgressLogger{logger: l}
+		}
+		return newProgressEventReadCloser(rc, l.newProgressTracker("unpack", "", "", size, 0)), &ProgressLogger{logger: l}
+	}
+	if progressEvents {
+		return newProgressEventReadCloser(rc, newProgressTracker("unpack", "", "", size, 0)), &ProgressLogger{}
+	}
 	if !progressEnabled {
-		return rc, &ProgressLogger{stdout}
+		return rc, &ProgressLogger{}
 	}
 
 	p := mpb.New(
@@ -160,7 +198,7 @@ func WrapUnpackReadCloser(size int64, rc io.ReadCloser) (io.ReadCloser, *Progres
 		mpb.BarRemoveOnComplete(),
 	)
 
-	return bar.ProxyReader(rc), &ProgressLogger{p}
+	return bar.ProxyReader(rc), &ProgressLogger{output: p}
 }
 
 type ProgressTar struct {
@@ -187,9 +225,27 @@ func (t *ProgressTar) Close() error {
 	return nil
 }
 
+// TarProgress wraps tw so that progress writing total bytes to it is reported to the default
+// Logger. See Logger.TarProgress.
 func TarProgress(total int64, tw *tar.Writer) (*ProgressTar, *ProgressLogger) {
+	return DefaultLogger().TarProgress(total, tw)
+}
+
+// TarProgress wraps tw so that progress writing total bytes to it is reported to l.
+func (l *Logger) TarProgress(total int64, tw *tar.Writer) (*ProgressTar, *ProgressLogger) {
+	if !l.isDefault() {
+		if l.progress == nil || total == 0 {
+			return &ProgressTar{tw: tw}, &ProgressLogger{logger: l}
+		}
+		pw := &progressEventWriter{Writer: tw, tracker: l.newProgressTracker("pack", "", "", total, 0)}
+		return &ProgressTar{tw: tw, pw: pw}, &ProgressLogger{logger: l}
+	}
+	if progressEvents && total > 0 {
+		pw := &progressEventWriter{Writer: tw, tracker: newProgressTracker("pack", "", "", total, 0)}
+		return &ProgressTar{tw: tw, pw: pw}, &ProgressLogger{}
+	}
 	if !progressEnabled || total == 0 {
-		return &ProgressTar{tw: tw}, &ProgressLogger{stdout}
+		return &ProgressTar{tw: tw}, &ProgressLogger{}
 	}
 
 	p := mpb.New(
@@ -209,7 +265,7 @@ func TarProgress(total int64, tw *tar.Writer) (*ProgressTar, *ProgressLogger) {
 		mpb.BarRemoveOnComplete(),
 	)
 	pw := bar.ProxyWriter(tw)
-	return &ProgressTar{tw: tw, pw: pw, bar: bar}, &ProgressLogger{p}
+	return &ProgressTar{tw: tw, pw: pw, bar: bar}, &ProgressLogger{output: p}
 }
 
 type PullProgress struct {
@@ -218,6 +274,15 @@ type PullProgress struct {
 }
 
 func (p *PullProgress) ProxyWriter(w io.Writer, digest string, size, offset int64) io.Writer {
+	if !p.logger.isDefault() {
+		if p.logger.progress == nil {
+			return w
+		}
+		return &progressEventWriter{Writer: w, tracker: p.logger.newProgressTracker("pull", "", digest, size, offset)}
+	}
+	if progressEvents {
+		return &progressEventWriter{Writer: w, tracker: newProgressTracker("pull", "", digest, size, offset)}
+	}
 	if !progressEnabled || p.progress == nil {
 		return w
 	}
@@ -245,10 +310,22 @@ func (p *PullProgress) Done() {
 	}
 }
 
+// NewPullProgress returns a PullProgress that reports progress to the default Logger. See
+// Logger.NewPullProgress.
 func NewPullProgress(ctx context.Context) *PullProgress {
+	return DefaultLogger().NewPullProgress(ctx)
+}
+
+// NewPullProgress returns a PullProgress that reports progress to l.
+func (l *Logger) NewPullProgress(ctx context.Context) *PullProgress {
+	if !l.isDefault() {
+		return &PullProgress{
+			ProgressLogger: ProgressLogger{logger: l},
+		}
+	}
 	if !progressEnabled {
 		return &PullProgress{
-			ProgressLogger: ProgressLogger{stdout},
+			ProgressLogger: ProgressLogger{},
 		}
 	}
 	p := mpb.NewWithContext(ctx,
@@ -257,17 +334,29 @@ func NewPullProgress(ctx context.Context) *PullProgress {
 	)
 	return &PullProgress{
 		progress:       p,
-		ProgressLogger: ProgressLogger{p},
+		ProgressLogger: ProgressLogger{output: p},
 	}
 }
 
 type DownloadProgressBar struct {
 	progress *mpb.Progress
+	// logger receives progress for downloads, if set to a non-default Logger
+	logger *Logger
 }
 
+// NewDownloadProgress returns a DownloadProgressBar that reports progress to the default Logger.
+// See Logger.NewDownloadProgress.
 func NewDownloadProgress() (*DownloadProgressBar, *ProgressLogger) {
+	return DefaultLogger().NewDownloadProgress()
+}
+
+// NewDownloadProgress returns a DownloadProgressBar that reports progress to l.
+func (l *Logger) NewDownloadProgress() (*DownloadProgressBar, *ProgressLogger) {
+	if !l.isDefault() {
+		return &DownloadProgressBar{logger: l}, &ProgressLogger{logger: l}
+	}
 	if !progressEnabled {
-		return &DownloadProgressBar{}, &ProgressLogger{stdout}
+		return &DownloadProgressBar{}, &ProgressLogger{}
 	}
 	p := mpb.New(
 		mpb.WithWidth(30),
@@ -275,10 +364,19 @@ func NewDownloadProgress() (*DownloadProgressBar, *ProgressLogger) {
 	)
 	return &DownloadProgressBar{
 		progress: p,
-	}, &ProgressLogger{p}
+	}, &ProgressLogger{output: p}
 }
 
 func (pb *DownloadProgressBar) TrackDownload(rc io.ReadCloser, name string, totalSize int64) io.ReadCloser {
+	if !pb.logger.isDefault() {
+		if pb.logger.progress == nil {
+			return rc
+		}
+		return newProgressEventReadCloser(rc, pb.logger.newProgressTracker("download", name, "", totalSize, 0))
+	}
+	if progressEvents {
+		return newProgressEventReadCloser(rc, newProgressTracker("download", name, "", totalSize, 0))
+	}
 	if pb.progress == nil {
 		return rc
 	}
@@ -296,8 +394,20 @@ func (pb *DownloadProgressBar) TrackDownload(rc io.ReadCloser, name string, tota
 		),
 		mpb.BarRemoveOnComplete(),
 	)
-	barRC := bar.ProxyReader(rc)
-	return barRC
+	return &abortOnCloseReadCloser{ReadCloser: bar.ProxyReader(rc), bar: bar}
+}
+
+// abortOnCloseReadCloser aborts (and removes) its progress bar when closed. This is a no-op if the bar is already
+// complete, but ensures that bars for failed downloads (e.g. ones that are retried) do not block waiting for
+// progress to finish.
+type abortOnCloseReadCloser struct {
+	io.ReadCloser
+	bar *mpb.Bar
+}
+
+func (rc *abortOnCloseReadCloser) Close() error {
+	rc.bar.Abort(true)
+	return rc.ReadCloser.Close()
 }
 
 func (pb *DownloadProgressBar) Done() {
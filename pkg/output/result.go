@@ -0,0 +1,185 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"gopkg.in/yaml.v3"
+)
+
+type OutputFormat string
+
+const (
+	OutputFormatText OutputFormat = "text"
+	OutputFormatJSON OutputFormat = "json"
+	OutputFormatYAML OutputFormat = "yaml"
+)
+
+var (
+	outputFormat  = OutputFormatText
+	commandResult any
+	commandError  string
+)
+
+// ResultDocument is the structured document printed for a command when the output
+// format is JSON or YAML.
+type ResultDocument struct {
+	Command    string    `json:"command"`
+	Success    bool      `json:"success"`
+	StartTime  time.Time `json:"startTime"`
+	DurationMs int64     `json:"durationMs"`
+	Result     any       `json:"result,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// SetOutputFormat configures how command results are printed. For structured
+// formats (json, yaml), all log output is written to stderr and progress bars are
+// disabled, so that stdout contains only the result document.
+func SetOutputFormat(format string) error {
+	switch OutputFormat(format) {
+	case OutputFormatText:
+		outputFormat = OutputFormatText
+	case OutputFormatJSON, OutputFormatYAML:
+		outputFormat = OutputFormat(format)
+		progressEnabled = false
+	default:
+		return fmt.Errorf("invalid output format '%s'. Options are 'text', 'json', 'yaml'", format)
+	}
+	return nil
+}
+
+// StructuredOutput returns true if command results should be printed as a JSON or
+// YAML document instead of human-readable text.
+func StructuredOutput() bool {
+	return outputFormat != OutputFormatText
+}
+
+// SetResult stores the result of the current command. When structured output is
+// enabled, it is included in the document printed by PrintResult; otherwise it is
+// ignored, and commands are expected to print text output themselves.
+func SetResult(result any) {
+	commandResult = result
+}
+
+// PrintResult prints the result document for a command that started at startTime
+// and finished with cmdErr. If structured output is not enabled, this is a no-op.
+func PrintResult(command string, startTime time.Time, cmdErr error) error {
+	if !StructuredOutput() {
+		return nil
+	}
+	doc := ResultDocument{
+		Command:    command,
+		Success:    cmdErr == nil,
+		StartTime:  startTime.UTC(),
+		DurationMs: time.Since(startTime).Milliseconds(),
+		Result:     commandResult,
+	}
+	if cmdErr != nil {
+		doc.Error = commandError
+		if doc.Error == "" {
+			doc.Error = cmdErr.Error()
+		}
+	}
+
+	jsonBytes, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to format result: %w", err)
+	}
+	if outputFormat == OutputFormatJSON {
+		_, err := fmt.Fprintln(stdout, string(jsonBytes))
+		return err
+	}
+	yamlBytes, err := jsonToYAML(jsonBytes)
+	if err != nil {
+		return fmt.Errorf("failed to format result: %w", err)
+	}
+	_, err = stdout.Write(yamlBytes)
+	return err
+}
+
+// jsonToYAML converts JSON to block-style YAML, preserving field names and order so
+// that both structured formats describe results identically.
+func jsonToYAML(jsonBytes []byte) ([]byte, error) {
+	node := &yaml.Node{}
+	if err := yaml.Unmarshal(jsonBytes, node); err != nil {
+		return nil, err
+	}
+	var clearStyle func(*yaml.Node)
+	clearStyle = func(n *yaml.Node) {
+		n.Style = 0
+		for _, child := range n.Content {
+			clearStyle(child)
+		}
+	}
+	clearStyle(node)
+
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ModelKitResult describes a modelkit affected by a command, e.g. one that was packed,
+// pushed, or pulled.
+type ModelKitResult struct {
+	Reference string        `json:"reference,omitempty"`
+	Digest    string        `json:"digest"`
+	Tags      []string      `json:"tags,omitempty"`
+	Size      int64         `json:"size"`
+	Layers    []LayerResult `json:"layers,omitempty"`
+}
+
+// LayerResult describes a single blob in a modelkit.
+type LayerResult struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+// NewModelKitResult builds a ModelKitResult from a manifest descriptor and, optionally,
+// the manifest it refers to. Size is the total size of the manifest, config, and layers.
+func NewModelKitResult(reference string, manifestDesc ocispec.Descriptor, manifest *ocispec.Manifest, tags []string) *ModelKitResult {
+	result := &ModelKitResult{
+		Reference: reference,
+		Digest:    manifestDesc.Digest.String(),
+		Tags:      tags,
+		Size:      manifestDesc.Size,
+	}
+	if manifest == nil {
+		return result
+	}
+	for _, blob := range append([]ocispec.Descriptor{manifest.Config}, manifest.Layers...) {
+		result.Size += blob.Size
+		result.Layers = append(result.Layers, LayerResult{
+			MediaType: blob.MediaType,
+			Digest:    blob.Digest.String(),
+			Size:      blob.Size,
+		})
+	}
+	return result
+}
@@ -0,0 +1,85 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestPrintResult(t *testing.T) {
+	type testResult struct {
+		Digest string   `json:"digest"`
+		Tags   []string `json:"tags"`
+	}
+	tests := []struct {
+		name      string
+		format    string
+		cmdErr    error
+		logErr    string
+		unmarshal func([]byte, any) error
+	}{
+		{name: "json success", format: "json", unmarshal: json.Unmarshal},
+		{name: "yaml success", format: "yaml", unmarshal: yaml.Unmarshal},
+		{name: "json error", format: "json", cmdErr: errors.New("failed to run"), logErr: "Failed to push", unmarshal: json.Unmarshal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdoutBuf, stderrBuf := &bytes.Buffer{}, &bytes.Buffer{}
+			SetOut(stdoutBuf)
+			SetErr(stderrBuf)
+			require.NoError(t, SetOutputFormat(tt.format))
+			t.Cleanup(func() {
+				SetOutputFormat("text")
+				commandResult, commandError = nil, ""
+			})
+
+			Infof("Informational log line")
+			SetResult(testResult{Digest: "sha256:abc", Tags: []string{"latest"}})
+			if tt.logErr != "" {
+				_ = Fatalf("%s", tt.logErr)
+			}
+			require.NoError(t, PrintResult("kit test", time.Now(), tt.cmdErr))
+
+			assert.Contains(t, stderrBuf.String(), "Informational log line", "logs should be written to stderr")
+			doc := map[string]any{}
+			require.NoError(t, tt.unmarshal(stdoutBuf.Bytes(), &doc), "stdout should contain only the result document")
+			assert.Equal(t, "kit test", doc["command"])
+			assert.Equal(t, tt.cmdErr == nil, doc["success"])
+			result, ok := doc["result"].(map[string]any)
+			require.True(t, ok)
+			assert.Equal(t, "sha256:abc", result["digest"])
+			if tt.logErr != "" {
+				assert.Equal(t, tt.logErr, doc["error"])
+			} else {
+				assert.NotContains(t, doc, "error")
+			}
+		})
+	}
+}
+
+func TestSetOutputFormatInvalid(t *testing.T) {
+	assert.Error(t, SetOutputFormat("xml"))
+	assert.False(t, StructuredOutput())
+}
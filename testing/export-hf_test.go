@@ -0,0 +1,276 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"kitops/pkg/lib/constants"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stubHFCommitServer implements the parts of the HuggingFace Hub API used to upload files: preupload,
+// the LFS batch API, and the commit API. Files with the .safetensors extension are uploaded using LFS.
+type stubHFCommitServer struct {
+	repo  string
+	token string
+
+	mu sync.Mutex
+	// uploads contains the contents of LFS objects uploaded, by oid
+	uploads map[string]string
+	// verified contains the oids of verified LFS objects
+	verified []string
+	// commitSummary is the summary (message) of the last commit
+	commitSummary string
+	// files contains the contents of files in the last commit, by path. LFS files are recorded as 'lfs:<oid>'
+	files map[string]string
+}
+
+func (s *stubHFCommitServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/upload/") && r.Method == http.MethodPut {
+		// Uploads go to storage rather than the Hub, so they are not authenticated with the HF token
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.uploads[strings.TrimPrefix(r.URL.Path, "/upload/")] = string(body)
+		s.mu.Unlock()
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+s.token {
+		writeStubError(w, http.StatusUnauthorized, "Invalid credentials in Authorization header")
+		return
+	}
+	switch r.URL.Path {
+	case fmt.Sprintf("/api/models/%s/preupload/main", s.repo):
+		var req struct {
+			Files []struct {
+				Path   string `json:"path"`
+				Sample string `json:"sample"`
+			} `json:"files"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeStubError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		var files []map[string]any
+		for _, f := range req.Files {
+			mode := "regular"
+			if strings.HasSuffix(f.Path, ".safetensors") {
+				mode = "lfs"
+			}
+			files = append(files, map[string]any{"path": f.Path, "uploadMode": mode, "shouldIgnore": false})
+		}
+		writeStubJSON(w, map[string]any{"files": files})
+	case fmt.Sprintf("/%s.git/info/lfs/objects/batch", s.repo):
+		var req struct {
+			Operation string `json:"operation"`
+			Objects   []struct {
+				OID  string `json:"oid"`
+				Size int64  `json:"size"`
+			} `json:"objects"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Operation != "upload" {
+			writeStubError(w, http.StatusBadRequest, "invalid batch request")
+			return
+		}
+		var objects []map[string]any
+		for _, obj := range req.Objects {
+			objects = append(objects, map[string]any{
+				"oid":  obj.OID,
+				"size": obj.Size,
+				"actions": map[string]any{
+					"upload": map[string]any{"href": fmt.Sprintf("http://%s/upload/%s", r.Host, obj.OID)},
+					"verify": map[string]any{"href": fmt.Sprintf("http://%s/verify", r.Host)},
+				},
+			})
+		}
+		w.Header().Set("Content-Type", "application/vnd.git-lfs+json")
+		writeStubJSON(w, map[string]any{"transfer": "basic", "objects": objects})
+	case "/verify":
+		var obj struct {
+			OID string `json:"oid"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&obj); err != nil {
+			writeStubError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.mu.Lock()
+		s.verified = append(s.verified, obj.OID)
+		s.mu.Unlock()
+		writeStubJSON(w, map[string]any{})
+	case fmt.Sprintf("/api/models/%s/commit/main", s.repo):
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.files = map[string]string{}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var op struct {
+				Key   string `json:"key"`
+				Value struct {
+					Summary string `json:"summary"`
+					Path    string `json:"path"`
+					Content string `json:"content"`
+					OID     string `json:"oid"`
+				} `json:"value"`
+			}
+			if err := json.Unmarshal(scanner.Bytes(), &op); err != nil {
+				writeStubError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			switch op.Key {
+			case "header":
+				s.commitSummary = op.Value.Summary
+			case "file":
+				content, err := base64.StdEncoding.DecodeString(op.Value.Content)
+				if err != nil {
+					writeStubError(w, http.StatusBadRequest, err.Error())
+					return
+				}
+				s.files[op.Value.Path] = string(content)
+			case "lfsFile":
+				s.files[op.Value.Path] = "lfs:" + op.Value.OID
+			}
+		}
+		writeStubJSON(w, map[string]any{"commitOid": "0123456789abcdef", "commitUrl": fmt.Sprintf("http://%s/%s/commit/0123456789abcdef", r.Host, s.repo)})
+	default:
+		writeStubError(w, http.StatusNotFound, "Repository not found")
+	}
+}
+
+func TestExportHF(t *testing.T) {
+	testPreflight(t)
+	tmpDir := setupTempDir(t)
+	modelKitPath, _, contextPath := setupTestDirs(t, tmpDir)
+	t.Setenv(constants.KitopsHomeEnvVar, contextPath)
+
+	weights := "testing: model weights"
+	readme := "---\npipeline_tag: text-generation\nlicense: mit\n---\n# Test model\n"
+	kitfile := `manifestVersion: 1.0.0
+package:
+  name: test-model
+  license: Apache-2.0
+model:
+  path: model.safetensors
+  framework: Transformers
+  parts:
+    - path: config.json
+  parameters:
+    base_model: org/base-model
+code:
+  - path: train.py
+datasets:
+  - name: org/test-dataset
+    path: data.csv
+docs:
+  - path: README.md
+  - path: LICENSE
+`
+	for file, content := range map[string]string{
+		constants.DefaultKitfileName: kitfile,
+		"model.safetensors":          weights,
+		"config.json":                `{"model_type":"test"}`,
+		"data.csv":                   "a,b\n1,2\n",
+		"README.md":                  readme,
+		"LICENSE":                    "Test license",
+		"train.py":                   "print('training')",
+	} {
+		if err := os.WriteFile(filepath.Join(modelKitPath, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runCommand(t, expectNoError, "pack", modelKitPath, "-t", modelKitTag)
+
+	exportDir := filepath.Join(tmpDir, "export")
+	runCommand(t, expectNoError, "export", modelKitTag, "--format", "hf", "-d", exportDir)
+
+	expectedReadme := `---
+pipeline_tag: text-generation
+license: mit
+tags:
+  - kitops
+  - transformers
+datasets:
+  - org/test-dataset
+base_model: org/base-model
+---
+# Test model
+`
+	actualReadme, err := os.ReadFile(filepath.Join(exportDir, "README.md"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, expectedReadme, string(actualReadme))
+	}
+	for _, file := range []string{constants.DefaultKitfileName, "model.safetensors", "config.json", "LICENSE"} {
+		assert.FileExists(t, filepath.Join(exportDir, file))
+	}
+	for _, file := range []string{"data.csv", "train.py"} {
+		assert.NoFileExists(t, filepath.Join(exportDir, file))
+	}
+
+	out := runCommand(t, expectError, "export", modelKitTag, "--format", "hf", "-d", exportDir)
+	assert.Contains(t, out, "already exists")
+
+	t.Run("pushes to HuggingFace", func(t *testing.T) {
+		server := &stubHFCommitServer{repo: "test-org/test-model", token: "test-token", uploads: map[string]string{}}
+		ts := httptest.NewServer(server)
+		defer ts.Close()
+		t.Setenv("HF_ENDPOINT", ts.URL)
+		t.Setenv("HF_TOKEN", "test-token")
+
+		runCommand(t, expectNoError, "export", modelKitTag, "--format", "hf", "-d", exportDir, "--overwrite", "--push-hf", "test-org/test-model")
+
+		weightsSum := sha256.Sum256([]byte(weights))
+		weightsOID := hex.EncodeToString(weightsSum[:])
+		assert.Equal(t, map[string]string{weightsOID: weights}, server.uploads)
+		assert.Equal(t, []string{weightsOID}, server.verified)
+		assert.Equal(t, "Upload ModelKit "+modelKitTag, server.commitSummary)
+		assert.Equal(t, map[string]string{
+			constants.DefaultKitfileName: kitfile,
+			"model.safetensors":          "lfs:" + weightsOID,
+			"config.json":                `{"model_type":"test"}`,
+			"README.md":                  expectedReadme,
+			"LICENSE":                    "Test license",
+		}, server.files)
+	})
+
+	t.Run("fails to push with invalid token", func(t *testing.T) {
+		server := &stubHFCommitServer{repo: "test-org/test-model", token: "test-token", uploads: map[string]string{}}
+		ts := httptest.NewServer(server)
+		defer ts.Close()
+		t.Setenv("HF_ENDPOINT", ts.URL)
+
+		out := runCommand(t, expectError, "export", modelKitTag, "--format", "hf", "-d", exportDir, "--overwrite", "--push-hf", "test-org/test-model", "--token", "invalid")
+		assert.Contains(t, out, "Invalid credentials")
+	})
+
+	t.Run("rejects push for other formats", func(t *testing.T) {
+		out := runCommand(t, expectError, "export", modelKitTag, "--format", "ollama", "-d", exportDir, "--push-hf", "test-org/test-model")
+		assert.Contains(t, out, "only supported when exporting with --format hf")
+	})
+}
@@ -0,0 +1,285 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"kitops/pkg/lib/constants"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stubLFSServer is a minimal Git LFS server implementing the batch API for a fixed set of objects, keyed by
+// sha256 digest.
+type stubLFSServer struct {
+	t       *testing.T
+	baseURL string
+	token   string
+	objects map[string]string
+	// downloaded records the oid of each object that was downloaded
+	downloaded []string
+	mu         sync.Mutex
+}
+
+func (s *stubLFSServer) handleBatch(w http.ResponseWriter, r *http.Request) {
+	if _, password, _ := r.BasicAuth(); password != s.token {
+		w.Header().Set("Content-Type", "application/vnd.git-lfs+json")
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(map[string]string{"message": "Invalid credentials"})
+		return
+	}
+	var req struct {
+		Operation string `json:"operation"`
+		Objects   []struct {
+			OID  string `json:"oid"`
+			Size int64  `json:"size"`
+		} `json:"objects"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Operation != "download" {
+		http.Error(w, "invalid batch request", http.StatusBadRequest)
+		return
+	}
+	var objects []map[string]any
+	for _, obj := range req.Objects {
+		entry := map[string]any{"oid": obj.OID, "size": obj.Size}
+		if _, ok := s.objects[obj.OID]; ok {
+			entry["actions"] = map[string]any{
+				"download": map[string]any{
+					"href":   s.baseURL + "/lfs-objects/" + obj.OID,
+					"header": map[string]string{"X-Download-Token": "download-" + s.token},
+				},
+			}
+		} else {
+			entry["error"] = map[string]any{"code": 404, "message": "Object does not exist"}
+		}
+		objects = append(objects, entry)
+	}
+	w.Header().Set("Content-Type", "application/vnd.git-lfs+json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"transfer": "basic", "objects": objects})
+}
+
+func (s *stubLFSServer) handleDownload(w http.ResponseWriter, r *http.Request) {
+	oid := strings.TrimPrefix(r.URL.Path, "/lfs-objects/")
+	content, ok := s.objects[oid]
+	if !ok || r.Header.Get("X-Download-Token") != "download-"+s.token {
+		http.NotFound(w, r)
+		return
+	}
+	s.mu.Lock()
+	s.downloaded = append(s.downloaded, oid)
+	s.mu.Unlock()
+	_, _ = w.Write([]byte(content))
+}
+
+// lfsPointer returns the contents of a Git LFS pointer file for content, along with its oid.
+func lfsPointer(content string) (pointer, oid string) {
+	sum := sha256.Sum256([]byte(content))
+	oid = hex.EncodeToString(sum[:])
+	return fmt.Sprintf("version https://git-lfs.github.com/spec/v1\noid sha256:%s\nsize %d\n", oid, len(content)), oid
+}
+
+// setupGitRepo creates a bare repository in root/repo.git with a commit for each entry in commits, tagging
+// each commit with the corresponding tag, and returns the SHA of each commit.
+func setupGitRepo(t *testing.T, root string, commits []map[string]string, tags []string) []string {
+	workDir := filepath.Join(root, "work")
+	gitCmd := func(dir string, args ...string) string {
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "init.defaultBranch=main"}, args...)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s failed: %s\n%s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	gitCmd(workDir, "init")
+	var shas []string
+	for idx, files := range commits {
+		for file, content := range files {
+			path := filepath.Join(workDir, file)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		gitCmd(workDir, "add", "-A")
+		gitCmd(workDir, "commit", "-m", fmt.Sprintf("commit %d", idx))
+		gitCmd(workDir, "tag", tags[idx])
+		shas = append(shas, gitCmd(workDir, "rev-parse", "HEAD"))
+	}
+	gitCmd(root, "clone", "--bare", workDir, "repo.git")
+	return shas
+}
+
+func TestImportFromGit(t *testing.T) {
+	testPreflight(t)
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git is required to serve test repository")
+	}
+
+	v1Model := "testing: model weights v1"
+	v2Model := "testing: model weights v2"
+	dataset := "testing: dataset"
+	v1Pointer, v1OID := lfsPointer(v1Model)
+	v2Pointer, v2OID := lfsPointer(v2Model)
+	datasetPointer, datasetOID := lfsPointer(dataset)
+
+	repoRoot := setupTempDir(t)
+	commitSHAs := setupGitRepo(t, repoRoot, []map[string]string{
+		{
+			".gitattributes":     "*.safetensors filter=lfs diff=lfs merge=lfs -text\n*.parquet filter=lfs diff=lfs merge=lfs -text\n",
+			"README.md":          "# Test model",
+			"config.json":        `{"testing": true}`,
+			"model.safetensors":  v1Pointer,
+			"data/train.parquet": datasetPointer,
+		},
+		{
+			"model.safetensors": v2Pointer,
+		},
+	}, []string{"v1", "v2"})
+
+	lfs := &stubLFSServer{t: t, token: "git-token", objects: map[string]string{v1OID: v1Model, v2OID: v2Model, datasetOID: dataset}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /repo.git/info/lfs/objects/batch", lfs.handleBatch)
+	mux.HandleFunc("GET /lfs-objects/", lfs.handleDownload)
+	mux.Handle("/repo.git/", &cgi.Handler{
+		Path: gitPath,
+		Args: []string{"http-backend"},
+		Env:  []string{"GIT_PROJECT_ROOT=" + repoRoot, "GIT_HTTP_EXPORT_ALL=1"},
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	lfs.baseURL = server.URL
+	repoURL := server.URL + "/repo.git"
+
+	tests := []struct {
+		name            string
+		args            []string
+		kitfile         string
+		expectCommit    string
+		expectFiles     map[string]string
+		expectNotInKit  []string
+		expectDownloads []string
+	}{
+		{
+			name:         "default branch with generated Kitfile",
+			expectCommit: commitSHAs[1],
+			expectFiles: map[string]string{
+				"README.md":          "# Test model",
+				"config.json":        `{"testing": true}`,
+				"model.safetensors":  v2Model,
+				"data/train.parquet": dataset,
+			},
+			expectDownloads: []string{v2OID, datasetOID},
+		},
+		{
+			name:         "tag with Kitfile selecting model only",
+			args:         []string{"--revision", "v1"},
+			kitfile:      "manifestVersion: 1.0.0\nmodel:\n  path: model.safetensors\ndocs:\n  - path: README.md\n",
+			expectCommit: commitSHAs[0],
+			expectFiles: map[string]string{
+				"README.md":         "# Test model",
+				"model.safetensors": v1Model,
+			},
+			expectNotInKit:  []string{"data/train.parquet", "config.json"},
+			expectDownloads: []string{v1OID},
+		},
+		{
+			name:         "commit SHA",
+			args:         []string{"--revision", commitSHAs[0]},
+			kitfile:      "manifestVersion: 1.0.0\nmodel:\n  path: model.safetensors\n",
+			expectCommit: commitSHAs[0],
+			expectFiles: map[string]string{
+				"model.safetensors": v1Model,
+			},
+			expectDownloads: []string{v1OID},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := setupTempDir(t)
+			_, unpackPath, contextPath := setupTestDirs(t, tmpDir)
+			t.Setenv(constants.KitopsHomeEnvVar, contextPath)
+			t.Setenv("HF_ENDPOINT", "")
+			lfs.downloaded = nil
+
+			args := append([]string{"import", repoURL, "--tag", modelKitTag, "--token", lfs.token}, tt.args...)
+			if tt.kitfile != "" {
+				kitfilePath := filepath.Join(tmpDir, constants.DefaultKitfileName)
+				if err := os.WriteFile(kitfilePath, []byte(tt.kitfile), 0644); err != nil {
+					t.Fatal(err)
+				}
+				args = append(args, "--file", kitfilePath)
+			}
+			runCommand(t, expectNoError, args...)
+			assert.ElementsMatch(t, tt.expectDownloads, lfs.downloaded, "Should only download LFS files included in Kitfile")
+
+			inspectOut := runCommand(t, expectNoError, "inspect", modelKitTag)
+			assert.Contains(t, inspectOut, tt.expectCommit, "Manifest should record the imported commit")
+			assert.Contains(t, inspectOut, repoURL, "Manifest should record the source repository")
+
+			runCommand(t, expectNoError, "unpack", modelKitTag, "-d", unpackPath)
+			for file, content := range tt.expectFiles {
+				actual, err := os.ReadFile(filepath.Join(unpackPath, file))
+				if assert.NoError(t, err) {
+					assert.Equal(t, content, string(actual))
+				}
+			}
+			for _, file := range tt.expectNotInKit {
+				assert.NoFileExists(t, filepath.Join(unpackPath, file))
+			}
+			assert.NoFileExists(t, filepath.Join(unpackPath, ".gitattributes"))
+		})
+	}
+
+	t.Run("fails with unknown revision", func(t *testing.T) {
+		tmpDir := setupTempDir(t)
+		_, _, contextPath := setupTestDirs(t, tmpDir)
+		t.Setenv(constants.KitopsHomeEnvVar, contextPath)
+
+		out := runCommand(t, expectError, "import", repoURL, "--tag", modelKitTag, "--revision", "does-not-exist")
+		assert.Contains(t, out, "does-not-exist not found in repository")
+	})
+
+	t.Run("fails with invalid LFS credentials", func(t *testing.T) {
+		tmpDir := setupTempDir(t)
+		_, _, contextPath := setupTestDirs(t, tmpDir)
+		t.Setenv(constants.KitopsHomeEnvVar, contextPath)
+
+		out := runCommand(t, expectError, "import", repoURL, "--tag", modelKitTag, "--token", "invalid-token")
+		assert.Contains(t, out, "Invalid credentials")
+	})
+}
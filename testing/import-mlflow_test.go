@@ -0,0 +1,152 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kitops/pkg/lib/constants"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const mlflowTestMLmodel = `artifact_path: model
+flavors:
+  python_function:
+    env:
+      conda: conda.yaml
+      virtualenv: python_env.yaml
+    loader_module: mlflow.sklearn
+    model_path: model.pkl
+    python_version: 3.10.12
+  sklearn:
+    pickled_model: model.pkl
+    serialization_format: cloudpickle
+    sklearn_version: 1.3.0
+mlflow_version: 2.9.2
+run_id: 3f2e1d0c9b8a7f6e5d4c3b2a1f0e9d8c
+saved_input_example_info:
+  artifact_path: input_example.json
+  type: dataframe
+signature:
+  inputs: '[{"type": "double", "name": "sepal_length", "required": true}]'
+  outputs: '[{"type": "tensor", "tensor-spec": {"dtype": "int64", "shape": [-1]}}]'
+`
+
+func TestImportFromMLflow(t *testing.T) {
+	testPreflight(t)
+
+	modelFiles := map[string]string{
+		"MLmodel":            mlflowTestMLmodel,
+		"model.pkl":          "testing: model weights",
+		"input_example.json": `{"columns": ["sepal_length"], "data": [[5.1]]}`,
+		"requirements.txt":   "mlflow==2.9.2\nscikit-learn==1.3.0\n",
+		"conda.yaml":         "name: mlflow-env\ndependencies:\n  - python=3.10.12\n",
+		"python_env.yaml":    "python: 3.10.12\n",
+	}
+
+	tests := []struct {
+		name      string
+		location  func(artifactsDir string) string
+		args      []string
+		expectTag string
+	}{
+		{
+			name:      "artifact directory with --tool",
+			location:  func(artifactsDir string) string { return artifactsDir },
+			args:      []string{"--tool", "mlflow", "--tag", modelKitTag},
+			expectTag: modelKitTag,
+		},
+		{
+			name: "model directory as file URI",
+			location: func(artifactsDir string) string {
+				return "file://" + filepath.ToSlash(filepath.Join(artifactsDir, "model"))
+			},
+			expectTag: "model:latest",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := setupTempDir(t)
+			_, unpackPath, contextPath := setupTestDirs(t, tmpDir)
+			t.Setenv(constants.KitopsHomeEnvVar, contextPath)
+
+			artifactsDir := filepath.Join(tmpDir, "mlruns", "0", "3f2e1d0c9b8a7f6e5d4c3b2a1f0e9d8c", "artifacts")
+			for file, content := range modelFiles {
+				path := filepath.Join(artifactsDir, "model", file)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			args := append([]string{"import", tt.location(artifactsDir)}, tt.args...)
+			runCommand(t, expectNoError, args...)
+			assert.NoFileExists(t, filepath.Join(artifactsDir, constants.DefaultKitfileName), "Import should not modify MLflow directory")
+
+			runCommand(t, expectNoError, "unpack", tt.expectTag, "-d", unpackPath)
+			kitfile, err := os.ReadFile(filepath.Join(unpackPath, constants.DefaultKitfileName))
+			if !assert.NoError(t, err) {
+				return
+			}
+			for _, expected := range []string{"framework: Scikit-learn", "sepal_length", "input_example.json", "flavor: sklearn", "requirements.txt", "conda.yaml"} {
+				assert.Contains(t, string(kitfile), expected)
+			}
+			modelDir := unpackPath
+			if tt.expectTag == modelKitTag {
+				modelDir = filepath.Join(unpackPath, "model")
+			}
+			for file, content := range modelFiles {
+				actual, err := os.ReadFile(filepath.Join(modelDir, file))
+				if assert.NoError(t, err) {
+					assert.Equal(t, content, string(actual))
+				}
+			}
+		})
+	}
+
+	t.Run("fails with unsupported artifact store", func(t *testing.T) {
+		tmpDir := setupTempDir(t)
+		_, _, contextPath := setupTestDirs(t, tmpDir)
+		t.Setenv(constants.KitopsHomeEnvVar, contextPath)
+
+		out := runCommand(t, expectError, "import", "runs:/3f2e1d0c9b8a/model", "--tool", "mlflow", "--tag", modelKitTag)
+		assert.Contains(t, out, "only local paths and file:// URIs are supported")
+	})
+
+	t.Run("fails with multiple models", func(t *testing.T) {
+		tmpDir := setupTempDir(t)
+		_, _, contextPath := setupTestDirs(t, tmpDir)
+		t.Setenv(constants.KitopsHomeEnvVar, contextPath)
+		artifactsDir := filepath.Join(tmpDir, "artifacts")
+		for _, dir := range []string{"model-a", "model-b"} {
+			if err := os.MkdirAll(filepath.Join(artifactsDir, dir), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(artifactsDir, dir, "MLmodel"), []byte(mlflowTestMLmodel), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		out := runCommand(t, expectError, "import", artifactsDir, "--tool", "mlflow", "--tag", modelKitTag)
+		assert.Contains(t, out, "multiple MLflow models in")
+	})
+}
@@ -0,0 +1,292 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"kitops/pkg/lib/constants"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const stubHubSHA = "0123456789abcdef0123456789abcdef01234567"
+
+// stubHub is a minimal HuggingFace Hub API that serves a single repository at a single commit.
+type stubHub struct {
+	t        *testing.T
+	token    string
+	apiPath  string
+	urlPath  string
+	files    map[string]string
+	cardData map[string]any
+	// rangeRequests records the Range header for each file download
+	rangeRequests []string
+	mu            sync.Mutex
+}
+
+func (h *stubHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.t.Logf("Stub hub request: %s %s", r.Method, r.URL.Path)
+	if r.Header.Get("Authorization") != "Bearer "+h.token {
+		writeStubError(w, http.StatusUnauthorized, "Invalid credentials in Authorization header")
+		return
+	}
+	switch {
+	case r.URL.Path == h.apiPath+"/revision/main":
+		writeStubJSON(w, map[string]any{"sha": stubHubSHA, "cardData": h.cardData})
+	case strings.HasPrefix(r.URL.Path, h.apiPath+"/tree/"+stubHubSHA):
+		dir := strings.Trim(strings.TrimPrefix(r.URL.Path, h.apiPath+"/tree/"+stubHubSHA), "/")
+		writeStubJSON(w, h.listDir(dir))
+	case strings.HasPrefix(r.URL.Path, h.urlPath+"/resolve/"+stubHubSHA+"/"):
+		file := strings.TrimPrefix(r.URL.Path, h.urlPath+"/resolve/"+stubHubSHA+"/")
+		content, ok := h.files[file]
+		if !ok {
+			writeStubError(w, http.StatusNotFound, "Entry not found")
+			return
+		}
+		h.mu.Lock()
+		h.rangeRequests = append(h.rangeRequests, r.Header.Get("Range"))
+		h.mu.Unlock()
+		http.ServeContent(w, r, file, time.Time{}, strings.NewReader(content))
+	default:
+		writeStubError(w, http.StatusNotFound, "Repository not found")
+	}
+}
+
+func (h *stubHub) listDir(dir string) []map[string]any {
+	var paths []string
+	for p := range h.files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var entries []map[string]any
+	seenDirs := map[string]bool{}
+	for _, p := range paths {
+		rel := p
+		if dir != "" {
+			if !strings.HasPrefix(p, dir+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(p, dir+"/")
+		}
+		if subdir, _, isNested := strings.Cut(rel, "/"); isNested {
+			subdirPath := path.Join(dir, subdir)
+			if !seenDirs[subdirPath] {
+				seenDirs[subdirPath] = true
+				entries = append(entries, map[string]any{"type": "directory", "path": subdirPath})
+			}
+			continue
+		}
+		content := h.files[p]
+		entry := map[string]any{"type": "file", "path": p, "size": len(content)}
+		if path.Ext(p) == ".safetensors" || path.Ext(p) == ".parquet" {
+			sha := sha256.Sum256([]byte(content))
+			entry["oid"] = "0000000000000000000000000000000000000000"
+			entry["lfs"] = map[string]any{"oid": hex.EncodeToString(sha[:]), "size": len(content)}
+		} else {
+			blobHash := sha1.New()
+			fmt.Fprintf(blobHash, "blob %d\x00%s", len(content), content)
+			entry["oid"] = hex.EncodeToString(blobHash.Sum(nil))
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func writeStubJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeStubError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+func TestImportFromHuggingFace(t *testing.T) {
+	testPreflight(t)
+
+	modelFiles := map[string]string{
+		"README.md":           "# Test model",
+		"model.safetensors":   "testing: model weights",
+		"config.json":         `{"testing": true}`,
+		"tokenizer/vocab.txt": "testing: vocab",
+	}
+	datasetFiles := map[string]string{
+		"README.md":                         "# Test dataset",
+		"data/train-00000-of-00001.parquet": "testing: train",
+		"data/test-00000-of-00001.parquet":  "testing: test",
+	}
+	datasetCard := map[string]any{
+		"license": "apache-2.0",
+		"configs": []any{map[string]any{"config_name": "default", "data_files": "data/*.parquet"}},
+	}
+
+	tests := []struct {
+		name        string
+		repo        string
+		hub         *stubHub
+		useEnv      bool
+		expectInKit []string
+	}{
+		{
+			name:        "model using HF_ENDPOINT and HF_TOKEN",
+			repo:        "testorg/testmodel",
+			hub:         &stubHub{token: "env-token", apiPath: "/api/models/testorg/testmodel", urlPath: "/testorg/testmodel", files: modelFiles},
+			useEnv:      true,
+			expectInKit: []string{"model.safetensors"},
+		},
+		{
+			name:        "model using config endpoint and saved token",
+			repo:        "testorg/testmodel",
+			hub:         &stubHub{token: "file-token", apiPath: "/api/models/testorg/testmodel", urlPath: "/testorg/testmodel", files: modelFiles},
+			useEnv:      false,
+			expectInKit: []string{"model.safetensors"},
+		},
+		{
+			name:        "dataset with card metadata",
+			repo:        "datasets/testorg/testdata",
+			hub:         &stubHub{token: "env-token", apiPath: "/api/datasets/testorg/testdata", urlPath: "/datasets/testorg/testdata", files: datasetFiles, cardData: datasetCard},
+			useEnv:      true,
+			expectInKit: []string{"path: data", "license: Apache-2.0", "config: default"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := setupTempDir(t)
+			_, unpackPath, contextPath := setupTestDirs(t, tmpDir)
+			t.Setenv(constants.KitopsHomeEnvVar, contextPath)
+
+			hub := tt.hub
+			hub.t = t
+			server := httptest.NewServer(hub)
+			t.Cleanup(server.Close)
+
+			hfHome := filepath.Join(tmpDir, "hf-home")
+			t.Setenv("HF_HOME", hfHome)
+			t.Setenv("HF_TOKEN_PATH", "")
+			if tt.useEnv {
+				t.Setenv("HF_ENDPOINT", server.URL)
+				t.Setenv("HF_TOKEN", hub.token)
+			} else {
+				t.Setenv("HF_ENDPOINT", "")
+				t.Setenv("HF_TOKEN", "")
+				config := fmt.Sprintf("huggingface:\n  endpoint: %s\n", server.URL)
+				if err := os.WriteFile(constants.ConfigPath(contextPath), []byte(config), 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.MkdirAll(hfHome, 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(hfHome, "token"), []byte(hub.token+"\n"), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			runCommand(t, expectNoError, "import", tt.repo, "--tag", modelKitTag)
+
+			inspectOut := runCommand(t, expectNoError, "inspect", modelKitTag)
+			assert.Contains(t, inspectOut, stubHubSHA, "Manifest should record the imported commit")
+			assert.Contains(t, inspectOut, server.URL, "Manifest should record the source repository")
+
+			runCommand(t, expectNoError, "unpack", modelKitTag, "-d", unpackPath)
+			var files []string
+			for file := range hub.files {
+				files = append(files, file)
+			}
+			checkFilesExist(t, unpackPath, files)
+
+			kitfile, err := os.ReadFile(filepath.Join(unpackPath, constants.DefaultKitfileName))
+			if !assert.NoError(t, err) {
+				return
+			}
+			for _, expected := range tt.expectInKit {
+				assert.Contains(t, string(kitfile), expected)
+			}
+		})
+	}
+
+	t.Run("resumes interrupted import", func(t *testing.T) {
+		tmpDir := setupTempDir(t)
+		_, unpackPath, contextPath := setupTestDirs(t, tmpDir)
+		t.Setenv(constants.KitopsHomeEnvVar, contextPath)
+		hub := stubHub{t: t, token: "env-token", apiPath: "/api/models/testorg/testmodel", urlPath: "/testorg/testmodel", files: modelFiles}
+		server := httptest.NewServer(&hub)
+		t.Cleanup(server.Close)
+		t.Setenv("HF_ENDPOINT", server.URL)
+		t.Setenv("HF_TOKEN", hub.token)
+
+		// Simulate a previous import that was interrupted partway through downloading the model and
+		// downloaded a corrupted copy of the readme.
+		importDir := filepath.Join(constants.CachePath(contextPath), "import", "hf_model_testorg_testmodel_"+stubHubSHA)
+		if err := os.MkdirAll(filepath.Join(importDir, "tokenizer"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(importDir, "tokenizer", "vocab.txt"), []byte(modelFiles["tokenizer/vocab.txt"]), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(importDir, "model.safetensors.partial"), []byte("testing: model"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(importDir, "README.md"), []byte("# Corrupted"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		runCommand(t, expectNoError, "import", "testorg/testmodel", "--tag", modelKitTag)
+		assert.Contains(t, hub.rangeRequests, fmt.Sprintf("bytes=%d-", len("testing: model")), "Should resume partial download")
+		assert.Len(t, hub.rangeRequests, 3, "Should download all files except verified vocab.txt")
+		assert.NoDirExists(t, importDir, "Import directory should be cleaned up after successful import")
+
+		runCommand(t, expectNoError, "unpack", modelKitTag, "-d", unpackPath)
+		for file, content := range modelFiles {
+			actual, err := os.ReadFile(filepath.Join(unpackPath, file))
+			if assert.NoError(t, err) {
+				assert.Equal(t, content, string(actual))
+			}
+		}
+	})
+
+	t.Run("fails with invalid token", func(t *testing.T) {
+		tmpDir := setupTempDir(t)
+		_, _, contextPath := setupTestDirs(t, tmpDir)
+		t.Setenv(constants.KitopsHomeEnvVar, contextPath)
+		hub := stubHub{t: t, token: "valid-token", apiPath: "/api/models/testorg/testmodel", urlPath: "/testorg/testmodel", files: modelFiles}
+		server := httptest.NewServer(&hub)
+		t.Cleanup(server.Close)
+		t.Setenv("HF_ENDPOINT", server.URL)
+		t.Setenv("HF_TOKEN", "")
+
+		out := runCommand(t, expectError, "import", "testorg/testmodel", "--tag", modelKitTag, "--token", "invalid-token")
+		assert.Contains(t, out, "Invalid credentials")
+	})
+}
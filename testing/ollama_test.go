@@ -0,0 +1,163 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kitops/pkg/lib/constants"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeOllamaModel writes an Ollama model with the specified layers (media type to contents) to the Ollama
+// models directory modelsDir.
+func writeOllamaModel(t *testing.T, modelsDir, name, tag string, layers [][2]string) {
+	writeBlob := func(mediaType, content string) map[string]any {
+		sum := sha256.Sum256([]byte(content))
+		digest := "sha256:" + hex.EncodeToString(sum[:])
+		blobPath := filepath.Join(modelsDir, "blobs", "sha256-"+hex.EncodeToString(sum[:]))
+		if err := os.MkdirAll(filepath.Dir(blobPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(blobPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return map[string]any{"mediaType": mediaType, "digest": digest, "size": len(content)}
+	}
+	config := `{"model_format":"gguf","model_family":"llama","model_type":"1.2B","file_type":"Q8_0"}`
+	manifest := map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.docker.distribution.manifest.v2+json",
+		"config":        writeBlob("application/vnd.docker.container.image.v1+json", config),
+	}
+	var manifestLayers []map[string]any
+	for _, layer := range layers {
+		manifestLayers = append(manifestLayers, writeBlob(layer[0], layer[1]))
+	}
+	manifest["layers"] = manifestLayers
+	manifestBytes, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifestPath := filepath.Join(modelsDir, "manifests", "registry.ollama.ai", "library", name, tag)
+	if err := os.MkdirAll(filepath.Dir(manifestPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(manifestPath, manifestBytes, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOllamaImportExport(t *testing.T) {
+	testPreflight(t)
+	tmpDir := setupTempDir(t)
+	_, unpackPath, contextPath := setupTestDirs(t, tmpDir)
+	t.Setenv(constants.KitopsHomeEnvVar, contextPath)
+	modelsDir := filepath.Join(tmpDir, "ollama-models")
+	t.Setenv("OLLAMA_MODELS", modelsDir)
+
+	weights := "GGUF testing: model weights"
+	template := "{{ if .System }}<|system|>{{ .System }}{{ end }}\n<|user|>{{ .Prompt }}<|assistant|>"
+	params := `{"stop":["<|user|>","<|assistant|>"],"temperature":0.6}`
+	system := "You are a test model."
+	license := "Test License\n\nPermission is granted for testing."
+	writeOllamaModel(t, modelsDir, "testmodel", "1b", [][2]string{
+		{"application/vnd.ollama.image.model", weights},
+		{"application/vnd.ollama.image.template", template},
+		{"application/vnd.ollama.image.license", license},
+		{"application/vnd.ollama.image.params", params},
+		{"application/vnd.ollama.image.system", system},
+	})
+
+	runCommand(t, expectNoError, "import", "testmodel:1b", "--tool", "ollama")
+
+	runCommand(t, expectNoError, "unpack", "testmodel:1b", "-d", unpackPath)
+	kitfile, err := os.ReadFile(filepath.Join(unpackPath, constants.DefaultKitfileName))
+	if assert.NoError(t, err) {
+		for _, expected := range []string{"path: model.gguf", "format: gguf", "type: template", "type: params", "type: system", "path: LICENSE", "quantization: Q8_0"} {
+			assert.Contains(t, string(kitfile), expected)
+		}
+	}
+	for file, content := range map[string]string{"model.gguf": weights, "template.tmpl": template, "params.json": params, "system.txt": system, "LICENSE": license} {
+		actual, err := os.ReadFile(filepath.Join(unpackPath, file))
+		if assert.NoError(t, err) {
+			assert.Equal(t, content, string(actual))
+		}
+	}
+
+	exportDir := filepath.Join(tmpDir, "export")
+	runCommand(t, expectNoError, "export", "testmodel:1b", "--format", "ollama", "-d", exportDir)
+
+	weightsSum := sha256.Sum256([]byte(weights))
+	blobName := "sha256-" + hex.EncodeToString(weightsSum[:])
+	blob, err := os.ReadFile(filepath.Join(exportDir, "blobs", blobName))
+	if assert.NoError(t, err) {
+		assert.Equal(t, weights, string(blob))
+	}
+	expectedModelfile := `FROM ./blobs/` + blobName + `
+TEMPLATE """{{ if .System }}<|system|>{{ .System }}{{ end }}
+<|user|>{{ .Prompt }}<|assistant|>"""
+SYSTEM "You are a test model."
+PARAMETER stop "<|user|>"
+PARAMETER stop "<|assistant|>"
+PARAMETER temperature 0.6
+LICENSE """Test License
+
+Permission is granted for testing."""
+`
+	modelfile, err := os.ReadFile(filepath.Join(exportDir, "Modelfile"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, expectedModelfile, string(modelfile))
+	}
+	entries, err := os.ReadDir(exportDir)
+	if assert.NoError(t, err) {
+		assert.Len(t, entries, 2, "Export directory should only contain Modelfile and blobs")
+	}
+
+	out := runCommand(t, expectError, "export", "testmodel:1b", "--format", "ollama", "-d", exportDir)
+	assert.Contains(t, out, "already exists")
+	runCommand(t, expectNoError, "export", "testmodel:1b", "--format", "ollama", "-d", exportDir, "--overwrite")
+
+	t.Run("fails for missing model", func(t *testing.T) {
+		out := runCommand(t, expectError, "import", "missing:latest", "--tool", "ollama")
+		assert.Contains(t, out, "not found in Ollama models directory")
+	})
+
+	t.Run("fails to export non-GGUF model", func(t *testing.T) {
+		modelKitPath := filepath.Join(tmpDir, "non-gguf")
+		if err := os.MkdirAll(modelKitPath, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(modelKitPath, "model.safetensors"), []byte("testing: safetensors"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		kitfile := "manifestVersion: 1.0.0\nmodel:\n  path: model.safetensors\n"
+		if err := os.WriteFile(filepath.Join(modelKitPath, constants.DefaultKitfileName), []byte(kitfile), 0644); err != nil {
+			t.Fatal(err)
+		}
+		runCommand(t, expectNoError, "pack", modelKitPath, "-t", modelKitTag)
+
+		out := runCommand(t, expectError, "export", modelKitTag, "--format", "ollama", "-d", filepath.Join(tmpDir, "non-gguf-export"))
+		assert.Contains(t, out, "only GGUF models can be exported to Ollama")
+	})
+}